@@ -0,0 +1,97 @@
+// Package analyze checks migration files for changes that are risky on a busy production database,
+// like adding a not null column without a default, or creating an index without concurrently,
+// so CI can catch them before they lock a table for minutes. Run it on the migrations with the built-in Rules,
+// and add your own by implementing Rule. Read the up migration files in the order they are applied, with their
+// included files, with migrate.AnalyzeFiles:
+//
+//	files, err := migrate.AnalyzeFiles(os.DirFS("sql/migrations"))
+//	if err != nil {
+//		return err
+//	}
+//	findings := analyze.Analyze(files, analyze.Rules()...)
+//
+// The analysis is textual, not a full SQL parser, and the built-in rules are written with Postgres in mind.
+package analyze
+
+import (
+	"maragu.dev/migrate/internal/sqlscan"
+)
+
+// Statement of a migration file, given to each Rule.
+type Statement struct {
+	// Name of the migration file.
+	Name string
+	// SQL of the statement, without comments, and with whitespace outside quotes collapsed to single spaces.
+	SQL string
+}
+
+// Rule checks the statements of the migration files, which Analyze passes to it one at a time, in order.
+// A Rule may keep state between statements, like to find references to dropped columns,
+// so the built-in rules are created by functions, for one analysis each.
+type Rule interface {
+	// Name of the rule in findings, like "not-null".
+	Name() string
+	// Check the statement, returning a message for each problem found.
+	Check(s Statement) []string
+}
+
+// NewRule with the given name and check function, for custom rules.
+func NewRule(name string, check func(s Statement) []string) Rule {
+	return funcRule{check: check, name: name}
+}
+
+type funcRule struct {
+	check func(s Statement) []string
+	name  string
+}
+
+func (r funcRule) Name() string {
+	return r.name
+}
+
+func (r funcRule) Check(s Statement) []string {
+	return r.check(s)
+}
+
+// Finding of a Rule in a statement.
+type Finding struct {
+	// Message about the problem.
+	Message string
+	// Name of the migration file.
+	Name string
+	// Rule that found the problem.
+	Rule string
+	// SQL of the statement.
+	SQL string
+}
+
+// String like "2-users.up.sql: not-null: adds column ...".
+func (f Finding) String() string {
+	return f.Name + ": " + f.Rule + ": " + f.Message
+}
+
+// File of a migration to analyze.
+type File struct {
+	// Name of the migration file.
+	Name string
+	// SQL of the migration.
+	SQL string
+}
+
+// Analyze the statements of the files with the rules, in the order of the files,
+// which should be the order the migrations are applied.
+func Analyze(files []File, rules ...Rule) []Finding {
+	var findings []Finding
+	for _, f := range files {
+		for _, statement := range sqlscan.Split(f.SQL) {
+			sql := sqlscan.Normalize(statement)
+			s := Statement{Name: f.Name, SQL: sql}
+			for _, r := range rules {
+				for _, message := range r.Check(s) {
+					findings = append(findings, Finding{Message: message, Name: f.Name, Rule: r.Name(), SQL: sql})
+				}
+			}
+		}
+	}
+	return findings
+}
@@ -0,0 +1,74 @@
+package analyze_test
+
+import (
+	"strings"
+	"testing"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate/analyze"
+)
+
+func TestAnalyze(t *testing.T) {
+	t.Run("finds nothing in safe migrations", func(t *testing.T) {
+		files := []analyze.File{
+			{Name: "1-accounts.up.sql", SQL: `create table accounts (id int, name text not null);
+create index accounts_name on accounts (name);
+alter table accounts add column created timestamp not null default now();`},
+			{Name: "2-users.up.sql", SQL: `-- Not null with a default is fine
+alter table accounts add column kind text not null default 'a; b';
+create index concurrently if not exists accounts_kind on accounts (kind);
+alter table accounts add column note text;`},
+		}
+
+		findings := analyze.Analyze(files, analyze.Rules()...)
+		is.Equal(t, 0, len(findings))
+	})
+
+	t.Run("finds risky statements with the built-in rules", func(t *testing.T) {
+		files := []analyze.File{
+			{Name: "1-accounts.up.sql", SQL: `create table accounts (id int, name text);
+create view account_names as select id, name from accounts;`},
+			{Name: "2-changes.up.sql", SQL: `alter table accounts add column kind text not null;
+alter table "accounts" alter column name set not null, alter column id type bigint;
+create index accounts_name on accounts (name);
+alter table accounts add column created timestamp default now();
+/* name is still in the view */
+alter table accounts drop column name;`},
+			{Name: "3-more.up.sql", SQL: `create function f() returns text as $$ select name from accounts; $$ language sql;`},
+		}
+
+		findings := analyze.Analyze(files, analyze.Rules()...)
+
+		var lines []string
+		for _, f := range findings {
+			lines = append(lines, f.String())
+		}
+		is.Equal(t, strings.Join([]string{
+			"2-changes.up.sql: not-null: adds column kind to accounts as not null without a default, which fails if the table has rows",
+			"2-changes.up.sql: not-null: sets column name of accounts to not null, which scans the table while holding an exclusive lock, " +
+				"unless a validated check constraint proves it",
+			"2-changes.up.sql: table-rewrite: changes the type of column id of accounts, which may rewrite the table while holding an exclusive lock",
+			"2-changes.up.sql: non-concurrent-index: creates an index on accounts without concurrently, which blocks writes to the table while it's built",
+			"2-changes.up.sql: table-rewrite: adds column created to accounts with a volatile default or stored generated value, " +
+				"which rewrites the table while holding an exclusive lock",
+			"2-changes.up.sql: dropped-column-referenced: drops column name of accounts, which 1-accounts.up.sql still references",
+			"3-more.up.sql: dropped-column-referenced: references column name of accounts, which 2-changes.up.sql drops",
+		}, "\n"), strings.Join(lines, "\n"))
+		is.Equal(t, "alter table accounts drop column name", findings[5].SQL)
+	})
+
+	t.Run("runs custom rules", func(t *testing.T) {
+		files := []analyze.File{{Name: "1-accounts.up.sql", SQL: "create table accounts (id int);\ntruncate accounts;"}}
+
+		rule := analyze.NewRule("no-truncate", func(s analyze.Statement) []string {
+			if strings.HasPrefix(s.SQL, "truncate") {
+				return []string{"truncates a table"}
+			}
+			return nil
+		})
+		findings := analyze.Analyze(files, rule)
+		is.Equal(t, 1, len(findings))
+		is.Equal(t, "1-accounts.up.sql: no-truncate: truncates a table", findings[0].String())
+	})
+}
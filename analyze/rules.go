@@ -0,0 +1,222 @@
+package analyze
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Rules returns new instances of all the built-in rules.
+func Rules() []Rule {
+	return []Rule{NotNull(), NonConcurrentIndex(), TableRewrite(), DroppedColumnReferenced()}
+}
+
+const identifier = "[\\w.\"`]+"
+
+var (
+	alterTableMatcher  = regexp.MustCompile("(?i)^alter table (?:if exists )?(?:only )?(" + identifier + ") (.*)$")
+	addColumnMatcher   = regexp.MustCompile("(?i)^add (?:column )?(?:if not exists )?(" + identifier + ") (.*)$")
+	alterColumnMatcher = regexp.MustCompile("(?i)^alter (?:column )?(" + identifier + ") (.*)$")
+	dropColumnMatcher  = regexp.MustCompile("(?i)^drop (?:column )?(?:if exists )?(" + identifier + ")")
+	createTableMatcher = regexp.MustCompile("(?i)^create (?:(?:global |local )?(?:temporary |temp |unlogged ))?table (?:if not exists )?(" + identifier + ")")
+	createIndexMatcher = regexp.MustCompile("(?i)^create (?:unique )?index (concurrently )?(?:if not exists )?(?:" + identifier + " )?on (?:only )?(" + identifier + ")")
+	referrerMatcher    = regexp.MustCompile("(?i)^create (?:or replace )?(?:materialized view|view|trigger|function|procedure|rule)\\b")
+	volatileMatcher    = regexp.MustCompile("(?i)\\b(?:now|clock_timestamp|statement_timestamp|random|gen_random_uuid|uuid_generate_v[14])\\s*\\(")
+)
+
+// NotNull finds not null columns added without a default, which fails if the table has rows,
+// and columns set to not null, which scans the whole table while holding an exclusive lock on it.
+func NotNull() Rule {
+	created := createdTables{}
+	return NewRule("not-null", func(s Statement) []string {
+		created.observe(s)
+		var messages []string
+		forEachAction(s, func(table, action string) {
+			if created.has(s, table) {
+				return
+			}
+			lower := strings.ToLower(action)
+			if match := addColumnMatcher.FindStringSubmatch(action); match != nil {
+				if strings.Contains(lower, " not null") && !strings.Contains(lower, " default ") &&
+					!strings.Contains(lower, " generated ") {
+					messages = append(messages, "adds column "+unquote(match[1])+" to "+table+
+						" as not null without a default, which fails if the table has rows")
+				}
+				return
+			}
+			if match := alterColumnMatcher.FindStringSubmatch(action); match != nil && strings.HasPrefix(strings.ToLower(match[2]), "set not null") {
+				messages = append(messages, "sets column "+unquote(match[1])+" of "+table+
+					" to not null, which scans the table while holding an exclusive lock, unless a validated check constraint proves it")
+			}
+		})
+		return messages
+	})
+}
+
+// NonConcurrentIndex finds indexes created without concurrently, which blocks writes to the table
+// while the index is built. Indexes on tables created in the same migration are fine.
+func NonConcurrentIndex() Rule {
+	created := createdTables{}
+	return NewRule("non-concurrent-index", func(s Statement) []string {
+		created.observe(s)
+		match := createIndexMatcher.FindStringSubmatch(s.SQL)
+		if match == nil || match[1] != "" || created.has(s, unquote(match[2])) {
+			return nil
+		}
+		return []string{"creates an index on " + unquote(match[2]) + " without concurrently, which blocks writes to the table while it's built"}
+	})
+}
+
+// TableRewrite finds statements that rewrite the whole table while holding an exclusive lock on it,
+// like changing the type of a column, adding a column with a volatile default like now(), and vacuum full.
+// Tables created in the same migration are fine.
+func TableRewrite() Rule {
+	created := createdTables{}
+	return NewRule("table-rewrite", func(s Statement) []string {
+		created.observe(s)
+		lower := strings.ToLower(s.SQL)
+		if strings.HasPrefix(lower, "vacuum full") || strings.HasPrefix(lower, "cluster ") {
+			return []string{"rewrites the table while holding an exclusive lock"}
+		}
+
+		var messages []string
+		forEachAction(s, func(table, action string) {
+			if created.has(s, table) {
+				return
+			}
+			if match := addColumnMatcher.FindStringSubmatch(action); match != nil {
+				if volatileMatcher.MatchString(match[2]) || strings.Contains(strings.ToLower(match[2]), " stored") {
+					messages = append(messages, "adds column "+unquote(match[1])+" to "+table+
+						" with a volatile default or stored generated value, which rewrites the table while holding an exclusive lock")
+				}
+				return
+			}
+			if match := alterColumnMatcher.FindStringSubmatch(action); match != nil {
+				rest := strings.ToLower(match[2])
+				if strings.HasPrefix(rest, "type ") || strings.HasPrefix(rest, "set data type ") {
+					messages = append(messages, "changes the type of column "+unquote(match[1])+" of "+table+
+						", which may rewrite the table while holding an exclusive lock")
+				}
+			}
+		})
+		return messages
+	})
+}
+
+// DroppedColumnReferenced finds dropped columns that views, triggers, functions, procedures, or rules
+// created by earlier migrations still reference, and statements of later migrations that reference dropped columns.
+// References are found by the table and column names appearing in the same statement.
+// Indexes are dropped along with their columns, so they aren't references.
+func DroppedColumnReferenced() Rule {
+	type reference struct{ name, sql string }
+	type column struct{ table, column, name string }
+	var referrers []reference
+	var dropped []column
+
+	return NewRule("dropped-column-referenced", func(s Statement) []string {
+		// Columns added again aren't dropped anymore
+		forEachAction(s, func(table, action string) {
+			match := addColumnMatcher.FindStringSubmatch(action)
+			if match == nil {
+				return
+			}
+			for i := len(dropped) - 1; i >= 0; i-- {
+				if strings.EqualFold(dropped[i].table, table) && strings.EqualFold(dropped[i].column, unquote(match[1])) {
+					dropped = append(dropped[:i], dropped[i+1:]...)
+				}
+			}
+		})
+
+		var messages []string
+		for _, d := range dropped {
+			if d.name != s.Name && mentions(s.SQL, d.table) && mentions(s.SQL, d.column) {
+				messages = append(messages, "references column "+d.column+" of "+d.table+", which "+d.name+" drops")
+			}
+		}
+
+		forEachAction(s, func(table, action string) {
+			match := dropColumnMatcher.FindStringSubmatch(action)
+			if match == nil || strings.HasPrefix(strings.ToLower(action), "drop constraint") {
+				return
+			}
+			name := unquote(match[1])
+			for _, r := range referrers {
+				if mentions(r.sql, table) && mentions(r.sql, name) {
+					messages = append(messages, "drops column "+name+" of "+table+", which "+r.name+" still references")
+				}
+			}
+			dropped = append(dropped, column{table: table, column: name, name: s.Name})
+		})
+
+		if referrerMatcher.MatchString(s.SQL) {
+			referrers = append(referrers, reference{name: s.Name, sql: s.SQL})
+		}
+		return messages
+	})
+}
+
+// createdTables by migration file name, because changes to tables created in the same migration are fine.
+type createdTables map[string]map[string]bool
+
+func (c createdTables) observe(s Statement) {
+	match := createTableMatcher.FindStringSubmatch(s.SQL)
+	if match == nil {
+		return
+	}
+	if c[s.Name] == nil {
+		c[s.Name] = map[string]bool{}
+	}
+	c[s.Name][strings.ToLower(unquote(match[1]))] = true
+}
+
+func (c createdTables) has(s Statement, table string) bool {
+	return c[s.Name][strings.ToLower(table)]
+}
+
+// forEachAction of an alter table statement, with the table name.
+func forEachAction(s Statement, fn func(table, action string)) {
+	match := alterTableMatcher.FindStringSubmatch(s.SQL)
+	if match == nil {
+		return
+	}
+	for _, action := range splitTopLevel(match[2]) {
+		fn(unquote(match[1]), action)
+	}
+}
+
+// splitTopLevel s on commas outside quotes and parentheses.
+func splitTopLevel(s string) []string {
+	var parts []string
+	depth := 0
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, strings.TrimSpace(s[start:]))
+}
+
+// mentions reports whether the SQL has the name as a word, ignoring case and quotes.
+// A schema-qualified name is matched by its last part.
+func mentions(sql, name string) bool {
+	name = name[strings.LastIndex(name, ".")+1:]
+	return regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(name) + `\b`).MatchString(unquote(sql))
+}
+
+func unquote(name string) string {
+	return strings.NewReplacer(`"`, "", "`", "").Replace(name)
+}
@@ -0,0 +1,136 @@
+package migrate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ArchiveDir is the subdirectory that Archive moves old migration files into.
+const ArchiveDir = "archive"
+
+var baselineMatcher = regexp.MustCompile(`^([\w-]+).baseline.sql$`)
+
+// Archive the migration files in dir up to and including the given version, by moving them into the archive
+// subdirectory, and writing a baseline file named like "0100-orders.baseline.sql" with their up migrations in order.
+// An existing baseline is archived too, and starts the new one. It returns the path of the baseline file.
+//
+// A fresh database gets the baseline instead of the archived migrations, and then the rest.
+// Databases at or after the version are unaffected, and migrating down stops at the baseline.
+// Databases before the version need the archived migrations first, like with Options.FS set to the archive subdirectory.
+// Review the baseline before committing it, because front matter in the archived files doesn't apply to it,
+// or replace its content with a schema dump of a database at the version.
+// Directories with a manifest can't be archived, see ManifestFileName.
+func Archive(dir, version string) (baselinePath string, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error archiving migrations: %w", err)
+		}
+	}()
+
+	fsys := os.DirFS(dir)
+	// The archived migrations would have to be in the order of the manifest, and leave it
+	if _, err := fs.Stat(fsys, ManifestFileName); err == nil {
+		return "", fmt.Errorf("%v orders the migrations, which archiving doesn't support", ManifestFileName)
+	}
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return "", err
+	}
+
+	var names []string
+	var baseline bytes.Buffer
+	baseline.WriteString("-- Baseline of the migrations up to and including " + version + ", archived in " + ArchiveDir + "/.\n")
+	found := false
+	for _, e := range entries {
+		name := e.Name()
+		if v := baselineMatcher.ReplaceAllString(name, "$1"); baselineMatcher.MatchString(name) {
+			if v > version {
+				return "", fmt.Errorf("baseline %v is after %v", name, version)
+			}
+			content, err := fs.ReadFile(fsys, name)
+			if err != nil {
+				return "", err
+			}
+			baseline.WriteString("\n-- " + name + "\n" + strings.TrimSpace(string(content)) + "\n")
+			names = append(names, name)
+			continue
+		}
+
+		v := upMatcher.ReplaceAllString(name, "$1")
+		isUp := upMatcher.MatchString(name)
+		if !isUp {
+			if !downMatcher.MatchString(name) {
+				continue
+			}
+			v = downMatcher.ReplaceAllString(name, "$1")
+		}
+		if v > version {
+			continue
+		}
+		found = found || v == version
+		names = append(names, name)
+
+		if !isUp {
+			continue
+		}
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return "", err
+		}
+		query := strings.TrimSpace(string(content))
+		if !strings.HasSuffix(query, ";") {
+			// The newline ends any comment on the last line of the migration
+			query += "\n;"
+		}
+		baseline.WriteString("\n-- " + name + "\n" + query + "\n")
+	}
+	if !found {
+		return "", errors.New("error finding version " + version)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, ArchiveDir), 0755); err != nil {
+		return "", err
+	}
+	for _, name := range names {
+		archivePath := filepath.Join(dir, ArchiveDir, name)
+		if _, err := os.Stat(archivePath); err == nil {
+			return "", fmt.Errorf("%v already exists", archivePath)
+		}
+	}
+
+	baselinePath = filepath.Join(dir, version+".baseline.sql")
+	if err := os.WriteFile(baselinePath, baseline.Bytes(), 0644); err != nil {
+		return "", err
+	}
+	for _, name := range names {
+		if name == version+".baseline.sql" {
+			continue
+		}
+		if err := os.Rename(filepath.Join(dir, name), filepath.Join(dir, ArchiveDir, name)); err != nil {
+			return "", err
+		}
+	}
+	return baselinePath, nil
+}
+
+// getBaseline file name and version, if there is one.
+func (m *Migrator) getBaseline() (name, version string, err error) {
+	names, err := m.getFilenames(baselineMatcher)
+	if err != nil {
+		return "", "", err
+	}
+	switch len(names) {
+	case 0:
+		return "", "", nil
+	case 1:
+		return names[0], baselineMatcher.ReplaceAllString(names[0], "$1"), nil
+	default:
+		return "", "", fmt.Errorf("error finding baseline: more than one baseline file, %v", strings.Join(names, ", "))
+	}
+}
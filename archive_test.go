@@ -0,0 +1,159 @@
+package migrate_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestArchive(t *testing.T) {
+	newDir := func(t *testing.T) string {
+		t.Helper()
+		dir := t.TempDir()
+		files := map[string]string{
+			"1-accounts.up.sql":   "create table accounts (id int);",
+			"1-accounts.down.sql": "drop table accounts;",
+			"2-users.up.sql":      "create table users (id int) -- no semicolon",
+			"2-users.down.sql":    "drop table users;",
+			"3-posts.up.sql":      "create table posts (id int);",
+			"3-posts.down.sql":    "drop table posts;",
+		}
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dir
+	}
+
+	t.Run("moves old migrations into the archive and writes a baseline", func(t *testing.T) {
+		dir := newDir(t)
+
+		baselinePath, err := migrate.Archive(dir, "2-users")
+		is.NotError(t, err)
+		is.Equal(t, filepath.Join(dir, "2-users.baseline.sql"), baselinePath)
+
+		baseline, err := os.ReadFile(baselinePath)
+		is.NotError(t, err)
+		is.Equal(t, "-- Baseline of the migrations up to and including 2-users, archived in archive/.\n"+
+			"\n-- 1-accounts.up.sql\ncreate table accounts (id int);\n"+
+			"\n-- 2-users.up.sql\ncreate table users (id int) -- no semicolon\n;\n", string(baseline))
+
+		entries, err := os.ReadDir(filepath.Join(dir, "archive"))
+		is.NotError(t, err)
+		is.Equal(t, 4, len(entries))
+
+		_, err = os.Stat(filepath.Join(dir, "3-posts.up.sql"))
+		is.NotError(t, err)
+	})
+
+	t.Run("archives an existing baseline into the new one", func(t *testing.T) {
+		dir := newDir(t)
+
+		_, err := migrate.Archive(dir, "1-accounts")
+		is.NotError(t, err)
+		_, err = migrate.Archive(dir, "2-users")
+		is.NotError(t, err)
+
+		_, err = os.Stat(filepath.Join(dir, "archive", "1-accounts.baseline.sql"))
+		is.NotError(t, err)
+		_, err = os.Stat(filepath.Join(dir, "1-accounts.baseline.sql"))
+		is.True(t, os.IsNotExist(err))
+
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, FS: os.DirFS(dir)})
+		err = m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "3-posts", getVersion(t, db))
+	})
+
+	t.Run("errors if the version doesn't exist", func(t *testing.T) {
+		dir := newDir(t)
+
+		_, err := migrate.Archive(dir, "4-comments")
+		is.Equal(t, "error archiving migrations: error finding version 4-comments", err.Error())
+	})
+}
+
+func TestMigrator_baseline(t *testing.T) {
+	newDir := func(t *testing.T) string {
+		t.Helper()
+		dir := t.TempDir()
+		files := map[string]string{
+			"1-accounts.up.sql":   "create table accounts (id int);",
+			"1-accounts.down.sql": "drop table accounts;",
+			"2-users.up.sql":      "create table users (id int);",
+			"2-users.down.sql":    "drop table users;",
+		}
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dir
+	}
+
+	t.Run("migrates a fresh database with the baseline and then the rest", func(t *testing.T) {
+		dir := newDir(t)
+		_, err := migrate.Archive(dir, "1-accounts")
+		is.NotError(t, err)
+
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, FS: os.DirFS(dir), MissingDown: migrate.CheckError})
+		err = m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "2-users", getVersion(t, db))
+
+		_, err = db.Exec(`select id from accounts`)
+		is.NotError(t, err)
+	})
+
+	t.Run("migrates down to the baseline", func(t *testing.T) {
+		dir := newDir(t)
+		_, err := migrate.Archive(dir, "1-accounts")
+		is.NotError(t, err)
+
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, FS: os.DirFS(dir)})
+		err = m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		err = m.MigrateDown(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "1-accounts", getVersion(t, db))
+	})
+
+	t.Run("leaves databases after the baseline unaffected", func(t *testing.T) {
+		dir := newDir(t)
+
+		db := createSQLiteDatabase(t)
+		err := migrate.New(migrate.Options{DB: db, FS: os.DirFS(dir)}).MigrateTo(context.Background(), "1-accounts")
+		is.NotError(t, err)
+
+		_, err = migrate.Archive(dir, "1-accounts")
+		is.NotError(t, err)
+
+		err = migrate.New(migrate.Options{DB: db, FS: os.DirFS(dir)}).MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "2-users", getVersion(t, db))
+	})
+
+	t.Run("errors on databases before the baseline", func(t *testing.T) {
+		dir := newDir(t)
+		_, err := migrate.Archive(dir, "2-users")
+		is.NotError(t, err)
+
+		db := createSQLiteDatabase(t)
+		err = migrate.New(migrate.Options{DB: db, FS: os.DirFS(filepath.Join(dir, "archive"))}).MigrateTo(context.Background(), "1-accounts")
+		is.NotError(t, err)
+
+		err = migrate.New(migrate.Options{DB: db, FS: os.DirFS(dir)}).MigrateUp(context.Background())
+		is.Equal(t, "error migrating up: current version 1-accounts is before the baseline 2-users.baseline.sql, "+
+			"so it needs the archived migrations first", err.Error())
+	})
+}
@@ -0,0 +1,36 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// auditTable name.
+func (m *Migrator) auditTable() string {
+	return m.table + "_audit"
+}
+
+// recordAudit of a run that started at the given time.
+func (m *Migrator) recordAudit(ctx context.Context, s Summary, startedAt time.Time) error {
+	outcome := "success"
+	if s.Err != nil {
+		outcome = "error: " + s.Err.Error()
+	}
+
+	d := m.dialect
+	query := `insert into ` + d.Quote(m.auditTable()) +
+		` (operation, actor, started_at, finished_at, from_version, to_version, outcome) values (` +
+		quoteString(d, s.Operation) + `, ` +
+		quoteString(d, m.actor) + `, ` +
+		quoteString(d, startedAt.UTC().Format(historyTimeLayout)) + `, ` +
+		quoteString(d, time.Now().UTC().Format(historyTimeLayout)) + `, ` +
+		quoteString(d, s.FromVersion) + `, ` +
+		quoteString(d, s.ToVersion) + `, ` +
+		quoteString(d, outcome) + `)`
+
+	if _, err := m.conn.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("error recording audit: %w", err)
+	}
+	return nil
+}
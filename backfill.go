@@ -0,0 +1,296 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Backfill of data in batches, like filling a new column of a large table, which runs in the background
+// after the migration it belongs to instead of in it, so the migration doesn't hold locks for long.
+// Each batch usually selects the next rows after the checkpoint with a limit, and updates them:
+//
+//	migrate.Backfill{
+//		After: "2-accounts_email_lower",
+//		Name:  "accounts email_lower",
+//		Batch: func(ctx context.Context, tx *sql.Tx, checkpoint string) (migrate.BackfillBatch, error) {
+//			after, _ := strconv.ParseInt(checkpoint, 10, 64)
+//			var last sql.NullInt64
+//			if err := tx.QueryRowContext(ctx, `select max(id) from (select id from accounts where id > $1 order by id limit 1000) b`,
+//				after).Scan(&last); err != nil || !last.Valid {
+//				return migrate.BackfillBatch{Checkpoint: checkpoint, Done: err == nil}, err
+//			}
+//			res, err := tx.ExecContext(ctx, `update accounts set email_lower = lower(email) where id > $1 and id <= $2`,
+//				after, last.Int64)
+//			if err != nil {
+//				return migrate.BackfillBatch{}, err
+//			}
+//			rows, _ := res.RowsAffected()
+//			return migrate.BackfillBatch{Checkpoint: strconv.FormatInt(last.Int64, 10), Rows: rows}, nil
+//		},
+//	}
+//
+// The checkpoint of each backfill is saved in a table named like Options.Table with the suffix "_backfills".
+type Backfill struct {
+	// After is the version of the migration the backfill belongs to. The backfill runs once it's applied.
+	After string
+	// Batch of the backfill after the checkpoint, which is empty for the first batch.
+	// It runs in a transaction with saving the returned checkpoint, so an interrupted backfill resumes
+	// after the last committed batch.
+	Batch func(ctx context.Context, tx *sql.Tx, checkpoint string) (BackfillBatch, error)
+	// Delay between batches, to limit the load on the database.
+	Delay time.Duration
+	// MaxRowsPerSecond processed, from the rows of each BackfillBatch, to limit the load on the database.
+	// After each batch, the backfill pauses for as long as needed to keep to it, or for Delay if that's longer.
+	// Zero means no limit.
+	MaxRowsPerSecond int
+	// Name of the backfill, which identifies its checkpoint. Must be unique.
+	Name string
+}
+
+// BackfillBatch is the result of a Backfill.Batch.
+type BackfillBatch struct {
+	// Checkpoint to continue the next batch after.
+	Checkpoint string
+	// Done is whether the backfill is finished.
+	Done bool
+	// Rows processed in the batch, for progress.
+	Rows int64
+}
+
+// BackfillStatus is the progress of a Backfill. See MigrationStatus.Backfills.
+type BackfillStatus struct {
+	// Checkpoint of the last committed batch.
+	Checkpoint string
+	// Done is whether the backfill is finished.
+	Done bool
+	// FinishedAt is when the backfill finished, or the zero time if it's not done.
+	FinishedAt time.Time
+	Name       string
+	// Rows processed in all batches so far.
+	Rows int64
+	// StartedAt is when the first batch started, or the zero time if the backfill hasn't started.
+	StartedAt time.Time
+}
+
+// backfillTable name.
+func (m *Migrator) backfillTable() string {
+	return m.table + "_backfills"
+}
+
+// RunBackfills in Options.Backfills whose migrations are applied, one after the other, batch by batch,
+// until they're done or ctx is cancelled. Run it in a goroutine after migrating up.
+// It holds a lock from the Dialect other than the migration lock, so with several instances,
+// only one runs the backfills. If the Dialect is a TryLocker, the others return right away instead of waiting.
+func (m *Migrator) RunBackfills(ctx context.Context) (err error) {
+	ctx, span := m.tracer.Start(ctx, "migrate run backfills")
+	span.SetAttribute("migrate.table", m.table)
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error running backfills: %w", err)
+		}
+		span.End(err)
+	}()
+
+	if len(m.backfills) == 0 {
+		return nil
+	}
+
+	currentVersion, err := m.currentVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting connection: %w", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	if tl, ok := m.dialect.(TryLocker); ok {
+		locked, err := tl.TryLock(ctx, conn, m.backfillTable())
+		if err != nil {
+			return fmt.Errorf("error locking backfills: %w", err)
+		}
+		if !locked {
+			return nil
+		}
+	} else if err := m.dialect.Lock(ctx, conn, m.backfillTable()); err != nil {
+		return fmt.Errorf("error locking backfills: %w", err)
+	}
+	defer func() {
+		if unlockErr := m.dialect.Unlock(context.Background(), conn, m.backfillTable()); unlockErr != nil && err == nil {
+			err = fmt.Errorf("error unlocking backfills: %w", unlockErr)
+		}
+	}()
+
+	statuses, err := m.getBackfillStatuses(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	order, err := m.getVersionOrder()
+	if err != nil {
+		return err
+	}
+
+	for _, b := range m.backfills {
+		if order.compare(b.After, currentVersion) > 0 || statuses[b.Name].Done {
+			continue
+		}
+		status, ok := statuses[b.Name]
+		if !ok {
+			status = BackfillStatus{Name: b.Name, StartedAt: time.Now()}
+			if err := m.insertBackfill(ctx, conn, status); err != nil {
+				return err
+			}
+		}
+		if err := m.runBackfill(ctx, conn, b, status); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runBackfill batch by batch, from the checkpoint of its status.
+func (m *Migrator) runBackfill(ctx context.Context, conn *sql.Conn, b Backfill, status BackfillStatus) error {
+	ctx, span := m.tracer.Start(ctx, "migrate backfill")
+	span.SetAttribute("migrate.backfill", b.Name)
+	var err error
+	defer func() {
+		span.End(err)
+	}()
+
+	for !status.Done {
+		if err = ctx.Err(); err != nil {
+			return fmt.Errorf("error running backfill %v: %w", b.Name, err)
+		}
+
+		start, rows := time.Now(), status.Rows
+		if err = m.runBackfillBatch(ctx, conn, b, &status); err != nil {
+			return fmt.Errorf("error running backfill %v after checkpoint %q: %w", b.Name, status.Checkpoint, err)
+		}
+
+		if !status.Done {
+			if err = pauseAfterBatch(ctx, b.Delay, b.MaxRowsPerSecond, status.Rows-rows, time.Since(start)); err != nil {
+				return fmt.Errorf("error running backfill %v: %w", b.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// runBackfillBatch in a transaction with saving the checkpoint, updating the status after the commit.
+func (m *Migrator) runBackfillBatch(ctx context.Context, conn *sql.Conn, b Backfill, status *BackfillStatus) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	batch, err := b.Batch(ctx, tx, status.Checkpoint)
+	if err != nil {
+		return err
+	}
+
+	next := *status
+	next.Checkpoint = batch.Checkpoint
+	next.Done = batch.Done
+	next.Rows += batch.Rows
+	if batch.Done {
+		next.FinishedAt = time.Now()
+	}
+
+	d := m.dialect
+	finishedAt := "null"
+	if next.Done {
+		finishedAt = quoteString(d, next.FinishedAt.UTC().Format(historyTimeLayout))
+	}
+	query := `update ` + d.Quote(m.backfillTable()) + ` set checkpoint = ` + quoteString(d, next.Checkpoint) +
+		`, rows_done = ` + strconv.FormatInt(next.Rows, 10) + `, finished_at = ` + finishedAt +
+		` where name = ` + quoteString(d, b.Name)
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("error saving checkpoint: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	*status = next
+	return nil
+}
+
+// insertBackfill status, before its first batch.
+func (m *Migrator) insertBackfill(ctx context.Context, q queryer, status BackfillStatus) error {
+	d := m.dialect
+	query := `insert into ` + d.Quote(m.backfillTable()) + ` (name, checkpoint, rows_done, started_at) values (` +
+		quoteString(d, status.Name) + `, '', 0, ` + quoteString(d, status.StartedAt.UTC().Format(historyTimeLayout)) + `)`
+	if _, err := q.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("error starting backfill %v: %w", status.Name, err)
+	}
+	return nil
+}
+
+// getBackfillStatuses from the backfill table, by name.
+func (m *Migrator) getBackfillStatuses(ctx context.Context, q queryer) (map[string]BackfillStatus, error) {
+	rows, err := q.QueryContext(ctx, `select name, checkpoint, rows_done, started_at, finished_at from `+
+		m.dialect.Quote(m.backfillTable()))
+	if err != nil {
+		return nil, fmt.Errorf("error getting backfills: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	statuses := map[string]BackfillStatus{}
+	for rows.Next() {
+		var s BackfillStatus
+		var startedAt string
+		var finishedAt sql.NullString
+		if err := rows.Scan(&s.Name, &s.Checkpoint, &s.Rows, &startedAt, &finishedAt); err != nil {
+			return nil, fmt.Errorf("error scanning backfill: %w", err)
+		}
+		if s.StartedAt, err = time.Parse(historyTimeLayout, startedAt); err != nil {
+			return nil, fmt.Errorf("error parsing started_at %v of backfill %v: %w", startedAt, s.Name, err)
+		}
+		if finishedAt.Valid {
+			if s.FinishedAt, err = time.Parse(historyTimeLayout, finishedAt.String); err != nil {
+				return nil, fmt.Errorf("error parsing finished_at %v of backfill %v: %w", finishedAt.String, s.Name, err)
+			}
+			s.Done = true
+		}
+		statuses[s.Name] = s
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error getting backfills: %w", err)
+	}
+	return statuses, nil
+}
+
+// backfillStatusesAfter each version, for Status, with the backfills not started yet too.
+func (m *Migrator) backfillStatusesAfter(ctx context.Context) (map[string][]BackfillStatus, error) {
+	if len(m.backfills) == 0 {
+		return nil, nil
+	}
+
+	statuses, err := m.getBackfillStatuses(ctx, m.conn)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[string][]BackfillStatus{}
+	for _, b := range m.backfills {
+		s, ok := statuses[b.Name]
+		if !ok {
+			s = BackfillStatus{Name: b.Name}
+		}
+		byVersion[b.After] = append(byVersion[b.After], s)
+	}
+	return byVersion, nil
+}
@@ -0,0 +1,183 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestMigrator_RunBackfills(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1.up.sql": {Data: []byte("create table numbers (id integer primary key, n integer);\n" +
+			"with recursive i(v) as (select 1 union all select v + 1 from i where v < 25) insert into numbers (id) select v from i")},
+		"2.up.sql": {Data: []byte("alter table numbers add column double integer")},
+	}
+
+	// newBackfill doubling numbers, ten at a time, recording the checkpoints of its batches.
+	newBackfill := func(checkpoints *[]string, fail func(checkpoint string) bool) migrate.Backfill {
+		return migrate.Backfill{
+			After: "2",
+			Name:  "double",
+			Batch: func(ctx context.Context, tx *sql.Tx, checkpoint string) (migrate.BackfillBatch, error) {
+				*checkpoints = append(*checkpoints, checkpoint)
+				if fail != nil && fail(checkpoint) {
+					return migrate.BackfillBatch{}, errors.New("oh no")
+				}
+				after, _ := strconv.Atoi(checkpoint)
+				var last sql.NullInt64
+				if err := tx.QueryRowContext(ctx, `select max(id) from (select id from numbers where id > ? order by id limit 10)`, after).Scan(&last); err != nil {
+					return migrate.BackfillBatch{}, err
+				}
+				if !last.Valid {
+					return migrate.BackfillBatch{Checkpoint: checkpoint, Done: true}, nil
+				}
+				res, err := tx.ExecContext(ctx, `update numbers set double = id * 2 where id > ? and id <= ?`, after, last.Int64)
+				if err != nil {
+					return migrate.BackfillBatch{}, err
+				}
+				rows, _ := res.RowsAffected()
+				return migrate.BackfillBatch{Checkpoint: strconv.FormatInt(last.Int64, 10), Rows: rows}, nil
+			},
+		}
+	}
+
+	t.Run("runs backfills of applied migrations in batches and reports progress in the status", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		var checkpoints []string
+		m := migrate.New(migrate.Options{
+			Backfills:   []migrate.Backfill{newBackfill(&checkpoints, nil)},
+			DB:          db,
+			Dialect:     migrate.SQLite,
+			FS:          fsys,
+			MissingDown: migrate.CheckIgnore,
+		})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		statuses, err := m.Status(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, 0, len(statuses[0].Backfills))
+		is.Equal(t, 1, len(statuses[1].Backfills))
+		is.Equal(t, "double", statuses[1].Backfills[0].Name)
+		is.True(t, statuses[1].Backfills[0].StartedAt.IsZero())
+
+		err = m.RunBackfills(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "[ 10 20 25]", fmt.Sprint(checkpoints))
+
+		var count int
+		err = db.QueryRow(`select count(*) from numbers where double = id * 2`).Scan(&count)
+		is.NotError(t, err)
+		is.Equal(t, 25, count)
+
+		statuses, err = m.Status(context.Background())
+		is.NotError(t, err)
+		s := statuses[1].Backfills[0]
+		is.True(t, s.Done)
+		is.Equal(t, "25", s.Checkpoint)
+		is.Equal(t, int64(25), s.Rows)
+		is.True(t, !s.StartedAt.IsZero())
+		is.True(t, !s.FinishedAt.Before(s.StartedAt))
+
+		err = m.RunBackfills(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, 4, len(checkpoints))
+	})
+
+	t.Run("resumes after the last committed batch", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		var checkpoints []string
+		failed := false
+		m := migrate.New(migrate.Options{
+			Backfills: []migrate.Backfill{newBackfill(&checkpoints, func(checkpoint string) bool {
+				if checkpoint == "20" && !failed {
+					failed = true
+					return true
+				}
+				return false
+			})},
+			DB:          db,
+			Dialect:     migrate.SQLite,
+			FS:          fsys,
+			MissingDown: migrate.CheckIgnore,
+		})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		err = m.RunBackfills(context.Background())
+		is.Equal(t, `error running backfills: error running backfill double after checkpoint "20": oh no`, err.Error())
+
+		statuses, err := m.Status(context.Background())
+		is.NotError(t, err)
+		is.True(t, !statuses[1].Backfills[0].Done)
+		is.Equal(t, int64(20), statuses[1].Backfills[0].Rows)
+
+		err = m.RunBackfills(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "[ 10 20 20 25]", fmt.Sprint(checkpoints))
+	})
+
+	t.Run("pauses between batches to keep to the max rows per second", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		var checkpoints []string
+		b := newBackfill(&checkpoints, nil)
+		b.MaxRowsPerSecond = 500
+		m := migrate.New(migrate.Options{
+			Backfills:   []migrate.Backfill{b},
+			DB:          db,
+			Dialect:     migrate.SQLite,
+			FS:          fsys,
+			MissingDown: migrate.CheckIgnore,
+		})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		start := time.Now()
+		err = m.RunBackfills(context.Background())
+		is.NotError(t, err)
+		// Batches of 10, 10, and 5 rows at 500 rows per second take at least 20, 20, and 10 milliseconds
+		is.True(t, time.Since(start) >= 50*time.Millisecond)
+	})
+
+	t.Run("does not run backfills of migrations that are not applied", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		var checkpoints []string
+		m := migrate.New(migrate.Options{
+			Backfills:   []migrate.Backfill{newBackfill(&checkpoints, nil)},
+			DB:          db,
+			Dialect:     migrate.SQLite,
+			FS:          fsys,
+			MissingDown: migrate.CheckIgnore,
+		})
+		err := m.MigrateTo(context.Background(), "1")
+		is.NotError(t, err)
+
+		err = m.RunBackfills(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, 0, len(checkpoints))
+	})
+
+	t.Run("panics if the dialect can't create the backfill table", func(t *testing.T) {
+		defer func() {
+			err := recover()
+			is.True(t, err != nil)
+			is.Equal(t, "backfills need a Dialect that is a BackfillTableCreator", err.(string))
+		}()
+		var checkpoints []string
+		migrate.New(migrate.Options{Backfills: []migrate.Backfill{newBackfill(&checkpoints, nil)}, DB: &sql.DB{},
+			Dialect: testDialect{Dialect: migrate.SQLite}, FS: fsys})
+	})
+}
@@ -0,0 +1,212 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"maragu.dev/migrate/internal/sqlscan"
+)
+
+// batchDirective marks an update or delete statement in a migration file to run in batches of rows,
+// ordered by a unique column, which defaults to id, with a size which defaults to 1000 rows:
+//
+//	-- migrate:batch column=id size=10000
+//	update accounts set email_lower = lower(email) where email_lower is null;
+//
+// Each batch runs the statement on its own for the next range of the column, added to its where clause,
+// so it only locks the rows of the batch, and replicas can keep up. The ranges cover the whole table,
+// even if the statement only changes some rows. Like the copy directive, it's only recognized with
+// Options.SplitStatements, and the migration must run without a transaction, with no-transaction in its front matter,
+// so each batch is committed on its own. The statement must not end with order by, limit, or returning.
+//
+// To not starve other traffic on a shared database, delay pauses between batches, like delay=100ms,
+// and rate limits the rows affected per second, like rate=5000, pausing after each batch as long as needed.
+const batchDirective = "-- migrate:batch"
+
+// batchOptions from a batch directive.
+type batchOptions struct {
+	column string
+	delay  time.Duration
+	rate   int
+	size   int
+}
+
+// parseBatchDirective of the statement, if it has one.
+func parseBatchDirective(statement string) (batchOptions, bool, error) {
+	for _, line := range strings.Split(statement, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "--") {
+			return batchOptions{}, false, nil
+		}
+		args := strings.TrimPrefix(line, batchDirective)
+		if args == line || (args != "" && args[0] != ' ') {
+			continue
+		}
+
+		opts := batchOptions{column: "id", size: 1000}
+		for _, arg := range strings.Fields(args) {
+			key, value, _ := strings.Cut(arg, "=")
+			switch key {
+			case "column":
+				if !columnMatcher.MatchString(value) {
+					return batchOptions{}, false, fmt.Errorf("invalid batch column %q, must match %v", value, columnMatcher)
+				}
+				opts.column = value
+			case "delay":
+				delay, err := time.ParseDuration(value)
+				if err != nil || delay < 0 {
+					return batchOptions{}, false, fmt.Errorf("invalid batch delay %q, must be a duration like 100ms", value)
+				}
+				opts.delay = delay
+			case "rate":
+				rate, err := strconv.Atoi(value)
+				if err != nil || rate < 1 {
+					return batchOptions{}, false, fmt.Errorf("invalid batch rate %q, must be a positive number of rows per second", value)
+				}
+				opts.rate = rate
+			case "size":
+				size, err := strconv.Atoi(value)
+				if err != nil || size < 1 {
+					return batchOptions{}, false, fmt.Errorf("invalid batch size %q, must be a positive number", value)
+				}
+				opts.size = size
+			default:
+				return batchOptions{}, false, fmt.Errorf("invalid batch option %q, must be column, delay, rate, or size", arg)
+			}
+		}
+		return opts, true, nil
+	}
+	return batchOptions{}, false, nil
+}
+
+var batchStatementMatcher = regexp.MustCompile("(?is)^(?:update\\s+(?:only\\s+)?|delete\\s+from\\s+(?:only\\s+)?)([\\w.`\"]+)")
+
+// executeInBatches the update or delete statement, with the range of each batch added to its where clause.
+// Returns the total number of rows affected, or -1 if it isn't known.
+func (m *Migrator) executeInBatches(ctx context.Context, q queryer, statement string, opts batchOptions) (int64, error) {
+	if _, ok := q.(*sql.Tx); ok {
+		return -1, errors.New("batch directive needs a migration without a transaction, see no-transaction in front matter")
+	}
+
+	statement = strings.TrimRight(trimCommentLines(statement), "; \t\n")
+	match := batchStatementMatcher.FindStringSubmatch(statement)
+	if match == nil {
+		return -1, errors.New("batch directive needs an update or delete statement")
+	}
+	table := match[1]
+
+	where := whereIndex(statement)
+	prefix, condition := statement, ""
+	if where >= 0 {
+		prefix, condition = statement[:where], strings.TrimSpace(statement[where+len("where"):])
+	}
+
+	var total, rows int64
+	var last sql.NullString
+	var elapsed time.Duration
+	for {
+		after := ""
+		if last.Valid {
+			after = ` where ` + opts.column + ` > ` + quoteString(m.dialect, last.String)
+		}
+		var upper sql.NullString
+		query := `select max(` + opts.column + `) from (select ` + opts.column + ` from ` + table + after +
+			` order by ` + opts.column + ` limit ` + strconv.Itoa(opts.size) + `) b`
+		if err := q.QueryRowContext(ctx, query).Scan(&upper); err != nil {
+			return -1, fmt.Errorf("error getting next batch: %w", err)
+		}
+		if !upper.Valid {
+			return total, nil
+		}
+
+		if last.Valid {
+			// Rows affected may not be known, so count the whole batch then
+			if rows < 0 {
+				rows = int64(opts.size)
+			}
+			if err := pauseAfterBatch(ctx, opts.delay, opts.rate, rows, elapsed); err != nil {
+				return -1, err
+			}
+		}
+
+		rangeCondition := opts.column + ` <= ` + quoteString(m.dialect, upper.String)
+		if last.Valid {
+			rangeCondition = opts.column + ` > ` + quoteString(m.dialect, last.String) + ` and ` + rangeCondition
+		}
+		batch := strings.TrimSpace(prefix) + ` where ` + rangeCondition
+		if condition != "" {
+			batch += ` and (` + condition + `)`
+		}
+
+		start := time.Now()
+		var err error
+		rows, err = exec(ctx, q, batch)
+		elapsed = time.Since(start)
+		if err != nil {
+			return -1, fmt.Errorf("error in batch after %v: %w", last.String, err)
+		}
+		if rows >= 0 && total >= 0 {
+			total += rows
+		} else {
+			total = -1
+		}
+		last = upper
+	}
+}
+
+// pauseAfterBatch of rows that took elapsed, for at least delay, and for as long as needed to keep to rowsPerSecond,
+// if it's positive. Returns early with an error if ctx is done.
+func pauseAfterBatch(ctx context.Context, delay time.Duration, rowsPerSecond int, rows int64, elapsed time.Duration) error {
+	if rowsPerSecond > 0 && rows > 0 {
+		if d := time.Duration(rows)*time.Second/time.Duration(rowsPerSecond) - elapsed; d > delay {
+			delay = d
+		}
+	}
+	if delay <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// whereIndex of the where keyword of a statement, outside parentheses and quotes, or -1 if it has none.
+func whereIndex(statement string) int {
+	depth := 0
+	var quote byte
+	lower := strings.ToLower(statement)
+	for i := 0; i < len(lower); i++ {
+		c := lower[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case depth == 0 && strings.HasPrefix(lower[i:], "where") && (i == 0 || !sqlscan.IsIdentifierByte(lower[i-1])) &&
+			(i+5 == len(lower) || !sqlscan.IsIdentifierByte(lower[i+5])):
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,96 @@
+package migrate_test
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestMigrator_BatchDirective(t *testing.T) {
+	setup := "create table accounts (id integer primary key, v int);\n" +
+		"with recursive n(i) as (select 1 union all select i + 1 from n where i < 25) insert into accounts select i, i from n;"
+
+	t.Run("runs an update statement in batches", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, MissingDown: migrate.CheckIgnore, SplitStatements: true,
+			FS: fstest.MapFS{
+				"1.up.sql": {Data: []byte(setup)},
+				"2.up.sql": {Data: []byte("-- ---\n-- no-transaction: true\n-- ---\n" +
+					"-- migrate:batch column=id size=10\nupdate accounts set v = -v where v % 2 = 0 or v = 25;\n" +
+					"-- migrate:batch size=7\ndelete from accounts where (v < 0 and id > 20);")},
+			},
+		})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "2", getVersion(t, db))
+
+		var count, sum int
+		err = db.QueryRow(`select count(*), sum(v) from accounts`).Scan(&count, &sum)
+		is.NotError(t, err)
+		// Rows 22, 24, and 25 are deleted, and the even rows up to 20 are negated
+		is.Equal(t, 22, count)
+		is.Equal(t, 1+3+5+7+9+11+13+15+17+19+21+23-(2+4+6+8+10+12+14+16+18+20), sum)
+	})
+
+	t.Run("pauses between batches with delay and rate", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, MissingDown: migrate.CheckIgnore, SplitStatements: true,
+			FS: fstest.MapFS{
+				"1.up.sql": {Data: []byte(setup)},
+				"2.up.sql": {Data: []byte("-- ---\n-- no-transaction: true\n-- ---\n" +
+					"-- migrate:batch size=10 rate=500\nupdate accounts set v = -v;\n" +
+					"-- migrate:batch size=10 delay=20ms\nupdate accounts set v = -v;")},
+			},
+		})
+		start := time.Now()
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "2", getVersion(t, db))
+		// Each statement pauses before its second and third batch, for 20 milliseconds each
+		is.True(t, time.Since(start) >= 80*time.Millisecond)
+	})
+
+	t.Run("errors in a transaction", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, MissingDown: migrate.CheckIgnore, SplitStatements: true,
+			FS: fstest.MapFS{
+				"1.up.sql": {Data: []byte(setup + "\n-- migrate:batch\ndelete from accounts;")},
+			},
+		})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, "error migrating up: error running migration 1 from 1.up.sql: error in statement 3: "+
+			"batch directive needs a migration without a transaction, see no-transaction in front matter", err.Error())
+	})
+
+	t.Run("errors on invalid options", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, MissingDown: migrate.CheckIgnore, SplitStatements: true,
+			FS: fstest.MapFS{
+				"1.up.sql": {Data: []byte("-- migrate:batch size=0\ndelete from accounts;")},
+			},
+		})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, `error migrating up: error running migration 1 from 1.up.sql: error in statement 1: `+
+			`invalid batch size "0", must be a positive number`, err.Error())
+	})
+
+	t.Run("errors on an invalid rate", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, MissingDown: migrate.CheckIgnore, SplitStatements: true,
+			FS: fstest.MapFS{
+				"1.up.sql": {Data: []byte("-- migrate:batch rate=fast\ndelete from accounts;")},
+			},
+		})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, `error migrating up: error running migration 1 from 1.up.sql: error in statement 1: `+
+			`invalid batch rate "fast", must be a positive number of rows per second`, err.Error())
+	})
+}
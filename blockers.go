@@ -0,0 +1,154 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Blocker is a session with a long-running transaction, which could block the statements of migrations,
+// or queue them behind it along with all other queries on the same tables. See Options.BlockerAge.
+type Blocker struct {
+	// Age of the transaction.
+	Age time.Duration
+	// ID of the session, like the process ID on Postgres and the connection ID on MySQL.
+	ID int64
+	// Query the session is running, or ran last.
+	Query string
+	// Tables touched by the migrations that the session holds locks on.
+	Tables []string
+}
+
+// String for error messages.
+func (b Blocker) String() string {
+	s := fmt.Sprintf("session %v with a transaction open for %v", b.ID, b.Age.Round(time.Second))
+	if len(b.Tables) > 0 {
+		s += " and locks on " + strings.Join(b.Tables, ", ")
+	}
+	return s + ", running " + b.Query
+}
+
+// BlockerFinder is a Dialect that can find Blockers. See Options.BlockerAge.
+type BlockerFinder interface {
+	// FindBlockers returns the sessions other than the one of the query with a transaction open for at least minAge,
+	// with the locks they hold on the tables, which may be schema-qualified.
+	FindBlockers(ctx context.Context, db *sql.DB, tables []string, minAge time.Duration) ([]Blocker, error)
+}
+
+func (d postgresDialect) FindBlockers(ctx context.Context, db *sql.DB, tables []string, minAge time.Duration) ([]Blocker, error) {
+	query := `select a.pid, (extract(epoch from now() - a.xact_start) * 1000)::bigint, coalesce(a.query, ''),
+			coalesce(string_agg(distinct c.relname, ',' order by c.relname), '')
+		from pg_stat_activity a
+		left join pg_locks l on l.pid = a.pid and l.granted and l.locktype = 'relation'
+		left join pg_class c on c.oid = l.relation and c.relname in (` + unqualifiedList(d, tables) + `)
+		where a.pid <> pg_backend_pid() and a.xact_start <= now() - $1 * interval '1 millisecond'
+		group by a.pid, a.xact_start, a.query
+		order by a.xact_start`
+	return queryBlockers(ctx, db, query, minAge.Milliseconds())
+}
+
+// FindBlockers among InnoDB transactions, with their metadata locks from the performance schema.
+func (d mysqlDialect) FindBlockers(ctx context.Context, db *sql.DB, tables []string, minAge time.Duration) ([]Blocker, error) {
+	query := `select t.trx_mysql_thread_id, timestampdiff(microsecond, t.trx_started, now()) div 1000, coalesce(t.trx_query, ''),
+			coalesce((select group_concat(distinct ml.object_name order by ml.object_name)
+				from performance_schema.metadata_locks ml
+				join performance_schema.threads th on th.thread_id = ml.owner_thread_id
+				where th.processlist_id = t.trx_mysql_thread_id and ml.object_type = 'TABLE' and ml.lock_status = 'GRANTED'
+					and ml.object_name in (` + unqualifiedList(d, tables) + `)), '')
+		from information_schema.innodb_trx t
+		where t.trx_mysql_thread_id <> connection_id() and t.trx_started <= now() - interval ? microsecond
+		order by t.trx_started`
+	return queryBlockers(ctx, db, query, minAge.Microseconds())
+}
+
+// unqualifiedList of the table names without schemas, as string literals for an in clause.
+func unqualifiedList(d Dialect, tables []string) string {
+	// An empty string matches no table, and keeps the in clause valid without tables
+	literals := []string{`''`}
+	for _, table := range tables {
+		literals = append(literals, quoteString(d, table[strings.LastIndex(table, ".")+1:]))
+	}
+	return strings.Join(literals, ", ")
+}
+
+// queryBlockers with a query that selects the ID, age in milliseconds, query, and comma-separated locked tables.
+func queryBlockers(ctx context.Context, db *sql.DB, query string, args ...any) ([]Blocker, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var blockers []Blocker
+	for rows.Next() {
+		var b Blocker
+		var ageMS int64
+		var tables string
+		if err := rows.Scan(&b.ID, &ageMS, &b.Query, &tables); err != nil {
+			return nil, err
+		}
+		b.Age = time.Duration(ageMS) * time.Millisecond
+		if tables != "" {
+			b.Tables = strings.Split(tables, ",")
+		}
+		blockers = append(blockers, b)
+	}
+	return blockers, rows.Err()
+}
+
+// checkBlockers of the tables touched by the steps, waiting up to Options.BlockerWait for them to go away,
+// if the Dialect is a BlockerFinder. See Options.BlockerAge.
+func (m *Migrator) checkBlockers(ctx context.Context, steps []step) error {
+	finder, ok := m.dialect.(BlockerFinder)
+	if m.blockerAge <= 0 || !ok || len(steps) == 0 {
+		return nil
+	}
+
+	var tables []string
+	seen := map[string]bool{}
+	for _, s := range steps {
+		touched, err := m.touchedTables(s)
+		if err != nil {
+			return err
+		}
+		for _, table := range touched {
+			if !seen[table] {
+				seen[table] = true
+				tables = append(tables, table)
+			}
+		}
+	}
+
+	deadline := time.Now().Add(m.blockerWait)
+	for {
+		blockers, err := finder.FindBlockers(ctx, m.db, tables, m.blockerAge)
+		if err != nil {
+			return fmt.Errorf("error finding blockers: %w", err)
+		}
+		if len(blockers) == 0 {
+			return nil
+		}
+
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			descriptions := make([]string, len(blockers))
+			for i, b := range blockers {
+				descriptions[i] = b.String()
+			}
+			return errors.New("blocked by " + strings.Join(descriptions, "; "))
+		}
+		if wait > time.Second {
+			wait = time.Second
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
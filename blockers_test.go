@@ -0,0 +1,75 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+// blockingDialect is SQLite with a long-running transaction on the accounts table,
+// for the given number of checks, or forever if negative.
+type blockingDialect struct {
+	migrate.Dialect
+	checks *int
+	tables *[]string
+}
+
+func (d blockingDialect) FindBlockers(_ context.Context, _ *sql.DB, tables []string, minAge time.Duration) ([]migrate.Blocker, error) {
+	*d.tables = tables
+	if *d.checks == 0 {
+		return nil, nil
+	}
+	*d.checks--
+	return []migrate.Blocker{{Age: 2 * minAge, ID: 42, Query: "select * from accounts for update", Tables: []string{"accounts"}}}, nil
+}
+
+func TestMigrator_BlockerAge(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1-accounts.up.sql": {Data: []byte("create table accounts (id int);\ninsert into accounts values (1);")},
+		"2-users.up.sql":    {Data: []byte("create table users (id int);\nalter table accounts add column user_id int;")},
+	}
+
+	t.Run("fails the run with a report of the blockers", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		checks, tables := -1, []string{}
+
+		m := migrate.New(migrate.Options{DB: db, Dialect: blockingDialect{Dialect: migrate.SQLite, checks: &checks, tables: &tables},
+			FS: fsys, BlockerAge: time.Minute, MissingDown: migrate.CheckIgnore})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, "error migrating up: blocked by session 42 with a transaction open for 2m0s and locks on accounts, "+
+			"running select * from accounts for update", err.Error())
+		is.Equal(t, "", getVersion(t, db))
+		is.Equal(t, "[accounts users]", fmt.Sprint(tables))
+	})
+
+	t.Run("waits for the blockers to go away", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		checks, tables := 1, []string{}
+
+		m := migrate.New(migrate.Options{DB: db, Dialect: blockingDialect{Dialect: migrate.SQLite, checks: &checks, tables: &tables},
+			FS: fsys, BlockerAge: time.Minute, BlockerWait: 5 * time.Second, MissingDown: migrate.CheckIgnore})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "2-users", getVersion(t, db))
+		is.Equal(t, 0, checks)
+	})
+
+	t.Run("doesn't check without a blocker age", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		checks, tables := -1, []string{}
+
+		m := migrate.New(migrate.Options{DB: db, Dialect: blockingDialect{Dialect: migrate.SQLite, checks: &checks, tables: &tables},
+			FS: fsys, MissingDown: migrate.CheckIgnore})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "2-users", getVersion(t, db))
+	})
+}
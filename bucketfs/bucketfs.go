@@ -0,0 +1,116 @@
+// Package bucketfs provides an fs.FS over the objects in a bucket, like in S3 or GCS,
+// so migrations can be stored centrally instead of embedded in binaries.
+//
+// The package doesn't depend on any cloud SDK. Instead, implement Bucket with the SDK you already use.
+// With the AWS SDK for S3, it's a few lines:
+//
+//	type s3Bucket struct {
+//		client *s3.Client
+//		name   string
+//	}
+//
+//	func (b s3Bucket) List(ctx context.Context, prefix string) ([]string, error) {
+//		var keys []string
+//		p := s3.NewListObjectsV2Paginator(b.client, &s3.ListObjectsV2Input{Bucket: &b.name, Prefix: &prefix})
+//		for p.HasMorePages() {
+//			page, err := p.NextPage(ctx)
+//			if err != nil {
+//				return nil, err
+//			}
+//			for _, o := range page.Contents {
+//				keys = append(keys, *o.Key)
+//			}
+//		}
+//		return keys, nil
+//	}
+//
+//	func (b s3Bucket) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+//		o, err := b.client.GetObject(ctx, &s3.GetObjectInput{Bucket: &b.name, Key: &key})
+//		if err != nil {
+//			return nil, err
+//		}
+//		return o.Body, nil
+//	}
+//
+// With the Google Cloud Storage client, it's similar:
+//
+//	type gcsBucket struct{ h *storage.BucketHandle }
+//
+//	func (b gcsBucket) List(ctx context.Context, prefix string) ([]string, error) {
+//		var keys []string
+//		it := b.h.Objects(ctx, &storage.Query{Prefix: prefix})
+//		for {
+//			o, err := it.Next()
+//			if errors.Is(err, iterator.Done) {
+//				return keys, nil
+//			}
+//			if err != nil {
+//				return nil, err
+//			}
+//			keys = append(keys, o.Name)
+//		}
+//	}
+//
+//	func (b gcsBucket) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+//		return b.h.Object(key).NewReader(ctx)
+//	}
+package bucketfs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+
+	"maragu.dev/migrate/internal/memfs"
+)
+
+// Bucket of objects by key.
+type Bucket interface {
+	// List the keys of all objects with the prefix.
+	List(ctx context.Context, prefix string) ([]string, error)
+
+	// Open the object with the key for reading.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// Load all objects under the prefix from the bucket into memory, returning them as an fs.FS.
+// Keys are relative to the prefix, so with the prefix "migrations/", the key "migrations/1.up.sql"
+// is the file "1.up.sql". Keys ending in a slash, like folder placeholders, are skipped.
+// Objects are only read once, so the FS stays the same for the whole run, even if the bucket changes.
+func Load(ctx context.Context, b Bucket, prefix string) (fs.FS, error) {
+	keys, err := b.List(ctx, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("error listing objects with prefix %v: %w", prefix, err)
+	}
+
+	files := memfs.FS{}
+	for _, key := range keys {
+		name := strings.TrimPrefix(key, prefix)
+		if name == "" || strings.HasSuffix(name, "/") {
+			continue
+		}
+		if !fs.ValidPath(name) {
+			return nil, fmt.Errorf("error loading object %v: invalid file name %v", key, name)
+		}
+
+		content, err := read(ctx, b, key)
+		if err != nil {
+			return nil, fmt.Errorf("error loading object %v: %w", key, err)
+		}
+		files[name] = content
+	}
+	return files, nil
+}
+
+func read(ctx context.Context, b Bucket, key string) ([]byte, error) {
+	r, err := b.Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+	return io.ReadAll(r)
+}
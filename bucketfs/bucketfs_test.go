@@ -0,0 +1,78 @@
+package bucketfs_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate/bucketfs"
+)
+
+type mapBucket map[string]string
+
+func (b mapBucket) List(_ context.Context, prefix string) ([]string, error) {
+	var keys []string
+	for key := range b {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (b mapBucket) Open(_ context.Context, key string) (io.ReadCloser, error) {
+	content, ok := b[key]
+	if !ok {
+		return nil, errors.New("no such key")
+	}
+	return io.NopCloser(strings.NewReader(content)), nil
+}
+
+func TestLoad(t *testing.T) {
+	b := mapBucket{
+		"migrations/":                  "",
+		"migrations/1.up.sql":          "create table a (v int)",
+		"migrations/1.down.sql":        "drop table a",
+		"migrations/common/grants.sql": "grant select on a to reader",
+		"other/1.up.sql":               "select 1",
+	}
+
+	t.Run("loads the objects under the prefix as files", func(t *testing.T) {
+		fsys, err := bucketfs.Load(context.Background(), b, "migrations/")
+		is.NotError(t, err)
+
+		entries, err := fs.ReadDir(fsys, ".")
+		is.NotError(t, err)
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		is.Equal(t, "[1.down.sql 1.up.sql common]", fmt.Sprint(names))
+
+		content, err := fs.ReadFile(fsys, "common/grants.sql")
+		is.NotError(t, err)
+		is.Equal(t, "grant select on a to reader", string(content))
+	})
+
+	t.Run("errors if listing fails", func(t *testing.T) {
+		_, err := bucketfs.Load(context.Background(), failingBucket{}, "migrations/")
+		is.True(t, err != nil)
+		is.Equal(t, "error listing objects with prefix migrations/: oh no", err.Error())
+	})
+}
+
+type failingBucket struct{}
+
+func (failingBucket) List(context.Context, string) ([]string, error) {
+	return nil, errors.New("oh no")
+}
+
+func (failingBucket) Open(context.Context, string) (io.ReadCloser, error) {
+	return nil, errors.New("oh no")
+}
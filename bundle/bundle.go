@@ -0,0 +1,286 @@
+// Package bundle packages the migrations between two versions into a signed tarball with a manifest of checksums,
+// so operators of air-gapped environments can apply them later without access to the source.
+// Bundles are signed with Ed25519, see GenerateKey.
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"maragu.dev/migrate"
+	"maragu.dev/migrate/internal/memfs"
+)
+
+const (
+	manifestName  = "manifest.json"
+	signatureName = "manifest.json.sig"
+	filesDir      = "migrations/"
+)
+
+// Manifest of a bundle.
+type Manifest struct {
+	// Files in the bundle with their checksums, sorted by name.
+	Files []File `json:"files"`
+	// From is the version the migrations in the bundle apply after. Empty means from the start.
+	From string `json:"from"`
+	// Order of all versions in the manifest of the migrations, see migrate.ManifestFileName.
+	// Versions are compared in this order instead of by name. Nil if the migrations have no manifest.
+	Order []string `json:"order,omitempty"`
+	// To is the newest version in the bundle.
+	To string `json:"to"`
+}
+
+// File in a Manifest.
+type File struct {
+	Name string `json:"name"`
+	// SHA256 checksum of the file, hex-encoded.
+	SHA256 string `json:"sha256"`
+}
+
+// GenerateKey for signing bundles with Write, and the public key for verifying them with Read.
+func GenerateKey() (ed25519.PublicKey, ed25519.PrivateKey, error) {
+	return ed25519.GenerateKey(rand.Reader)
+}
+
+// Write a bundle to w, as a gzipped tarball, with the up and down migration files of the versions after from,
+// up to and including to, from fsys. Empty to means up to the newest version. Other files in fsys,
+// like files included with the include directive, are bundled too. If fsys has a manifest, versions are compared
+// in its order, and the bundle gets a manifest of just the bundled migrations. The manifest is signed with key.
+func Write(w io.Writer, fsys fs.FS, from, to string, key ed25519.PrivateKey) (Manifest, error) {
+	order, err := migrate.ReadManifest(fsys)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("error reading migrations: %w", err)
+	}
+	compare := compareVersions(order)
+
+	files := map[string][]byte{}
+	upNames := map[string]string{}
+	var versions []string
+	err = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || name == migrate.ManifestFileName {
+			return err
+		}
+		if migration, err := migrate.ParseName(name); err == nil {
+			if compare(migration.Version, from) <= 0 || (to != "" && compare(migration.Version, to) > 0) {
+				return nil
+			}
+			if !migration.Down {
+				versions = append(versions, migration.Version)
+				upNames[migration.Version] = name
+			}
+		}
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		files[name] = content
+		return nil
+	})
+	if err != nil {
+		return Manifest{}, fmt.Errorf("error reading migrations: %w", err)
+	}
+	if len(versions) == 0 {
+		return Manifest{}, fmt.Errorf("error writing bundle: no migrations after %q up to %q", from, to)
+	}
+	sort.Slice(versions, func(i, j int) bool { return compare(versions[i], versions[j]) < 0 })
+
+	// Listed files that don't exist are an error, so only the bundled migrations are listed
+	if order != nil {
+		var list strings.Builder
+		for _, v := range versions {
+			list.WriteString(upNames[v] + "\n")
+		}
+		files[migrate.ManifestFileName] = []byte(list.String())
+	}
+
+	manifest := Manifest{From: from, Order: order, To: versions[len(versions)-1]}
+	for name, content := range files {
+		manifest.Files = append(manifest.Files, File{Name: name, SHA256: checksum(content)})
+	}
+	sort.Slice(manifest.Files, func(i, j int) bool { return manifest.Files[i].Name < manifest.Files[j].Name })
+
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return Manifest{}, fmt.Errorf("error writing bundle: %w", err)
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	type entry struct {
+		name    string
+		content []byte
+	}
+	entries := []entry{
+		{manifestName, manifestJSON},
+		{signatureName, []byte(hex.EncodeToString(ed25519.Sign(key, manifestJSON)))},
+	}
+	for _, f := range manifest.Files {
+		entries = append(entries, entry{filesDir + f.Name, files[f.Name]})
+	}
+	for _, e := range entries {
+		if err := tw.WriteHeader(&tar.Header{Mode: 0o644, Name: e.name, Size: int64(len(e.content)), Typeflag: tar.TypeReg}); err != nil {
+			return Manifest{}, fmt.Errorf("error writing bundle: %w", err)
+		}
+		if _, err := tw.Write(e.content); err != nil {
+			return Manifest{}, fmt.Errorf("error writing bundle: %w", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return Manifest{}, fmt.Errorf("error writing bundle: %w", err)
+	}
+	if err := gw.Close(); err != nil {
+		return Manifest{}, fmt.Errorf("error writing bundle: %w", err)
+	}
+	return manifest, nil
+}
+
+// Read a bundle written by Write from r, verifying the signature of the manifest with key,
+// and the checksums of the files. It returns the files as an fs.FS, and the manifest.
+// It errors without returning any files if the bundle doesn't verify.
+func Read(r io.Reader, key ed25519.PublicKey) (fs.FS, Manifest, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, Manifest{}, fmt.Errorf("error reading bundle: %w", err)
+	}
+	defer func() {
+		_ = gr.Close()
+	}()
+
+	var manifestJSON, signature []byte
+	files := memfs.FS{}
+	tr := tar.NewReader(gr)
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, Manifest{}, fmt.Errorf("error reading bundle: %w", err)
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, Manifest{}, fmt.Errorf("error reading bundle: %w", err)
+		}
+
+		switch {
+		case h.Name == manifestName:
+			manifestJSON = content
+		case h.Name == signatureName:
+			signature = content
+		case strings.HasPrefix(h.Name, filesDir):
+			name := path.Clean(strings.TrimPrefix(h.Name, filesDir))
+			if !fs.ValidPath(name) {
+				return nil, Manifest{}, fmt.Errorf("error reading bundle: invalid file name %v", h.Name)
+			}
+			files[name] = content
+		}
+	}
+
+	if manifestJSON == nil || signature == nil {
+		return nil, Manifest{}, errors.New("error reading bundle: no signed manifest")
+	}
+	sig, err := hex.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil || !ed25519.Verify(key, manifestJSON, sig) {
+		return nil, Manifest{}, errors.New("error verifying bundle: signature is invalid")
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+		return nil, Manifest{}, fmt.Errorf("error reading bundle manifest: %w", err)
+	}
+
+	listed := map[string]bool{}
+	for _, f := range manifest.Files {
+		listed[f.Name] = true
+		content, ok := files[f.Name]
+		if !ok {
+			return nil, Manifest{}, fmt.Errorf("error verifying bundle: %v is missing", f.Name)
+		}
+		if sum := checksum(content); sum != f.SHA256 {
+			return nil, Manifest{}, fmt.Errorf("error verifying bundle: checksum of %v is %v, but the manifest has %v", f.Name, sum, f.SHA256)
+		}
+	}
+	for name := range files {
+		if !listed[name] {
+			return nil, Manifest{}, fmt.Errorf("error verifying bundle: %v is not in the manifest", name)
+		}
+	}
+	return files, manifest, nil
+}
+
+// Apply the migrations in a bundle from r, verified with key, migrating up with opts, with Options.FS set to the bundle.
+// It errors before applying anything if the database is at a version before the bundle starts,
+// because the bundle doesn't have the migrations in between.
+func Apply(ctx context.Context, r io.Reader, key ed25519.PublicKey, opts migrate.Options) error {
+	fsys, manifest, err := Read(r, key)
+	if err != nil {
+		return err
+	}
+
+	compare := compareVersions(manifest.Order)
+	opts.FS = fsys
+	beforeAll := opts.BeforeAll
+	opts.BeforeAll = func(ctx context.Context, s migrate.Summary) error {
+		if compare(s.FromVersion, manifest.From) < 0 {
+			return fmt.Errorf("database is at version %q, but the bundle starts after %v", s.FromVersion, manifest.From)
+		}
+		// The manifest in the bundle doesn't list newer versions, so the Migrator would take them as older
+		if manifest.Order != nil && compare(s.FromVersion, manifest.To) >= 0 {
+			return errApplied
+		}
+		if beforeAll != nil {
+			return beforeAll(ctx, s)
+		}
+		return nil
+	}
+
+	if err := migrate.New(opts).MigrateUp(ctx); err != nil {
+		if errors.Is(err, errApplied) {
+			return nil
+		}
+		return fmt.Errorf("error applying bundle: %w", err)
+	}
+	return nil
+}
+
+var errApplied = errors.New("bundle is applied already")
+
+// compareVersions like strings.Compare, or in order if it's not nil, with versions that aren't in it first,
+// like a Migrator does with a manifest.
+func compareVersions(order []string) func(a, b string) int {
+	if order == nil {
+		return strings.Compare
+	}
+	ranks := map[string]int{}
+	for i, v := range order {
+		ranks[v] = i + 1
+	}
+	return func(a, b string) int {
+		ra, rb := ranks[a], ranks[b]
+		if ra == 0 && rb == 0 {
+			return strings.Compare(a, b)
+		}
+		return ra - rb
+	}
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,192 @@
+package bundle_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/mattn/go-sqlite3"
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+	"maragu.dev/migrate/bundle"
+)
+
+var migrations = fstest.MapFS{
+	"1.up.sql":          {Data: []byte("create table a (v int)")},
+	"1.down.sql":        {Data: []byte("drop table a")},
+	"2.up.sql":          {Data: []byte("-- migrate:include common/b.sql")},
+	"2.down.sql":        {Data: []byte("drop table b")},
+	"3.up.sql":          {Data: []byte("create table c (v int)")},
+	"3.down.sql":        {Data: []byte("drop table c")},
+	"common/b.sql":      {Data: []byte("create table b (v int)")},
+	"common/README.txt": {Data: []byte("Shared SQL.")},
+}
+
+var orderedMigrations = fstest.MapFS{
+	"1.up.sql":        {Data: []byte("create table a (v int)")},
+	"3.up.sql":        {Data: []byte("create table c (v int)")},
+	"2.up.sql":        {Data: []byte("create table b (v int)")},
+	"migrations.list": {Data: []byte("1.up.sql\n3.up.sql\n2.up.sql\n")},
+}
+
+func TestWrite(t *testing.T) {
+	t.Run("bundles the migrations between the versions, and other files", func(t *testing.T) {
+		_, private, err := bundle.GenerateKey()
+		is.NotError(t, err)
+
+		var b bytes.Buffer
+		manifest, err := bundle.Write(&b, migrations, "1", "2", private)
+		is.NotError(t, err)
+		is.Equal(t, "1", manifest.From)
+		is.Equal(t, "2", manifest.To)
+		var names []string
+		for _, f := range manifest.Files {
+			names = append(names, f.Name)
+		}
+		is.Equal(t, "[2.down.sql 2.up.sql common/README.txt common/b.sql]", fmt.Sprint(names))
+	})
+
+	t.Run("bundles the migrations after the version in the order of the migrations manifest", func(t *testing.T) {
+		public, private, err := bundle.GenerateKey()
+		is.NotError(t, err)
+
+		var b bytes.Buffer
+		manifest, err := bundle.Write(&b, orderedMigrations, "3", "", private)
+		is.NotError(t, err)
+		is.Equal(t, "2", manifest.To)
+		is.Equal(t, "[1 3 2]", fmt.Sprint(manifest.Order))
+
+		fsys, _, err := bundle.Read(&b, public)
+		is.NotError(t, err)
+		content, err := fs.ReadFile(fsys, "migrations.list")
+		is.NotError(t, err)
+		is.Equal(t, "2.up.sql\n", string(content))
+	})
+
+	t.Run("errors if there are no migrations between the versions", func(t *testing.T) {
+		_, private, err := bundle.GenerateKey()
+		is.NotError(t, err)
+
+		_, err = bundle.Write(&bytes.Buffer{}, migrations, "3", "", private)
+		is.True(t, err != nil)
+		is.Equal(t, `error writing bundle: no migrations after "3" up to ""`, err.Error())
+	})
+}
+
+func TestRead(t *testing.T) {
+	t.Run("reads the files of a bundle signed with the key", func(t *testing.T) {
+		public, private, err := bundle.GenerateKey()
+		is.NotError(t, err)
+
+		var b bytes.Buffer
+		_, err = bundle.Write(&b, migrations, "", "", private)
+		is.NotError(t, err)
+
+		fsys, manifest, err := bundle.Read(&b, public)
+		is.NotError(t, err)
+		is.Equal(t, "3", manifest.To)
+		content, err := fs.ReadFile(fsys, "common/b.sql")
+		is.NotError(t, err)
+		is.Equal(t, "create table b (v int)", string(content))
+	})
+
+	t.Run("errors if the bundle is signed with another key", func(t *testing.T) {
+		_, private, err := bundle.GenerateKey()
+		is.NotError(t, err)
+		other, _, err := bundle.GenerateKey()
+		is.NotError(t, err)
+
+		var b bytes.Buffer
+		_, err = bundle.Write(&b, migrations, "", "", private)
+		is.NotError(t, err)
+
+		_, _, err = bundle.Read(&b, other)
+		is.True(t, err != nil)
+		is.Equal(t, "error verifying bundle: signature is invalid", err.Error())
+	})
+}
+
+func TestApply(t *testing.T) {
+	newDB := func(t *testing.T) *sql.DB {
+		t.Helper()
+		db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "db.sqlite"))
+		is.NotError(t, err)
+		t.Cleanup(func() {
+			_ = db.Close()
+		})
+		return db
+	}
+
+	t.Run("applies the pending migrations in the bundle", func(t *testing.T) {
+		public, private, err := bundle.GenerateKey()
+		is.NotError(t, err)
+
+		db := newDB(t)
+		err = migrate.New(migrate.Options{DB: db, FS: migrations}).MigrateTo(context.Background(), "1")
+		is.NotError(t, err)
+
+		var b bytes.Buffer
+		_, err = bundle.Write(&b, migrations, "1", "", private)
+		is.NotError(t, err)
+
+		err = bundle.Apply(context.Background(), &b, public, migrate.Options{DB: db})
+		is.NotError(t, err)
+
+		var version string
+		err = db.QueryRow(`select version from migrations`).Scan(&version)
+		is.NotError(t, err)
+		is.Equal(t, "3", version)
+		_, err = db.Exec(`select * from b`)
+		is.NotError(t, err)
+	})
+
+	t.Run("errors if the database is before the start of the bundle", func(t *testing.T) {
+		public, private, err := bundle.GenerateKey()
+		is.NotError(t, err)
+
+		var b bytes.Buffer
+		_, err = bundle.Write(&b, migrations, "1", "", private)
+		is.NotError(t, err)
+
+		err = bundle.Apply(context.Background(), &b, public, migrate.Options{DB: newDB(t)})
+		is.True(t, err != nil)
+		is.Equal(t, `error applying bundle: error migrating up: error in 'before all' callback: database is at version "", but the bundle starts after 1`, err.Error())
+	})
+
+	t.Run("applies a bundle in the order of the migrations manifest", func(t *testing.T) {
+		public, private, err := bundle.GenerateKey()
+		is.NotError(t, err)
+
+		db := newDB(t)
+		err = migrate.New(migrate.Options{DB: db, FS: orderedMigrations}).MigrateTo(context.Background(), "3")
+		is.NotError(t, err)
+
+		var b bytes.Buffer
+		_, err = bundle.Write(&b, orderedMigrations, "1", "", private)
+		is.NotError(t, err)
+
+		err = bundle.Apply(context.Background(), &b, public, migrate.Options{DB: db})
+		is.NotError(t, err)
+
+		var version string
+		err = db.QueryRow(`select version from migrations`).Scan(&version)
+		is.NotError(t, err)
+		is.Equal(t, "2", version)
+
+		// A bundle that ends before the version of the database does nothing
+		b.Reset()
+		_, err = bundle.Write(&b, orderedMigrations, "1", "3", private)
+		is.NotError(t, err)
+		err = bundle.Apply(context.Background(), &b, public, migrate.Options{DB: db})
+		is.NotError(t, err)
+		err = db.QueryRow(`select version from migrations`).Scan(&version)
+		is.NotError(t, err)
+		is.Equal(t, "2", version)
+	})
+}
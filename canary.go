@@ -0,0 +1,63 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+)
+
+// applyToCanary the up migrations up to and including the last of steps, before they are applied to the primary,
+// see Options.Canary. The canary migrates up from its own current version, which can be before the primary's.
+func (m *Migrator) applyToCanary(ctx context.Context, steps []step) error {
+	if m.canary == nil || len(steps) == 0 || steps[0].down {
+		return nil
+	}
+
+	ctx, span := m.tracer.Start(ctx, "migrate canary")
+	span.SetAttribute("migrate.table", m.table)
+	target := steps[len(steps)-1].version
+	span.SetAttribute("migrate.target_version", target)
+
+	// The canary keeps its version in its own migrations table, and doesn't count in the primary's metrics.
+	// The files are already checked for the primary.
+	c := *m
+	c.applied = nil
+	c.blockerAge = 0
+	c.canary = nil
+	c.confirmDangerous = nil
+	c.conn = m.canary
+	c.db = m.canary
+	c.emptyFile = CheckIgnore
+	c.implicitCommit = CheckIgnore
+	c.maintenance = MaintenanceNone
+	c.metrics = noopMetrics{}
+	c.missingApplied = CheckIgnore
+	c.missingDown = CheckIgnore
+	c.notifyChannel = ""
+	c.numberCollision = CheckIgnore
+	c.policy = nil
+	c.store = nil
+	c.sumMismatch = CheckIgnore
+
+	err := c.session(ctx, func(s *Migrator) error {
+		if err := s.createMigrationsTable(ctx); err != nil {
+			return err
+		}
+
+		currentVersion, err := s.getCurrentVersion(ctx)
+		if err != nil {
+			return err
+		}
+
+		steps, err := s.planUp(currentVersion, target)
+		if err != nil {
+			return err
+		}
+
+		return s.applyAll(ctx, steps)
+	})
+	if err != nil {
+		err = fmt.Errorf("error applying to canary: %w", err)
+	}
+	span.End(err)
+	return err
+}
@@ -0,0 +1,60 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestMigrator_Canary(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1.up.sql": {Data: []byte("create table accounts (email text)")},
+		"2.up.sql": {Data: []byte("create unique index accounts_email on accounts (email)")},
+	}
+
+	createCanary := func(t *testing.T) *sql.DB {
+		t.Helper()
+		canary, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "canary.sqlite"))
+		is.NotError(t, err)
+		t.Cleanup(func() {
+			_ = canary.Close()
+		})
+		return canary
+	}
+
+	t.Run("applies to the canary first", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		canary := createCanary(t)
+
+		m := migrate.New(migrate.Options{Canary: canary, DB: db, FS: fsys, MissingDown: migrate.CheckIgnore})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "2", getVersion(t, db))
+		is.Equal(t, "2", getVersion(t, canary))
+	})
+
+	t.Run("fails before applying to the primary if the canary fails", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		canary := createCanary(t)
+
+		// The canary is like a snapshot of production, with data the unique index fails on
+		err := migrate.New(migrate.Options{DB: canary, FS: fsys, MissingDown: migrate.CheckIgnore}).UpTo(context.Background(), "1")
+		is.NotError(t, err)
+		_, err = canary.Exec(`insert into accounts values ('me@example.com'), ('me@example.com')`)
+		is.NotError(t, err)
+
+		m := migrate.New(migrate.Options{Canary: canary, DB: db, FS: fsys, MissingDown: migrate.CheckIgnore})
+		err = m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, "error migrating up: error applying to canary: error running migration 2 from 2.up.sql: "+
+			"UNIQUE constraint failed: accounts.email", err.Error())
+		is.Equal(t, "", getVersion(t, db))
+		is.Equal(t, "1", getVersion(t, canary))
+	})
+}
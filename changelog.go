@@ -0,0 +1,127 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	texttemplate "text/template"
+
+	"maragu.dev/migrate/internal/sqlscan"
+)
+
+// ChangelogFormat for Migrator.WriteChangelog.
+type ChangelogFormat int
+
+const (
+	// Markdown changelog, like for release notes.
+	Markdown ChangelogFormat = iota
+	// HTML changelog page.
+	HTML
+)
+
+// WriteChangelog of the migrations to w, newest first, with the version, description, a summary of the SQL
+// with the first line of each statement, and when it was applied to the database, if recorded in the history.
+// See Migrator.Status.
+func (m *Migrator) WriteChangelog(ctx context.Context, w io.Writer, format ChangelogFormat) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error writing changelog: %w", err)
+		}
+	}()
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	var entries []changelogEntry
+	for i := len(statuses) - 1; i >= 0; i-- {
+		s := statuses[i]
+		summary, err := m.summarize(step{name: s.Name})
+		if err != nil {
+			return err
+		}
+		entries = append(entries, changelogEntry{MigrationStatus: s, Summary: summary})
+	}
+
+	switch format {
+	case HTML:
+		err = htmlChangelog.Execute(w, entries)
+	default:
+		err = markdownChangelog.Execute(w, entries)
+	}
+	return err
+}
+
+type changelogEntry struct {
+	MigrationStatus
+	// Summary of the SQL, with the first line of each statement.
+	Summary []string
+}
+
+// summarize the SQL of a step with the first line of each statement, without comments.
+func (m *Migrator) summarize(s step) ([]string, error) {
+	f, err := m.open(s)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var summary []string
+	scanner := sqlscan.NewScanner(f)
+	for scanner.Scan() {
+		for _, line := range strings.Split(scanner.Statement(), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "--") {
+				continue
+			}
+			summary = append(summary, strings.TrimSuffix(line, ";"))
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading migration file %v: %w", s.name, err)
+	}
+	return summary, nil
+}
+
+var markdownChangelog = texttemplate.Must(texttemplate.New("changelog").Parse(`# Changelog
+{{range .}}
+## {{.Version}}{{if .Description}}: {{.Description}}{{end}}
+
+{{if not .AppliedAt.IsZero}}Applied at {{.AppliedAt.UTC.Format "2006-01-02 15:04:05 UTC"}}.{{else if .Applied}}Applied.{{else}}Pending.{{end}}
+{{if .Summary}}
+{{range .Summary}}- ` + "`{{.}}`" + `
+{{end}}{{end}}{{end}}`))
+
+var htmlChangelog = htmltemplate.Must(htmltemplate.New("changelog").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Changelog</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2rem; color: #222; }
+code { font-size: 0.9em; }
+.pending { color: #a15c00; }
+.applied { color: #1a7f37; }
+</style>
+</head>
+<body>
+<h1>Changelog</h1>
+{{range .}}<section>
+<h2><code>{{.Version}}</code>{{if .Description}}: {{.Description}}{{end}}</h2>
+{{if not .AppliedAt.IsZero}}<p class="applied">Applied at {{.AppliedAt.UTC.Format "2006-01-02 15:04:05 UTC"}}.</p>
+{{else if .Applied}}<p class="applied">Applied.</p>
+{{else}}<p class="pending">Pending.</p>
+{{end}}{{if .Summary}}<ul>
+{{range .Summary}}<li><code>{{.}}</code></li>
+{{end}}</ul>
+{{end}}</section>
+{{end}}</body>
+</html>
+`))
@@ -0,0 +1,64 @@
+package migrate_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestMigrator_WriteChangelog(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1-accounts.up.sql": {Data: []byte("-- ---\n-- description: Add the accounts table\n-- ---\n" +
+			"create table accounts (\n  id int\n);\n-- Seed\ninsert into accounts (id) values (1);")},
+		"2-users.up.sql": {Data: []byte("create table users (id int, name text default '<none>')")},
+	}
+
+	t.Run("writes a Markdown changelog, newest first", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, MissingDown: migrate.CheckIgnore})
+		err := m.MigrateTo(context.Background(), "1-accounts")
+		is.NotError(t, err)
+
+		var b strings.Builder
+		err = m.WriteChangelog(context.Background(), &b, migrate.Markdown)
+		is.NotError(t, err)
+		is.Equal(t, "# Changelog\n\n"+
+			"## 2-users: users\n\nPending.\n\n- `create table users (id int, name text default '<none>')`\n\n"+
+			"## 1-accounts: Add the accounts table\n\nApplied.\n\n- `create table accounts (`\n- `insert into accounts (id) values (1)`\n",
+			b.String())
+	})
+
+	t.Run("writes when migrations were applied, with history", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, History: true, MissingDown: migrate.CheckIgnore})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		var b strings.Builder
+		err = m.WriteChangelog(context.Background(), &b, migrate.Markdown)
+		is.NotError(t, err)
+		is.Equal(t, 2, strings.Count(b.String(), "Applied at "))
+	})
+
+	t.Run("writes an HTML changelog", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, MissingDown: migrate.CheckIgnore})
+		err := m.MigrateTo(context.Background(), "1-accounts")
+		is.NotError(t, err)
+
+		var b strings.Builder
+		err = m.WriteChangelog(context.Background(), &b, migrate.HTML)
+		is.NotError(t, err)
+		is.True(t, strings.Contains(b.String(), "<h2><code>2-users</code>: users</h2>\n<p class=\"pending\">Pending.</p>"))
+		is.True(t, strings.Contains(b.String(), "<li><code>create table users (id int, name text default &#39;&lt;none&gt;&#39;)</code></li>"))
+		is.True(t, strings.Contains(b.String(), "<p class=\"applied\">Applied.</p>"))
+	})
+}
@@ -0,0 +1,190 @@
+package migrate
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+
+	"maragu.dev/migrate/internal/sqlscan"
+)
+
+// Logger for warnings, like *log.Logger.
+type Logger interface {
+	Printf(format string, v ...any)
+}
+
+// CheckLevel of a check of the migration files before they are applied.
+type CheckLevel int
+
+const (
+	// CheckIgnore doesn't check, and is the default.
+	CheckIgnore CheckLevel = iota
+	// CheckWarn logs a warning with the Logger.
+	CheckWarn
+	// CheckError fails the run before any migrations are applied.
+	CheckError
+)
+
+// check the migration files of steps, before any of them are applied.
+func (m *Migrator) check(steps []step) error {
+	if err := m.checkNumbers(); err != nil {
+		return err
+	}
+
+	for _, s := range steps {
+		// Stored migrations are from the database, and so already checked when their up migration was applied
+		if s.stored {
+			continue
+		}
+
+		if m.emptyFile != CheckIgnore {
+			empty, err := m.isEmpty(s.name)
+			if err != nil {
+				return err
+			}
+			if empty {
+				if err := m.report(m.emptyFile, "%v is empty", s.name); err != nil {
+					return err
+				}
+			}
+		}
+
+		if m.implicitCommit != CheckIgnore && supportsTransactions(m.dialect) && !m.dialect.SupportsTransactionalDDL() {
+			mixed, err := m.hasDDLWithOtherStatements(s.name)
+			if err != nil {
+				return err
+			}
+			if mixed {
+				if err := m.report(m.implicitCommit, "%v has DDL with other statements, and the database commits DDL implicitly, "+
+					"so if it fails, the statements before the failing one stay applied", s.name); err != nil {
+					return err
+				}
+			}
+		}
+
+		if s.down || s.baseline || m.missingDown == CheckIgnore {
+			continue
+		}
+
+		downName := s.fileVersion + ".down.sql"
+		if _, err := fs.Stat(m.fs, downName); err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("error checking migration file %v: %w", downName, err)
+			}
+			if err := m.report(m.missingDown, "%v has no down migration file %v", s.name, downName); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkNumbers of the up migration files for collisions. See Options.NumberCollision.
+func (m *Migrator) checkNumbers() error {
+	if m.numberCollision == CheckIgnore {
+		return nil
+	}
+
+	names, err := m.getFilenames(upMatcher)
+	if err != nil {
+		return err
+	}
+
+	byNumber := map[string]string{}
+	for _, name := range names {
+		number := numberMatcher.FindString(name)
+		if number == "" {
+			continue
+		}
+		if other, ok := byNumber[number]; ok {
+			if err := m.report(m.numberCollision, "%v and %v have the same number %v", other, name, number); err != nil {
+				return err
+			}
+			continue
+		}
+		byNumber[number] = name
+	}
+	return nil
+}
+
+// isEmpty reports whether the named file has only whitespace, reading only up to the first other byte.
+func (m *Migrator) isEmpty(name string) (bool, error) {
+	f, err := m.fs.Open(name)
+	if err != nil {
+		return false, fmt.Errorf("error checking migration file %v: %w", name, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	r := bufio.NewReader(f)
+	for {
+		c, err := r.ReadByte()
+		if errors.Is(err, io.EOF) {
+			return true, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("error checking migration file %v: %w", name, err)
+		}
+		if !sqlscan.IsSpace(c) {
+			return false, nil
+		}
+	}
+}
+
+// hasDDLWithOtherStatements reports whether the named file with its included files has a DDL statement
+// and at least one other statement.
+func (m *Migrator) hasDDLWithOtherStatements(name string) (bool, error) {
+	f, err := m.open(step{name: name})
+	if err != nil {
+		return false, fmt.Errorf("error checking migration file %v: %w", name, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var statements int
+	var ddl bool
+	scanner := sqlscan.NewScanner(f)
+	for scanner.Scan() {
+		statements++
+		if isDDL(scanner.Statement()) {
+			ddl = true
+		}
+		if ddl && statements > 1 {
+			return true, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("error checking migration file %v: %w", name, err)
+	}
+	return false, nil
+}
+
+// isDDL reports whether the statement starts with a DDL keyword, after any comments.
+func isDDL(statement string) bool {
+	words := sqlscan.Words(statement)
+	if len(words) == 0 {
+		return false
+	}
+	switch words[0] {
+	case "create", "alter", "drop", "rename", "truncate":
+		return true
+	}
+	return false
+}
+
+// report a failed check at the given level.
+func (m *Migrator) report(level CheckLevel, format string, v ...any) error {
+	switch level {
+	case CheckIgnore:
+		return nil
+	case CheckError:
+		return fmt.Errorf("error checking migration files: "+format, v...)
+	default:
+		m.logger.Printf("migrate: warning: "+format, v...)
+		return nil
+	}
+}
@@ -0,0 +1,171 @@
+package migrate_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+type recordingLogger struct {
+	lines []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...any) {
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestMigrator_MissingDown(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1.up.sql":   {Data: []byte("create table a (v int)")},
+		"1.down.sql": {Data: []byte("drop table a")},
+		"2.up.sql":   {Data: []byte("create table b (v int)")},
+	}
+
+	t.Run("warns about up migrations without a down migration file with check warn", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		l := &recordingLogger{}
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, Logger: l, MissingDown: migrate.CheckWarn})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		is.Equal(t, 1, len(l.lines))
+		is.Equal(t, "migrate: warning: 2.up.sql has no down migration file 2.down.sql", l.lines[0])
+		is.Equal(t, "2", getVersion(t, db))
+	})
+
+	t.Run("errors before applying anything with check error", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, MissingDown: migrate.CheckError})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, "error migrating up: error checking migration files: 2.up.sql has no down migration file 2.down.sql", err.Error())
+		is.Equal(t, "", getVersion(t, db))
+	})
+
+	t.Run("does not check by default", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		l := &recordingLogger{}
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, Logger: l})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, 0, len(l.lines))
+	})
+}
+
+func TestMigrator_EmptyFile(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1.up.sql":   {Data: []byte("create table a (v int)")},
+		"1.down.sql": {Data: []byte("drop table a")},
+		"2.up.sql":   {Data: []byte(" \n\t\n")},
+		"2.down.sql": {Data: []byte("")},
+	}
+
+	t.Run("warns about empty migration files with check warn", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		l := &recordingLogger{}
+		m := migrate.New(migrate.Options{DB: db, EmptyFile: migrate.CheckWarn, FS: fsys, Logger: l})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "[migrate: warning: 2.up.sql is empty]", fmt.Sprint(l.lines))
+
+		err = m.MigrateDown(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "[migrate: warning: 2.up.sql is empty migrate: warning: 2.down.sql is empty]", fmt.Sprint(l.lines))
+	})
+
+	t.Run("errors before applying anything with check error", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, EmptyFile: migrate.CheckError, FS: fsys})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, "error migrating up: error checking migration files: 2.up.sql is empty", err.Error())
+		is.Equal(t, "", getVersion(t, db))
+	})
+}
+
+func TestMigrator_ImplicitCommit(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1.up.sql":   {Data: []byte("create table a (v int)")},
+		"1.down.sql": {Data: []byte("drop table a")},
+		"2.up.sql":   {Data: []byte("insert into a values (1);\n-- add a column\nALTER\ttable a add column w int;")},
+		"2.down.sql": {Data: []byte("delete from a")},
+	}
+
+	t.Run("warns about DDL with other statements if the dialect doesn't support transactional DDL", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		l := &recordingLogger{}
+		m := migrate.New(migrate.Options{DB: db, Dialect: noTransactionalDDLDialect{Dialect: migrate.SQLite}, FS: fsys,
+			ImplicitCommit: migrate.CheckWarn, Logger: l})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, 1, len(l.lines))
+		is.Equal(t, "migrate: warning: 2.up.sql has DDL with other statements, and the database commits DDL implicitly, "+
+			"so if it fails, the statements before the failing one stay applied", l.lines[0])
+	})
+
+	t.Run("does not warn if the dialect supports transactional DDL", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		l := &recordingLogger{}
+		m := migrate.New(migrate.Options{DB: db, Dialect: migrate.SQLite, FS: fsys, ImplicitCommit: migrate.CheckWarn, Logger: l})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, 0, len(l.lines))
+	})
+
+	t.Run("errors with check error", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{
+			DB:             db,
+			Dialect:        noTransactionalDDLDialect{Dialect: migrate.SQLite},
+			FS:             fsys,
+			ImplicitCommit: migrate.CheckError,
+		})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, "", getVersion(t, db))
+	})
+}
+
+func TestMigrator_NumberCollision(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1700000000-accounts.up.sql":   {Data: []byte("create table accounts (v int)")},
+		"1700000000-accounts.down.sql": {Data: []byte("drop table accounts")},
+		"1700000000-users.up.sql":      {Data: []byte("create table users (v int)")},
+		"1700000000-users.down.sql":    {Data: []byte("drop table users")},
+	}
+
+	t.Run("warns about up migration files with the same number with check warn", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		l := &recordingLogger{}
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, Logger: l, NumberCollision: migrate.CheckWarn})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "[migrate: warning: 1700000000-accounts.up.sql and 1700000000-users.up.sql have the same number 1700000000]",
+			fmt.Sprint(l.lines))
+	})
+
+	t.Run("errors before applying anything with check error", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, NumberCollision: migrate.CheckError})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, "error migrating up: error checking migration files: "+
+			"1700000000-accounts.up.sql and 1700000000-users.up.sql have the same number 1700000000", err.Error())
+		is.Equal(t, "", getVersion(t, db))
+	})
+}
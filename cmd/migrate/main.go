@@ -1,26 +1,168 @@
 package main
 
 import (
+	"context"
+	"crypto/ed25519"
+	"database/sql"
+	"encoding/hex"
 	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
-	"path"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
 	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v4/stdlib"
+	_ "github.com/mattn/go-sqlite3"
+
+	"maragu.dev/migrate"
+	"maragu.dev/migrate/analyze"
+	"maragu.dev/migrate/bundle"
+	"maragu.dev/migrate/migratetest"
+	"maragu.dev/migrate/schema"
 )
 
+const usage = `Usage:
+  migrate analyze <dir>
+  migrate archive <dir> <version>
+  migrate [-sequence] [-template-dir <dir>] [-template <name>] create <dir> <name>
+  migrate bundle-keygen <name>
+  migrate invert <up file>
+  migrate renumber <dir>
+  migrate sum <dir>
+  migrate bundle -key <private key file> [-from <version>] [-to <version>] <dir> <bundle file>
+  migrate -driver <pgx|mysql|sqlite3> -dsn <dsn> [-table <table>] apply-bundle -key <public key file> <bundle file>
+  migrate -driver <pgx|mysql|sqlite3> -dsn <dsn> [-table <table>] changelog [-html] <dir>
+  migrate -driver <pgx|mysql|sqlite3> -dsn <dsn> [-table <table>] [-sequence] diff <schema.sql> <dir> <name>
+  migrate -driver <pgx|mysql|sqlite3> -dsn <dsn> [-table <table>] doctor <dir>
+  migrate -driver <pgx|mysql|sqlite3> -dsn <dsn> [-table <table>] down -since <duration> <dir>
+  migrate -driver <pgx|mysql|sqlite3> -dsn <dsn> [-table <table>] reconcile <dir>
+  migrate -driver <pgx|mysql|sqlite3> -dsn <dsn> [-table <table>] run [-wait <duration>] <dir>
+  migrate -driver <pgx|mysql|sqlite3> -dsn <dsn> [-table <table>] script [-to <version>] <dir>
+  migrate -driver <pgx|mysql|sqlite3> -dsn <dsn> [-table <table>] stats [-slowest <n>] <dir>
+  migrate -driver <pgx|mysql|sqlite3> -dsn <dsn> [-table <table>] status [-durations <dsn>] <dir>
+  migrate -driver <pgx|mysql|sqlite3> -dsn <dsn> [-table <table>] to [-time <time>] <dir> [<version>]
+  migrate [-driver <pgx|mysql|sqlite3>] [-dsn <dsn>] [-table <table>] test [-docker <image>] [-queries <dir>] <dir>
+  migrate -driver <pgx|mysql|sqlite3> -dsn <dsn> [-table <table>] up [-fake <version>] <dir>
+
+The run command logs JSON lines and exits with 0 if the migrations are applied, 1 if a migration failed,
+2 on a usage error, and 3 if the database did not accept connections in time.
+
+The test command migrates a throwaway database up, down, and up again, one migration at a time, checking that
+each down migration reverts its up migration. It uses a temporary SQLite database, a database in a new Docker
+container from the image with -docker, or the database at -dsn, which must be a throwaway database.
+With -queries, it then checks that the queries in the .sql files of that directory, like sqlc's, are valid
+against the migrated schema.
+
+With -template-dir, the create command fills the new files from the template named with -template in that directory,
+with a <name>.up.sql and an optional <name>.down.sql file for each template, like table, index, or backfill.
+Without -template, it uses the template named default, if there is one.
+
+The doctor command checks the database and migrations for problems that need fixing by hand, like a dirty version,
+changed or missing migration files, or a stuck lock, and prints how to fix each. It exits with an error if it finds any.`
+
+// dialects by driver name.
+var dialects = map[string]string{
+	"mysql":   "mysql",
+	"pgx":     "postgres",
+	"sqlite3": "sqlite",
+}
+
 func main() {
 	log := log.New(os.Stderr, "", 0)
+	driver := flag.String("driver", "", "database driver for commands that connect to the database, one of pgx, mysql, or sqlite3")
+	dsn := flag.String("dsn", "", "data source name for the database driver")
+	sequence := flag.Bool("sequence", false, "number new migrations in sequence instead of with a timestamp")
+	table := flag.String("table", "", "migrations table, if not the default")
+	templateName := flag.String("template", "", "name of the template in the template directory for new migrations")
+	templateDir := flag.String("template-dir", "", "directory with named templates for new migrations")
 	flag.Parse()
-	if flag.NArg() < 3 {
-		log.Fatalln("Usage: migrate create <dir> <name>")
+	if flag.NArg() < 1 {
+		log.Fatalln(usage)
 	}
 
 	var err error
 	switch flag.Arg(0) {
+	case "analyze":
+		if flag.NArg() < 2 {
+			log.Fatalln(usage)
+		}
+		err = analyzeMigrations(flag.Arg(1))
+	case "apply-bundle":
+		err = applyBundle(*driver, *dsn, *table, flag.Args()[1:])
+	case "archive":
+		if flag.NArg() < 3 {
+			log.Fatalln(usage)
+		}
+		err = archive(flag.Arg(1), flag.Arg(2))
+	case "bundle":
+		err = writeBundle(flag.Args()[1:])
+	case "bundle-keygen":
+		if flag.NArg() < 2 {
+			log.Fatalln(usage)
+		}
+		err = bundleKeygen(flag.Arg(1))
+	case "changelog":
+		err = changelog(*driver, *dsn, *table, flag.Args()[1:])
 	case "create":
-		err = create(flag.Arg(1), flag.Arg(2))
+		if flag.NArg() < 3 {
+			log.Fatalln(usage)
+		}
+		err = create(flag.Arg(1), flag.Arg(2), *sequence, *templateDir, *templateName)
+	case "diff":
+		if flag.NArg() < 4 {
+			log.Fatalln(usage)
+		}
+		err = diff(*driver, *dsn, *table, flag.Arg(1), flag.Arg(2), flag.Arg(3), *sequence)
+	case "doctor":
+		if flag.NArg() < 2 {
+			log.Fatalln(usage)
+		}
+		err = doctor(*driver, *dsn, *table, flag.Arg(1))
+	case "down":
+		err = down(*driver, *dsn, *table, flag.Args()[1:])
+	case "invert":
+		if flag.NArg() < 2 {
+			log.Fatalln(usage)
+		}
+		err = invert(flag.Arg(1))
+	case "reconcile":
+		if flag.NArg() < 2 {
+			log.Fatalln(usage)
+		}
+		err = reconcile(*driver, *dsn, *table, flag.Arg(1))
+	case "renumber":
+		if flag.NArg() < 2 {
+			log.Fatalln(usage)
+		}
+		err = renumber(flag.Arg(1))
+	case "run":
+		os.Exit(run(*driver, *dsn, *table, flag.Args()[1:]))
+	case "script":
+		err = script(*driver, *dsn, *table, flag.Args()[1:])
+	case "stats":
+		err = stats(*driver, *dsn, *table, flag.Args()[1:])
+	case "status":
+		err = status(*driver, *dsn, *table, flag.Args()[1:])
+	case "sum":
+		if flag.NArg() < 2 {
+			log.Fatalln(usage)
+		}
+		err = migrate.WriteSumFile(flag.Arg(1))
+	case "test":
+		err = test(*driver, *dsn, *table, flag.Args()[1:])
+	case "to":
+		err = to(*driver, *dsn, *table, flag.Args()[1:])
+	case "up":
+		err = up(*driver, *dsn, *table, flag.Args()[1:])
 	default:
 		err = errors.New("unknown command " + flag.Arg(0))
 	}
@@ -29,17 +171,713 @@ func main() {
 	}
 }
 
-func create(dir, name string) error {
-	now := time.Now().Unix()
-	prefix := fmt.Sprintf("%v-%v", now, name)
-	for _, suffix := range []string{".up.sql", ".down.sql"} {
-		f, err := os.Create(path.Join(dir, prefix+suffix))
+func analyzeMigrations(dir string) error {
+	files, err := migrate.AnalyzeFiles(os.DirFS(dir))
+	if err != nil {
+		return err
+	}
+	findings := analyze.Analyze(files, analyze.Rules()...)
+	for _, f := range findings {
+		fmt.Println(f)
+	}
+	if len(findings) > 0 {
+		return fmt.Errorf("%v findings", len(findings))
+	}
+	return nil
+}
+
+func applyBundle(driver, dsn, table string, args []string) error {
+	flags := flag.NewFlagSet("apply-bundle", flag.ExitOnError)
+	keyPath := flags.String("key", "", "file with the hex-encoded public key to verify the bundle with")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 1 || *keyPath == "" {
+		return errors.New("apply-bundle needs -key and a bundle file\n" + usage)
+	}
+
+	key, err := readKey(*keyPath, ed25519.PublicKeySize)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	opts, closeDB, err := newOptions(driver, dsn, table)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	return bundle.Apply(context.Background(), f, key, opts)
+}
+
+func archive(dir, version string) error {
+	baselinePath, err := migrate.Archive(dir, version)
+	if err != nil {
+		return err
+	}
+	fmt.Println(baselinePath)
+	return nil
+}
+
+func writeBundle(args []string) error {
+	flags := flag.NewFlagSet("bundle", flag.ExitOnError)
+	from := flags.String("from", "", "bundle the migrations after this version, like the version in production")
+	keyPath := flags.String("key", "", "file with the hex-encoded private key to sign the bundle with")
+	to := flags.String("to", "", "bundle the migrations up to and including this version, instead of the newest")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 2 || *keyPath == "" {
+		return errors.New("bundle needs -key, a directory, and a bundle file\n" + usage)
+	}
+
+	key, err := readKey(*keyPath, ed25519.PrivateKeySize)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	manifest, err := bundle.Write(f, os.DirFS(flags.Arg(0)), *from, *to, key)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Bundled %v files after version %q up to %v.\n", len(manifest.Files), manifest.From, manifest.To)
+	return nil
+}
+
+// bundleKeygen writes a hex-encoded key pair to name.key and name.pub.
+func bundleKeygen(name string) error {
+	public, private, err := bundle.GenerateKey()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(name+".key", []byte(hex.EncodeToString(private)+"\n"), 0o600); err != nil {
+		return err
+	}
+	if err := os.WriteFile(name+".pub", []byte(hex.EncodeToString(public)+"\n"), 0o644); err != nil {
+		return err
+	}
+	fmt.Println(name + ".key")
+	fmt.Println(name + ".pub")
+	return nil
+}
+
+// readKey from a file with a hex-encoded key of the given size.
+func readKey(path string, size int) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(b)))
+	if err != nil || len(key) != size {
+		return nil, errors.New("invalid key in " + path)
+	}
+	return key, nil
+}
+
+func changelog(driver, dsn, table string, args []string) error {
+	flags := flag.NewFlagSet("changelog", flag.ExitOnError)
+	html := flags.Bool("html", false, "write HTML instead of Markdown")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 1 {
+		return errors.New("changelog needs a directory\n" + usage)
+	}
+
+	m, _, closeDB, err := newMigrator(driver, dsn, table, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	format := migrate.Markdown
+	if *html {
+		format = migrate.HTML
+	}
+	return m.WriteChangelog(context.Background(), os.Stdout, format)
+}
+
+func create(dir, name string, sequence bool, templateDir, template string) error {
+	opts := migrate.CreateOptions{Template: template, TemplateDir: templateDir}
+	if sequence {
+		opts.Numbering = migrate.Sequence
+	}
+	upPath, downPath, err := migrate.Create(dir, name, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Println(upPath)
+	fmt.Println(downPath)
+	return nil
+}
+
+// invert the statements of the up file into its down file, if that's empty, and print the path of the down file.
+func invert(upPath string) error {
+	if !strings.HasSuffix(upPath, ".up.sql") {
+		return errors.New(upPath + " is not an up migration file")
+	}
+	up, err := os.ReadFile(upPath)
+	if err != nil {
+		return err
+	}
+
+	downPath := strings.TrimSuffix(upPath, ".up.sql") + ".down.sql"
+	existing, err := os.ReadFile(downPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if strings.TrimSpace(string(existing)) != "" {
+		return errors.New(downPath + " already has content")
+	}
+
+	down, ok := schema.Invert(string(up))
+	if err := os.WriteFile(downPath, []byte(down), 0644); err != nil {
+		return err
+	}
+	fmt.Println(downPath)
+	if !ok {
+		return errors.New("some statements couldn't be inverted, see the TODO comments in " + downPath)
+	}
+	return nil
+}
+
+// diff the database against the desired schema file, and create migrations in dir with the difference.
+// The database must be migrated with the migrations in dir first, like a development database, and is only read.
+func diff(driver, dsn, table, schemaPath, dir, name string, sequence bool) error {
+	desired, err := os.ReadFile(schemaPath)
+	if err != nil {
+		return err
+	}
+
+	m, db, closeDB, err := newMigrator(driver, dsn, table, dir)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	ctx := context.Background()
+	ok, err := m.IsUpToDate(ctx)
+	if err != nil {
+		return fmt.Errorf("migrate the database up first: %w", err)
+	}
+	if !ok {
+		return errors.New("the database has pending migrations, so the new ones would repeat them; migrate it up first")
+	}
+
+	if table == "" {
+		table = "migrations"
+	}
+	var opts migrate.CreateOptions
+	if sequence {
+		opts.Numbering = migrate.Sequence
+	}
+	upPath, downPath, err := schema.Generate(ctx, db, dialectOf(driver), string(desired), dir, name, opts, table, table+"_audit", table+"_history")
+	if errors.Is(err, schema.ErrNoChanges) {
+		fmt.Println("No changes.")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println(upPath)
+	fmt.Println(downPath)
+	return nil
+}
+
+// doctor prints the problems with the state of the migrations, and how to fix them.
+func doctor(driver, dsn, table, dir string) error {
+	m, _, closeDB, err := newMigrator(driver, dsn, table, dir)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	diagnoses, err := m.Diagnose(context.Background())
+	if err != nil {
+		return err
+	}
+	for _, d := range diagnoses {
+		fmt.Println(d.Problem)
+		fmt.Println("  Fix:", d.Remedy)
+	}
+	if len(diagnoses) > 0 {
+		return fmt.Errorf("%v problems", len(diagnoses))
+	}
+	return nil
+}
+
+func down(driver, dsn, table string, args []string) error {
+	flags := flag.NewFlagSet("down", flag.ExitOnError)
+	since := flags.Duration("since", 0, "revert the migrations applied within this duration, like 2h")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 1 || *since <= 0 {
+		return errors.New("down needs -since and a directory\n" + usage)
+	}
+
+	m, _, closeDB, err := newMigrator(driver, dsn, table, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	return m.DownSince(context.Background(), time.Now().Add(-*since))
+}
+
+// reconcile the version with the migrations applied out of band with a script, and print their names.
+func reconcile(driver, dsn, table, dir string) error {
+	m, _, closeDB, err := newMigrator(driver, dsn, table, dir)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	names, err := m.Reconcile(context.Background())
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		fmt.Println(name)
+	}
+	return nil
+}
+
+// renumber the migrations in dir in sequence, and print the old and new versions.
+func renumber(dir string) error {
+	mapping, err := migrate.Renumber(dir)
+	if err != nil {
+		return err
+	}
+	var olds []string
+	for old := range mapping {
+		olds = append(olds, old)
+	}
+	sort.Strings(olds)
+	for _, old := range olds {
+		fmt.Println(old, "->", mapping[old])
+	}
+	return nil
+}
+
+// Exit codes of the run command.
+const (
+	exitFailed      = 1
+	exitUsage       = 2
+	exitUnavailable = 3
+)
+
+// run migrations up for a Kubernetes init container or Job, returning the exit code.
+func run(driver, dsn, table string, args []string) int {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	wait := flags.Duration("wait", time.Minute, "how long to wait for the database to accept connections")
+	if err := flags.Parse(args); err != nil || flags.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "run needs a directory\n"+usage)
+		return exitUsage
+	}
+
+	opts, closeDB, err := newOptions(driver, dsn, table)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return exitUsage
+	}
+	defer closeDB()
+	opts.FS = os.DirFS(flags.Arg(0))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err = migrate.RunOnce(ctx, migrate.RunConfig{Options: opts, Wait: *wait})
+	switch {
+	case errors.Is(err, migrate.ErrDatabaseUnavailable):
+		return exitUnavailable
+	case err != nil:
+		return exitFailed
+	}
+	return 0
+}
+
+func script(driver, dsn, table string, args []string) error {
+	flags := flag.NewFlagSet("script", flag.ExitOnError)
+	to := flags.String("to", "", "write the migrations up to and including this version, instead of the newest")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 1 {
+		return errors.New("script needs a directory\n" + usage)
+	}
+
+	m, _, closeDB, err := newMigrator(driver, dsn, table, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	return m.Script(context.Background(), os.Stdout, *to)
+}
+
+func test(driver, dsn, table string, args []string) error {
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	image := flags.String("docker", "", "Docker image to run the throwaway database in, like postgres:16 or mysql:8")
+	queriesDir := flags.String("queries", "", "Directory of .sql query files to check against the migrated schema")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 1 {
+		return errors.New("test needs a directory\n" + usage)
+	}
+	if driver == "" {
+		driver = "sqlite3"
+	}
+
+	switch {
+	case *image != "":
+		var stop func()
+		var err error
+		dsn, stop, err = startContainer(driver, *image)
+		if err != nil {
+			return err
+		}
+		defer stop()
+	case dsn == "" && driver == "sqlite3":
+		dir, err := os.MkdirTemp("", "migrate-test")
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = os.RemoveAll(dir)
+		}()
+		dsn = filepath.Join(dir, "test.sqlite")
+	case dsn == "":
+		return errors.New("test needs -dsn or -docker for driver " + driver)
+	}
+
+	opts, closeDB, err := newOptions(driver, dsn, table)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	opts.FS = os.DirFS(flags.Arg(0))
+	opts.EmptyFile, opts.ImplicitCommit, opts.MissingDown = migrate.CheckError, migrate.CheckError, migrate.CheckError
+
+	ctx := context.Background()
+	if err := waitForDatabase(ctx, opts.DB); err != nil {
+		return err
+	}
+	if err := migratetest.UpDownUp(ctx, opts); err != nil {
+		return err
+	}
+	if *queriesDir != "" {
+		queries, err := migratetest.ReadQueries(os.DirFS(*queriesDir))
 		if err != nil {
 			return err
 		}
-		if err := f.Close(); err != nil {
+		if err := migratetest.CheckQueries(ctx, opts.DB, queries); err != nil {
 			return err
 		}
 	}
+	fmt.Println("OK")
 	return nil
 }
+
+func to(driver, dsn, table string, args []string) error {
+	flags := flag.NewFlagSet("to", flag.ExitOnError)
+	at := flags.String("time", "", "migrate to the newest version created at or before this time, like 2025-06-01T00:00, in UTC")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 1 || (*at == "") == (flags.NArg() < 2) {
+		return errors.New("to needs a directory, and either -time or a version\n" + usage)
+	}
+
+	m, _, closeDB, err := newMigrator(driver, dsn, table, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	if *at == "" {
+		return m.MigrateTo(context.Background(), flags.Arg(1))
+	}
+
+	var t time.Time
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"} {
+		if t, err = time.Parse(layout, *at); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("error parsing time %v: %w", *at, err)
+	}
+	return m.MigrateToTime(context.Background(), t)
+}
+
+func up(driver, dsn, table string, args []string) error {
+	flags := flag.NewFlagSet("up", flag.ExitOnError)
+	fake := flags.String("fake", "", "record the next migration with this version as applied without running it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 1 {
+		return errors.New("up needs a directory\n" + usage)
+	}
+
+	m, _, closeDB, err := newMigrator(driver, dsn, table, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	if *fake != "" {
+		return m.Skip(context.Background(), *fake)
+	}
+	return m.MigrateUp(context.Background())
+}
+
+// startContainer for a throwaway database with the Docker image, returning the DSN and a function to remove it.
+func startContainer(driver, image string) (string, func(), error) {
+	var args []string
+	var port string
+	switch driver {
+	case "pgx":
+		args, port = []string{"-e", "POSTGRES_PASSWORD=test"}, "5432/tcp"
+	case "mysql":
+		args, port = []string{"-e", "MYSQL_ROOT_PASSWORD=test", "-e", "MYSQL_DATABASE=test"}, "3306/tcp"
+	default:
+		return "", nil, errors.New("-docker needs driver pgx or mysql")
+	}
+
+	out, err := exec.Command("docker", append(append([]string{"run", "-d", "--rm", "-P"}, args...), image)...).Output()
+	if err != nil {
+		return "", nil, fmt.Errorf("error starting container: %w", err)
+	}
+	id := strings.TrimSpace(string(out))
+	stop := func() {
+		_ = exec.Command("docker", "rm", "-f", id).Run()
+	}
+
+	out, err = exec.Command("docker", "port", id, port).Output()
+	if err != nil {
+		stop()
+		return "", nil, fmt.Errorf("error getting container port: %w", err)
+	}
+	// The first line is like 0.0.0.0:49153
+	addr, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	addr = strings.Replace(addr, "0.0.0.0", "127.0.0.1", 1)
+
+	if driver == "pgx" {
+		return "postgres://postgres:test@" + addr + "/postgres?sslmode=disable", stop, nil
+	}
+	return "root:test@tcp(" + addr + ")/test?multiStatements=true", stop, nil
+}
+
+// waitForDatabase to accept connections for up to a minute, like after starting a container.
+func waitForDatabase(ctx context.Context, db *sql.DB) error {
+	deadline := time.Now().Add(time.Minute)
+	for {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("error waiting for database: %w", err)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// stats prints an overview of the migrations: how many are applied and pending, the last applied,
+// and the slowest from the history.
+func stats(driver, dsn, table string, args []string) error {
+	flags := flag.NewFlagSet("stats", flag.ExitOnError)
+	slowest := flags.Int("slowest", 5, "number of the slowest applied migrations to print")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 1 {
+		return errors.New("stats needs a directory\n" + usage)
+	}
+
+	m, _, closeDB, err := newMigrator(driver, dsn, table, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	ctx := context.Background()
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	var applied []migrate.MigrationStatus
+	for _, s := range statuses {
+		if s.Applied {
+			applied = append(applied, s)
+		}
+	}
+
+	// The most recently applied migration, which isn't the last in order after out-of-order or parallel runs
+	last, err := m.LastApplied(ctx)
+	if err != nil {
+		return err
+	}
+	// Migrations applied before the history existed have no times
+	if last.Version == "" && len(applied) > 0 {
+		last.Version = applied[len(applied)-1].Version
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Migrations:\t%v\n", len(statuses))
+	fmt.Fprintf(w, "Applied:\t%v\n", len(applied))
+	fmt.Fprintf(w, "Pending:\t%v\n", len(statuses)-len(applied))
+	if last.Version != "" {
+		lastApplied := last.Version
+		if !last.AppliedAt.IsZero() {
+			lastApplied += " at " + last.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "Last applied:\t%v\n", lastApplied)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	sort.SliceStable(applied, func(i, j int) bool {
+		return applied[i].Duration > applied[j].Duration
+	})
+	if len(applied) > *slowest {
+		applied = applied[:*slowest]
+	}
+	if len(applied) == 0 || applied[0].Duration == 0 {
+		return nil
+	}
+	fmt.Println("\nSlowest:")
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, s := range applied {
+		if s.Duration == 0 {
+			break
+		}
+		fmt.Fprintf(w, "  %v\t%v\n", s.Version, s.Duration.Round(time.Millisecond))
+	}
+	return w.Flush()
+}
+
+func status(driver, dsn, table string, args []string) error {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	durationsDSN := flags.String("durations", "", "data source name of a database, like staging, with the durations of the pending migrations")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 1 {
+		return errors.New("status needs a directory\n" + usage)
+	}
+
+	m, _, closeDB, err := newMigrator(driver, dsn, table, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	ctx := context.Background()
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	var recorded []migrate.AppliedMigration
+	if *durationsDSN != "" {
+		other, _, closeOther, err := newMigrator(driver, *durationsDSN, table, flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer closeOther()
+		if recorded, err = other.Applied(ctx); err != nil {
+			return err
+		}
+	}
+	estimate, err := m.Estimate(ctx, recorded)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tAPPLIED\tDESCRIPTION")
+	for _, s := range statuses {
+		applied := "no"
+		switch {
+		case !s.AppliedAt.IsZero():
+			applied = s.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		case s.Applied:
+			applied = "yes"
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\n", s.Version, applied, s.Description)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(estimate.Pending) > 0 {
+		fmt.Printf("\n%v pending, estimated %v", len(estimate.Pending), estimate.Remaining.Round(time.Second))
+		if len(estimate.Unknown) > 0 {
+			fmt.Printf(", with %v without a recorded duration", len(estimate.Unknown))
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// newMigrator with history for the migrations in dir, returning the database and a function to close it.
+func newMigrator(driver, dsn, table, dir string) (*migrate.Migrator, *sql.DB, func(), error) {
+	opts, closeDB, err := newOptions(driver, dsn, table)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	opts.FS = os.DirFS(dir)
+	return migrate.New(opts), opts.DB, closeDB, nil
+}
+
+// newOptions with history and a database, without the FS, returning a function to close the database.
+func newOptions(driver, dsn, table string) (migrate.Options, func(), error) {
+	dialect := dialectOf(driver)
+	if dialect == nil {
+		return migrate.Options{}, nil, errors.New("unknown driver " + driver)
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return migrate.Options{}, nil, err
+	}
+
+	opts := migrate.Options{
+		DB:      db,
+		Dialect: dialect,
+		History: true,
+		Table:   table,
+	}
+	return opts, func() { _ = db.Close() }, nil
+}
+
+// dialectOf the driver, or nil if it's unknown.
+func dialectOf(driver string) migrate.Dialect {
+	dialectName, ok := dialects[driver]
+	if !ok {
+		return nil
+	}
+	dialect, _ := migrate.LookupDialect(dialectName)
+	return dialect
+}
@@ -0,0 +1,162 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// VersionColumns of the version table, to adopt an existing version table from another tool instead of creating
+// a parallel one. Set Options.Table to the name of the existing table, and Options.VersionColumns to its columns.
+// With VersionColumns, the version table has a single row, or none if no migrations are applied,
+// and each version update deletes the row and inserts a new one.
+type VersionColumns struct {
+	// Dirty column, a boolean, which is set to false with each version update.
+	// If it's true, like after a failed migration with the other tool, getting the version errors.
+	Dirty string
+	// Numeric stores only the number at the start of the version in an integer column, and finds the version
+	// from the migration file with that number, like for golang-migrate, which doesn't store names.
+	Numeric bool
+	// UpdatedAt column, a timestamp, which is set to the current time with each version update.
+	UpdatedAt string
+	// Version column. It must be set to use VersionColumns.
+	Version string
+}
+
+// GolangMigrateColumns of the schema_migrations table of golang-migrate, which has a numeric version
+// and a dirty flag. Set Options.Table to "schema_migrations" to adopt it.
+// Migration files named like golang-migrate's, like "1_accounts.up.sql", work as is.
+var GolangMigrateColumns = VersionColumns{Dirty: "dirty", Numeric: true, Version: "version"}
+
+var columnMatcher = regexp.MustCompile(`^\w+$`)
+
+// validate the column names, returning an error for the first illegal one.
+func (c VersionColumns) validate() error {
+	for _, column := range []string{c.Version, c.Dirty, c.UpdatedAt} {
+		if column != "" && !columnMatcher.MatchString(column) {
+			return errors.New("illegal column name " + column + ", must match " + columnMatcher.String())
+		}
+	}
+	if c.Version == "" && (c.Dirty != "" || c.UpdatedAt != "" || c.Numeric) {
+		return errors.New("version column must be set")
+	}
+	return nil
+}
+
+// createVersionTableWithColumns returns SQL to create the version table with the VersionColumns.
+func (m *Migrator) createVersionTableWithColumns() string {
+	versionType := "text"
+	if m.columns.Numeric {
+		versionType = "bigint"
+	}
+	columns := m.dialect.Quote(m.columns.Version) + " " + versionType + " not null"
+	if m.columns.Dirty != "" {
+		columns += ", " + m.dialect.Quote(m.columns.Dirty) + " boolean not null"
+	}
+	if m.columns.UpdatedAt != "" {
+		columns += ", " + m.dialect.Quote(m.columns.UpdatedAt) + " timestamp"
+	}
+	return `create table if not exists ` + m.dialect.Quote(m.table) + ` (` + columns + `)`
+}
+
+// selectVersionWithColumns from the version table with the VersionColumns, or the empty string if it has no row.
+func (m *Migrator) selectVersionWithColumns(ctx context.Context, q queryer) (string, error) {
+	columns := m.dialect.Quote(m.columns.Version)
+	if m.columns.Dirty != "" {
+		columns += ", " + m.dialect.Quote(m.columns.Dirty)
+	}
+
+	var version string
+	var number int64
+	var dirty bool
+	dest := []any{&version}
+	if m.columns.Numeric {
+		dest = []any{&number}
+	}
+	if m.columns.Dirty != "" {
+		dest = append(dest, &dirty)
+	}
+
+	err := q.QueryRowContext(ctx, `select `+columns+` from `+m.dialect.Quote(m.table)).Scan(dest...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if m.columns.Numeric {
+		if version, err = m.versionOfNumber(number); err != nil {
+			return "", err
+		}
+	}
+	if dirty {
+		return "", fmt.Errorf("version %v is dirty, so fix the database and set %v to false", version, m.columns.Dirty)
+	}
+	return version, nil
+}
+
+// versionOfNumber finds the version of the up migration file with the number.
+func (m *Migrator) versionOfNumber(number int64) (string, error) {
+	names, err := m.getFilenames(upMatcher)
+	if err != nil {
+		return "", err
+	}
+	for _, name := range names {
+		n, err := strconv.ParseInt(numberMatcher.FindString(name), 10, 64)
+		if err == nil && n == number {
+			return upMatcher.ReplaceAllString(name, "$1"), nil
+		}
+	}
+	return "", fmt.Errorf("error finding migration file with number %v", number)
+}
+
+// updateVersionWithColumns returns SQL statements to set the version in the version table with the VersionColumns,
+// with the values as literals.
+func (m *Migrator) updateVersionWithColumns(version string) ([]string, error) {
+	table := m.dialect.Quote(m.table)
+	statements := []string{`delete from ` + table}
+	if version == "" {
+		return statements, nil
+	}
+
+	value := quoteString(m.dialect, version)
+	if m.columns.Numeric {
+		number := numberMatcher.FindString(version)
+		if number == "" {
+			return nil, fmt.Errorf("version %v has no number", version)
+		}
+		value = strings.TrimLeft(number, "0")
+		if value == "" {
+			value = "0"
+		}
+	}
+
+	columns, values := m.dialect.Quote(m.columns.Version), value
+	if m.columns.Dirty != "" {
+		columns += ", " + m.dialect.Quote(m.columns.Dirty)
+		values += ", false"
+	}
+	if m.columns.UpdatedAt != "" {
+		columns += ", " + m.dialect.Quote(m.columns.UpdatedAt)
+		values += ", current_timestamp"
+	}
+	return append(statements, `insert into `+table+` (`+columns+`) values (`+values+`)`), nil
+}
+
+// literalVersionUpdate returns SQL to set the version, with the version as a literal, like for scripts.
+// It may be more than one statement, separated by semicolons.
+func (m *Migrator) literalVersionUpdate(version string) (string, error) {
+	if m.columns.Version == "" {
+		return `update ` + m.dialect.Quote(m.table) + ` set version = ` + quoteString(m.dialect, version), nil
+	}
+	statements, err := m.updateVersionWithColumns(version)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(statements, ";\n"), nil
+}
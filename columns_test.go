@@ -0,0 +1,112 @@
+package migrate_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestMigrator_VersionColumns(t *testing.T) {
+	t.Run("adopts a golang-migrate table", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		_, err := db.Exec(`create table schema_migrations (version bigint not null primary key, dirty boolean not null);
+			insert into schema_migrations values (1, false);
+			create table accounts (id int)`)
+		is.NotError(t, err)
+
+		fsys := fstest.MapFS{
+			"1_accounts.up.sql":   {Data: []byte("create table accounts (id int);")},
+			"1_accounts.down.sql": {Data: []byte("drop table accounts;")},
+			"2_users.up.sql":      {Data: []byte("create table users (id int);")},
+			"2_users.down.sql":    {Data: []byte("drop table users;")},
+		}
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, Table: "schema_migrations", VersionColumns: migrate.GolangMigrateColumns})
+
+		err = m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		var version int64
+		var dirty bool
+		err = db.QueryRow(`select version, dirty from schema_migrations`).Scan(&version, &dirty)
+		is.NotError(t, err)
+		is.Equal(t, int64(2), version)
+		is.True(t, !dirty)
+
+		err = m.MigrateDown(context.Background())
+		is.NotError(t, err)
+
+		var count int
+		err = db.QueryRow(`select count(*) from schema_migrations`).Scan(&count)
+		is.NotError(t, err)
+		is.Equal(t, 0, count)
+	})
+
+	t.Run("errors on a dirty golang-migrate table", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		_, err := db.Exec(`create table schema_migrations (version bigint not null primary key, dirty boolean not null);
+			insert into schema_migrations values (1, true)`)
+		is.NotError(t, err)
+
+		fsys := fstest.MapFS{
+			"1_accounts.up.sql": {Data: []byte("create table accounts (id int);")},
+		}
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, Table: "schema_migrations", VersionColumns: migrate.GolangMigrateColumns})
+
+		err = m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.True(t, strings.Contains(err.Error(), "version 1_accounts is dirty, so fix the database and set dirty to false"))
+	})
+
+	t.Run("adopts a table with custom columns and writes scripts for it", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		_, err := db.Exec(`create table schema_version (installed_version varchar(255) not null, installed_on timestamp);
+			insert into schema_version values ('1-accounts', current_timestamp)`)
+		is.NotError(t, err)
+
+		fsys := fstest.MapFS{
+			"1-accounts.up.sql": {Data: []byte("create table accounts (id int);")},
+			"2-users.up.sql":    {Data: []byte("create table users (id int);")},
+		}
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, MissingDown: migrate.CheckIgnore, Table: "schema_version",
+			VersionColumns: migrate.VersionColumns{UpdatedAt: "installed_on", Version: "installed_version"}})
+
+		var b strings.Builder
+		err = m.Script(context.Background(), &b, "")
+		is.NotError(t, err)
+		is.True(t, strings.Contains(b.String(), "delete from schema_version;\n"+
+			"insert into schema_version (installed_version, installed_on) values ('2-users', current_timestamp);\n"))
+
+		err = m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		var version string
+		var installedOn *string
+		err = db.QueryRow(`select installed_version, installed_on from schema_version`).Scan(&version, &installedOn)
+		is.NotError(t, err)
+		is.Equal(t, "2-users", version)
+		is.True(t, installedOn != nil)
+	})
+
+	t.Run("creates the table with the columns if it doesn't exist", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		fsys := fstest.MapFS{
+			"0001_accounts.up.sql": {Data: []byte("create table accounts (id int);")},
+		}
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, MissingDown: migrate.CheckIgnore, Table: "schema_migrations",
+			VersionColumns: migrate.GolangMigrateColumns})
+
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		var version int64
+		err = db.QueryRow(`select version from schema_migrations`).Scan(&version)
+		is.NotError(t, err)
+		is.Equal(t, int64(1), version)
+	})
+}
@@ -0,0 +1,56 @@
+package migrate
+
+import (
+	"fmt"
+	"regexp"
+
+	"maragu.dev/migrate/internal/sqlscan"
+)
+
+// ConcurrentIndexer is a Dialect that can build and drop indexes concurrently, which can't happen in a transaction,
+// like create index concurrently on Postgres. Migrations with such statements run without a transaction,
+// statement by statement, like with no-transaction in their front matter and Options.SplitStatements,
+// instead of failing with an error from the database. A failure can then leave such a migration partially applied.
+type ConcurrentIndexer interface {
+	// IsConcurrentIndex reports whether the statement, without leading comment lines,
+	// builds or drops an index concurrently.
+	IsConcurrentIndex(statement string) bool
+}
+
+var concurrentlyMatcher = regexp.MustCompile("(?is)^(?:" +
+	"create\\s+(?:unique\\s+)?index\\s+concurrently|" +
+	"drop\\s+index\\s+concurrently|" +
+	"reindex\\s+(?:\\(.*\\)\\s+)?\\w+\\s+concurrently)\\b")
+
+// IsConcurrentIndex for create index concurrently, drop index concurrently, and reindex concurrently.
+func (postgresDialect) IsConcurrentIndex(statement string) bool {
+	return concurrentlyMatcher.MatchString(statement)
+}
+
+// hasConcurrently reports whether the file of the step has a statement that builds or drops an index concurrently,
+// if the Dialect is a ConcurrentIndexer.
+func (m *Migrator) hasConcurrently(s step) (bool, error) {
+	ci, ok := m.dialect.(ConcurrentIndexer)
+	if !ok {
+		return false, nil
+	}
+
+	f, err := m.open(s)
+	if err != nil {
+		return false, fmt.Errorf("error reading migration file %v: %w", s.name, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	scanner := sqlscan.NewScanner(f)
+	for scanner.Scan() {
+		if ci.IsConcurrentIndex(trimCommentLines(scanner.Statement())) {
+			return true, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("error reading migration file %v: %w", s.name, err)
+	}
+	return false, nil
+}
@@ -0,0 +1,81 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+// concurrentIndexerDialect is SQLite with the concurrent indexes of Postgres.
+type concurrentIndexerDialect struct {
+	migrate.Dialect
+}
+
+func (concurrentIndexerDialect) IsConcurrentIndex(statement string) bool {
+	return migrate.Postgres.(migrate.ConcurrentIndexer).IsConcurrentIndex(statement)
+}
+
+func TestMigrator_Concurrently(t *testing.T) {
+	// SQLite takes concurrently as the index name, so the migration is valid there too
+	fsys := fstest.MapFS{
+		"1.up.sql": {Data: []byte("create table accounts (id int)")},
+		"2.up.sql": {Data: []byte("-- Index for lookups\ncreate index concurrently on accounts (id);\nselect 1")},
+	}
+
+	t.Run("applies a migration with create index concurrently outside a transaction", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		var transactions []string
+		l := &recordingLogger{}
+		m := migrate.New(migrate.Options{DB: db, Dialect: concurrentIndexerDialect{Dialect: migrate.SQLite}, FS: fsys, Logger: l,
+			MissingDown: migrate.CheckIgnore,
+			Before: func(ctx context.Context, tx *sql.Tx, version string) error {
+				transactions = append(transactions, fmt.Sprintf("%v %v", version, tx != nil))
+				return nil
+			},
+		})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "2", getVersion(t, db))
+		is.Equal(t, "[1 true 2 false]", fmt.Sprint(transactions))
+		is.Equal(t, 1, len(l.lines))
+		is.Equal(t, "migrate: warning: 2.up.sql builds or drops an index concurrently, so it runs without a transaction, "+
+			"statement by statement, and a failure leaves it partially applied", l.lines[0])
+	})
+
+	t.Run("applies a migration with create index concurrently in a transaction if the Dialect isn't a ConcurrentIndexer", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		var transactions []string
+		m := migrate.New(migrate.Options{DB: db, Dialect: migrate.SQLite, FS: fsys, MissingDown: migrate.CheckIgnore,
+			Before: func(ctx context.Context, tx *sql.Tx, version string) error {
+				transactions = append(transactions, fmt.Sprintf("%v %v", version, tx != nil))
+				return nil
+			},
+		})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "[1 true 2 true]", fmt.Sprint(transactions))
+	})
+
+	t.Run("writes a migration with create index concurrently without a transaction in a script", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, Dialect: concurrentIndexerDialect{Dialect: migrate.SQLite}, FS: fsys,
+			MissingDown: migrate.CheckIgnore})
+		err := m.MigrateTo(context.Background(), "1")
+		is.NotError(t, err)
+
+		var b strings.Builder
+		err = m.Script(context.Background(), &b, "")
+		is.NotError(t, err)
+		is.True(t, !strings.Contains(b.String(), "begin;"))
+	})
+}
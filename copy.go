@@ -0,0 +1,82 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Copier bulk loads data into the database, for statements in migration files marked with the copy directive:
+//
+//	-- migrate:copy 2-accounts.csv
+//	copy accounts (id, name) from stdin with (format csv);
+//
+// The file is opened from the migration FS, and passed to Copy with the statement, including the directive.
+// The directive is only recognized with Options.SplitStatements, and needs a single connection
+// from Options.SingleConn or Options.Dialect.
+//
+// Copy gets the driver connection from sql.Conn.Raw, so it can use driver-specific bulk loading,
+// like the Postgres COPY protocol. Using the pgx driver, a Copier is a few lines:
+//
+//	type pgxCopier struct{}
+//
+//	func (pgxCopier) Copy(ctx context.Context, driverConn any, statement string, r io.Reader) (int64, error) {
+//		tag, err := driverConn.(*stdlib.Conn).Conn().PgConn().CopyFrom(ctx, r, statement)
+//		return tag.RowsAffected(), err
+//	}
+type Copier interface {
+	// Copy the data from r with the statement, returning the number of rows copied.
+	Copy(ctx context.Context, driverConn any, statement string, r io.Reader) (int64, error)
+}
+
+const copyDirective = "-- migrate:copy "
+
+// copyFile from the copy directive of the statement, if it has one.
+func copyFile(statement string) (string, bool) {
+	for _, line := range strings.Split(statement, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if name := strings.TrimPrefix(line, copyDirective); name != line {
+			return strings.TrimSpace(name), true
+		}
+		if !strings.HasPrefix(line, "--") {
+			return "", false
+		}
+	}
+	return "", false
+}
+
+// copy the data from the named file with the statement, using the Copier.
+func (m *Migrator) copy(ctx context.Context, statement, name string) (int64, error) {
+	if m.copier == nil {
+		return -1, errors.New("copy directive needs a Copier in Options")
+	}
+	conn, ok := m.conn.(*sql.Conn)
+	if !ok {
+		return -1, errors.New("copy directive needs a single connection, see Options.SingleConn")
+	}
+
+	f, err := m.fs.Open(name)
+	if err != nil {
+		return -1, fmt.Errorf("error opening copy file %v: %w", name, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var rows int64
+	err = conn.Raw(func(driverConn any) error {
+		var err error
+		rows, err = m.copier.Copy(ctx, driverConn, statement, f)
+		return err
+	})
+	if err != nil {
+		return -1, fmt.Errorf("error copying from %v: %w", name, err)
+	}
+	return rows, nil
+}
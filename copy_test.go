@@ -0,0 +1,71 @@
+package migrate_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+type recordingCopier struct {
+	data       []string
+	statements []string
+}
+
+func (c *recordingCopier) Copy(ctx context.Context, driverConn any, statement string, r io.Reader) (int64, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	c.statements = append(c.statements, statement)
+	c.data = append(c.data, string(b))
+	return int64(strings.Count(string(b), "\n")), nil
+}
+
+func TestMigrator_Copier(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1.up.sql":       {Data: []byte("create table accounts (id int, name text);\n-- migrate:copy 1-accounts.csv\ncopy accounts (id, name) from stdin with (format csv);\ninsert into accounts values (3, 'c');\n")},
+		"1-accounts.csv": {Data: []byte("1,a\n2,b\n")},
+	}
+
+	t.Run("copies data for statements with the copy directive", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		c := &recordingCopier{}
+		m := migrate.New(migrate.Options{Copier: c, DB: db, FS: fsys, SingleConn: true, SplitStatements: true})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		is.Equal(t, 1, len(c.statements))
+		is.Equal(t, "-- migrate:copy 1-accounts.csv\ncopy accounts (id, name) from stdin with (format csv)", c.statements[0])
+		is.Equal(t, "1,a\n2,b\n", c.data[0])
+
+		var count int
+		err = db.QueryRow(`select count(*) from accounts`).Scan(&count)
+		is.NotError(t, err)
+		is.Equal(t, 1, count)
+	})
+
+	t.Run("errors without a single connection", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{Copier: &recordingCopier{}, DB: db, FS: fsys, SplitStatements: true})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.True(t, strings.Contains(err.Error(), "copy directive needs a single connection"))
+	})
+
+	t.Run("errors without a copier", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, SingleConn: true, SplitStatements: true})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.True(t, strings.Contains(err.Error(), "copy directive needs a Copier"))
+	})
+}
@@ -0,0 +1,217 @@
+package migrate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Numbering of new migration files.
+type Numbering int
+
+const (
+	// Timestamp numbers files with the current Unix time in seconds, and is the default.
+	// If a file in the directory already has that number or a higher one, like after creating several
+	// in the same second, it's one after the highest instead, so the numbers don't collide.
+	Timestamp Numbering = iota
+	// Sequence numbers files one after the highest number in the directory, zero-padded to four digits
+	// or the width of the highest number, so they sort correctly.
+	Sequence
+)
+
+// CreateOptions for Create.
+type CreateOptions struct {
+	// DownTemplate for the content of the down file, in text/template format, with the fields Name and Version.
+	// Defaults to an empty file.
+	DownTemplate string
+	Numbering    Numbering
+	// Template in TemplateDir to use, like "table" for the files table.up.sql and table.down.sql.
+	// Defaults to "default", if TemplateDir has it.
+	Template string
+	// TemplateDir with named templates, like a directory shared by all repositories of an organization,
+	// each in a <name>.up.sql file and an optional <name>.down.sql file. If set, the Template from it replaces
+	// UpTemplate and DownTemplate.
+	TemplateDir string
+	// UpTemplate like DownTemplate, for the up file.
+	UpTemplate string
+}
+
+// CreateTemplateData is passed to the templates in CreateOptions.
+type CreateTemplateData struct {
+	// Name given to Create.
+	Name string
+	// Version of the migration, which is the number and name, like "1700000000-accounts".
+	Version string
+}
+
+var (
+	numberMatcher = regexp.MustCompile(`^(\d+)`)
+	nameMatcher   = regexp.MustCompile(`^[\w-]+$`)
+)
+
+// Create up and down migration files in dir, for a migration with the given name. It returns the paths of the files.
+// The name must match ^[\w-]+$ like the versions in migration file names, so spaces, dots, and path separators
+// are an error instead of creating files that the Migrator ignores. It doesn't overwrite existing files.
+// If dir has a manifest, the up file is appended to it, see ManifestFileName.
+func Create(dir, name string, opts CreateOptions) (upPath, downPath string, err error) {
+	if !nameMatcher.MatchString(name) {
+		return "", "", fmt.Errorf("error creating migration: invalid name %v", name)
+	}
+
+	if opts.TemplateDir != "" {
+		if err := opts.readTemplates(); err != nil {
+			return "", "", err
+		}
+	} else if opts.Template != "" {
+		return "", "", fmt.Errorf("error creating migration: template %v needs a template directory", opts.Template)
+	}
+
+	number, err := nextNumber(dir, opts.Numbering)
+	if err != nil {
+		return "", "", err
+	}
+
+	version := number + "-" + name
+	data := CreateTemplateData{Name: name, Version: version}
+
+	upPath = filepath.Join(dir, version+".up.sql")
+	downPath = filepath.Join(dir, version+".down.sql")
+	if err := createFile(upPath, opts.UpTemplate, data); err != nil {
+		return "", "", err
+	}
+	if err := createFile(downPath, opts.DownTemplate, data); err != nil {
+		_ = os.Remove(upPath)
+		return "", "", err
+	}
+	if err := appendToManifest(dir, version+".up.sql"); err != nil {
+		_ = os.Remove(upPath)
+		_ = os.Remove(downPath)
+		return "", "", err
+	}
+	return upPath, downPath, nil
+}
+
+// readTemplates of the Template in TemplateDir into UpTemplate and DownTemplate.
+func (o *CreateOptions) readTemplates() error {
+	name := o.Template
+	if name == "" {
+		name = "default"
+	}
+	if !nameMatcher.MatchString(name) {
+		return fmt.Errorf("error creating migration: invalid template name %v", name)
+	}
+
+	up, err := os.ReadFile(filepath.Join(o.TemplateDir, name+".up.sql"))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("error reading template %v: %w", name, err)
+		}
+		if o.Template == "" {
+			return nil
+		}
+		names, err := templateNames(o.TemplateDir)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("error creating migration: no template %v in %v, only %v", name, o.TemplateDir, strings.Join(names, ", "))
+	}
+
+	down, err := os.ReadFile(filepath.Join(o.TemplateDir, name+".down.sql"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error reading template %v: %w", name, err)
+	}
+
+	o.UpTemplate, o.DownTemplate = string(up), string(down)
+	return nil
+}
+
+// templateNames in dir, from the names of the up templates.
+func templateNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading template directory %v: %w", dir, err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		if name := strings.TrimSuffix(e.Name(), ".up.sql"); name != e.Name() && !e.IsDir() {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// nextNumber for a new migration file in dir.
+func nextNumber(dir string, n Numbering) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("error reading migrations directory %v: %w", dir, err)
+	}
+
+	var highest uint64
+	width := 4
+	for _, e := range entries {
+		if !upMatcher.MatchString(e.Name()) && !downMatcher.MatchString(e.Name()) {
+			continue
+		}
+		number := numberMatcher.FindString(e.Name())
+		if number == "" {
+			continue
+		}
+		v, err := strconv.ParseUint(number, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("error parsing number of migration file %v: %w", e.Name(), err)
+		}
+		if v >= highest {
+			highest = v
+			if len(number) > width {
+				width = len(number)
+			}
+		}
+	}
+	if n == Timestamp {
+		now := uint64(time.Now().Unix())
+		if now <= highest {
+			now = highest + 1
+		}
+		return strconv.FormatUint(now, 10), nil
+	}
+	return fmt.Sprintf("%0*d", width, highest+1), nil
+}
+
+// createFile at path with the executed template as content, without overwriting an existing file.
+func createFile(path, tmpl string, data CreateTemplateData) error {
+	var content bytes.Buffer
+	if tmpl != "" {
+		t, err := template.New(filepath.Base(path)).Parse(tmpl)
+		if err != nil {
+			return fmt.Errorf("error parsing template for %v: %w", path, err)
+		}
+		if err := t.Execute(&content, data); err != nil {
+			return fmt.Errorf("error executing template for %v: %w", path, err)
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("error creating migration file %v: already exists", path)
+		}
+		return fmt.Errorf("error creating migration file %v: %w", path, err)
+	}
+	if _, err := f.Write(content.Bytes()); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("error writing migration file %v: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("error closing migration file %v: %w", path, err)
+	}
+	return nil
+}
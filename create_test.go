@@ -0,0 +1,182 @@
+package migrate_test
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestCreate(t *testing.T) {
+	t.Run("creates empty up and down files with a timestamp", func(t *testing.T) {
+		dir := t.TempDir()
+
+		upPath, downPath, err := migrate.Create(dir, "accounts", migrate.CreateOptions{})
+		is.NotError(t, err)
+		is.True(t, strings.HasSuffix(upPath, "-accounts.up.sql"))
+		is.True(t, strings.HasSuffix(downPath, "-accounts.down.sql"))
+		is.Equal(t, dir, filepath.Dir(upPath))
+
+		content, err := os.ReadFile(upPath)
+		is.NotError(t, err)
+		is.Equal(t, "", string(content))
+		_, err = os.Stat(downPath)
+		is.NotError(t, err)
+	})
+
+	t.Run("numbers files in sequence", func(t *testing.T) {
+		dir := t.TempDir()
+
+		upPath, _, err := migrate.Create(dir, "accounts", migrate.CreateOptions{Numbering: migrate.Sequence})
+		is.NotError(t, err)
+		is.Equal(t, filepath.Join(dir, "0001-accounts.up.sql"), upPath)
+
+		upPath, downPath, err := migrate.Create(dir, "users", migrate.CreateOptions{Numbering: migrate.Sequence})
+		is.NotError(t, err)
+		is.Equal(t, filepath.Join(dir, "0002-users.up.sql"), upPath)
+		is.Equal(t, filepath.Join(dir, "0002-users.down.sql"), downPath)
+	})
+
+	t.Run("keeps the width of existing numbers", func(t *testing.T) {
+		dir := t.TempDir()
+		err := os.WriteFile(filepath.Join(dir, "000009-accounts.up.sql"), nil, 0644)
+		is.NotError(t, err)
+
+		upPath, _, err := migrate.Create(dir, "users", migrate.CreateOptions{Numbering: migrate.Sequence})
+		is.NotError(t, err)
+		is.Equal(t, filepath.Join(dir, "000010-users.up.sql"), upPath)
+	})
+
+	t.Run("executes templates", func(t *testing.T) {
+		dir := t.TempDir()
+
+		upPath, downPath, err := migrate.Create(dir, "accounts", migrate.CreateOptions{
+			DownTemplate: "-- Revert {{.Name}}\n",
+			Numbering:    migrate.Sequence,
+			UpTemplate:   "-- {{.Version}}\n",
+		})
+		is.NotError(t, err)
+
+		content, err := os.ReadFile(upPath)
+		is.NotError(t, err)
+		is.Equal(t, "-- 0001-accounts\n", string(content))
+
+		content, err = os.ReadFile(downPath)
+		is.NotError(t, err)
+		is.Equal(t, "-- Revert accounts\n", string(content))
+	})
+
+	t.Run("executes the named template from the template directory", func(t *testing.T) {
+		dir, templateDir := t.TempDir(), t.TempDir()
+		err := os.WriteFile(filepath.Join(templateDir, "table.up.sql"), []byte("create table {{.Name}} ();\n"), 0644)
+		is.NotError(t, err)
+		err = os.WriteFile(filepath.Join(templateDir, "table.down.sql"), []byte("drop table {{.Name}};\n"), 0644)
+		is.NotError(t, err)
+		err = os.WriteFile(filepath.Join(templateDir, "index.up.sql"), []byte("create index concurrently;\n"), 0644)
+		is.NotError(t, err)
+
+		upPath, downPath, err := migrate.Create(dir, "accounts", migrate.CreateOptions{
+			Numbering:   migrate.Sequence,
+			Template:    "table",
+			TemplateDir: templateDir,
+			UpTemplate:  "-- replaced",
+		})
+		is.NotError(t, err)
+
+		content, err := os.ReadFile(upPath)
+		is.NotError(t, err)
+		is.Equal(t, "create table accounts ();\n", string(content))
+
+		content, err = os.ReadFile(downPath)
+		is.NotError(t, err)
+		is.Equal(t, "drop table accounts;\n", string(content))
+
+		_, downPath, err = migrate.Create(dir, "accounts_id", migrate.CreateOptions{Template: "index", TemplateDir: templateDir})
+		is.NotError(t, err)
+		content, err = os.ReadFile(downPath)
+		is.NotError(t, err)
+		is.Equal(t, "", string(content))
+	})
+
+	t.Run("executes the default template from the template directory, if there is one", func(t *testing.T) {
+		dir, templateDir := t.TempDir(), t.TempDir()
+
+		upPath, _, err := migrate.Create(dir, "accounts", migrate.CreateOptions{Numbering: migrate.Sequence, TemplateDir: templateDir})
+		is.NotError(t, err)
+		content, err := os.ReadFile(upPath)
+		is.NotError(t, err)
+		is.Equal(t, "", string(content))
+
+		err = os.WriteFile(filepath.Join(templateDir, "default.up.sql"), []byte("-- {{.Version}}\n"), 0644)
+		is.NotError(t, err)
+
+		upPath, _, err = migrate.Create(dir, "users", migrate.CreateOptions{Numbering: migrate.Sequence, TemplateDir: templateDir})
+		is.NotError(t, err)
+		content, err = os.ReadFile(upPath)
+		is.NotError(t, err)
+		is.Equal(t, "-- 0002-users\n", string(content))
+	})
+
+	t.Run("errors on a template that isn't in the template directory", func(t *testing.T) {
+		dir, templateDir := t.TempDir(), t.TempDir()
+		err := os.WriteFile(filepath.Join(templateDir, "table.up.sql"), nil, 0644)
+		is.NotError(t, err)
+		err = os.WriteFile(filepath.Join(templateDir, "index.up.sql"), nil, 0644)
+		is.NotError(t, err)
+
+		_, _, err = migrate.Create(dir, "accounts", migrate.CreateOptions{Template: "backfill", TemplateDir: templateDir})
+		is.True(t, err != nil)
+		is.Equal(t, "error creating migration: no template backfill in "+templateDir+", only index, table", err.Error())
+
+		entries, err := os.ReadDir(dir)
+		is.NotError(t, err)
+		is.Equal(t, 0, len(entries))
+	})
+
+	t.Run("errors on invalid names", func(t *testing.T) {
+		_, _, err := migrate.Create(t.TempDir(), "no spaces", migrate.CreateOptions{})
+		is.True(t, err != nil)
+		is.Equal(t, "error creating migration: invalid name no spaces", err.Error())
+
+		for _, name := range []string{"no.dots", "../accounts", `sub\accounts`, ""} {
+			dir := t.TempDir()
+			_, _, err = migrate.Create(dir, name, migrate.CreateOptions{Numbering: migrate.Sequence})
+			is.Equal(t, "error creating migration: invalid name "+name, err.Error())
+
+			entries, err := os.ReadDir(dir)
+			is.NotError(t, err)
+			is.Equal(t, 0, len(entries))
+		}
+	})
+
+	t.Run("errors early on invalid names, before reading the directory", func(t *testing.T) {
+		_, _, err := migrate.Create(filepath.Join(t.TempDir(), "missing"), "no.dots", migrate.CreateOptions{Numbering: migrate.Sequence})
+		is.Equal(t, "error creating migration: invalid name no.dots", err.Error())
+	})
+
+	t.Run("numbers timestamps after existing numbers in the same second or later", func(t *testing.T) {
+		dir := t.TempDir()
+
+		upPath1, _, err := migrate.Create(dir, "accounts", migrate.CreateOptions{})
+		is.NotError(t, err)
+		upPath2, _, err := migrate.Create(dir, "users", migrate.CreateOptions{})
+		is.NotError(t, err)
+		upPath3, _, err := migrate.Create(dir, "orders", migrate.CreateOptions{})
+		is.NotError(t, err)
+
+		number := func(path string) int64 {
+			n, err := strconv.ParseInt(strings.SplitN(filepath.Base(path), "-", 2)[0], 10, 64)
+			is.NotError(t, err)
+			return n
+		}
+		is.True(t, number(upPath1) >= time.Now().Add(-time.Minute).Unix())
+		is.True(t, number(upPath2) > number(upPath1))
+		is.True(t, number(upPath3) > number(upPath2))
+	})
+}
@@ -0,0 +1,24 @@
+package migrate
+
+// ConstraintDeferrer is a Dialect that can defer checking constraints until the transaction of a migration commits,
+// for migrations with defer-constraints in their front matter. See frontMatterDelimiter.
+type ConstraintDeferrer interface {
+	// DeferConstraints returns SQL to defer checking constraints until the transaction commits,
+	// or an empty string if there's nothing to defer.
+	DeferConstraints() string
+}
+
+// DeferConstraints that are declared deferrable, which foreign keys aren't by default.
+func (postgresDialect) DeferConstraints() string {
+	return `set constraints all deferred`
+}
+
+// DeferConstraints returns an empty string, because Redshift doesn't enforce constraints.
+func (redshiftDialect) DeferConstraints() string {
+	return ""
+}
+
+// DeferConstraints of foreign keys, which SQLite switches back on at the end of the transaction.
+func (sqliteDialect) DeferConstraints() string {
+	return `pragma defer_foreign_keys = on`
+}
@@ -0,0 +1,628 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Dialect describes the SQL differences between databases that matter to the Migrator.
+// The package ships with dialects for some common databases. Implement Dialect to support other databases,
+// and optionally register it with RegisterDialect so tools can find it by name.
+type Dialect interface {
+	// CreateVersionTable returns SQL to create the version table, if it does not exist already.
+	// The table name is already quoted.
+	CreateVersionTable(table string) string
+
+	// Quote an identifier, such as the version table name. The identifier may contain dots
+	// to separate a schema from the table name.
+	Quote(identifier string) string
+
+	// Lock the migrations identified by table, blocking until the lock is acquired.
+	// The lock must be held by the session of conn, so that other sessions wait for it.
+	Lock(ctx context.Context, conn *sql.Conn, table string) error
+
+	// Unlock what was locked by Lock.
+	Unlock(ctx context.Context, conn *sql.Conn, table string) error
+
+	// SupportsTransactionalDDL reports whether DDL statements can run in a transaction without committing it implicitly.
+	SupportsTransactionalDDL() bool
+}
+
+// TryLocker is a Dialect that can try to get the lock without waiting for it. See Migrator.MigrateUpOrWait.
+type TryLocker interface {
+	// TryLock for the migrations identified by table on the connection, reporting whether it got the lock.
+	TryLock(ctx context.Context, conn *sql.Conn, table string) (bool, error)
+}
+
+// VersionUpdater is a Dialect that sets the version with its own SQL, like with placeholders.
+// Dialects that aren't VersionUpdaters set the version as a string literal, see StringQuoter.
+type VersionUpdater interface {
+	// UpdateVersion returns SQL to set the version in the version table, and the arguments for its placeholders.
+	// Use the placeholder style of the driver, or return no arguments and quote the version as a string literal.
+	// The table name is already quoted.
+	UpdateVersion(table, version string) (query string, args []any)
+}
+
+// updateVersion with d if it's a VersionUpdater, or else with the version as a string literal.
+func updateVersion(d Dialect, table, version string) (string, []any) {
+	if u, ok := d.(VersionUpdater); ok {
+		return u.UpdateVersion(table, version)
+	}
+	return `update ` + table + ` set version = ` + quoteString(d, version), nil
+}
+
+// StringQuoter is a Dialect that quotes string literals its own way.
+// Dialects that aren't StringQuoters get both quotes and backslashes doubled, like the generic dialect.
+type StringQuoter interface {
+	// QuoteString as a string literal.
+	QuoteString(s string) string
+}
+
+// quoteString with d if it's a StringQuoter, or else like the generic dialect.
+func quoteString(d Dialect, s string) string {
+	if q, ok := d.(StringQuoter); ok {
+		return q.QuoteString(s)
+	}
+	return genericDialect{}.QuoteString(s)
+}
+
+// Retrier is a Dialect that can retry failed statements in savepoints, see Options.Retries.
+// Statements aren't retried with Dialects that aren't Retriers.
+type Retrier interface {
+	// IsRetryable reports whether a statement that failed with err can be retried, like after a deadlock or lock timeout.
+	IsRetryable(err error) bool
+
+	// SupportsSavepoints reports whether savepoints can be used in transactions, to retry statements in them.
+	SupportsSavepoints() bool
+}
+
+// UndefinedTableChecker is a Dialect that recognizes the error of querying a table that doesn't exist,
+// so the migrations table can be read without creating it, like for Handler.
+// Dialects that aren't UndefinedTableCheckers recognize the errors of common databases, like the generic dialect.
+type UndefinedTableChecker interface {
+	// IsUndefinedTable reports whether err is from querying a table that doesn't exist.
+	IsUndefinedTable(err error) bool
+}
+
+// isUndefinedTable with d if it's an UndefinedTableChecker, or else like the generic dialect.
+func isUndefinedTable(d Dialect, err error) bool {
+	if c, ok := d.(UndefinedTableChecker); ok {
+		return c.IsUndefinedTable(err)
+	}
+	return genericDialect{}.IsUndefinedTable(err)
+}
+
+// AuditTableCreator is a Dialect that creates the audit table with its own column types, see Options.Audit.
+// Dialects that aren't AuditTableCreators get an audit table with generic column types.
+type AuditTableCreator interface {
+	// CreateAuditTable returns SQL to create the audit table, if it does not exist already.
+	// The table has the text columns operation, actor, started_at, finished_at, from_version, to_version, and outcome.
+	// The table name is already quoted.
+	CreateAuditTable(table string) string
+}
+
+// createAuditTable with d if it's an AuditTableCreator, or else with the generic dialect.
+func createAuditTable(d Dialect, table string) string {
+	if c, ok := d.(AuditTableCreator); ok {
+		return c.CreateAuditTable(table)
+	}
+	return genericDialect{}.CreateAuditTable(table)
+}
+
+// HistoryTableCreator is a Dialect that creates the history table with its own column types, see Options.History.
+// Dialects that aren't HistoryTableCreators get a history table with generic column types.
+type HistoryTableCreator interface {
+	// CreateHistoryTable returns SQL to create the history table, if it does not exist already.
+	// The table has the text columns version and applied_at, the integer column duration_ms,
+	// the nullable text column down_sql, the nullable integer column batch, and the nullable text columns description and checksum.
+	// The table name is already quoted.
+	CreateHistoryTable(table string) string
+}
+
+// createHistoryTable with d if it's a HistoryTableCreator, or else with the generic dialect.
+func createHistoryTable(d Dialect, table string) string {
+	if c, ok := d.(HistoryTableCreator); ok {
+		return c.CreateHistoryTable(table)
+	}
+	return genericDialect{}.CreateHistoryTable(table)
+}
+
+// BackfillTableCreator is a Dialect that can create the backfill table, which is needed for Options.Backfills.
+type BackfillTableCreator interface {
+	// CreateBackfillTable returns SQL to create the backfill table, if it does not exist already.
+	// The table has the text columns name, checkpoint, and started_at, the integer column rows_done,
+	// and the nullable text column finished_at. The table name is already quoted.
+	CreateBackfillTable(table string) string
+}
+
+// TransactionSupporter is a Dialect that reports whether the database supports transactions.
+// Dialects that aren't TransactionSupporters are assumed to support them.
+type TransactionSupporter interface {
+	// SupportsTransactions reports whether the database and driver support transactions.
+	// If not, each migration and its version update run directly on the connection, one statement after the other.
+	SupportsTransactions() bool
+}
+
+// supportsTransactions reports whether d supports transactions, which it does unless it's a TransactionSupporter
+// that reports otherwise.
+func supportsTransactions(d Dialect) bool {
+	ts, ok := d.(TransactionSupporter)
+	return !ok || ts.SupportsTransactions()
+}
+
+var (
+	dialectsMu sync.RWMutex
+	dialects   = map[string]Dialect{}
+)
+
+// RegisterDialect makes a Dialect available by name.
+// RegisterDialect panics if it's called twice with the same name, or if d is nil.
+func RegisterDialect(name string, d Dialect) {
+	dialectsMu.Lock()
+	defer dialectsMu.Unlock()
+	if d == nil {
+		panic("dialect is nil")
+	}
+	if _, ok := dialects[name]; ok {
+		panic("dialect " + name + " already registered")
+	}
+	dialects[name] = d
+}
+
+// LookupDialect by the name it was registered with.
+func LookupDialect(name string) (Dialect, bool) {
+	dialectsMu.RLock()
+	defer dialectsMu.RUnlock()
+	d, ok := dialects[name]
+	return d, ok
+}
+
+// Dialects returns the names of the registered dialects, sorted.
+func Dialects() []string {
+	dialectsMu.RLock()
+	defer dialectsMu.RUnlock()
+	var names []string
+	for name := range dialects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var (
+	// Postgres dialect. Locks using session-level advisory locks.
+	Postgres Dialect = postgresDialect{}
+
+	// MySQL dialect, which also works for MariaDB. Locks using GET_LOCK.
+	// MySQL commits a transaction implicitly on most DDL statements.
+	MySQL Dialect = mysqlDialect{}
+
+	// SQLite dialect. SQLite locks the whole database on writes, so Lock and Unlock do nothing.
+	SQLite Dialect = sqliteDialect{}
+
+	// Snowflake dialect. Snowflake has no advisory locks, so Lock and Unlock do nothing,
+	// and you must make sure only one Migrator runs at a time.
+	// Snowflake commits a transaction implicitly on DDL statements.
+	// To run migration files with more than one statement, set the MULTI_STATEMENT_COUNT session parameter to 0,
+	// for example with the DSN parameter MULTI_STATEMENT_COUNT=0. Because each run happens on a single connection,
+	// the session parameter applies to all migrations in the run.
+	Snowflake Dialect = snowflakeDialect{}
+
+	// Redshift dialect. Redshift is based on Postgres, but has no advisory locks, so Lock and Unlock do nothing,
+	// and you must make sure only one Migrator runs at a time.
+	// Use it with a Postgres driver.
+	Redshift Dialect = redshiftDialect{}
+
+	// BigQuery dialect, for use with a database/sql BigQuery driver, which runs each migration file as a query job.
+	// Set Options.Table to include the dataset, such as "mydataset.migrations".
+	// BigQuery has no transactions for DDL and no locks, so each migration runs without a transaction,
+	// and you must make sure only one Migrator runs at a time.
+	BigQuery Dialect = bigQueryDialect{}
+)
+
+func init() {
+	RegisterDialect("postgres", Postgres)
+	RegisterDialect("mysql", MySQL)
+	RegisterDialect("sqlite", SQLite)
+	RegisterDialect("snowflake", Snowflake)
+	RegisterDialect("redshift", Redshift)
+	RegisterDialect("bigquery", BigQuery)
+}
+
+// defaultDialect is used when Options.Dialect is not set, and works on most databases by not quoting or locking.
+var defaultDialect Dialect = genericDialect{}
+
+// defaultMySQLDialect is used instead of defaultDialect with MySQL drivers, because MySQL commits DDL implicitly.
+var defaultMySQLDialect Dialect = genericNonTransactionalDDLDialect{}
+
+// isMySQLDriver reports whether the driver is likely a MySQL or MariaDB driver, going by its type name.
+func isMySQLDriver(d driver.Driver) bool {
+	name := strings.ToLower(fmt.Sprintf("%T", d))
+	return strings.Contains(name, "mysql") || strings.Contains(name, "maria")
+}
+
+type genericDialect struct{}
+
+func (genericDialect) CreateVersionTable(table string) string {
+	return `create table if not exists ` + table + ` (version text not null)`
+}
+
+func (genericDialect) CreateHistoryTable(table string) string {
+	return `create table if not exists ` + table + ` (version text not null, applied_at text not null, duration_ms bigint not null, down_sql text, batch bigint, description text, checksum text)`
+}
+
+func (genericDialect) CreateAuditTable(table string) string {
+	return `create table if not exists ` + table + ` (operation text not null, actor text not null, started_at text not null, ` +
+		`finished_at text not null, from_version text not null, to_version text not null, outcome text not null)`
+}
+
+func (genericDialect) CreateBackfillTable(table string) string {
+	return `create table if not exists ` + table + ` (name text not null, checkpoint text not null, rows_done bigint not null, ` +
+		`started_at text not null, finished_at text)`
+}
+
+// UpdateVersion with the version as a string literal, because placeholder styles differ between drivers.
+func (d genericDialect) UpdateVersion(table, version string) (string, []any) {
+	return `update ` + table + ` set version = ` + d.QuoteString(version), nil
+}
+
+func (genericDialect) Quote(identifier string) string {
+	return identifier
+}
+
+// QuoteString with both quotes and backslashes doubled. Doubling backslashes makes the literal safe on databases
+// that treat backslashes as escape characters, like MySQL, at the cost of storing them doubled on databases that don't.
+func (genericDialect) QuoteString(s string) string {
+	return `'` + strings.ReplaceAll(strings.ReplaceAll(s, `\`, `\\`), `'`, `''`) + `'`
+}
+
+func (genericDialect) Lock(context.Context, *sql.Conn, string) error {
+	return nil
+}
+
+func (genericDialect) Unlock(context.Context, *sql.Conn, string) error {
+	return nil
+}
+
+// IsRetryable is always false, because the errors differ between databases.
+func (genericDialect) IsRetryable(error) bool {
+	return false
+}
+
+// IsUndefinedTable for the errors of Postgres, MySQL, and SQLite, because the generic dialect is used with all of them.
+func (genericDialect) IsUndefinedTable(err error) bool {
+	return postgresDialect{}.IsUndefinedTable(err) || mysqlDialect{}.IsUndefinedTable(err) || sqliteDialect{}.IsUndefinedTable(err)
+}
+
+func (genericDialect) SupportsSavepoints() bool {
+	return false
+}
+
+func (genericDialect) SupportsTransactionalDDL() bool {
+	return true
+}
+
+func (genericDialect) SupportsTransactions() bool {
+	return true
+}
+
+type genericNonTransactionalDDLDialect struct {
+	genericDialect
+}
+
+func (genericNonTransactionalDDLDialect) SupportsTransactionalDDL() bool {
+	return false
+}
+
+type postgresDialect struct {
+	genericDialect
+}
+
+func (postgresDialect) UpdateVersion(table, version string) (string, []any) {
+	return `update ` + table + ` set version = $1`, []any{version}
+}
+
+func (postgresDialect) Quote(identifier string) string {
+	return QuoteIdentifier(identifier, `"`, `"`)
+}
+
+// QuoteString assuming standard_conforming_strings, so only quotes are doubled.
+func (postgresDialect) QuoteString(s string) string {
+	return `'` + strings.ReplaceAll(s, `'`, `''`) + `'`
+}
+
+// IsRetryable for serialization failures, deadlocks, and lock timeouts.
+// The SQLSTATE is read from the SQLState method that pgx and lib/pq errors have.
+func (postgresDialect) IsRetryable(err error) bool {
+	var e interface{ SQLState() string }
+	if !errors.As(err, &e) {
+		return false
+	}
+	switch e.SQLState() {
+	case "40001", "40P01", "55P03":
+		return true
+	}
+	return false
+}
+
+// IsUndefinedTable for the SQLSTATE undefined_table, read like in IsRetryable.
+func (postgresDialect) IsUndefinedTable(err error) bool {
+	var e interface{ SQLState() string }
+	return errors.As(err, &e) && e.SQLState() == "42P01"
+}
+
+func (postgresDialect) SupportsSavepoints() bool {
+	return true
+}
+
+func (postgresDialect) Lock(ctx context.Context, conn *sql.Conn, table string) error {
+	_, err := conn.ExecContext(ctx, `select pg_advisory_lock($1)`, lockKey(table))
+	return err
+}
+
+func (postgresDialect) TryLock(ctx context.Context, conn *sql.Conn, table string) (bool, error) {
+	var ok bool
+	err := conn.QueryRowContext(ctx, `select pg_try_advisory_lock($1)`, lockKey(table)).Scan(&ok)
+	return ok, err
+}
+
+func (postgresDialect) Unlock(ctx context.Context, conn *sql.Conn, table string) error {
+	_, err := conn.ExecContext(ctx, `select pg_advisory_unlock($1)`, lockKey(table))
+	return err
+}
+
+type mysqlDialect struct {
+	genericDialect
+}
+
+func (mysqlDialect) UpdateVersion(table, version string) (string, []any) {
+	return `update ` + table + ` set version = ?`, []any{version}
+}
+
+func (mysqlDialect) Quote(identifier string) string {
+	return QuoteIdentifier(identifier, "`", "`")
+}
+
+func (mysqlDialect) Lock(ctx context.Context, conn *sql.Conn, table string) error {
+	var result sql.NullInt64
+	if err := conn.QueryRowContext(ctx, `select get_lock(?, -1)`, lockName(table)).Scan(&result); err != nil {
+		return err
+	}
+	if result.Int64 != 1 {
+		return errors.New("could not get lock " + lockName(table))
+	}
+	return nil
+}
+
+func (mysqlDialect) TryLock(ctx context.Context, conn *sql.Conn, table string) (bool, error) {
+	var result sql.NullInt64
+	if err := conn.QueryRowContext(ctx, `select get_lock(?, 0)`, lockName(table)).Scan(&result); err != nil {
+		return false, err
+	}
+	return result.Int64 == 1, nil
+}
+
+func (mysqlDialect) Unlock(ctx context.Context, conn *sql.Conn, table string) error {
+	_, err := conn.ExecContext(ctx, `select release_lock(?)`, lockName(table))
+	return err
+}
+
+// IsRetryable for deadlocks and lock wait timeouts. The error number is read from the error message,
+// which starts like "Error 1213" with go-sql-driver/mysql.
+func (mysqlDialect) IsRetryable(err error) bool {
+	msg := err.Error()
+	return strings.HasPrefix(msg, "Error 1213") || strings.HasPrefix(msg, "Error 1205")
+}
+
+// IsUndefinedTable for error 1146, read from the error message like in IsRetryable.
+func (mysqlDialect) IsUndefinedTable(err error) bool {
+	return strings.Contains(err.Error(), "Error 1146")
+}
+
+func (mysqlDialect) SupportsSavepoints() bool {
+	return true
+}
+
+func (mysqlDialect) SupportsTransactionalDDL() bool {
+	return false
+}
+
+type sqliteDialect struct {
+	genericDialect
+}
+
+func (sqliteDialect) UpdateVersion(table, version string) (string, []any) {
+	return `update ` + table + ` set version = ?`, []any{version}
+}
+
+func (sqliteDialect) Quote(identifier string) string {
+	return QuoteIdentifier(identifier, `"`, `"`)
+}
+
+// QuoteString with quotes doubled, because SQLite doesn't treat backslashes as escape characters.
+func (sqliteDialect) QuoteString(s string) string {
+	return `'` + strings.ReplaceAll(s, `'`, `''`) + `'`
+}
+
+// IsRetryable if the database is busy or locked by another connection.
+func (sqliteDialect) IsRetryable(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "database is locked") || strings.Contains(msg, "database table is locked") ||
+		strings.Contains(msg, "SQLITE_BUSY")
+}
+
+func (sqliteDialect) IsUndefinedTable(err error) bool {
+	return strings.Contains(err.Error(), "no such table")
+}
+
+func (sqliteDialect) SupportsSavepoints() bool {
+	return true
+}
+
+type snowflakeDialect struct {
+	genericDialect
+}
+
+func (snowflakeDialect) CreateVersionTable(table string) string {
+	return `create table if not exists ` + table + ` (version varchar not null)`
+}
+
+func (snowflakeDialect) CreateAuditTable(table string) string {
+	return `create table if not exists ` + table + ` (operation varchar not null, actor varchar not null, started_at varchar not null, ` +
+		`finished_at varchar not null, from_version varchar not null, to_version varchar not null, outcome varchar not null)`
+}
+
+func (snowflakeDialect) CreateBackfillTable(table string) string {
+	return `create table if not exists ` + table + ` (name varchar not null, checkpoint varchar not null, rows_done bigint not null, ` +
+		`started_at varchar not null, finished_at varchar)`
+}
+
+func (snowflakeDialect) CreateHistoryTable(table string) string {
+	return `create table if not exists ` + table + ` (version varchar not null, applied_at varchar not null, duration_ms bigint not null, down_sql varchar, batch bigint, description varchar, checksum varchar)`
+}
+
+func (snowflakeDialect) UpdateVersion(table, version string) (string, []any) {
+	return `update ` + table + ` set version = ?`, []any{version}
+}
+
+// Quote identifiers, which also makes them case-sensitive in Snowflake.
+func (snowflakeDialect) Quote(identifier string) string {
+	return QuoteIdentifier(identifier, `"`, `"`)
+}
+
+// IsUndefinedTable for error 002003, which Snowflake also returns for tables the role isn't authorized to see.
+func (snowflakeDialect) IsUndefinedTable(err error) bool {
+	return strings.Contains(err.Error(), "002003")
+}
+
+func (snowflakeDialect) SupportsTransactionalDDL() bool {
+	return false
+}
+
+type redshiftDialect struct {
+	postgresDialect
+}
+
+// CreateVersionTable with an explicit varchar length, because Redshift turns text into varchar(256) anyway.
+func (redshiftDialect) CreateVersionTable(table string) string {
+	return `create table if not exists ` + table + ` (version varchar(256) not null)`
+}
+
+// CreateAuditTable with the maximum varchar length for the outcome, which can contain long error messages.
+func (redshiftDialect) CreateAuditTable(table string) string {
+	return `create table if not exists ` + table + ` (operation varchar(256) not null, actor varchar(256) not null, ` +
+		`started_at varchar(64) not null, finished_at varchar(64) not null, from_version varchar(256) not null, ` +
+		`to_version varchar(256) not null, outcome varchar(65535) not null)`
+}
+
+// CreateBackfillTable with the maximum varchar length for the checkpoint.
+func (redshiftDialect) CreateBackfillTable(table string) string {
+	return `create table if not exists ` + table + ` (name varchar(256) not null, checkpoint varchar(65535) not null, ` +
+		`rows_done bigint not null, started_at varchar(64) not null, finished_at varchar(64))`
+}
+
+func (redshiftDialect) CreateHistoryTable(table string) string {
+	return `create table if not exists ` + table + ` (version varchar(256) not null, applied_at varchar(64) not null, duration_ms bigint not null, ` +
+		`down_sql varchar(65535), batch bigint, description varchar(65535), checksum varchar(64))`
+}
+
+// QuoteString with both quotes and backslashes doubled, because Redshift treats backslashes as escape characters.
+func (redshiftDialect) QuoteString(s string) string {
+	return genericDialect{}.QuoteString(s)
+}
+
+// SupportsSavepoints is false, because Redshift doesn't have them.
+func (redshiftDialect) SupportsSavepoints() bool {
+	return false
+}
+
+func (redshiftDialect) Lock(context.Context, *sql.Conn, string) error {
+	return nil
+}
+
+// TryLock always gets the lock, because Redshift has no advisory locks.
+func (redshiftDialect) TryLock(context.Context, *sql.Conn, string) (bool, error) {
+	return true, nil
+}
+
+func (redshiftDialect) Unlock(context.Context, *sql.Conn, string) error {
+	return nil
+}
+
+type bigQueryDialect struct {
+	genericDialect
+}
+
+func (bigQueryDialect) CreateVersionTable(table string) string {
+	return `create table if not exists ` + table + ` (version string not null)`
+}
+
+func (bigQueryDialect) CreateAuditTable(table string) string {
+	return `create table if not exists ` + table + ` (operation string not null, actor string not null, started_at string not null, ` +
+		`finished_at string not null, from_version string not null, to_version string not null, outcome string not null)`
+}
+
+func (bigQueryDialect) CreateBackfillTable(table string) string {
+	return `create table if not exists ` + table + ` (name string not null, checkpoint string not null, rows_done int64 not null, ` +
+		`started_at string not null, finished_at string)`
+}
+
+func (bigQueryDialect) CreateHistoryTable(table string) string {
+	return `create table if not exists ` + table + ` (version string not null, applied_at string not null, duration_ms int64 not null, down_sql string, batch int64, description string, checksum string)`
+}
+
+// UpdateVersion with a where clause, because BigQuery requires one for updates.
+func (bigQueryDialect) UpdateVersion(table, version string) (string, []any) {
+	return `update ` + table + ` set version = ? where true`, []any{version}
+}
+
+func (bigQueryDialect) Quote(identifier string) string {
+	return QuoteIdentifier(identifier, "`", "`")
+}
+
+// QuoteString with backslash escapes, because BigQuery doesn't support doubled quotes.
+func (bigQueryDialect) QuoteString(s string) string {
+	return `'` + strings.ReplaceAll(strings.ReplaceAll(s, `\`, `\\`), `'`, `\'`) + `'`
+}
+
+func (bigQueryDialect) IsUndefinedTable(err error) bool {
+	return strings.Contains(err.Error(), "Not found: Table")
+}
+
+func (bigQueryDialect) SupportsTransactionalDDL() bool {
+	return false
+}
+
+func (bigQueryDialect) SupportsTransactions() bool {
+	return false
+}
+
+// QuoteIdentifier quotes each dot-separated part of identifier with the given start and end quotes,
+// doubling any end quotes inside the parts. Use it to implement Dialect.Quote, for example
+// with double quotes for standard SQL, backticks for MySQL, or brackets for SQL Server:
+//
+//	QuoteIdentifier("dbo.migrations", "[", "]") // [dbo].[migrations]
+func QuoteIdentifier(identifier, start, end string) string {
+	parts := strings.Split(identifier, ".")
+	for i, part := range parts {
+		parts[i] = start + strings.ReplaceAll(part, end, end+end) + end
+	}
+	return strings.Join(parts, ".")
+}
+
+// lockName for the migrations identified by table.
+func lockName(table string) string {
+	return "migrate:" + table
+}
+
+// lockKey is lockName as a number, for databases with numeric lock identifiers.
+func lockKey(table string) int64 {
+	return int64(crc32.ChecksumIEEE([]byte(lockName(table))))
+}
@@ -0,0 +1,398 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+type testDialect struct {
+	migrate.Dialect
+}
+
+// noTransactionsDialect is SQLite pretending not to support transactions.
+type noTransactionsDialect struct {
+	migrate.Dialect
+}
+
+func (noTransactionsDialect) SupportsTransactions() bool {
+	return false
+}
+
+// noTransactionalDDLDialect is SQLite pretending to commit DDL implicitly, like MySQL.
+type noTransactionalDDLDialect struct {
+	migrate.Dialect
+}
+
+func (noTransactionalDDLDialect) SupportsTransactionalDDL() bool {
+	return false
+}
+
+func TestRegisterDialect(t *testing.T) {
+	t.Run("registers a dialect by name", func(t *testing.T) {
+		// Registration is global, so use a unique name in case the test runs more than once
+		name := fmt.Sprintf("test%v", time.Now().UnixNano())
+		d := testDialect{Dialect: migrate.Postgres}
+		migrate.RegisterDialect(name, d)
+
+		found, ok := migrate.LookupDialect(name)
+		is.True(t, ok)
+		is.True(t, found == migrate.Dialect(d))
+	})
+
+	t.Run("panics on duplicate name", func(t *testing.T) {
+		defer func() {
+			err := recover()
+			is.True(t, err != nil)
+			is.Equal(t, "dialect postgres already registered", err.(string))
+		}()
+		migrate.RegisterDialect("postgres", migrate.Postgres)
+	})
+
+	t.Run("panics on nil dialect", func(t *testing.T) {
+		defer func() {
+			err := recover()
+			is.True(t, err != nil)
+			is.Equal(t, "dialect is nil", err.(string))
+		}()
+		migrate.RegisterDialect("nil", nil)
+	})
+}
+
+func TestLookupDialect(t *testing.T) {
+	t.Run("finds the built-in dialects", func(t *testing.T) {
+		for name, expected := range map[string]migrate.Dialect{
+			"postgres":  migrate.Postgres,
+			"mysql":     migrate.MySQL,
+			"sqlite":    migrate.SQLite,
+			"snowflake": migrate.Snowflake,
+			"redshift":  migrate.Redshift,
+			"bigquery":  migrate.BigQuery,
+		} {
+			d, ok := migrate.LookupDialect(name)
+			is.True(t, ok)
+			is.True(t, d == expected)
+		}
+	})
+
+	t.Run("returns false for unknown dialects", func(t *testing.T) {
+		_, ok := migrate.LookupDialect("doesnotexist")
+		is.True(t, !ok)
+	})
+}
+
+func TestDialects(t *testing.T) {
+	t.Run("returns sorted names including the built-in dialects", func(t *testing.T) {
+		names := migrate.Dialects()
+		for i := 1; i < len(names); i++ {
+			is.True(t, names[i-1] < names[i])
+		}
+		is.True(t, contains(names, "bigquery"))
+		is.True(t, contains(names, "mysql"))
+		is.True(t, contains(names, "postgres"))
+		is.True(t, contains(names, "redshift"))
+		is.True(t, contains(names, "snowflake"))
+		is.True(t, contains(names, "sqlite"))
+	})
+}
+
+func TestDialect_Quote(t *testing.T) {
+	tests := []struct {
+		dialect  migrate.Dialect
+		input    string
+		expected string
+	}{
+		{migrate.Postgres, "migrations", `"migrations"`},
+		{migrate.Postgres, "schema.migrations", `"schema"."migrations"`},
+		{migrate.MySQL, "migrations", "`migrations`"},
+		{migrate.MySQL, "db.migrations", "`db`.`migrations`"},
+		{migrate.SQLite, "migrations", `"migrations"`},
+		{migrate.Snowflake, "db.schema.migrations", `"db"."schema"."migrations"`},
+		{migrate.Redshift, "schema.migrations", `"schema"."migrations"`},
+		{migrate.BigQuery, "dataset.migrations", "`dataset`.`migrations`"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			is.Equal(t, test.expected, test.dialect.Quote(test.input))
+		})
+	}
+}
+
+func TestDialect_CreateVersionTable(t *testing.T) {
+	t.Run("uses varchar for Snowflake", func(t *testing.T) {
+		is.Equal(t, `create table if not exists "migrations" (version varchar not null)`,
+			migrate.Snowflake.CreateVersionTable(migrate.Snowflake.Quote("migrations")))
+	})
+
+	t.Run("uses string for BigQuery", func(t *testing.T) {
+		is.Equal(t, "create table if not exists `ds`.`migrations` (version string not null)",
+			migrate.BigQuery.CreateVersionTable(migrate.BigQuery.Quote("ds.migrations")))
+	})
+
+	t.Run("uses varchar with length for Redshift", func(t *testing.T) {
+		is.Equal(t, `create table if not exists "migrations" (version varchar(256) not null)`,
+			migrate.Redshift.CreateVersionTable(migrate.Redshift.Quote("migrations")))
+	})
+}
+
+func TestDialect_CreateAuditTable(t *testing.T) {
+	t.Run("audits with a Dialect that isn't an AuditTableCreator", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{Audit: true, DB: db, Dialect: testDialect{Dialect: migrate.SQLite}, FS: mustSub(t, testdata, "good")})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		var operation string
+		err = db.QueryRow(`select operation from migrations_audit`).Scan(&operation)
+		is.NotError(t, err)
+		is.Equal(t, "up", operation)
+	})
+}
+
+func TestDialect_UpdateVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		dialect  migrate.Dialect
+		expected string
+	}{
+		{"postgres", migrate.Postgres, `update migrations set version = $1`},
+		{"mysql", migrate.MySQL, `update migrations set version = ?`},
+		{"sqlite", migrate.SQLite, `update migrations set version = ?`},
+		{"snowflake", migrate.Snowflake, `update migrations set version = ?`},
+		{"redshift", migrate.Redshift, `update migrations set version = $1`},
+		{"bigquery", migrate.BigQuery, `update migrations set version = ? where true`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			query, args := test.dialect.(migrate.VersionUpdater).UpdateVersion("migrations", "1-it's")
+			is.Equal(t, test.expected, query)
+			is.Equal(t, 1, len(args))
+			is.Equal(t, "1-it's", args[0].(string))
+		})
+	}
+
+	t.Run("sets the version as a string literal if the dialect isn't a VersionUpdater", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		_, err := db.Exec(`create table migrations (version text not null); insert into migrations values ('')`)
+		is.NotError(t, err)
+
+		s := migrate.DBVersionStore{DB: db, Dialect: testDialect{Dialect: migrate.SQLite}}
+		err = s.Set(context.Background(), "1-it's")
+		is.NotError(t, err)
+		is.Equal(t, "1-it's", getVersion(t, db))
+	})
+}
+
+func TestDialect_SupportsTransactions(t *testing.T) {
+	t.Run("is false only for BigQuery", func(t *testing.T) {
+		for _, d := range []migrate.Dialect{migrate.Postgres, migrate.MySQL, migrate.SQLite, migrate.Snowflake, migrate.Redshift} {
+			is.True(t, d.(migrate.TransactionSupporter).SupportsTransactions())
+		}
+		is.True(t, !migrate.BigQuery.(migrate.TransactionSupporter).SupportsTransactions())
+	})
+
+	t.Run("migrates in transactions if the dialect isn't a TransactionSupporter", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		var beforeTx *sql.Tx
+		m := migrate.New(migrate.Options{
+			Before: func(ctx context.Context, tx *sql.Tx, version string) error {
+				beforeTx = tx
+				return nil
+			},
+			DB:      db,
+			Dialect: testDialect{Dialect: migrate.SQLite},
+			FS:      mustSub(t, testdata, "good"),
+		})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.True(t, beforeTx != nil)
+	})
+
+	t.Run("migrates without transactions if not supported", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		var beforeTx, afterTx *sql.Tx
+		m := migrate.New(migrate.Options{
+			Before: func(ctx context.Context, tx *sql.Tx, version string) error {
+				beforeTx = tx
+				return nil
+			},
+			After: func(ctx context.Context, tx *sql.Tx, version string) error {
+				afterTx = tx
+				return nil
+			},
+			DB:      db,
+			Dialect: noTransactionsDialect{Dialect: migrate.SQLite},
+			FS:      mustSub(t, testdata, "good"),
+		})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.True(t, beforeTx == nil)
+		is.True(t, afterTx == nil)
+
+		version := getVersion(t, db)
+		is.Equal(t, "3", version)
+
+		err = m.MigrateDown(context.Background())
+		is.NotError(t, err)
+
+		version = getVersion(t, db)
+		is.Equal(t, "", version)
+	})
+
+	t.Run("does not update the version if the migration fails without transactions", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, Dialect: noTransactionsDialect{Dialect: migrate.SQLite}, FS: mustSub(t, testdata, "bad")})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+
+		version := getVersion(t, db)
+		is.Equal(t, "1", version)
+	})
+}
+
+func TestDialect_Lock(t *testing.T) {
+	t.Run("does nothing for Redshift, Snowflake, and BigQuery", func(t *testing.T) {
+		// A nil connection would panic if used
+		for _, d := range []migrate.Dialect{migrate.Redshift, migrate.Snowflake, migrate.BigQuery} {
+			is.NotError(t, d.Lock(context.Background(), nil, "migrations"))
+			is.NotError(t, d.Unlock(context.Background(), nil, "migrations"))
+		}
+	})
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	tests := []struct {
+		input, start, end, expected string
+	}{
+		{"migrations", `"`, `"`, `"migrations"`},
+		{"dbo.migrations", "[", "]", "[dbo].[migrations]"},
+		{`mi"grations`, `"`, `"`, `"mi""grations"`},
+		{"mi]grations", "[", "]", "[mi]]grations]"},
+		{"mi`grations", "`", "`", "`mi``grations`"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			is.Equal(t, test.expected, migrate.QuoteIdentifier(test.input, test.start, test.end))
+		})
+	}
+}
+
+func TestDialect_QuoteString(t *testing.T) {
+	tests := []struct {
+		name     string
+		dialect  migrate.Dialect
+		expected string
+	}{
+		{"postgres", migrate.Postgres, `'it''s a \ backslash'`},
+		{"mysql", migrate.MySQL, `'it''s a \\ backslash'`},
+		{"sqlite", migrate.SQLite, `'it''s a \ backslash'`},
+		{"snowflake", migrate.Snowflake, `'it''s a \\ backslash'`},
+		{"redshift", migrate.Redshift, `'it''s a \\ backslash'`},
+		{"bigquery", migrate.BigQuery, `'it\'s a \\ backslash'`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			is.Equal(t, test.expected, test.dialect.(migrate.StringQuoter).QuoteString(`it's a \ backslash`))
+		})
+	}
+}
+
+func TestDialect_SupportsTransactionalDDL(t *testing.T) {
+	t.Run("is false for MySQL, Snowflake, and BigQuery", func(t *testing.T) {
+		is.True(t, migrate.Postgres.SupportsTransactionalDDL())
+		is.True(t, migrate.Redshift.SupportsTransactionalDDL())
+		is.True(t, !migrate.MySQL.SupportsTransactionalDDL())
+		is.True(t, migrate.SQLite.SupportsTransactionalDDL())
+		is.True(t, !migrate.Snowflake.SupportsTransactionalDDL())
+		is.True(t, !migrate.BigQuery.SupportsTransactionalDDL())
+	})
+}
+
+type sqlStateError string
+
+func (e sqlStateError) Error() string {
+	return "error with SQLSTATE " + string(e)
+}
+
+func (e sqlStateError) SQLState() string {
+	return string(e)
+}
+
+func TestDialect_IsRetryable(t *testing.T) {
+	t.Run("detects deadlocks and lock timeouts", func(t *testing.T) {
+		is.True(t, migrate.Postgres.(migrate.Retrier).IsRetryable(fmt.Errorf("wrapped: %w", sqlStateError("40P01"))))
+		is.True(t, migrate.Postgres.(migrate.Retrier).IsRetryable(sqlStateError("55P03")))
+		is.True(t, !migrate.Postgres.(migrate.Retrier).IsRetryable(sqlStateError("23505")))
+		is.True(t, migrate.MySQL.(migrate.Retrier).IsRetryable(errors.New("Error 1213 (40001): Deadlock found when trying to get lock")))
+		is.True(t, !migrate.MySQL.(migrate.Retrier).IsRetryable(errors.New("Error 1062 (23000): Duplicate entry")))
+		is.True(t, migrate.SQLite.(migrate.Retrier).IsRetryable(errors.New("database is locked")))
+		is.True(t, !migrate.BigQuery.(migrate.Retrier).IsRetryable(errors.New("database is locked")))
+	})
+}
+
+func TestDialect_IsUndefinedTable(t *testing.T) {
+	t.Run("detects errors of missing tables", func(t *testing.T) {
+		is.True(t, migrate.Postgres.(migrate.UndefinedTableChecker).IsUndefinedTable(fmt.Errorf("wrapped: %w", sqlStateError("42P01"))))
+		is.True(t, !migrate.Postgres.(migrate.UndefinedTableChecker).IsUndefinedTable(sqlStateError("42501")))
+		is.True(t, migrate.MySQL.(migrate.UndefinedTableChecker).IsUndefinedTable(errors.New("Error 1146 (42S02): Table 'db.migrations' doesn't exist")))
+		is.True(t, !migrate.MySQL.(migrate.UndefinedTableChecker).IsUndefinedTable(errors.New("Error 1142 (42000): SELECT command denied")))
+		is.True(t, migrate.SQLite.(migrate.UndefinedTableChecker).IsUndefinedTable(errors.New("no such table: migrations")))
+		is.True(t, !migrate.SQLite.(migrate.UndefinedTableChecker).IsUndefinedTable(errors.New("no such column: version")))
+		is.True(t, !migrate.BigQuery.(migrate.UndefinedTableChecker).IsUndefinedTable(errors.New("no such table: migrations")))
+	})
+}
+
+func TestDialect_SupportsSavepoints(t *testing.T) {
+	t.Run("is true for Postgres, MySQL, and SQLite", func(t *testing.T) {
+		is.True(t, migrate.Postgres.(migrate.Retrier).SupportsSavepoints())
+		is.True(t, migrate.MySQL.(migrate.Retrier).SupportsSavepoints())
+		is.True(t, migrate.SQLite.(migrate.Retrier).SupportsSavepoints())
+		is.True(t, !migrate.Redshift.(migrate.Retrier).SupportsSavepoints())
+		is.True(t, !migrate.Snowflake.(migrate.Retrier).SupportsSavepoints())
+		is.True(t, !migrate.BigQuery.(migrate.Retrier).SupportsSavepoints())
+	})
+}
+
+func ExampleRegisterDialect() {
+	db, err := sql.Open("sqlite3", "db.sqlite")
+	if err != nil {
+		panic(err)
+	}
+
+	// Register the dialect once, typically in an init function of the package implementing it.
+	migrate.RegisterDialect("mydialect", testDialect{Dialect: migrate.SQLite})
+
+	d, ok := migrate.LookupDialect("mydialect")
+	if !ok {
+		panic("dialect not found")
+	}
+
+	m := migrate.New(migrate.Options{DB: db, Dialect: d, FS: migrations})
+	if err := m.MigrateUp(context.Background()); err != nil {
+		panic(err)
+	}
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+)
+
+// Diagnosis of a problem with the state of the migrations, from Migrator.Diagnose.
+type Diagnosis struct {
+	// Problem found, like "1-accounts.up.sql has changed since it was applied".
+	Problem string
+	// Remedy for the problem, often with a command or SQL statement to run.
+	Remedy string
+}
+
+// Diagnose the state of the migrations in the database against the migration files, for problems that need
+// someone to fix them by hand: a dirty version with VersionColumns, applied versions without an up migration file,
+// and a lock held by another session, if the Dialect is a TryLocker. With Options.History, also up migration files
+// that have changed since they were applied, and up migration files before the current version that were never applied,
+// like after merging a branch with an older number. It returns no diagnoses if everything looks fine.
+func (m *Migrator) Diagnose(ctx context.Context) (diagnoses []Diagnosis, err error) {
+	ctx, span := m.tracer.Start(ctx, "migrate diagnose")
+	span.SetAttribute("migrate.table", m.table)
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error diagnosing: %w", err)
+		}
+		span.End(err)
+	}()
+
+	// Getting the version fails if it's dirty, so check the flag first. Errors, like when the table doesn't exist yet,
+	// are left for getting the version.
+	if m.columns.Dirty != "" {
+		var version string
+		var dirty bool
+		err := m.conn.QueryRowContext(ctx, `select `+m.dialect.Quote(m.columns.Version)+`, `+m.dialect.Quote(m.columns.Dirty)+
+			` from `+m.dialect.Quote(m.table)).Scan(&version, &dirty)
+		if err == nil && dirty {
+			// Nothing else can be diagnosed without the current version
+			return []Diagnosis{{
+				Problem: fmt.Sprintf("version %v is dirty, so a migration failed partway", version),
+				Remedy: fmt.Sprintf("fix the database by hand, then run: update %v set %v = false",
+					m.dialect.Quote(m.table), m.dialect.Quote(m.columns.Dirty)),
+			}}, nil
+		}
+	}
+
+	if err := m.createMigrationsTable(ctx); err != nil {
+		return nil, err
+	}
+
+	missing, err := m.getMissingApplied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, version := range missing {
+		diagnoses = append(diagnoses, Diagnosis{
+			Problem: fmt.Sprintf("applied version %v has no up migration file %v.up.sql", version, version),
+			Remedy:  "restore the file, unless it was pruned on purpose",
+		})
+	}
+
+	if m.history {
+		historyDiagnoses, err := m.diagnoseHistory(ctx)
+		if err != nil {
+			return nil, err
+		}
+		diagnoses = append(diagnoses, historyDiagnoses...)
+	}
+
+	if tl, ok := m.dialect.(TryLocker); ok {
+		locked, err := m.isLockHeld(ctx, tl)
+		if err != nil {
+			return nil, err
+		}
+		if locked {
+			diagnoses = append(diagnoses, Diagnosis{
+				Problem: "the migration lock is held by another session, from a running migration or a stuck one",
+				Remedy:  "wait for the running migration to finish, or end the database session holding the lock",
+			})
+		}
+	}
+	return diagnoses, nil
+}
+
+// diagnoseHistory for changed up migration files, and up migration files before the current version
+// that were never applied.
+func (m *Migrator) diagnoseHistory(ctx context.Context) ([]Diagnosis, error) {
+	currentVersion, err := m.getCurrentVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	checksums, err := m.getChecksums(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := m.getFilenames(upMatcher)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := m.getVersionOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	var diagnoses []Diagnosis
+	var unapplied []string
+	for _, name := range names {
+		version := upMatcher.ReplaceAllString(name, "$1")
+		if order.compare(version, currentVersion) > 0 {
+			break
+		}
+
+		recorded, ok := checksums[version]
+		if !ok {
+			unapplied = append(unapplied, name)
+			continue
+		}
+		// Migrations applied before the history existed were probably applied in order
+		if len(unapplied) > 0 {
+			for _, name := range unapplied {
+				diagnoses = append(diagnoses, Diagnosis{
+					Problem: fmt.Sprintf("%v is before the current version %v, but was never applied", name, currentVersion),
+					Remedy:  "rename it with a number after the current version, so it's applied next, or apply it by hand",
+				})
+			}
+			unapplied = nil
+		}
+
+		if !recorded.Valid {
+			continue
+		}
+		checksum, err := m.checksum(name)
+		if err != nil {
+			return nil, err
+		}
+		if checksum != recorded.String {
+			diagnoses = append(diagnoses, Diagnosis{
+				Problem: fmt.Sprintf("%v has changed since it was applied", name),
+				Remedy: fmt.Sprintf("revert the change and make it in a new migration, or if the change is harmless, run: "+
+					"update %v set checksum = '%v' where version = '%v'", m.dialect.Quote(m.historyTable()), checksum, version),
+			})
+		}
+	}
+	return diagnoses, nil
+}
+
+// isLockHeld by another session, by trying to lock it, and unlocking it right away if that worked.
+func (m *Migrator) isLockHeld(ctx context.Context, tl TryLocker) (bool, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return false, fmt.Errorf("error getting connection: %w", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	locked, err := tl.TryLock(ctx, conn, m.table)
+	if err != nil {
+		return false, fmt.Errorf("error locking: %w", err)
+	}
+	if !locked {
+		return true, nil
+	}
+	if err := m.dialect.Unlock(ctx, conn, m.table); err != nil {
+		return false, fmt.Errorf("error unlocking: %w", err)
+	}
+	return false, nil
+}
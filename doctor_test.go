@@ -0,0 +1,98 @@
+package migrate_test
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestMigrator_Diagnose(t *testing.T) {
+	fsys := func() fstest.MapFS {
+		return fstest.MapFS{
+			"1-accounts.up.sql":   {Data: []byte("create table accounts (id integer primary key)")},
+			"1-accounts.down.sql": {Data: []byte("drop table accounts")},
+			"3-invoices.up.sql":   {Data: []byte("create table invoices (id integer primary key)")},
+			"3-invoices.down.sql": {Data: []byte("drop table invoices")},
+		}
+	}
+
+	t.Run("finds nothing if everything looks fine", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, Dialect: migrate.SQLite, FS: fsys(), History: true})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		diagnoses, err := m.Diagnose(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, 0, len(diagnoses))
+	})
+
+	t.Run("finds changed, missing, and never applied up migration files", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, Dialect: migrate.SQLite, FS: fsys(), History: true})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		changed := fsys()
+		changed["1-accounts.up.sql"] = &fstest.MapFile{Data: []byte("create table accounts (id integer primary key, name text)")}
+		changed["2-users.up.sql"] = &fstest.MapFile{Data: []byte("create table users (id integer primary key)")}
+		delete(changed, "3-invoices.up.sql")
+		m = migrate.New(migrate.Options{DB: db, Dialect: migrate.SQLite, FS: changed, History: true})
+
+		diagnoses, err := m.Diagnose(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, 2, len(diagnoses))
+		is.Equal(t, "applied version 3-invoices has no up migration file 3-invoices.up.sql", diagnoses[0].Problem)
+		is.Equal(t, "1-accounts.up.sql has changed since it was applied", diagnoses[1].Problem)
+	})
+
+	t.Run("finds up migration files before the current version that were never applied", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, Dialect: migrate.SQLite, FS: fsys(), History: true})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		merged := fsys()
+		merged["2-users.up.sql"] = &fstest.MapFile{Data: []byte("create table users (id integer primary key)")}
+		m = migrate.New(migrate.Options{DB: db, Dialect: migrate.SQLite, FS: merged, History: true})
+
+		diagnoses, err := m.Diagnose(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, 1, len(diagnoses))
+		is.Equal(t, "2-users.up.sql is before the current version 3-invoices, but was never applied", diagnoses[0].Problem)
+	})
+
+	t.Run("finds a dirty version", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		_, err := db.Exec(`create table schema_migrations (version bigint not null primary key, dirty boolean not null);
+			insert into schema_migrations values (1, true)`)
+		is.NotError(t, err)
+
+		m := migrate.New(migrate.Options{DB: db, FS: fsys(), Table: "schema_migrations", VersionColumns: migrate.GolangMigrateColumns})
+		diagnoses, err := m.Diagnose(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, 1, len(diagnoses))
+		is.Equal(t, "version 1 is dirty, so a migration failed partway", diagnoses[0].Problem)
+		is.Equal(t, "fix the database by hand, then run: update schema_migrations set dirty = false", diagnoses[0].Remedy)
+	})
+
+	t.Run("finds a lock held by another session", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		d := tryLockDialect{Dialect: migrate.SQLite, locked: make(chan struct{}, 1)}
+		m := migrate.New(migrate.Options{DB: db, Dialect: d, FS: fsys()})
+
+		diagnoses, err := m.Diagnose(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, 0, len(diagnoses))
+
+		d.locked <- struct{}{}
+		diagnoses, err = m.Diagnose(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, 1, len(diagnoses))
+		is.Equal(t, "the migration lock is held by another session, from a running migration or a stuck one", diagnoses[0].Problem)
+	})
+}
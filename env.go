@@ -0,0 +1,62 @@
+package migrate
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+)
+
+// envMatcher matches ${VAR} references to environment variables. Only the form with braces is expanded,
+// so Postgres placeholders like $1 and dollar-quoted strings are left alone.
+var envMatcher = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// envReader expands environment variable references in the lines it reads, see Options.ExpandEnv.
+type envReader struct {
+	closer  io.Closer
+	name    string
+	pending string
+	r       *bufio.Reader
+}
+
+func newEnvReader(name string, rc io.ReadCloser) *envReader {
+	return &envReader{closer: rc, name: name, r: bufio.NewReader(rc)}
+}
+
+func (e *envReader) Read(p []byte) (int, error) {
+	for e.pending == "" {
+		line, err := e.r.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, err
+		}
+		if line == "" {
+			return 0, io.EOF
+		}
+		if e.pending, err = expandEnv(line); err != nil {
+			return 0, fmt.Errorf("error expanding environment variables in %v: %w", e.name, err)
+		}
+	}
+	n := copy(p, e.pending)
+	e.pending = e.pending[n:]
+	return n, nil
+}
+
+func (e *envReader) Close() error {
+	return e.closer.Close()
+}
+
+// expandEnv references in s, erroring on variables that are not set.
+func expandEnv(s string) (string, error) {
+	var err error
+	expanded := envMatcher.ReplaceAllStringFunc(s, func(ref string) string {
+		name := envMatcher.FindStringSubmatch(ref)[1]
+		value, ok := os.LookupEnv(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("%v is not set", name)
+		}
+		return value
+	})
+	return expanded, err
+}
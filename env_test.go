@@ -0,0 +1,65 @@
+package migrate_test
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestMigrator_ExpandEnv(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1.up.sql": {Data: []byte("create table ${MIGRATE_TEST_TABLE} (v text);\ninsert into ${MIGRATE_TEST_TABLE} values ('$1 and $$')")},
+	}
+
+	t.Run("replaces environment variables in braces", func(t *testing.T) {
+		t.Setenv("MIGRATE_TEST_TABLE", "accounts")
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, ExpandEnv: true, FS: fsys})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		var v string
+		err = db.QueryRow(`select v from accounts`).Scan(&v)
+		is.NotError(t, err)
+		is.Equal(t, "$1 and $$", v)
+	})
+
+	t.Run("errors if a variable is not set", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, ExpandEnv: true, FS: fsys})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, "error migrating up: error reading migration file 1.up.sql: "+
+			"error expanding environment variables in 1.up.sql: MIGRATE_TEST_TABLE is not set", err.Error())
+		is.Equal(t, "", getVersion(t, db))
+	})
+
+	t.Run("leaves references alone by default", func(t *testing.T) {
+		t.Setenv("MIGRATE_TEST_TABLE", "accounts")
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, FS: fsys})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+	})
+
+	t.Run("checksums the files without expanding them", func(t *testing.T) {
+		t.Setenv("MIGRATE_TEST_TABLE", "accounts")
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, ExpandEnv: true, FS: fsys, History: true})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		t.Setenv("MIGRATE_TEST_TABLE", "users")
+		diagnoses, err := m.Diagnose(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, 0, len(diagnoses))
+	})
+}
@@ -0,0 +1,69 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Estimate of the time to apply the pending migrations, see Migrator.Estimate.
+type Estimate struct {
+	// Pending migrations, by version, in the order they are applied.
+	Pending []string
+	// Remaining time to apply the pending migrations.
+	Remaining time.Duration
+	// Unknown versions of the pending migrations without a recorded duration, which count with the average duration.
+	Unknown []string
+}
+
+// Estimate the time to apply the pending migrations from the recorded durations of migrations,
+// like from Migrator.Applied on a staging database, which has applied them before, to decide whether a deploy
+// fits in a maintenance window. If recorded is nil, the durations in the history table of this database are used,
+// which needs Options.History. Pending migrations without a recorded duration count with the average of the recorded ones.
+func (m *Migrator) Estimate(ctx context.Context, recorded []AppliedMigration) (e Estimate, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error estimating: %w", err)
+		}
+	}()
+
+	if recorded == nil && m.history {
+		if recorded, err = m.Applied(ctx); err != nil {
+			return Estimate{}, err
+		}
+	}
+
+	if err := m.createMigrationsTable(ctx); err != nil {
+		return Estimate{}, err
+	}
+	currentVersion, err := m.getCurrentVersion(ctx)
+	if err != nil {
+		return Estimate{}, err
+	}
+	steps, err := m.planUp(currentVersion, "")
+	if err != nil {
+		return Estimate{}, err
+	}
+
+	durations := map[string]time.Duration{}
+	var total time.Duration
+	for _, a := range recorded {
+		durations[a.Version] = a.Duration
+		total += a.Duration
+	}
+	var average time.Duration
+	if len(recorded) > 0 {
+		average = total / time.Duration(len(recorded))
+	}
+
+	for _, s := range steps {
+		e.Pending = append(e.Pending, s.version)
+		d, ok := durations[s.version]
+		if !ok {
+			e.Unknown = append(e.Unknown, s.version)
+			d = average
+		}
+		e.Remaining += d
+	}
+	return e, nil
+}
@@ -0,0 +1,59 @@
+package migrate_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestMigrator_Estimate(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1-accounts.up.sql": {Data: []byte("create table accounts (id int);")},
+		"2-users.up.sql":    {Data: []byte("create table users (id int);")},
+		"3-posts.up.sql":    {Data: []byte("create table posts (id int);")},
+	}
+
+	t.Run("sums the recorded durations of pending migrations, with the average for unknown ones", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, FS: fsys})
+
+		e, err := m.Estimate(context.Background(), []migrate.AppliedMigration{
+			{Version: "1-accounts", Duration: 2 * time.Second},
+			{Version: "2-users", Duration: 4 * time.Second},
+		})
+		is.NotError(t, err)
+		is.Equal(t, "[1-accounts 2-users 3-posts]", fmt.Sprint(e.Pending))
+		is.Equal(t, 9*time.Second, e.Remaining)
+		is.Equal(t, "[3-posts]", fmt.Sprint(e.Unknown))
+	})
+
+	t.Run("only estimates pending migrations, from the history table by default", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, History: true, MissingDown: migrate.CheckIgnore})
+		err := m.MigrateTo(context.Background(), "1-accounts")
+		is.NotError(t, err)
+
+		e, err := m.Estimate(context.Background(), nil)
+		is.NotError(t, err)
+		is.Equal(t, "[2-users 3-posts]", fmt.Sprint(e.Pending))
+		is.Equal(t, "[2-users 3-posts]", fmt.Sprint(e.Unknown))
+	})
+
+	t.Run("has no pending migrations when up to date", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, MissingDown: migrate.CheckIgnore})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		e, err := m.Estimate(context.Background(), nil)
+		is.NotError(t, err)
+		is.Equal(t, 0, len(e.Pending))
+		is.Equal(t, time.Duration(0), e.Remaining)
+	})
+}
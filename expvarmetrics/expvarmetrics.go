@@ -0,0 +1,90 @@
+// Package expvarmetrics publishes migrate.Metrics with the expvar package, so processes that already serve /debug/vars
+// show the migrations without a metrics library. Importing it imports expvar, which serves /debug/vars on
+// http.DefaultServeMux.
+package expvarmetrics
+
+import (
+	"expvar"
+	"time"
+
+	"maragu.dev/migrate"
+)
+
+// Metrics published as an expvar map with the counters applied and failed, the gauge pending,
+// the versions current_version and target_version, and last_duration_seconds of the last applied or failed migration.
+// Use it as migrate.Options.Metrics. It's safe for concurrent use.
+type Metrics struct {
+	applied        expvar.Int
+	currentVersion expvar.String
+	failed         expvar.Int
+	lastDuration   expvar.Float
+	pending        expvar.Int
+	targetVersion  expvar.String
+}
+
+var _ migrate.VersionMetrics = (*Metrics)(nil)
+
+// New Metrics published with the given name, like "migrate". Like expvar.Publish, it panics if the name is already used.
+func New(name string) *Metrics {
+	m := &Metrics{}
+	vars := expvar.NewMap(name)
+	vars.Set("applied", &m.applied)
+	vars.Set("current_version", &m.currentVersion)
+	vars.Set("failed", &m.failed)
+	vars.Set("last_duration_seconds", &m.lastDuration)
+	vars.Set("pending", &m.pending)
+	vars.Set("target_version", &m.targetVersion)
+	return m
+}
+
+// Applied satisfies migrate.Metrics. The applied version becomes the current version.
+func (m *Metrics) Applied(version string, duration time.Duration) {
+	m.applied.Add(1)
+	m.currentVersion.Set(version)
+	m.lastDuration.Set(duration.Seconds())
+}
+
+// Failed satisfies migrate.Metrics.
+func (m *Metrics) Failed(version string, duration time.Duration) {
+	m.failed.Add(1)
+	m.lastDuration.Set(duration.Seconds())
+}
+
+// Pending satisfies migrate.Metrics.
+func (m *Metrics) Pending(count int) {
+	m.pending.Set(int64(count))
+}
+
+// Versions satisfies migrate.VersionMetrics.
+func (m *Metrics) Versions(current, target string) {
+	m.currentVersion.Set(current)
+	m.targetVersion.Set(target)
+}
+
+// Stats published by Metrics, see Metrics.Stats.
+type Stats struct {
+	// Applied migrations since the Metrics were created.
+	Applied int64
+	// CurrentVersion at the start of the last run, or after the last applied migration.
+	CurrentVersion string
+	// Failed migrations since the Metrics were created.
+	Failed int64
+	// LastDuration of the last applied or failed migration.
+	LastDuration time.Duration
+	// Pending migrations of the current or last run.
+	Pending int64
+	// TargetVersion of the last run.
+	TargetVersion string
+}
+
+// Stats published by the Metrics, for processes that report them in other ways, like on a status page.
+func (m *Metrics) Stats() Stats {
+	return Stats{
+		Applied:        m.applied.Value(),
+		CurrentVersion: m.currentVersion.Value(),
+		Failed:         m.failed.Value(),
+		LastDuration:   time.Duration(m.lastDuration.Value() * float64(time.Second)),
+		Pending:        m.pending.Value(),
+		TargetVersion:  m.targetVersion.Value(),
+	}
+}
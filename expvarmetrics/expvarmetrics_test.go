@@ -0,0 +1,71 @@
+package expvarmetrics_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"expvar"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/mattn/go-sqlite3"
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+	"maragu.dev/migrate/expvarmetrics"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Run("publishes counters and versions of runs", func(t *testing.T) {
+		db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "app.sqlite"))
+		is.NotError(t, err)
+		t.Cleanup(func() {
+			_ = db.Close()
+		})
+
+		metrics := expvarmetrics.New("migrate_test")
+		m := migrate.New(migrate.Options{
+			DB: db,
+			FS: fstest.MapFS{
+				"1.up.sql": {Data: []byte("create table accounts (id int)")},
+				"2.up.sql": {Data: []byte("create table users (id int)")},
+				"3.up.sql": {Data: []byte("not sql")},
+			},
+			Metrics:     metrics,
+			MissingDown: migrate.CheckIgnore,
+		})
+
+		err = m.MigrateTo(context.Background(), "2")
+		is.NotError(t, err)
+
+		stats := metrics.Stats()
+		is.Equal(t, int64(2), stats.Applied)
+		is.Equal(t, int64(0), stats.Failed)
+		is.Equal(t, int64(0), stats.Pending)
+		is.Equal(t, "2", stats.CurrentVersion)
+		is.Equal(t, "2", stats.TargetVersion)
+
+		err = m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+
+		stats = metrics.Stats()
+		is.Equal(t, int64(1), stats.Failed)
+		is.Equal(t, int64(1), stats.Pending)
+		is.Equal(t, "2", stats.CurrentVersion)
+		is.Equal(t, "3", stats.TargetVersion)
+
+		var published struct {
+			Applied        int64  `json:"applied"`
+			CurrentVersion string `json:"current_version"`
+			Failed         int64  `json:"failed"`
+			TargetVersion  string `json:"target_version"`
+		}
+		err = json.Unmarshal([]byte(expvar.Get("migrate_test").String()), &published)
+		is.NotError(t, err)
+		is.Equal(t, int64(2), published.Applied)
+		is.Equal(t, int64(1), published.Failed)
+		is.Equal(t, "2", published.CurrentVersion)
+		is.Equal(t, "3", published.TargetVersion)
+	})
+}
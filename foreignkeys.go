@@ -0,0 +1,110 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ForeignKeyDisabler is a Dialect that can disable enforcing foreign keys on the connection during a migration,
+// like for rebuilding a table on SQLite, or creating tables that reference each other on MySQL.
+// See Options.DisableForeignKeysOnDown, and disable-foreign-keys in the front matter.
+type ForeignKeyDisabler interface {
+	// DisableForeignKeys returns SQL to disable enforcing foreign keys, and SQL to enable it again.
+	// Both run on the connection outside the transaction of the migration.
+	DisableForeignKeys() (disable, enable string)
+
+	// CheckForeignKeys returns a query for rows that violate foreign keys, run after the migration
+	// in its transaction, or the empty string if the database can't check them.
+	CheckForeignKeys() string
+}
+
+// DisableForeignKeys with the pragma, which does nothing in a transaction, so it's set outside of it.
+func (sqliteDialect) DisableForeignKeys() (disable, enable string) {
+	return `pragma foreign_keys = off`, `pragma foreign_keys = on`
+}
+
+func (sqliteDialect) CheckForeignKeys() string {
+	return `pragma foreign_key_check`
+}
+
+// DisableForeignKeys with foreign_key_checks, remembering the setting in a user variable to restore it.
+func (mysqlDialect) DisableForeignKeys() (disable, enable string) {
+	return `set @migrate_foreign_key_checks = @@foreign_key_checks, foreign_key_checks = 0`,
+		`set foreign_key_checks = @migrate_foreign_key_checks`
+}
+
+// CheckForeignKeys returns the empty string, because MySQL can't check existing rows against foreign keys.
+func (mysqlDialect) CheckForeignKeys() string {
+	return ""
+}
+
+// disableForeignKeys on the connection, returning a function to enable them again.
+func (m *Migrator) disableForeignKeys(ctx context.Context) (func() error, error) {
+	// The pool could run the migration on another connection
+	if _, ok := m.conn.(*sql.DB); ok {
+		return nil, errors.New("disabling foreign keys needs a single connection, see Options.Dialect")
+	}
+
+	disable, enable := m.dialect.(ForeignKeyDisabler).DisableForeignKeys()
+	if _, err := m.conn.ExecContext(ctx, disable); err != nil {
+		return nil, fmt.Errorf("error disabling foreign keys: %w", err)
+	}
+	return func() error {
+		// Enable even if ctx is done, so the connection doesn't go back to the pool without them
+		if _, err := m.conn.ExecContext(context.Background(), enable); err != nil {
+			return fmt.Errorf("error enabling foreign keys: %w", err)
+		}
+		return nil
+	}, nil
+}
+
+// checkForeignKeys for rows that violate them after the migration of a step, if the Dialect can check them.
+func (m *Migrator) checkForeignKeys(ctx context.Context, q queryer, s step) error {
+	query := m.dialect.(ForeignKeyDisabler).CheckForeignKeys()
+	if query == "" {
+		return nil
+	}
+
+	rows, err := q.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("error checking foreign keys: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return fmt.Errorf("error checking foreign keys: %w", err)
+	}
+
+	var violations []string
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dest := make([]any, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return fmt.Errorf("error checking foreign keys: %w", err)
+		}
+		var fields []string
+		for i, v := range values {
+			if v.Valid {
+				fields = append(fields, columns[i]+"="+v.String)
+			}
+		}
+		violations = append(violations, strings.Join(fields, " "))
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error checking foreign keys: %w", err)
+	}
+
+	if len(violations) > 0 {
+		return fmt.Errorf("migration %v from %v violates foreign keys: %v", s.version, s.name, strings.Join(violations, ", "))
+	}
+	return nil
+}
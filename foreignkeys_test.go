@@ -0,0 +1,132 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestMigrator_DisableForeignKeys(t *testing.T) {
+	newDB := func(t *testing.T) *sql.DB {
+		t.Helper()
+		db := createSQLiteDatabase(t)
+		db.SetMaxOpenConns(1)
+		_, err := db.Exec(`pragma foreign_keys = on`)
+		is.NotError(t, err)
+		return db
+	}
+
+	up := "create table parents (id int primary key, name text);\n" +
+		"create table children (parent_id int references parents (id));\n" +
+		"insert into parents values (1, 'a');\n" +
+		"insert into children values (1)"
+
+	// The common SQLite pattern for changes alter table can't do
+	rebuild := "create table parents_new (id int primary key);\n" +
+		"insert into parents_new select id from parents;\n" +
+		"drop table parents;\n" +
+		"alter table parents_new rename to parents"
+
+	t.Run("rebuilds a referenced table in a down migration", func(t *testing.T) {
+		db := newDB(t)
+		m := migrate.New(migrate.Options{DB: db, Dialect: migrate.SQLite, DisableForeignKeysOnDown: true,
+			FS: fstest.MapFS{
+				"1.up.sql":   {Data: []byte(up)},
+				"1.down.sql": {Data: []byte("drop table children; drop table parents")},
+				"2.up.sql":   {Data: []byte("alter table parents add column age int")},
+				"2.down.sql": {Data: []byte(rebuild)},
+			},
+		})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		err = m.MigrateTo(context.Background(), "1")
+		is.NotError(t, err)
+		is.Equal(t, "1", getVersion(t, db))
+
+		var enabled bool
+		err = db.QueryRow(`pragma foreign_keys`).Scan(&enabled)
+		is.NotError(t, err)
+		is.True(t, enabled)
+	})
+
+	t.Run("errors on the rebuild without it", func(t *testing.T) {
+		db := newDB(t)
+		m := migrate.New(migrate.Options{DB: db, Dialect: migrate.SQLite,
+			FS: fstest.MapFS{
+				"1.up.sql":   {Data: []byte(up)},
+				"1.down.sql": {Data: []byte("drop table children; drop table parents")},
+				"2.up.sql":   {Data: []byte("alter table parents add column age int")},
+				"2.down.sql": {Data: []byte(rebuild)},
+			},
+		})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		err = m.MigrateTo(context.Background(), "1")
+		is.True(t, err != nil)
+		is.Equal(t, "2", getVersion(t, db))
+	})
+
+	t.Run("fails a down migration that leaves rows violating foreign keys", func(t *testing.T) {
+		db := newDB(t)
+		m := migrate.New(migrate.Options{DB: db, Dialect: migrate.SQLite, DisableForeignKeysOnDown: true,
+			FS: fstest.MapFS{
+				"1.up.sql":   {Data: []byte(up)},
+				"1.down.sql": {Data: []byte("drop table children; drop table parents")},
+				"2.up.sql":   {Data: []byte("insert into parents values (2, 'b')")},
+				"2.down.sql": {Data: []byte("delete from parents where id = 1")},
+			},
+		})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		err = m.MigrateTo(context.Background(), "1")
+		is.True(t, err != nil)
+		is.Equal(t, "error migrating to: migration 1 from 2.down.sql violates foreign keys: "+
+			"table=children rowid=1 parent=parents fkid=0", err.Error())
+		is.Equal(t, "2", getVersion(t, db))
+	})
+
+	t.Run("rebuilds a referenced table in a migration with disable-foreign-keys", func(t *testing.T) {
+		db := newDB(t)
+		m := migrate.New(migrate.Options{DB: db, Dialect: migrate.SQLite, MissingDown: migrate.CheckIgnore,
+			FS: fstest.MapFS{
+				"1.up.sql": {Data: []byte(up)},
+				"2.up.sql": {Data: []byte("-- ---\n-- disable-foreign-keys: true\n-- ---\n" + rebuild)},
+			},
+		})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "2", getVersion(t, db))
+
+		var enabled bool
+		err = db.QueryRow(`pragma foreign_keys`).Scan(&enabled)
+		is.NotError(t, err)
+		is.True(t, enabled)
+	})
+
+	t.Run("errors on disable-foreign-keys if the dialect can't disable foreign keys", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, MissingDown: migrate.CheckIgnore,
+			FS: fstest.MapFS{
+				"1.up.sql": {Data: []byte("-- ---\n-- disable-foreign-keys: true\n-- ---\nselect 1")},
+			},
+		})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, "error migrating up: error in front matter of 1.up.sql: "+
+			"disable-foreign-keys needs a Dialect that is a ForeignKeyDisabler", err.Error())
+	})
+
+	t.Run("panics if the dialect can't disable foreign keys", func(t *testing.T) {
+		defer func() {
+			err := recover()
+			is.True(t, err != nil)
+			is.Equal(t, "disabling foreign keys on down needs a Dialect that is a ForeignKeyDisabler", err.(string))
+		}()
+		migrate.New(migrate.Options{DB: &sql.DB{}, Dialect: migrate.Postgres, FS: fstest.MapFS{}, DisableForeignKeysOnDown: true})
+	})
+}
@@ -0,0 +1,192 @@
+package migrate
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// frontMatterDelimiter starts and ends the front matter block, in comment lines at the start of a migration file:
+//
+//	-- ---
+//	-- description: Add the accounts table
+//	-- no-transaction: true
+//	-- timeout: 30s
+//	-- depends-on: 1-accounts 2-users
+//	-- tags: accounts, billing
+//	-- run-after: 2025-07-01T00:00:00Z
+//	-- online-schema-change: true
+//	-- requires: server >= 14, extension pgcrypto
+//	-- defer-constraints: true
+//	-- disable-foreign-keys: true
+//	-- ---
+//
+// All keys are optional.
+const frontMatterDelimiter = "---"
+
+// frontMatter of a migration file.
+type frontMatter struct {
+	// deferConstraints checks constraints when the transaction of the migration commits, see ConstraintDeferrer.
+	deferConstraints bool
+	// disableForeignKeys enforcing them during the migration, see ForeignKeyDisabler.
+	disableForeignKeys bool
+	// dependsOn are the versions the migration waits for with Options.Parallel, if hasDependsOn is set.
+	dependsOn    []string
+	description  string
+	hasDependsOn bool
+	// noTransaction runs the migration directly on the connection, for statements that can't run in a transaction.
+	noTransaction bool
+	// onlineSchemaChange passes alter table statements to the OnlineSchemaChanger.
+	onlineSchemaChange bool
+	// requires of the migration, checked before any migrations of a run are applied.
+	requires []requirement
+	// runAfter is the time before which the migration isn't applied, if not zero.
+	runAfter time.Time
+	tags     []string
+	// timeout for applying the migration, if not zero.
+	timeout time.Duration
+}
+
+// readFrontMatter of a step, which is empty if the file has none.
+func (m *Migrator) readFrontMatter(s step) (frontMatter, error) {
+	f, err := m.open(s)
+	if err != nil {
+		return frontMatter{}, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var fm frontMatter
+	scanner := bufio.NewScanner(f)
+	started := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !started && line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "--") {
+			if started {
+				return frontMatter{}, fmt.Errorf("error in front matter of %v: not closed with %v", s.name, frontMatterDelimiter)
+			}
+			break
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "--"))
+
+		if line == frontMatterDelimiter {
+			if started {
+				return fm, nil
+			}
+			started = true
+			continue
+		}
+		if !started {
+			break
+		}
+
+		if err := fm.set(s.name, line); err != nil {
+			return frontMatter{}, fmt.Errorf("error in front matter of %v: %w", s.name, err)
+		}
+	}
+	// A line too long for the scanner isn't front matter, because it's only on short lines at the start
+	if err := scanner.Err(); err != nil && !errors.Is(err, bufio.ErrTooLong) {
+		return frontMatter{}, fmt.Errorf("error reading migration file %v: %w", s.name, err)
+	}
+	if started {
+		return frontMatter{}, fmt.Errorf("error in front matter of %v: not closed with %v", s.name, frontMatterDelimiter)
+	}
+	return frontMatter{}, nil
+}
+
+// set the key in a "key: value" line of front matter of the named file.
+func (fm *frontMatter) set(name, line string) error {
+	key, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return fmt.Errorf("invalid line %q, must be like key: value", line)
+	}
+	key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+	switch key {
+	case "defer-constraints":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid defer-constraints %q: %w", value, err)
+		}
+		fm.deferConstraints = b
+	case "disable-foreign-keys":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid disable-foreign-keys %q: %w", value, err)
+		}
+		fm.disableForeignKeys = b
+	case "depends-on":
+		fm.dependsOn = strings.Fields(value)
+		fm.hasDependsOn = true
+	case "description":
+		fm.description = value
+	case "no-transaction":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid no-transaction %q: %w", value, err)
+		}
+		fm.noTransaction = b
+	case "online-schema-change":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid online-schema-change %q: %w", value, err)
+		}
+		fm.onlineSchemaChange = b
+	case "requires":
+		requires, err := parseRequirements(name, value)
+		if err != nil {
+			return err
+		}
+		fm.requires = requires
+	case "run-after":
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return fmt.Errorf("invalid run-after %q: %w", value, err)
+		}
+		fm.runAfter = t
+	case "tags":
+		fm.tags = nil
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				fm.tags = append(fm.tags, tag)
+			}
+		}
+	case "timeout":
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid timeout %q: %w", value, err)
+		}
+		fm.timeout = d
+	default:
+		return fmt.Errorf("unknown key %v", key)
+	}
+	return nil
+}
+
+// gate up steps at the first step with a run-after time in the future, returning the steps before it.
+// Versions are applied in order, so the steps after it wait too.
+func (m *Migrator) gate(steps []step) ([]step, error) {
+	now := time.Now()
+	for i, s := range steps {
+		if s.down {
+			return steps, nil
+		}
+		fm, err := m.readFrontMatter(s)
+		if err != nil {
+			return nil, err
+		}
+		if fm.runAfter.After(now) {
+			m.logger.Printf("migrate: %v runs after %v, so it and later migrations are not applied yet",
+				s.name, fm.runAfter.Format(time.RFC3339))
+			return steps[:i], nil
+		}
+	}
+	return steps, nil
+}
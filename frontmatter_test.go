@@ -0,0 +1,136 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestMigrator_FrontMatter(t *testing.T) {
+	t.Run("runs a migration with no-transaction outside a transaction", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		fsys := fstest.MapFS{
+			"1.up.sql": {Data: []byte("-- ---\n-- description: Vacuum\n-- no-transaction: true\n-- ---\nvacuum")},
+		}
+
+		m := migrate.New(migrate.Options{DB: db, FS: fsys})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "1", getVersion(t, db))
+	})
+
+	t.Run("stops a migration after the timeout", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		fsys := fstest.MapFS{
+			"1.up.sql": {Data: []byte("-- ---\n-- timeout: 10ms\n-- ---\ncreate table a (v int)")},
+		}
+
+		m := migrate.New(migrate.Options{
+			DB: db,
+			FS: fsys,
+			Before: func(ctx context.Context, tx *sql.Tx, version string) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+		})
+		err := m.MigrateUp(context.Background())
+		is.True(t, errors.Is(err, context.DeadlineExceeded))
+	})
+
+	t.Run("stops before a migration with run-after in the future", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		fsys := fstest.MapFS{
+			"1.up.sql": {Data: []byte("-- ---\n-- run-after: 2020-01-01T00:00:00Z\n-- ---\nselect 1")},
+			"2.up.sql": {Data: []byte("-- ---\n-- run-after: 2999-01-01T00:00:00Z\n-- ---\nselect 1")},
+			"3.up.sql": {Data: []byte("select 1")},
+		}
+
+		logger := &recordingLogger{}
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, Logger: logger, MissingDown: migrate.CheckIgnore})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "1", getVersion(t, db))
+		is.Equal(t, "[migrate: 2.up.sql runs after 2999-01-01T00:00:00Z, so it and later migrations are not applied yet]", fmt.Sprint(logger.lines))
+	})
+
+	t.Run("errors on invalid front matter", func(t *testing.T) {
+		tests := []struct {
+			content string
+			err     string
+		}{
+			{"-- ---\n-- color: blue\n-- ---\nselect 1", "error in front matter of 1.up.sql: unknown key color"},
+			{"-- ---\n-- timeout: soon\n-- ---\nselect 1", `error in front matter of 1.up.sql: invalid timeout "soon": time: invalid duration "soon"`},
+			{"-- ---\n-- description: Unclosed\nselect 1", "error in front matter of 1.up.sql: not closed with ---"},
+			{"-- ---\n-- defer-constraints: true\n-- no-transaction: true\n-- ---\nselect 1", "error in front matter of 1.up.sql: defer-constraints needs a transaction"},
+		}
+
+		for _, test := range tests {
+			t.Run(test.content, func(t *testing.T) {
+				db := createSQLiteDatabase(t)
+
+				m := migrate.New(migrate.Options{DB: db, FS: fstest.MapFS{"1.up.sql": {Data: []byte(test.content)}}})
+				err := m.MigrateUp(context.Background())
+				is.True(t, err != nil)
+				is.Equal(t, "error migrating up: "+test.err, err.Error())
+				is.Equal(t, "", getVersion(t, db))
+			})
+		}
+	})
+
+	t.Run("checks foreign keys at the end of the transaction with defer-constraints", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		db.SetMaxOpenConns(1)
+		_, err := db.Exec(`pragma foreign_keys = on`)
+		is.NotError(t, err)
+
+		fsys := fstest.MapFS{
+			"1.up.sql": {Data: []byte("create table parents (id int primary key);\n" +
+				"create table children (parent_id int references parents (id))")},
+			"2.up.sql": {Data: []byte("-- ---\n-- defer-constraints: true\n-- ---\n" +
+				"insert into children values (1);\ninsert into parents values (1)")},
+			"3.up.sql": {Data: []byte("insert into children values (2);\ninsert into parents values (2)")},
+		}
+
+		m := migrate.New(migrate.Options{DB: db, Dialect: migrate.SQLite, FS: fsys, MissingDown: migrate.CheckIgnore})
+		err = m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, "2", getVersion(t, db))
+	})
+
+	t.Run("errors on defer-constraints if the dialect can't defer constraints", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		fsys := fstest.MapFS{
+			"1.up.sql": {Data: []byte("-- ---\n-- defer-constraints: true\n-- ---\nselect 1")},
+		}
+
+		m := migrate.New(migrate.Options{DB: db, FS: fsys})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, "error migrating up: error in front matter of 1.up.sql: defer-constraints needs a Dialect that is a ConstraintDeferrer", err.Error())
+	})
+
+	t.Run("waits for the versions in depends-on with Parallel", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		fsys := fstest.MapFS{
+			"1.up.sql": {Data: []byte("create table a (v int)")},
+			"2.up.sql": {Data: []byte("-- ---\n-- depends-on: 1\n-- ---\ninsert into a values (1)")},
+		}
+
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, Parallel: 2})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "2", getVersion(t, db))
+	})
+}
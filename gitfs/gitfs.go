@@ -0,0 +1,72 @@
+// Package gitfs provides an fs.FS of migration files from a git repository at a given ref,
+// like an older tag for a hotfix rollback, without checking it out.
+//
+// It runs the git command, so git must be installed, instead of depending on a Go implementation of git.
+package gitfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"path"
+	"strings"
+
+	"maragu.dev/migrate/internal/memfs"
+)
+
+// Load the files in dir of the git repository at repo, as they are at ref, into memory,
+// returning them as an fs.FS rooted at dir. The ref is anything git can resolve to a commit,
+// like a tag, branch, or commit hash. It's resolved once, so all files are from the same commit.
+// Use "." as dir for the root of the repository.
+func Load(ctx context.Context, repo, ref, dir string) (fs.FS, error) {
+	if !fs.ValidPath(dir) {
+		return nil, fmt.Errorf("error loading from git: invalid directory %v", dir)
+	}
+
+	commit, err := git(ctx, repo, "rev-parse", "--verify", "--end-of-options", ref+"^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("error resolving ref %v: %w", ref, err)
+	}
+	commitHash := strings.TrimSpace(string(commit))
+
+	treeish := commitHash
+	if dir != "." {
+		treeish += ":" + dir
+	}
+	list, err := git(ctx, repo, "ls-tree", "-r", "-z", "--name-only", treeish)
+	if err != nil {
+		return nil, fmt.Errorf("error listing %v at %v: %w", dir, ref, err)
+	}
+
+	files := memfs.FS{}
+	for _, name := range strings.Split(string(list), "\x00") {
+		if name == "" {
+			continue
+		}
+		content, err := git(ctx, repo, "cat-file", "blob", commitHash+":"+path.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("error reading %v at %v: %w", name, ref, err)
+		}
+		files[name] = content
+	}
+	return files, nil
+}
+
+// git runs a git command in repo, returning its output.
+func git(ctx context.Context, repo string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", repo}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stderr.Len() > 0 {
+			return nil, fmt.Errorf("%w: %v", err, strings.TrimSpace(stderr.String()))
+		}
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,82 @@
+package gitfs_test
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate/gitfs"
+)
+
+func TestLoad(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	repo := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=Test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatal(string(out))
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		err := os.MkdirAll(filepath.Join(repo, filepath.Dir(name)), 0o755)
+		is.NotError(t, err)
+		err = os.WriteFile(filepath.Join(repo, name), []byte(content), 0o644)
+		is.NotError(t, err)
+	}
+
+	run("init", "-q")
+	write("sql/migrations/1.up.sql", "create table a (v int)")
+	write("sql/migrations/1.down.sql", "drop table a")
+	write("README.md", "# Test")
+	run("add", "-A")
+	run("commit", "-q", "-m", "First")
+	run("tag", "v1")
+	write("sql/migrations/1.down.sql", "drop table if exists a")
+	write("sql/migrations/2.up.sql", "create table b (v int)")
+	run("add", "-A")
+	run("commit", "-q", "-m", "Second")
+
+	t.Run("loads the files in the directory at the ref", func(t *testing.T) {
+		fsys, err := gitfs.Load(context.Background(), repo, "v1", "sql/migrations")
+		is.NotError(t, err)
+
+		entries, err := fs.ReadDir(fsys, ".")
+		is.NotError(t, err)
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		is.Equal(t, "[1.down.sql 1.up.sql]", fmt.Sprint(names))
+
+		content, err := fs.ReadFile(fsys, "1.down.sql")
+		is.NotError(t, err)
+		is.Equal(t, "drop table a", string(content))
+	})
+
+	t.Run("loads from the root of the repository", func(t *testing.T) {
+		fsys, err := gitfs.Load(context.Background(), repo, "HEAD", ".")
+		is.NotError(t, err)
+
+		content, err := fs.ReadFile(fsys, "sql/migrations/2.up.sql")
+		is.NotError(t, err)
+		is.Equal(t, "create table b (v int)", string(content))
+	})
+
+	t.Run("errors on unknown refs", func(t *testing.T) {
+		_, err := gitfs.Load(context.Background(), repo, "v2", "sql/migrations")
+		is.True(t, err != nil)
+		is.True(t, strings.HasPrefix(err.Error(), "error resolving ref v2: exit status"))
+	})
+}
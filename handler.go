@@ -0,0 +1,316 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HandlerOptions for Handler.
+type HandlerOptions struct {
+	// AllowUp reports whether the request may apply pending migrations, like after checking its credentials.
+	// If not set, applying migrations through the handler is not allowed.
+	AllowUp func(r *http.Request) bool
+}
+
+// Handler for an internal admin router, with JSON endpoints:
+//
+//   - GET /: an HTML page with the status of each migration, with checksums of the up files and durations.
+//   - GET /status: the status of each migration, see Migrator.Status.
+//   - GET /version: the current version.
+//   - GET /plan: the current version and the pending up migrations.
+//   - POST /up: migrate up, if HandlerOptions.AllowUp allows it.
+//
+// The GET endpoints don't create the migrations table, so before migrations have run, the version is empty
+// and no migrations are applied. Each migration has the checksum of its up file, and with Options.History,
+// a checksum problem if the file changed since it was applied.
+// Errors are returned as JSON with an error field. Mount it under a prefix with http.StripPrefix:
+//
+//	mux.Handle("/admin/migrations/", http.StripPrefix("/admin/migrations", migrate.Handler(m, migrate.HandlerOptions{})))
+func Handler(m *Migrator, opts HandlerOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/", "":
+			if !allowMethod(w, r, http.MethodGet) {
+				return
+			}
+			writeStatusPage(w, m, r)
+
+		case "/status":
+			if !allowMethod(w, r, http.MethodGet) {
+				return
+			}
+			_, migrations, err := m.readStatus(r.Context())
+			if err != nil {
+				writeJSONError(w, http.StatusInternalServerError, err)
+				return
+			}
+			ms := []migrationJSON{}
+			for _, hm := range migrations {
+				ms = append(ms, newMigrationJSON(hm))
+			}
+			writeJSON(w, http.StatusOK, ms)
+
+		case "/version":
+			if !allowMethod(w, r, http.MethodGet) {
+				return
+			}
+			version, _, err := m.readVersion(r.Context())
+			if err != nil {
+				writeJSONError(w, http.StatusInternalServerError, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, versionJSON{Version: version})
+
+		case "/plan":
+			if !allowMethod(w, r, http.MethodGet) {
+				return
+			}
+			version, migrations, err := m.readStatus(r.Context())
+			if err != nil {
+				writeJSONError(w, http.StatusInternalServerError, err)
+				return
+			}
+			plan := planJSON{Pending: []migrationJSON{}, Version: version}
+			for _, hm := range migrations {
+				if !hm.Applied {
+					plan.Pending = append(plan.Pending, newMigrationJSON(hm))
+				}
+			}
+			writeJSON(w, http.StatusOK, plan)
+
+		case "/up":
+			if !allowMethod(w, r, http.MethodPost) {
+				return
+			}
+			if opts.AllowUp == nil || !opts.AllowUp(r) {
+				writeJSON(w, http.StatusForbidden, errorJSON{Error: "migrating up is not allowed"})
+				return
+			}
+			if err := m.MigrateUp(r.Context()); err != nil {
+				writeJSONError(w, http.StatusInternalServerError, err)
+				return
+			}
+			version, err := m.currentVersion(r.Context())
+			if err != nil {
+				writeJSONError(w, http.StatusInternalServerError, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, versionJSON{Version: version})
+
+		default:
+			writeJSON(w, http.StatusNotFound, errorJSON{Error: "not found"})
+		}
+	})
+}
+
+// handlerMigration is the status of a migration with the checksum of its up file, for the Handler.
+type handlerMigration struct {
+	MigrationStatus
+	// Checksum of the up file with its included files, hex-encoded SHA-256, or empty if it can't be read.
+	Checksum string
+	// ChecksumProblem with the up file, like that it changed since it was applied, or can't be read.
+	ChecksumProblem string
+}
+
+// ShortChecksum is the start of Checksum, for display.
+func (hm handlerMigration) ShortChecksum() string {
+	if len(hm.Checksum) < 12 {
+		return hm.Checksum
+	}
+	return hm.Checksum[:12]
+}
+
+// readStatus of each migration like Migrator.Status, but without creating the migrations table, and with checksums.
+// Checksum problems are reported for each migration instead of failing the whole status.
+func (m *Migrator) readStatus(ctx context.Context) (string, []handlerMigration, error) {
+	version, tables, err := m.readVersion(ctx)
+	if err != nil {
+		return "", nil, fmt.Errorf("error getting status: %w", err)
+	}
+
+	statuses, err := m.getStatus(ctx, version, tables)
+	if err != nil {
+		return "", nil, fmt.Errorf("error getting status: %w", err)
+	}
+
+	checksums := map[string]sql.NullString{}
+	if tables && m.history {
+		if checksums, err = m.getChecksums(ctx); err != nil {
+			return "", nil, fmt.Errorf("error getting status: %w", err)
+		}
+	}
+
+	var migrations []handlerMigration
+	for _, s := range statuses {
+		hm := handlerMigration{MigrationStatus: s}
+		checksum, err := m.checksum(s.Name)
+		switch {
+		case err != nil:
+			hm.ChecksumProblem = err.Error()
+		case s.Applied && checksums[s.Version].Valid && checksums[s.Version].String != checksum:
+			hm.Checksum = checksum
+			hm.ChecksumProblem = "changed since it was applied with checksum " + checksums[s.Version].String
+		default:
+			hm.Checksum = checksum
+		}
+		migrations = append(migrations, hm)
+	}
+	return version, migrations, nil
+}
+
+type migrationJSON struct {
+	Applied         bool       `json:"applied"`
+	AppliedAt       *time.Time `json:"applied_at,omitempty"`
+	Batch           int64      `json:"batch,omitempty"`
+	Checksum        string     `json:"checksum,omitempty"`
+	ChecksumProblem string     `json:"checksum_problem,omitempty"`
+	Description     string     `json:"description,omitempty"`
+	DurationMS      *int64     `json:"duration_ms,omitempty"`
+	Name            string     `json:"name"`
+	Version         string     `json:"version"`
+}
+
+func newMigrationJSON(hm handlerMigration) migrationJSON {
+	s := hm.MigrationStatus
+	j := migrationJSON{
+		Applied:         s.Applied,
+		Batch:           s.Batch,
+		Checksum:        hm.Checksum,
+		ChecksumProblem: hm.ChecksumProblem,
+		Description:     s.Description,
+		Name:            s.Name,
+		Version:         s.Version,
+	}
+	if !s.AppliedAt.IsZero() {
+		appliedAt := s.AppliedAt
+		durationMS := s.Duration.Milliseconds()
+		j.AppliedAt = &appliedAt
+		j.DurationMS = &durationMS
+	}
+	return j
+}
+
+type versionJSON struct {
+	Version string `json:"version"`
+}
+
+type planJSON struct {
+	Pending []migrationJSON `json:"pending"`
+	Version string          `json:"version"`
+}
+
+type errorJSON struct {
+	Error string `json:"error"`
+}
+
+// allowMethod of the request, writing an error and reporting false if it's not the given method.
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeJSON(w, http.StatusMethodNotAllowed, errorJSON{Error: "method not allowed"})
+	return false
+}
+
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorJSON{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+var statusPage = template.Must(template.New("status").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Migrations</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2rem; color: #222; }
+table { border-collapse: collapse; }
+th, td { padding: 0.4rem 0.8rem; text-align: left; border-bottom: 1px solid #ddd; }
+code { font-size: 0.9em; }
+.pending { color: #a15c00; }
+.applied { color: #1a7f37; }
+.problem { color: #cf222e; }
+</style>
+</head>
+<body>
+<h1>Migrations</h1>
+<p>Current version: <code>{{if .Version}}{{.Version}}{{else}}(none){{end}}</code>, {{.Pending}} pending.</p>
+<table>
+<thead><tr><th>Version</th><th>Status</th><th>Applied at</th><th>Duration</th><th>Checksum</th><th>Description</th></tr></thead>
+<tbody>
+{{range .Migrations}}<tr>
+<td><code>{{.Version}}</code></td>
+{{if .Applied}}<td class="applied">applied</td>{{else}}<td class="pending">pending</td>{{end}}
+<td>{{if not .AppliedAt.IsZero}}{{.AppliedAt.UTC.Format "2006-01-02 15:04:05 UTC"}}{{end}}</td>
+<td>{{if not .AppliedAt.IsZero}}{{.Duration}}{{end}}</td>
+<td><code title="SHA-256 of {{.Name}}">{{.ShortChecksum}}</code>{{if .ChecksumProblem}} <span class="problem">{{.ChecksumProblem}}</span>{{end}}</td>
+<td>{{.Description}}</td>
+</tr>
+{{end}}</tbody>
+</table>
+</body>
+</html>
+`))
+
+type statusPageData struct {
+	Migrations []handlerMigration
+	Pending    int
+	Version    string
+}
+
+func writeStatusPage(w http.ResponseWriter, m *Migrator, r *http.Request) {
+	version, migrations, err := m.readStatus(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := statusPageData{Migrations: migrations, Version: version}
+	for _, hm := range migrations {
+		if !hm.Applied {
+			data.Pending++
+		}
+	}
+
+	var b bytes.Buffer
+	if err := statusPage.Execute(&b, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(b.Bytes())
+}
+
+// checksum of the named migration file with its included files, hex-encoded SHA-256.
+// The file isn't expanded with ExpandEnv, so the checksum doesn't depend on the environment.
+func (m *Migrator) checksum(name string) (string, error) {
+	f, err := m.openIncluding(name, nil)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("error reading migration file %v: %w", name, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
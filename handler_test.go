@@ -0,0 +1,201 @@
+package migrate_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+type handlerMigration struct {
+	Applied         bool    `json:"applied"`
+	AppliedAt       *string `json:"applied_at"`
+	Checksum        string  `json:"checksum"`
+	ChecksumProblem string  `json:"checksum_problem"`
+	Name            string  `json:"name"`
+	Version         string  `json:"version"`
+}
+
+type handlerResponse struct {
+	Error   string             `json:"error"`
+	Pending []handlerMigration `json:"pending"`
+	Version string             `json:"version"`
+}
+
+func TestHandler(t *testing.T) {
+	do := func(t *testing.T, h http.Handler, method, path string, v any) int {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		is.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		err := json.Unmarshal(w.Body.Bytes(), v)
+		is.NotError(t, err)
+		return w.Code
+	}
+
+	t.Run("reports status, version, and plan", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, FS: mustSub(t, testdata, "good"), History: true})
+		err := m.MigrateTo(context.Background(), "2")
+		is.NotError(t, err)
+		h := migrate.Handler(m, migrate.HandlerOptions{})
+
+		var migrations []handlerMigration
+		code := do(t, h, http.MethodGet, "/status", &migrations)
+		is.Equal(t, http.StatusOK, code)
+		is.Equal(t, 3, len(migrations))
+		is.Equal(t, "1", migrations[0].Version)
+		is.True(t, migrations[0].Applied)
+		is.True(t, migrations[0].AppliedAt != nil)
+		is.True(t, !migrations[2].Applied)
+		is.True(t, migrations[2].AppliedAt == nil)
+
+		var res handlerResponse
+		code = do(t, h, http.MethodGet, "/version", &res)
+		is.Equal(t, http.StatusOK, code)
+		is.Equal(t, "2", res.Version)
+
+		res = handlerResponse{}
+		code = do(t, h, http.MethodGet, "/plan", &res)
+		is.Equal(t, http.StatusOK, code)
+		is.Equal(t, "2", res.Version)
+		is.Equal(t, 1, len(res.Pending))
+		is.Equal(t, "3.up.sql", res.Pending[0].Name)
+	})
+
+	t.Run("does not create the migrations table", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		h := migrate.Handler(migrate.New(migrate.Options{DB: db, FS: mustSub(t, testdata, "good"), History: true}), migrate.HandlerOptions{})
+
+		var migrations []handlerMigration
+		code := do(t, h, http.MethodGet, "/status", &migrations)
+		is.Equal(t, http.StatusOK, code)
+		is.Equal(t, 3, len(migrations))
+		is.True(t, !migrations[0].Applied)
+
+		var res handlerResponse
+		code = do(t, h, http.MethodGet, "/plan", &res)
+		is.Equal(t, http.StatusOK, code)
+		is.Equal(t, "", res.Version)
+		is.Equal(t, 3, len(res.Pending))
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		is.Equal(t, http.StatusOK, w.Code)
+
+		var count int
+		err := db.QueryRow(`select count(*) from sqlite_master where type = 'table'`).Scan(&count)
+		is.NotError(t, err)
+		is.Equal(t, 0, count)
+	})
+
+	t.Run("errors if the migrations table can't be read", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		_, err := db.Exec(`create table migrations (v text)`)
+		is.NotError(t, err)
+
+		h := migrate.Handler(migrate.New(migrate.Options{DB: db, FS: mustSub(t, testdata, "good")}), migrate.HandlerOptions{})
+
+		var res handlerResponse
+		code := do(t, h, http.MethodGet, "/plan", &res)
+		is.Equal(t, http.StatusInternalServerError, code)
+		is.True(t, strings.Contains(res.Error, "no such column"))
+	})
+
+	t.Run("reports files changed since they were applied", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		fsys := fstest.MapFS{
+			"1.up.sql": {Data: []byte("create table a (v int)")},
+			"2.up.sql": {Data: []byte("create table b (v int)")},
+		}
+		err := migrate.New(migrate.Options{DB: db, FS: fsys, History: true}).MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		fsys["1.up.sql"] = &fstest.MapFile{Data: []byte("create table a (v int, w int)")}
+		h := migrate.Handler(migrate.New(migrate.Options{DB: db, FS: fsys, History: true}), migrate.HandlerOptions{})
+
+		var migrations []handlerMigration
+		code := do(t, h, http.MethodGet, "/status", &migrations)
+		is.Equal(t, http.StatusOK, code)
+		is.Equal(t, 2, len(migrations))
+		is.Equal(t, 64, len(migrations[0].Checksum))
+		is.True(t, strings.HasPrefix(migrations[0].ChecksumProblem, "changed since it was applied with checksum "))
+		is.Equal(t, "", migrations[1].ChecksumProblem)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		is.Equal(t, http.StatusOK, w.Code)
+		is.True(t, strings.Contains(w.Body.String(), `<span class="problem">changed since it was applied`))
+	})
+
+	t.Run("migrates up only if allowed", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, FS: mustSub(t, testdata, "good")})
+		h := migrate.Handler(m, migrate.HandlerOptions{})
+
+		var res handlerResponse
+		code := do(t, h, http.MethodPost, "/up", &res)
+		is.Equal(t, http.StatusForbidden, code)
+		is.Equal(t, "migrating up is not allowed", res.Error)
+
+		h = migrate.Handler(m, migrate.HandlerOptions{
+			AllowUp: func(r *http.Request) bool {
+				return true
+			},
+		})
+
+		res = handlerResponse{}
+		code = do(t, h, http.MethodGet, "/up", &res)
+		is.Equal(t, http.StatusMethodNotAllowed, code)
+
+		res = handlerResponse{}
+		code = do(t, h, http.MethodPost, "/up", &res)
+		is.Equal(t, http.StatusOK, code)
+		is.Equal(t, "3", res.Version)
+		is.Equal(t, "3", getVersion(t, db))
+	})
+
+	t.Run("renders an HTML status page", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, FS: mustSub(t, testdata, "good"), History: true})
+		err := m.MigrateTo(context.Background(), "2")
+		is.NotError(t, err)
+		h := migrate.Handler(m, migrate.HandlerOptions{})
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		is.Equal(t, http.StatusOK, w.Code)
+		is.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+
+		body := w.Body.String()
+		is.True(t, strings.Contains(body, "Current version: <code>2</code>, 1 pending."))
+		is.Equal(t, 2, strings.Count(body, `<td class="applied">applied</td>`))
+		is.Equal(t, 1, strings.Count(body, `<td class="pending">pending</td>`))
+		is.True(t, strings.Contains(body, `<code title="SHA-256 of 1.up.sql">`))
+	})
+
+	t.Run("reports not found for other paths", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		h := migrate.Handler(migrate.New(migrate.Options{DB: db, FS: mustSub(t, testdata, "good")}), migrate.HandlerOptions{})
+		var res handlerResponse
+		code := do(t, h, http.MethodGet, "/version", &res)
+		is.Equal(t, http.StatusOK, code)
+		is.Equal(t, "", res.Version)
+
+		code = do(t, h, http.MethodGet, "/other", &res)
+		is.Equal(t, http.StatusNotFound, code)
+	})
+}
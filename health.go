@@ -0,0 +1,130 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// IsUpToDate reports whether the database is at or after the version of the newest up migration file,
+// like for a readiness probe, so an app doesn't serve traffic against a schema it doesn't expect.
+// A database after the newest file is up to date, so older app versions stay ready during a rolling deploy.
+// It doesn't create the migrations table, so it errors if migrations have never run.
+func (m *Migrator) IsUpToDate(ctx context.Context) (ok bool, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error checking if up to date: %w", err)
+		}
+	}()
+
+	names, err := m.getFilenames(upMatcher)
+	if err != nil {
+		return false, err
+	}
+	if len(names) == 0 {
+		return true, nil
+	}
+	newest := upMatcher.ReplaceAllString(names[len(names)-1], "$1")
+
+	currentVersion, err := m.getCurrentVersion(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	order, err := m.getVersionOrder()
+	if err != nil {
+		return false, err
+	}
+	return order.compare(currentVersion, newest) >= 0, nil
+}
+
+const (
+	waitMinDelay = 100 * time.Millisecond
+	waitMaxDelay = 5 * time.Second
+)
+
+// WaitForVersion polls the database until it's at or after the given version, or the newest up migration file
+// if version is empty, like for app replicas waiting for a separate job to run the migrations.
+// The delay between polls doubles from 100ms up to 5s. Errors getting the version, like when the migrations table
+// doesn't exist yet, are retried too. Set a deadline on the context to stop waiting,
+// in which case it returns the context error, with the last error getting the version, if any.
+func (m *Migrator) WaitForVersion(ctx context.Context, version string) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error waiting for version %v: %w", version, err)
+		}
+	}()
+
+	if version == "" {
+		names, err := m.getFilenames(upMatcher)
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			return nil
+		}
+		version = upMatcher.ReplaceAllString(names[len(names)-1], "$1")
+	}
+
+	order, err := m.getVersionOrder()
+	if err != nil {
+		return err
+	}
+
+	delay := waitMinDelay
+	var lastErr error
+	for {
+		currentVersion, err := m.getCurrentVersion(ctx)
+		if err == nil && order.compare(currentVersion, version) >= 0 {
+			return nil
+		}
+		lastErr = err
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			if lastErr != nil {
+				return fmt.Errorf("%w, last error: %v", ctx.Err(), lastErr)
+			}
+			return ctx.Err()
+		case <-t.C:
+		}
+
+		delay *= 2
+		if delay > waitMaxDelay {
+			delay = waitMaxDelay
+		}
+	}
+}
+
+// MigrateUpOrWait from the current version if no other Migrator holds the lock,
+// or else waits for the database to get to the newest up migration file, see WaitForVersion.
+// So when many app replicas start at the same time, one of them applies the migrations while the others wait,
+// without running callbacks or recording an audit. Set a deadline on the context to stop waiting.
+// If the leader fails, the others wait until the deadline.
+//
+// It needs a Dialect that is a TryLocker, like Postgres and MySQL. Otherwise, it's the same as MigrateUp,
+// where each Migrator waits for the lock in turn, and then finds nothing to apply.
+func (m *Migrator) MigrateUpOrWait(ctx context.Context) (err error) {
+	ctx, span := m.tracer.Start(ctx, "migrate up or wait")
+	span.SetAttribute("migrate.table", m.table)
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error migrating up or waiting: %w", err)
+		}
+		span.End(err)
+	}()
+
+	called, err := m.trySession(ctx, true, func(s *Migrator) error {
+		return s.run(ctx, "up", func() error {
+			return s.migrateUp(ctx)
+		})
+	})
+	if err != nil || called {
+		return err
+	}
+
+	span.SetAttribute("migrate.waited", "true")
+	return m.WaitForVersion(ctx, "")
+}
@@ -0,0 +1,151 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestMigrator_IsUpToDate(t *testing.T) {
+	t.Run("reports whether the database is at the newest version", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, FS: mustSub(t, testdata, "good")})
+		err := m.MigrateTo(context.Background(), "2")
+		is.NotError(t, err)
+
+		ok, err := m.IsUpToDate(context.Background())
+		is.NotError(t, err)
+		is.True(t, !ok)
+
+		err = m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		ok, err = m.IsUpToDate(context.Background())
+		is.NotError(t, err)
+		is.True(t, ok)
+	})
+
+	t.Run("reports up to date if the database is after the newest file", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, FS: mustSub(t, testdata, "good")})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		m = migrate.New(migrate.Options{DB: db, FS: mustSub(t, testdata, "example")})
+		ok, err := m.IsUpToDate(context.Background())
+		is.NotError(t, err)
+		is.True(t, ok)
+	})
+
+	t.Run("errors if migrations have never run", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, FS: mustSub(t, testdata, "good")})
+		_, err := m.IsUpToDate(context.Background())
+		is.True(t, err != nil)
+		is.True(t, strings.HasPrefix(err.Error(), "error checking if up to date: error getting current migration version: "))
+	})
+}
+
+func TestMigrator_WaitForVersion(t *testing.T) {
+	t.Run("waits until the database is at the version", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, FS: mustSub(t, testdata, "good")})
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			_ = m.MigrateUp(context.Background())
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err := m.WaitForVersion(ctx, "")
+		is.NotError(t, err)
+		is.Equal(t, "3", getVersion(t, db))
+	})
+
+	t.Run("stops waiting when the context is done", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, FS: mustSub(t, testdata, "good")})
+		err := m.MigrateTo(context.Background(), "1")
+		is.NotError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		err = m.WaitForVersion(ctx, "2")
+		is.True(t, errors.Is(err, context.DeadlineExceeded))
+		is.Equal(t, "error waiting for version 2: context deadline exceeded", err.Error())
+	})
+}
+
+// tryLockDialect is SQLite with a lock that's shared by all Migrators using it.
+type tryLockDialect struct {
+	migrate.Dialect
+	locked chan struct{}
+}
+
+func (d tryLockDialect) TryLock(context.Context, *sql.Conn, string) (bool, error) {
+	select {
+	case d.locked <- struct{}{}:
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+func (d tryLockDialect) Unlock(context.Context, *sql.Conn, string) error {
+	<-d.locked
+	return nil
+}
+
+func TestMigrator_MigrateUpOrWait(t *testing.T) {
+	t.Run("migrates up if it gets the lock, and waits otherwise", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		d := tryLockDialect{Dialect: migrate.SQLite, locked: make(chan struct{}, 1)}
+		var runs int64
+		newMigrator := func() *migrate.Migrator {
+			return migrate.New(migrate.Options{
+				BeforeAll: func(ctx context.Context, s migrate.Summary) error {
+					atomic.AddInt64(&runs, 1)
+					return nil
+				},
+				DB:      db,
+				Dialect: d,
+				FS:      mustSub(t, testdata, "good"),
+			})
+		}
+
+		// Hold the lock like another replica migrating
+		d.locked <- struct{}{}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		done := make(chan error)
+		go func() {
+			done <- newMigrator().MigrateUpOrWait(ctx)
+		}()
+
+		time.Sleep(50 * time.Millisecond)
+		<-d.locked
+		err := newMigrator().MigrateUpOrWait(ctx)
+		is.NotError(t, err)
+
+		err = <-done
+		is.NotError(t, err)
+		is.Equal(t, "3", getVersion(t, db))
+		is.Equal(t, int64(1), atomic.LoadInt64(&runs))
+	})
+}
@@ -0,0 +1,322 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// historyTimeLayout is fixed-width, so stored times sort as text.
+const historyTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// MigrationStatus of a migration.
+type MigrationStatus struct {
+	// Applied is whether the migration is at or below the current version.
+	Applied bool
+	// AppliedAt is when the migration was applied, if recorded in the history. Otherwise, it's the zero time.
+	AppliedAt time.Time
+	// Backfills in Options.Backfills that belong to the migration, with their progress.
+	Backfills []BackfillStatus
+	// Batch is the number of the run that applied the migration, if recorded in the history. Otherwise, it's 0.
+	Batch int64
+	// Description from the front matter of the up file, or else the name after the leading number,
+	// like "add accounts" for 1-add_accounts.up.sql. If recorded in the history, it's the description when applied.
+	Description string
+	// Duration of applying the migration, if recorded in the history.
+	Duration time.Duration
+	// Name of the up migration file.
+	Name string
+	// Version from the file name.
+	Version string
+}
+
+// Status of each migration with an up file, in the order they are applied.
+// Set Options.History to also get when each migration was applied, how long it took, and its description then.
+func (m *Migrator) Status(ctx context.Context) (statuses []MigrationStatus, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error getting status: %w", err)
+		}
+	}()
+
+	if err := m.createMigrationsTable(ctx); err != nil {
+		return nil, err
+	}
+
+	currentVersion, err := m.getCurrentVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.getStatus(ctx, currentVersion, true)
+}
+
+// getStatus of each migration at the current version, see Status. The history and backfill tables are only read
+// if tables is true, so it also works before the migrations table is created.
+func (m *Migrator) getStatus(ctx context.Context, currentVersion string, tables bool) ([]MigrationStatus, error) {
+	history := map[string]historyEntry{}
+	var backfills map[string][]BackfillStatus
+	if tables {
+		var err error
+		if m.history {
+			if history, err = m.getHistory(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		if backfills, err = m.backfillStatusesAfter(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	names, err := m.getFilenames(upMatcher)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := m.getVersionOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, name := range names {
+		version := upMatcher.ReplaceAllString(name, "$1")
+		entry := history[version]
+
+		description := entry.description
+		if description == "" {
+			fm, err := m.readFrontMatter(step{name: name})
+			if err != nil {
+				return nil, err
+			}
+			description = describe(fm, version)
+		}
+
+		statuses = append(statuses, MigrationStatus{
+			Applied:     order.compare(version, currentVersion) <= 0,
+			AppliedAt:   entry.appliedAt,
+			Backfills:   backfills[version],
+			Batch:       entry.batch,
+			Description: description,
+			Duration:    entry.duration,
+			Name:        name,
+			Version:     version,
+		})
+	}
+	return statuses, nil
+}
+
+// AppliedMigration recorded in the history table, see Migrator.Applied.
+type AppliedMigration struct {
+	// AppliedAt is when the migration was applied.
+	AppliedAt time.Time
+	// Batch is the number of the run that applied the migration, or 0 if it's not known.
+	Batch int64
+	// Checksum of the up file with its included files when it was applied, hex-encoded SHA-256,
+	// or empty if it was applied before checksums were recorded.
+	Checksum string
+	// Description of the migration when it was applied.
+	Description string
+	// Duration of applying the migration.
+	Duration time.Duration
+	// Version from the file name.
+	Version string
+}
+
+// Applied migrations in the history table, in the order they are applied, like to find out when a migration
+// was applied in an environment. It needs Options.History, and migrations applied without it aren't included.
+func (m *Migrator) Applied(ctx context.Context) (applied []AppliedMigration, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error getting applied migrations: %w", err)
+		}
+	}()
+
+	if !m.history {
+		return nil, errors.New("history is needed, see Options.History")
+	}
+
+	if err := m.createMigrationsTable(ctx); err != nil {
+		return nil, err
+	}
+
+	records, err := m.getHistoryRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range records {
+		applied = append(applied, AppliedMigration{
+			AppliedAt:   r.AppliedAt,
+			Batch:       r.Batch,
+			Checksum:    r.Checksum,
+			Description: r.Description,
+			Duration:    time.Duration(r.DurationMS) * time.Millisecond,
+			Version:     r.Version,
+		})
+	}
+	return applied, nil
+}
+
+// LastApplied migration in the history table, which is the one applied most recently, like to show next to
+// the version of a service. It needs Options.History. If no migrations are recorded, it's the zero AppliedMigration.
+func (m *Migrator) LastApplied(ctx context.Context) (AppliedMigration, error) {
+	applied, err := m.Applied(ctx)
+	if err != nil {
+		return AppliedMigration{}, err
+	}
+
+	var last AppliedMigration
+	for _, a := range applied {
+		if !a.AppliedAt.Before(last.AppliedAt) {
+			last = a
+		}
+	}
+	return last, nil
+}
+
+// historyEntry is a row in the history table.
+type historyEntry struct {
+	appliedAt   time.Time
+	batch       int64
+	description string
+	duration    time.Duration
+}
+
+// describe a migration with the description from its front matter, or else its version without the leading number.
+func describe(fm frontMatter, version string) string {
+	if fm.description != "" {
+		return fm.description
+	}
+	slug := strings.TrimLeft(version, "0123456789")
+	slug = strings.TrimLeft(slug, "-_")
+	return strings.NewReplacer("-", " ", "_", " ").Replace(slug)
+}
+
+// historyTable name.
+func (m *Migrator) historyTable() string {
+	return m.table + "_history"
+}
+
+// updateHistory with a step that took the given duration to apply, and its description.
+// Up migrations are recorded, and down migrations remove the record of the migration they revert.
+func (m *Migrator) updateHistory(ctx context.Context, q queryer, s step, description string, duration time.Duration) error {
+	// Normally we wouldn't just string interpolate values like this,
+	// but because we know the version has been matched against the regexes, and we create the rest, we know it's safe.
+	query := `delete from ` + m.dialect.Quote(m.historyTable()) + ` where version = '` + s.fileVersion + `'`
+	if !s.down {
+		var err error
+		query, err = m.insertHistory(s, description, time.Now(), duration, strconv.FormatInt(m.historyBatch, 10))
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := q.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("error updating history for version %v: %w", s.fileVersion, err)
+	}
+	return nil
+}
+
+// insertHistory returns SQL to record the up migration of a step in the history table, with the checksum of its file,
+// and the batch as SQL, like a number or null.
+func (m *Migrator) insertHistory(s step, description string, appliedAt time.Time, duration time.Duration, batch string) (string, error) {
+	checksum, err := m.checksum(s.name)
+	if err != nil {
+		return "", err
+	}
+
+	columns := `version, applied_at, duration_ms, batch, checksum`
+	values := `'` + s.fileVersion + `', '` + appliedAt.UTC().Format(historyTimeLayout) + `', ` +
+		strconv.FormatInt(duration.Milliseconds(), 10) + `, ` + batch + `, '` + checksum + `'`
+
+	if description != "" {
+		columns += `, description`
+		values += `, ` + quoteString(m.dialect, description)
+	}
+
+	if m.storeDown {
+		downSQL, ok, err := m.readDown(s.fileVersion)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			columns += `, down_sql`
+			values += `, ` + quoteString(m.dialect, downSQL)
+		}
+	}
+
+	return `insert into ` + m.dialect.Quote(m.historyTable()) + ` (` + columns + `) values (` + values + `)`, nil
+}
+
+// readDown migration file for the version with its included files, base64-encoded so it's stored unchanged
+// whatever the database does with quotes and backslashes in string literals. Reports false if there is no down file.
+func (m *Migrator) readDown(version string) (string, bool, error) {
+	name := version + ".down.sql"
+	f, err := m.open(step{name: name})
+	if err != nil {
+		if _, statErr := fs.Stat(m.fs, name); errors.Is(statErr, fs.ErrNotExist) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	content, err := io.ReadAll(f)
+	_ = f.Close()
+	if err != nil {
+		return "", false, fmt.Errorf("error reading migration file %v: %w", name, err)
+	}
+	return base64.StdEncoding.EncodeToString(content), true, nil
+}
+
+// getLastBatch number in the history table, or 0 if there are no batches.
+func (m *Migrator) getLastBatch(ctx context.Context) (int64, error) {
+	var batch int64
+	query := `select coalesce(max(batch), 0) from ` + m.dialect.Quote(m.historyTable())
+	if err := m.conn.QueryRowContext(ctx, query).Scan(&batch); err != nil {
+		return 0, fmt.Errorf("error getting last batch: %w", err)
+	}
+	return batch, nil
+}
+
+// getHistory by version.
+func (m *Migrator) getHistory(ctx context.Context) (map[string]historyEntry, error) {
+	rows, err := m.conn.QueryContext(ctx, `select version, applied_at, duration_ms, batch, description from `+m.dialect.Quote(m.historyTable()))
+	if err != nil {
+		return nil, fmt.Errorf("error getting history: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	history := map[string]historyEntry{}
+	for rows.Next() {
+		var version, appliedAt string
+		var durationMS int64
+		var batch sql.NullInt64
+		var description sql.NullString
+		if err := rows.Scan(&version, &appliedAt, &durationMS, &batch, &description); err != nil {
+			return nil, fmt.Errorf("error scanning history: %w", err)
+		}
+		t, err := time.Parse(historyTimeLayout, appliedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing applied_at %v of version %v: %w", appliedAt, version, err)
+		}
+		history[version] = historyEntry{
+			appliedAt:   t,
+			batch:       batch.Int64,
+			description: description.String,
+			duration:    time.Duration(durationMS) * time.Millisecond,
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error getting history: %w", err)
+	}
+	return history, nil
+}
@@ -0,0 +1,119 @@
+package migrate_test
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestMigrator_Applied(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1-accounts.up.sql": {Data: []byte("-- ---\n-- description: Add the accounts table\n-- ---\ncreate table accounts (id int);")},
+		"2-users.up.sql":    {Data: []byte("create table users (id int);")},
+		"3-posts.up.sql":    {Data: []byte("create table posts (id int);")},
+	}
+
+	t.Run("lists the applied migrations with when they were applied and their checksums", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, History: true, MissingDown: migrate.CheckIgnore})
+
+		before := time.Now().Add(-time.Second)
+		err := m.MigrateTo(context.Background(), "1-accounts")
+		is.NotError(t, err)
+		err = m.MigrateTo(context.Background(), "2-users")
+		is.NotError(t, err)
+
+		applied, err := m.Applied(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, 2, len(applied))
+
+		is.Equal(t, "1-accounts", applied[0].Version)
+		is.Equal(t, "Add the accounts table", applied[0].Description)
+		is.Equal(t, int64(1), applied[0].Batch)
+		is.True(t, applied[0].AppliedAt.After(before))
+		is.Equal(t, 64, len(applied[0].Checksum))
+
+		is.Equal(t, "2-users", applied[1].Version)
+		is.Equal(t, "users", applied[1].Description)
+		is.Equal(t, int64(2), applied[1].Batch)
+	})
+
+	t.Run("lists the applied migrations in the order they were applied, not by name", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		fsys := fstest.MapFS{
+			"9-accounts.up.sql": {Data: []byte("create table accounts (id int);")},
+			"10-users.up.sql":   {Data: []byte("create table users (id int);")},
+			"migrations.list":   {Data: []byte("9-accounts.up.sql\n10-users.up.sql\n")},
+		}
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, History: true})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		applied, err := m.Applied(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, 2, len(applied))
+		is.Equal(t, "9-accounts", applied[0].Version)
+		is.Equal(t, "10-users", applied[1].Version)
+	})
+
+	t.Run("records history with a Dialect that isn't a HistoryTableCreator", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, Dialect: testDialect{Dialect: migrate.SQLite}, FS: fsys, History: true,
+			MissingDown: migrate.CheckIgnore})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		applied, err := m.Applied(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, 3, len(applied))
+	})
+
+	t.Run("errors without history", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, MissingDown: migrate.CheckIgnore})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		_, err = m.Applied(context.Background())
+		is.Equal(t, "error getting applied migrations: history is needed, see Options.History", err.Error())
+	})
+}
+
+func TestMigrator_LastApplied(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1-accounts.up.sql":   {Data: []byte("create table accounts (id int);")},
+		"1-accounts.down.sql": {Data: []byte("drop table accounts;")},
+		"2-users.up.sql":      {Data: []byte("create table users (id int);")},
+		"2-users.down.sql":    {Data: []byte("drop table users;")},
+	}
+
+	t.Run("gets the most recently applied migration", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, History: true})
+
+		last, err := m.LastApplied(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "", last.Version)
+		is.True(t, last.AppliedAt.IsZero())
+
+		err = m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		last, err = m.LastApplied(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "2-users", last.Version)
+		is.True(t, !last.AppliedAt.IsZero())
+
+		err = m.MigrateTo(context.Background(), "1-accounts")
+		is.NotError(t, err)
+
+		last, err = m.LastApplied(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "1-accounts", last.Version)
+	})
+}
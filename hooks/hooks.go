@@ -0,0 +1,145 @@
+// Package hooks provides ready-made callbacks and integrations for migrate.Options, like posting run summaries to a webhook
+// and altering tables online with gh-ost.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"maragu.dev/migrate"
+)
+
+// Format of the webhook payload.
+type Format int
+
+const (
+	// Generic JSON payload with all fields of the run summary.
+	Generic Format = iota
+	// Slack incoming webhook payload, with the summary as text.
+	Slack
+	// Teams incoming webhook payload, with the summary as text.
+	Teams
+)
+
+// Webhook posts run summaries to URL.
+// Use its methods as the BeforeAll, AfterAll, and OnError callbacks in migrate.Options:
+//
+//	w := hooks.Webhook{Format: hooks.Slack, URL: "https://hooks.slack.com/services/..."}
+//	m := migrate.New(migrate.Options{AfterAll: w.AfterAll, DB: db, FS: fsys, OnError: w.OnError})
+type Webhook struct {
+	// Client for the requests. Defaults to http.DefaultClient.
+	Client *http.Client
+	Format Format
+	URL    string
+}
+
+// BeforeAll posts that a run is starting. An error aborts the run.
+func (w Webhook) BeforeAll(ctx context.Context, s migrate.Summary) error {
+	return w.post(ctx, "before", s)
+}
+
+// AfterAll posts that a run succeeded. An error fails the run.
+func (w Webhook) AfterAll(ctx context.Context, s migrate.Summary) error {
+	return w.post(ctx, "after", s)
+}
+
+// OnError posts that a run failed. Errors from posting are ignored, as the run has already failed.
+func (w Webhook) OnError(ctx context.Context, s migrate.Summary) {
+	_ = w.post(ctx, "error", s)
+}
+
+type genericPayload struct {
+	Event       string   `json:"event"`
+	Operation   string   `json:"operation"`
+	FromVersion string   `json:"from_version"`
+	ToVersion   string   `json:"to_version"`
+	Applied     []string `json:"applied"`
+	DurationMS  int64    `json:"duration_ms"`
+	Error       string   `json:"error,omitempty"`
+}
+
+type textPayload struct {
+	Text string `json:"text"`
+}
+
+func (w Webhook) post(ctx context.Context, event string, s migrate.Summary) error {
+	var payload any
+	switch w.Format {
+	case Slack, Teams:
+		payload = textPayload{Text: text(event, s)}
+	default:
+		p := genericPayload{
+			Event:       event,
+			Operation:   s.Operation,
+			FromVersion: s.FromVersion,
+			ToVersion:   s.ToVersion,
+			Applied:     s.Applied,
+			DurationMS:  s.Duration.Milliseconds(),
+		}
+		if p.Applied == nil {
+			p.Applied = []string{}
+		}
+		if s.Err != nil {
+			p.Error = s.Err.Error()
+		}
+		payload = p
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error marshalling webhook payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error posting webhook: %w", err)
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("error posting webhook, got status %v", res.StatusCode)
+	}
+	return nil
+}
+
+// text summary of a run, for chat messages.
+func text(event string, s migrate.Summary) string {
+	switch event {
+	case "before":
+		return fmt.Sprintf("Migrating %v from version %v.", s.Operation, versionOrNone(s.FromVersion))
+	case "after":
+		t := fmt.Sprintf("Migrated %v from version %v to %v in %v.", s.Operation, versionOrNone(s.FromVersion),
+			versionOrNone(s.ToVersion), s.Duration.Round(time.Millisecond))
+		if len(s.Applied) > 0 {
+			t += " Applied: " + strings.Join(s.Applied, ", ") + "."
+		}
+		return t
+	default:
+		return fmt.Sprintf("Error migrating %v from version %v, stopped at %v: %v", s.Operation,
+			versionOrNone(s.FromVersion), versionOrNone(s.ToVersion), s.Err)
+	}
+}
+
+func versionOrNone(v string) string {
+	if v == "" {
+		return "(none)"
+	}
+	return v
+}
@@ -0,0 +1,110 @@
+package hooks_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+	"maragu.dev/migrate/hooks"
+)
+
+func TestWebhook(t *testing.T) {
+	summary := migrate.Summary{
+		Applied:     []string{"1.up.sql", "2.up.sql"},
+		Duration:    1500 * time.Millisecond,
+		FromVersion: "",
+		Operation:   "up",
+		ToVersion:   "2",
+	}
+
+	t.Run("posts a generic JSON summary after all", func(t *testing.T) {
+		var body map[string]any
+		s := newServer(t, http.StatusOK, &body)
+
+		w := hooks.Webhook{URL: s.URL}
+		err := w.AfterAll(context.Background(), summary)
+		is.NotError(t, err)
+
+		is.Equal(t, "after", body["event"].(string))
+		is.Equal(t, "up", body["operation"].(string))
+		is.Equal(t, "", body["from_version"].(string))
+		is.Equal(t, "2", body["to_version"].(string))
+		is.Equal(t, float64(1500), body["duration_ms"].(float64))
+		is.Equal(t, 2, len(body["applied"].([]any)))
+		_, ok := body["error"]
+		is.True(t, !ok)
+	})
+
+	t.Run("posts text for Slack before all", func(t *testing.T) {
+		var body map[string]any
+		s := newServer(t, http.StatusOK, &body)
+
+		w := hooks.Webhook{Format: hooks.Slack, URL: s.URL}
+		err := w.BeforeAll(context.Background(), migrate.Summary{Operation: "down", FromVersion: "2"})
+		is.NotError(t, err)
+
+		is.Equal(t, "Migrating down from version 2.", body["text"].(string))
+	})
+
+	t.Run("posts text for Teams after all", func(t *testing.T) {
+		var body map[string]any
+		s := newServer(t, http.StatusOK, &body)
+
+		w := hooks.Webhook{Format: hooks.Teams, URL: s.URL}
+		err := w.AfterAll(context.Background(), summary)
+		is.NotError(t, err)
+
+		is.Equal(t, "Migrated up from version (none) to 2 in 1.5s. Applied: 1.up.sql, 2.up.sql.", body["text"].(string))
+	})
+
+	t.Run("posts the error on error", func(t *testing.T) {
+		var body map[string]any
+		s := newServer(t, http.StatusOK, &body)
+
+		w := hooks.Webhook{URL: s.URL}
+		failed := summary
+		failed.Err = errors.New("oh no")
+		w.OnError(context.Background(), failed)
+
+		is.Equal(t, "error", body["event"].(string))
+		is.Equal(t, "oh no", body["error"].(string))
+	})
+
+	t.Run("errors on non-2xx status", func(t *testing.T) {
+		var body map[string]any
+		s := newServer(t, http.StatusInternalServerError, &body)
+
+		w := hooks.Webhook{URL: s.URL}
+		err := w.AfterAll(context.Background(), summary)
+		is.True(t, err != nil)
+		is.Equal(t, "error posting webhook, got status 500", err.Error())
+	})
+}
+
+func newServer(t *testing.T, status int, body *map[string]any) *httptest.Server {
+	t.Helper()
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Error("unexpected request", r.Method, r.Header.Get("Content-Type"))
+		}
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		if err := json.Unmarshal(b, body); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
@@ -0,0 +1,96 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// GhOst alters tables online with the gh-ost command, as a migrate.OnlineSchemaChanger:
+//
+//	g := hooks.GhOst{Args: []string{"--host=db", "--user=migrate", "--allow-on-master"}, Database: "shop"}
+//	m := migrate.New(migrate.Options{DB: db, Dialect: migrate.MySQL, FS: fsys, OnlineSchemaChanger: g})
+//
+// A table qualified with its database, like "shop.orders", overrides Database.
+type GhOst struct {
+	// Args for gh-ost besides --database, --table, --alter, and --execute, like connection and throttling flags.
+	Args     []string
+	Database string
+	// Output of the command as it runs, like os.Stderr. Defaults to discarding it.
+	Output io.Writer
+	// Path of the gh-ost command. Defaults to looking up gh-ost in PATH.
+	Path string
+}
+
+// Alter the table with gh-ost.
+func (g GhOst) Alter(ctx context.Context, table, alter string) error {
+	database, table := splitTable(g.Database, table)
+	args := append(g.Args[:len(g.Args):len(g.Args)], "--database="+database, "--table="+table, "--alter="+alter, "--execute")
+	return run(ctx, orDefault(g.Path, "gh-ost"), args, g.Output)
+}
+
+// PTOnlineSchemaChange alters tables online with the pt-online-schema-change command from Percona Toolkit,
+// as a migrate.OnlineSchemaChanger. A table qualified with its database, like "shop.orders", overrides Database.
+type PTOnlineSchemaChange struct {
+	// Args for pt-online-schema-change besides --alter and --execute, like throttling flags.
+	Args     []string
+	Database string
+	// DSN of the server without the database and table, like "h=db,u=migrate".
+	DSN string
+	// Output of the command as it runs, like os.Stderr. Defaults to discarding it.
+	Output io.Writer
+	// Path of the pt-online-schema-change command. Defaults to looking up pt-online-schema-change in PATH.
+	Path string
+}
+
+// Alter the table with pt-online-schema-change.
+func (p PTOnlineSchemaChange) Alter(ctx context.Context, table, alter string) error {
+	database, table := splitTable(p.Database, table)
+	dsn := "D=" + database + ",t=" + table
+	if p.DSN != "" {
+		dsn = p.DSN + "," + dsn
+	}
+	args := append(p.Args[:len(p.Args):len(p.Args)], "--alter="+alter, "--execute", dsn)
+	return run(ctx, orDefault(p.Path, "pt-online-schema-change"), args, p.Output)
+}
+
+// run the command, with the end of its output in the error if it fails.
+func run(ctx context.Context, path string, args []string, output io.Writer) error {
+	var buf bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, args...)
+	if output != nil {
+		cmd.Stdout = io.MultiWriter(&buf, output)
+	} else {
+		cmd.Stdout = &buf
+	}
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Run(); err != nil {
+		out := strings.TrimSpace(buf.String())
+		if len(out) > 1000 {
+			out = "..." + out[len(out)-1000:]
+		}
+		if out == "" {
+			return fmt.Errorf("error running %v: %w", path, err)
+		}
+		return fmt.Errorf("error running %v: %w: %v", path, err, out)
+	}
+	return nil
+}
+
+// splitTable into the database and the table, if it's qualified, otherwise the database is the given default.
+func splitTable(database, table string) (string, string) {
+	if i := strings.LastIndex(table, "."); i >= 0 {
+		return table[:i], table[i+1:]
+	}
+	return database, table
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
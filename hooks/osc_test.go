@@ -0,0 +1,77 @@
+package hooks_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+	"maragu.dev/migrate/hooks"
+)
+
+// fakeCommand that writes its arguments to a file, one per line, and fails if the first argument is "fail".
+func fakeCommand(t *testing.T) (path, argsPath string) {
+	t.Helper()
+	dir := t.TempDir()
+	argsPath = filepath.Join(dir, "args")
+	path = filepath.Join(dir, "cmd")
+	script := "#!/bin/sh\nprintf '%s\\n' \"$@\" > " + argsPath + "\n[ \"$1\" = fail ] && echo oh no && exit 1\nexit 0\n"
+	err := os.WriteFile(path, []byte(script), 0o755)
+	is.NotError(t, err)
+	return path, argsPath
+}
+
+func readArgs(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	is.NotError(t, err)
+	return strings.TrimSpace(string(b))
+}
+
+func TestGhOst_Alter(t *testing.T) {
+	var _ migrate.OnlineSchemaChanger = hooks.GhOst{}
+
+	t.Run("runs gh-ost with the table and alteration", func(t *testing.T) {
+		path, argsPath := fakeCommand(t)
+		g := hooks.GhOst{Args: []string{"--host=db"}, Database: "shop", Path: path}
+
+		err := g.Alter(context.Background(), "orders", "add column note text")
+		is.NotError(t, err)
+		is.Equal(t, "--host=db\n--database=shop\n--table=orders\n--alter=add column note text\n--execute", readArgs(t, argsPath))
+	})
+
+	t.Run("uses the database of a qualified table", func(t *testing.T) {
+		path, argsPath := fakeCommand(t)
+		g := hooks.GhOst{Database: "shop", Path: path}
+
+		err := g.Alter(context.Background(), "billing.invoices", "drop column note")
+		is.NotError(t, err)
+		is.Equal(t, "--database=billing\n--table=invoices\n--alter=drop column note\n--execute", readArgs(t, argsPath))
+	})
+
+	t.Run("errors with the output if the command fails", func(t *testing.T) {
+		path, _ := fakeCommand(t)
+		g := hooks.GhOst{Args: []string{"fail"}, Path: path}
+
+		err := g.Alter(context.Background(), "orders", "add column note text")
+		is.True(t, err != nil)
+		is.Equal(t, "error running "+path+": exit status 1: oh no", err.Error())
+	})
+}
+
+func TestPTOnlineSchemaChange_Alter(t *testing.T) {
+	var _ migrate.OnlineSchemaChanger = hooks.PTOnlineSchemaChange{}
+
+	t.Run("runs pt-online-schema-change with the alteration and DSN", func(t *testing.T) {
+		path, argsPath := fakeCommand(t)
+		p := hooks.PTOnlineSchemaChange{Database: "shop", DSN: "h=db,u=migrate", Path: path}
+
+		err := p.Alter(context.Background(), "orders", "add column note text")
+		is.NotError(t, err)
+		is.Equal(t, "--alter=add column note text\n--execute\nh=db,u=migrate,D=shop,t=orders", readArgs(t, argsPath))
+	})
+}
@@ -0,0 +1,101 @@
+// Package httpfs provides an fs.FS of migration files fetched over HTTP, like from a release server.
+package httpfs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"maragu.dev/migrate/internal/memfs"
+)
+
+// Source of migration files on a server, listed in a manifest in the format of sha256sum:
+//
+//	3b5d5c3712955042212316173ccf37be800c5c4d1a2f4c2e8b5e5d4e1d1a5e7f  1-accounts.up.sql
+//	9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08  1-accounts.down.sql
+//
+// Create it with something like "sha256sum *.sql > SHA256SUMS". Files are fetched relative to the manifest URL,
+// and their checksums are verified against the manifest. The checksums only protect against files changing
+// or being corrupted, so fetch the manifest over HTTPS from a server you trust.
+type Source struct {
+	// Client for the requests. Defaults to http.DefaultClient.
+	Client *http.Client
+	// URL of the manifest.
+	URL string
+}
+
+// Load the manifest and all files in it into memory, returning them as an fs.FS.
+// It errors without returning any files if a file can't be fetched or doesn't match its checksum.
+func (s Source) Load(ctx context.Context) (fs.FS, error) {
+	base, err := url.Parse(s.URL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing manifest URL: %w", err)
+	}
+
+	manifest, err := s.get(ctx, base)
+	if err != nil {
+		return nil, fmt.Errorf("error getting manifest: %w", err)
+	}
+
+	files := memfs.FS{}
+	scanner := bufio.NewScanner(bytes.NewReader(manifest))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		sum, name, ok := strings.Cut(text, " ")
+		// sha256sum marks files read in binary mode with an asterisk
+		name = strings.TrimPrefix(strings.TrimSpace(name), "*")
+		if !ok || len(sum) != sha256.Size*2 || !fs.ValidPath(name) {
+			return nil, fmt.Errorf("error parsing manifest line %v: must be a sha256 checksum and a file name", line)
+		}
+
+		content, err := s.get(ctx, base.ResolveReference(&url.URL{Path: name}))
+		if err != nil {
+			return nil, fmt.Errorf("error getting %v: %w", name, err)
+		}
+		actual := sha256.Sum256(content)
+		if !strings.EqualFold(hex.EncodeToString(actual[:]), sum) {
+			return nil, fmt.Errorf("error verifying %v: checksum is %x, but the manifest has %v", name, actual, sum)
+		}
+		files[name] = content
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading manifest: %w", err)
+	}
+	return files, nil
+}
+
+func (s Source) get(ctx context.Context, u *url.URL) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, fmt.Errorf("got status %v", res.StatusCode)
+	}
+	return io.ReadAll(res.Body)
+}
@@ -0,0 +1,91 @@
+package httpfs_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate/httpfs"
+)
+
+func TestSource_Load(t *testing.T) {
+	files := map[string]string{
+		"1.up.sql":          "create table a (v int)",
+		"1.down.sql":        "drop table a",
+		"common/grants.sql": "grant select on a to reader",
+	}
+
+	newServer := func(t *testing.T, manifest string) *httptest.Server {
+		t.Helper()
+		mux := http.NewServeMux()
+		mux.HandleFunc("/release/SHA256SUMS", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(manifest))
+		})
+		mux.HandleFunc("/release/", func(w http.ResponseWriter, r *http.Request) {
+			content, ok := files[strings.TrimPrefix(r.URL.Path, "/release/")]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write([]byte(content))
+		})
+		s := httptest.NewServer(mux)
+		t.Cleanup(s.Close)
+		return s
+	}
+
+	sum := func(s string) string {
+		return fmt.Sprintf("%x", sha256.Sum256([]byte(s)))
+	}
+
+	t.Run("loads the files in the manifest", func(t *testing.T) {
+		manifest := sum(files["1.up.sql"]) + "  1.up.sql\n" +
+			sum(files["1.down.sql"]) + " *1.down.sql\n" +
+			"\n" +
+			sum(files["common/grants.sql"]) + "  common/grants.sql\n"
+		s := newServer(t, manifest)
+
+		fsys, err := httpfs.Source{URL: s.URL + "/release/SHA256SUMS"}.Load(context.Background())
+		is.NotError(t, err)
+
+		content, err := fs.ReadFile(fsys, "1.down.sql")
+		is.NotError(t, err)
+		is.Equal(t, "drop table a", string(content))
+
+		content, err = fs.ReadFile(fsys, "common/grants.sql")
+		is.NotError(t, err)
+		is.Equal(t, "grant select on a to reader", string(content))
+	})
+
+	t.Run("errors if a checksum doesn't match", func(t *testing.T) {
+		wrong := sum("drop table b")
+		s := newServer(t, wrong+"  1.down.sql\n")
+
+		_, err := httpfs.Source{URL: s.URL + "/release/SHA256SUMS"}.Load(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, "error verifying 1.down.sql: checksum is "+sum("drop table a")+", but the manifest has "+wrong, err.Error())
+	})
+
+	t.Run("errors if a file is missing", func(t *testing.T) {
+		s := newServer(t, sum("")+"  2.up.sql\n")
+
+		_, err := httpfs.Source{URL: s.URL + "/release/SHA256SUMS"}.Load(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, "error getting 2.up.sql: got status 404", err.Error())
+	})
+
+	t.Run("errors on invalid manifest lines", func(t *testing.T) {
+		s := newServer(t, "1.up.sql\n")
+
+		_, err := httpfs.Source{URL: s.URL + "/release/SHA256SUMS"}.Load(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, "error parsing manifest line 1: must be a sha256 checksum and a file name", err.Error())
+	})
+}
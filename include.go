@@ -0,0 +1,94 @@
+package migrate
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// includeDirective on its own line in a migration file is replaced by the content of the named file
+// from the migration FS, which may include other files itself:
+//
+//	-- migrate:include common/updated_at_trigger.sql
+const includeDirective = "-- migrate:include "
+
+// includeReader reads a file, replacing lines with the include directive by the included files.
+type includeReader struct {
+	// chain of files including this one, to detect cycles.
+	chain    []string
+	closer   io.Closer
+	included io.ReadCloser
+	m        *Migrator
+	pending  string
+	r        *bufio.Reader
+}
+
+// openIncluding the named file, which is included by the files in chain, if any.
+func (m *Migrator) openIncluding(name string, chain []string) (io.ReadCloser, error) {
+	for _, c := range chain {
+		if c == name {
+			return nil, fmt.Errorf("error including %v in %v: include cycle", name, chain[len(chain)-1])
+		}
+	}
+
+	f, err := m.fs.Open(name)
+	if err != nil {
+		if len(chain) > 0 {
+			return nil, fmt.Errorf("error including %v in %v: %w", name, chain[len(chain)-1], err)
+		}
+		return nil, fmt.Errorf("error reading migration file %v: %w", name, err)
+	}
+	return &includeReader{chain: append(chain[:len(chain):len(chain)], name), closer: f, m: m, r: bufio.NewReader(f)}, nil
+}
+
+func (i *includeReader) Read(p []byte) (int, error) {
+	for {
+		if i.included != nil {
+			n, err := i.included.Read(p)
+			if errors.Is(err, io.EOF) {
+				_ = i.included.Close()
+				i.included = nil
+				// End the included content with a newline, so it doesn't run into the next line
+				i.pending = "\n"
+				err = nil
+			}
+			if n > 0 || err != nil {
+				return n, err
+			}
+			continue
+		}
+
+		if i.pending != "" {
+			n := copy(p, i.pending)
+			i.pending = i.pending[n:]
+			return n, nil
+		}
+
+		line, err := i.r.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, err
+		}
+		if line == "" {
+			return 0, io.EOF
+		}
+
+		if name := strings.TrimPrefix(strings.TrimSpace(line), includeDirective); name != strings.TrimSpace(line) {
+			included, err := i.m.openIncluding(strings.TrimSpace(name), i.chain)
+			if err != nil {
+				return 0, err
+			}
+			i.included = included
+			continue
+		}
+		i.pending = line
+	}
+}
+
+func (i *includeReader) Close() error {
+	if i.included != nil {
+		_ = i.included.Close()
+	}
+	return i.closer.Close()
+}
@@ -0,0 +1,66 @@
+package migrate_test
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestMigrator_Include(t *testing.T) {
+	t.Run("replaces include directives with the included files", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		fsys := fstest.MapFS{
+			"1.up.sql":          {Data: []byte("create table a (v int);\n-- migrate:include common/grants.sql\ninsert into a values (3);")},
+			"common/grants.sql": {Data: []byte("insert into a values (1);\n-- migrate:include common/more.sql")},
+			"common/more.sql":   {Data: []byte("insert into a values (2); -- no newline at the end")},
+		}
+
+		for _, split := range []bool{false, true} {
+			m := migrate.New(migrate.Options{DB: db, FS: fsys, SplitStatements: split})
+			err := m.MigrateUp(context.Background())
+			is.NotError(t, err)
+
+			var sum int
+			err = db.QueryRow(`select sum(v) from a`).Scan(&sum)
+			is.NotError(t, err)
+			is.Equal(t, 6, sum)
+
+			_, err = db.Exec(`drop table a; update migrations set version = ''`)
+			is.NotError(t, err)
+		}
+	})
+
+	t.Run("errors on include cycles", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		fsys := fstest.MapFS{
+			"1.up.sql": {Data: []byte("-- migrate:include a.sql")},
+			"a.sql":    {Data: []byte("-- migrate:include b.sql")},
+			"b.sql":    {Data: []byte("-- migrate:include a.sql")},
+		}
+
+		m := migrate.New(migrate.Options{DB: db, FS: fsys})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, "error migrating up: error reading migration file 1.up.sql: error including a.sql in b.sql: include cycle", err.Error())
+		is.Equal(t, "", getVersion(t, db))
+	})
+
+	t.Run("errors on missing included files", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		fsys := fstest.MapFS{
+			"1.up.sql": {Data: []byte("-- migrate:include missing.sql")},
+		}
+
+		m := migrate.New(migrate.Options{DB: db, FS: fsys})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, "error migrating up: error reading migration file 1.up.sql: error including missing.sql in 1.up.sql: open missing.sql: file does not exist", err.Error())
+	})
+}
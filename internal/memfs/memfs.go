@@ -0,0 +1,182 @@
+// Package memfs provides a read-only fs.FS of files in memory, for migration sources that load all files up front.
+package memfs
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FS of file contents by slash-separated path. Directories are implied by the paths of the files in them.
+type FS map[string][]byte
+
+// Open the named file or directory.
+func (f FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if data, ok := f[name]; ok {
+		return &file{info: info{name: name, size: int64(len(data))}, r: bytes.NewReader(data)}, nil
+	}
+	entries, ok := f.entries(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &dir{entries: entries, info: info{dir: true, name: name}}, nil
+}
+
+// ReadDir of the named directory, sorted by name.
+func (f FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	entries, ok := f.entries(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	return entries, nil
+}
+
+// ReadFile contents of the named file. The caller may modify the returned slice.
+func (f FS) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrInvalid}
+	}
+	data, ok := f[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrNotExist}
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// entries of the named directory, reporting whether it exists.
+func (f FS) entries(name string) ([]fs.DirEntry, bool) {
+	prefix := ""
+	if name != "." {
+		prefix = name + "/"
+	}
+
+	seen := map[string]bool{}
+	var entries []fs.DirEntry
+	for path, data := range f {
+		rest := strings.TrimPrefix(path, prefix)
+		if rest == path && prefix != "" {
+			continue
+		}
+		child, _, isDir := strings.Cut(rest, "/")
+		if seen[child] {
+			continue
+		}
+		seen[child] = true
+		entry := info{dir: isDir, name: child}
+		if !isDir {
+			entry.size = int64(len(data))
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 && name != "." {
+		return nil, false
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, true
+}
+
+// info about a file or directory, as both fs.FileInfo and fs.DirEntry.
+type info struct {
+	dir  bool
+	name string
+	size int64
+}
+
+func (i info) Name() string {
+	if i.name == "." {
+		return "."
+	}
+	return i.name[strings.LastIndex(i.name, "/")+1:]
+}
+
+func (i info) Size() int64 {
+	return i.size
+}
+
+func (i info) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
+
+func (i info) ModTime() time.Time {
+	return time.Time{}
+}
+
+func (i info) IsDir() bool {
+	return i.dir
+}
+
+func (i info) Sys() any {
+	return nil
+}
+
+func (i info) Type() fs.FileMode {
+	return i.Mode().Type()
+}
+
+func (i info) Info() (fs.FileInfo, error) {
+	return i, nil
+}
+
+type file struct {
+	info info
+	r    *bytes.Reader
+}
+
+func (f *file) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	return f.r.Read(p)
+}
+
+func (f *file) Close() error {
+	return nil
+}
+
+type dir struct {
+	entries []fs.DirEntry
+	info    info
+	offset  int
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fs.ErrInvalid}
+}
+
+func (d *dir) Close() error {
+	return nil
+}
+
+// ReadDir like fs.ReadDirFile.
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(rest) {
+		n = len(rest)
+	}
+	d.offset += n
+	return rest[:n], nil
+}
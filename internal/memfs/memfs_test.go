@@ -0,0 +1,21 @@
+package memfs_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate/internal/memfs"
+)
+
+func TestFS(t *testing.T) {
+	f := memfs.FS{
+		"1.up.sql":          []byte("create table a (v int)"),
+		"1.down.sql":        []byte("drop table a"),
+		"common/grants.sql": []byte("grant select on a to reader"),
+		"common/x/y.sql":    []byte(""),
+	}
+	err := fstest.TestFS(f, "1.up.sql", "1.down.sql", "common/grants.sql", "common/x/y.sql")
+	is.NotError(t, err)
+}
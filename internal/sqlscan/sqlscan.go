@@ -0,0 +1,333 @@
+// Package sqlscan splits SQL into tokens, statements, and words, for the textual checks of migrations in this module.
+// Quotes, comments, and Postgres dollar-quoted strings are recognized, so semicolons and words in them are left alone.
+// Backslashes are not treated as escape characters in quotes.
+package sqlscan
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Kind of a Token.
+type Kind int
+
+const (
+	// Code is a run of anything outside quotes and comments, except whitespace and semicolons.
+	Code Kind = iota
+	// Comment is a line comment with its newline, or a block comment.
+	Comment
+	// Quoted is a string literal, a quoted identifier, or a dollar-quoted string, with its quotes.
+	Quoted
+	// Semicolon ends a statement.
+	Semicolon
+	// Space is a whitespace character.
+	Space
+)
+
+// Token of SQL, from a Lexer.
+type Token struct {
+	Kind Kind
+	Text string
+}
+
+// Lexer reads SQL a Token at a time.
+type Lexer struct {
+	err   error
+	prev  byte
+	r     *bufio.Reader
+	token Token
+}
+
+func NewLexer(r io.Reader) *Lexer {
+	return &Lexer{r: bufio.NewReader(r)}
+}
+
+// Scan to the next token, returning false when there are no more tokens or on error.
+func (l *Lexer) Scan() bool {
+	c, err := l.r.ReadByte()
+	if err != nil {
+		if err != io.EOF {
+			l.err = err
+		}
+		return false
+	}
+
+	var b strings.Builder
+	b.WriteByte(c)
+	kind := Code
+	ok := true
+	switch {
+	case c == '-' && l.peekIs("-"):
+		kind = Comment
+		ok = l.write(&b, "-") && l.readUntil(&b, "\n")
+
+	case c == '/' && l.peekIs("*"):
+		kind = Comment
+		ok = l.write(&b, "*") && l.readUntil(&b, "*/")
+
+	case c == '\'' || c == '"' || c == '`':
+		// Doubled quotes inside work too, as they look like two quoted strings next to each other
+		kind = Quoted
+		ok = l.readUntil(&b, string(c))
+
+	case c == '$' && !IsIdentifierByte(l.prev) && l.peekDollarTag() != "":
+		tag := l.peekDollarTag()
+		kind = Quoted
+		ok = l.write(&b, tag[1:]) && l.readUntil(&b, tag)
+
+	case c == ';':
+		kind = Semicolon
+
+	case IsSpace(c):
+		kind = Space
+
+	default:
+		ok = l.readCode(&b)
+	}
+	if !ok {
+		return false
+	}
+
+	l.token = Token{Kind: kind, Text: b.String()}
+	l.prev = l.token.Text[len(l.token.Text)-1]
+	return true
+}
+
+// Token found by the last call to Scan.
+func (l *Lexer) Token() Token {
+	return l.token
+}
+
+// Err is the first non-EOF error encountered by Scan.
+func (l *Lexer) Err() error {
+	return l.err
+}
+
+// peekIs reports whether the next bytes are prefix, without consuming them.
+func (l *Lexer) peekIs(prefix string) bool {
+	next, _ := l.r.Peek(len(prefix))
+	return string(next) == prefix
+}
+
+// peekDollarTag after a dollar sign, returning the closing tag (like "$$" or "$body$") if there is one.
+func (l *Lexer) peekDollarTag() string {
+	const maxTagLength = 64
+	for n := 1; n <= maxTagLength; n++ {
+		next, _ := l.r.Peek(n)
+		if len(next) < n {
+			return ""
+		}
+		c := next[n-1]
+		if c == '$' {
+			return "$" + string(next)
+		}
+		// Tags are like identifiers, so they can't start with a digit, which also rules out placeholders like $1
+		if !IsIdentifierByte(c) || n == 1 && c >= '0' && c <= '9' {
+			return ""
+		}
+	}
+	return ""
+}
+
+// write the rest of an opening sequence to b, consuming it from the reader.
+// Reports false on errors.
+func (l *Lexer) write(b *strings.Builder, rest string) bool {
+	if _, err := l.r.Discard(len(rest)); err != nil {
+		l.err = err
+		return false
+	}
+	b.WriteString(rest)
+	return true
+}
+
+// readUntil end has been read, writing everything read to b, including end.
+// Reports false on errors other than EOF.
+func (l *Lexer) readUntil(b *strings.Builder, end string) bool {
+	for n := 1; ; n++ {
+		c, err := l.r.ReadByte()
+		if err == io.EOF {
+			return true
+		}
+		if err != nil {
+			l.err = err
+			return false
+		}
+		b.WriteByte(c)
+		// Only match end in what's read here, not in the opening sequence before it
+		if n >= len(end) && c == end[len(end)-1] && strings.HasSuffix(b.String(), end) {
+			return true
+		}
+	}
+}
+
+// readCode to b until the next byte starts another token. A dollar sign always does, so its next Scan can check
+// whether it starts a dollar-quoted string. Reports false on errors other than EOF.
+func (l *Lexer) readCode(b *strings.Builder) bool {
+	for {
+		next, err := l.r.Peek(2)
+		if len(next) == 0 {
+			if err != io.EOF {
+				l.err = err
+				return false
+			}
+			return true
+		}
+		c := next[0]
+		if IsSpace(c) || c == ';' || c == '\'' || c == '"' || c == '`' || c == '$' ||
+			len(next) == 2 && (c == '-' && next[1] == '-' || c == '/' && next[1] == '*') {
+			return true
+		}
+		_, _ = l.r.ReadByte()
+		b.WriteByte(c)
+	}
+}
+
+// Scanner splits SQL into statements on semicolons outside quotes and comments.
+// Statements with only whitespace and comments are skipped.
+type Scanner struct {
+	l         *Lexer
+	statement string
+}
+
+func NewScanner(r io.Reader) *Scanner {
+	return &Scanner{l: NewLexer(r)}
+}
+
+// Scan to the next statement, returning false when there are no more statements or on error.
+func (s *Scanner) Scan() bool {
+	var b strings.Builder
+	hasCode := false
+	for s.l.Scan() {
+		t := s.l.Token()
+		switch t.Kind {
+		case Semicolon:
+			if hasCode {
+				s.statement = strings.TrimSpace(b.String())
+				return true
+			}
+			b.Reset()
+			continue
+		case Code, Quoted:
+			hasCode = true
+		}
+		b.WriteString(t.Text)
+	}
+	if s.l.Err() != nil || !hasCode {
+		return false
+	}
+	s.statement = strings.TrimSpace(b.String())
+	return true
+}
+
+// Statement found by the last call to Scan, with its comments.
+func (s *Scanner) Statement() string {
+	return s.statement
+}
+
+// Err is the first non-EOF error encountered by Scan.
+func (s *Scanner) Err() error {
+	return s.l.Err()
+}
+
+// Split sql into statements like a Scanner.
+func Split(sql string) []string {
+	var statements []string
+	s := NewScanner(strings.NewReader(sql))
+	for s.Scan() {
+		statements = append(statements, s.Statement())
+	}
+	return statements
+}
+
+// tokens of sql.
+func tokens(sql string) []Token {
+	var ts []Token
+	l := NewLexer(strings.NewReader(sql))
+	for l.Scan() {
+		ts = append(ts, l.Token())
+	}
+	return ts
+}
+
+// StripComments from sql, replacing line comments with a newline and block comments with a space.
+func StripComments(sql string) string {
+	var b strings.Builder
+	for _, t := range tokens(sql) {
+		switch {
+		case t.Kind != Comment:
+			b.WriteString(t.Text)
+		case strings.HasPrefix(t.Text, "--"):
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(' ')
+		}
+	}
+	return b.String()
+}
+
+// Normalize a statement by removing its comments, and collapsing whitespace outside quotes to single spaces.
+func Normalize(statement string) string {
+	var b strings.Builder
+	space := false
+	for _, t := range tokens(statement) {
+		switch t.Kind {
+		case Comment, Space:
+			space = true
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteString(t.Text)
+	}
+	return b.String()
+}
+
+// Words of a statement, lowercase, without comments, and with each string literal as a pair of single quotes.
+// Quoted identifiers are unquoted, so "public"."accounts" is public.accounts.
+// Words are separated by whitespace, parentheses, commas, and semicolons.
+func Words(statement string) []string {
+	var words []string
+	var b strings.Builder
+	flush := func() {
+		if b.Len() > 0 {
+			words = append(words, strings.ToLower(b.String()))
+			b.Reset()
+		}
+	}
+
+	for _, t := range tokens(statement) {
+		switch {
+		case t.Kind == Code:
+			for i := 0; i < len(t.Text); i++ {
+				switch c := t.Text[i]; c {
+				case '(', ')', ',':
+					flush()
+				default:
+					b.WriteByte(c)
+				}
+			}
+		case t.Kind == Quoted && (t.Text[0] == '"' || t.Text[0] == '`'):
+			b.WriteString(strings.TrimSuffix(t.Text[1:], t.Text[:1]))
+		case t.Kind == Quoted:
+			flush()
+			words = append(words, "''")
+		default:
+			flush()
+		}
+	}
+	flush()
+	return words
+}
+
+// IsIdentifierByte reports whether c can be part of an unquoted identifier.
+func IsIdentifierByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// IsSpace reports whether c is whitespace.
+func IsSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
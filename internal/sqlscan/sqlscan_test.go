@@ -0,0 +1,68 @@
+package sqlscan_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate/internal/sqlscan"
+)
+
+func TestScanner(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{"splits on semicolons", "select 1; select 2;", []string{"select 1", "select 2"}},
+		{"keeps the last statement without semicolon", "select 1;\nselect 2", []string{"select 1", "select 2"}},
+		{"skips empty statements", ";; select 1;;\n;", []string{"select 1"}},
+		{"skips comment-only statements", "select 1;\n-- the end\n", []string{"select 1"}},
+		{"ignores semicolons in single quotes", "insert into t values ('a;b'); select 1", []string{"insert into t values ('a;b')", "select 1"}},
+		{"ignores semicolons in doubled quotes", "insert into t values ('it''s;'); select 1", []string{"insert into t values ('it''s;')", "select 1"}},
+		{"ignores semicolons in double quotes", `create table "a;b" (v text); select 1`, []string{`create table "a;b" (v text)`, "select 1"}},
+		{"ignores semicolons in backticks", "create table `a;b` (v text); select 1", []string{"create table `a;b` (v text)", "select 1"}},
+		{"ignores semicolons in line comments", "select 1 -- a; b\n; select 2", []string{"select 1 -- a; b", "select 2"}},
+		{"ignores semicolons in block comments", "select /* a; b */ 1; select 2", []string{"select /* a; b */ 1", "select 2"}},
+		{"does not end block comments early", "select /*/ a; */ 1; select 2", []string{"select /*/ a; */ 1", "select 2"}},
+		{"ignores semicolons in dollar quotes", "create function f() returns void as $$ begin; end; $$ language plpgsql; select 1",
+			[]string{"create function f() returns void as $$ begin; end; $$ language plpgsql", "select 1"}},
+		{"ignores semicolons in tagged dollar quotes", "do $body$ begin; select '$$'; end $body$; select 1",
+			[]string{"do $body$ begin; select '$$'; end $body$", "select 1"}},
+		{"does not treat placeholders as dollar quotes", "select $1; select $2", []string{"select $1", "select $2"}},
+		{"does not treat dollar signs in identifiers as dollar quotes", "select a$b$; select 2", []string{"select a$b$", "select 2"}},
+		{"returns nothing for empty input", "", nil},
+		{"returns nothing for whitespace and comments", " \n-- hi\n/* there */\n", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var statements []string
+			s := sqlscan.NewScanner(strings.NewReader(test.input))
+			for s.Scan() {
+				statements = append(statements, s.Statement())
+			}
+			is.NotError(t, s.Err())
+			is.Equal(t, len(test.expected), len(statements))
+			for i := range test.expected {
+				is.Equal(t, test.expected[i], statements[i])
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	t.Run("removes comments and collapses whitespace outside quotes", func(t *testing.T) {
+		is.Equal(t, "alter table accounts add column kind text default 'a  b'",
+			sqlscan.Normalize("-- Kind\nalter table  accounts\n\tadd column /* the kind */ kind\ntext default 'a  b'\n"))
+	})
+}
+
+func TestWords(t *testing.T) {
+	t.Run("lowercases words without comments, and unquotes identifiers", func(t *testing.T) {
+		words := sqlscan.Words(`ALTER TABLE "public"."Accounts" /* a */ add (v) -- b` + "\n" + `default 'x y', $$z$$;`)
+		is.Equal(t, "[alter table public.accounts add v default '' '']", fmt.Sprint(words))
+	})
+}
@@ -0,0 +1,161 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"maragu.dev/migrate/internal/sqlscan"
+)
+
+// Maintenance of the tables touched by the up migrations of a run, after they are all applied,
+// so the query planner doesn't work with stale statistics right after a big schema change. See Options.Maintenance.
+type Maintenance int
+
+const (
+	// MaintenanceNone does no maintenance, and is the default.
+	MaintenanceNone Maintenance = iota
+	// MaintenanceAnalyze updates the statistics of the tables, like with analyze on Postgres and SQLite,
+	// and analyze table on MySQL.
+	MaintenanceAnalyze
+	// MaintenanceOptimize also reclaims space, like with vacuum analyze on Postgres and optimize table on MySQL,
+	// which may take a long time on large tables.
+	MaintenanceOptimize
+)
+
+// Maintainer is a Dialect that can maintain tables after migrations. See Options.Maintenance.
+type Maintainer interface {
+	// Maintain returns SQL to maintain the table, or the empty string if there's nothing to do for the Maintenance.
+	// The SQL runs outside a transaction. The table name is already quoted.
+	Maintain(table string, mt Maintenance) string
+}
+
+func (postgresDialect) Maintain(table string, mt Maintenance) string {
+	switch mt {
+	case MaintenanceAnalyze:
+		return `analyze ` + table
+	case MaintenanceOptimize:
+		return `vacuum analyze ` + table
+	default:
+		return ""
+	}
+}
+
+// Maintain with analyze only, because Redshift vacuums in the background and can't vacuum and analyze at once.
+func (redshiftDialect) Maintain(table string, mt Maintenance) string {
+	if mt == MaintenanceNone {
+		return ""
+	}
+	return `analyze ` + table
+}
+
+func (mysqlDialect) Maintain(table string, mt Maintenance) string {
+	switch mt {
+	case MaintenanceAnalyze:
+		return `analyze table ` + table
+	case MaintenanceOptimize:
+		return `optimize table ` + table
+	default:
+		return ""
+	}
+}
+
+// Maintain with analyze only, because vacuum in SQLite is for the whole database.
+func (sqliteDialect) Maintain(table string, mt Maintenance) string {
+	if mt == MaintenanceNone {
+		return ""
+	}
+	return `analyze ` + table
+}
+
+var touchedTableMatcher = regexp.MustCompile("(?is)^(?:" +
+	"create\\s+table\\s+(?:if\\s+not\\s+exists\\s+)?|" +
+	"alter\\s+table\\s+(?:if\\s+exists\\s+)?(?:only\\s+)?|" +
+	"create\\s+(?:unique\\s+)?index\\s+(?:concurrently\\s+)?(?:if\\s+not\\s+exists\\s+)?[\\w`\"]*\\s*on\\s+(?:only\\s+)?|" +
+	"insert\\s+into\\s+|update\\s+|delete\\s+from\\s+)([\\w.`\"]+)")
+
+// parseTouchedTable of a statement that creates, alters, indexes, or changes the rows of a table, if it does.
+// Leading comment lines are skipped.
+func parseTouchedTable(statement string) (string, bool) {
+	match := touchedTableMatcher.FindStringSubmatch(trimCommentLines(statement))
+	if match == nil {
+		return "", false
+	}
+	return unquoteIdentifier(match[1]), true
+}
+
+// trimCommentLines at the start of a statement, and surrounding whitespace.
+func trimCommentLines(statement string) string {
+	lines := strings.Split(strings.TrimSpace(statement), "\n")
+	for len(lines) > 0 && (strings.HasPrefix(strings.TrimSpace(lines[0]), "--") || strings.TrimSpace(lines[0]) == "") {
+		lines = lines[1:]
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// unquoteIdentifier by removing double quotes and backticks.
+func unquoteIdentifier(identifier string) string {
+	return strings.NewReplacer("`", "", `"`, "").Replace(identifier)
+}
+
+// maintain the tables touched by the up migrations of the steps, if the Dialect is a Maintainer.
+// The migrations are already applied, so failures are logged as warnings instead of failing the run.
+func (m *Migrator) maintain(ctx context.Context, steps []step) error {
+	maintainer, ok := m.dialect.(Maintainer)
+	if m.maintenance == MaintenanceNone || !ok {
+		return nil
+	}
+
+	var tables []string
+	seen := map[string]bool{}
+	for _, s := range steps {
+		if s.down {
+			continue
+		}
+		touched, err := m.touchedTables(s)
+		if err != nil {
+			return err
+		}
+		for _, table := range touched {
+			if !seen[table] {
+				seen[table] = true
+				tables = append(tables, table)
+			}
+		}
+	}
+
+	for _, table := range tables {
+		query := maintainer.Maintain(m.dialect.Quote(table), m.maintenance)
+		if query == "" {
+			continue
+		}
+		if _, err := m.conn.ExecContext(ctx, query); err != nil {
+			m.logger.Printf("migrate: warning: error maintaining table %v: %v", table, err)
+		}
+	}
+	return nil
+}
+
+// touchedTables by the statements in the file of the step, with its included files, in the order they're touched.
+func (m *Migrator) touchedTables(s step) ([]string, error) {
+	f, err := m.open(s)
+	if err != nil {
+		return nil, fmt.Errorf("error reading migration file %v: %w", s.name, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var tables []string
+	scanner := sqlscan.NewScanner(f)
+	for scanner.Scan() {
+		if table, ok := parseTouchedTable(scanner.Statement()); ok {
+			tables = append(tables, table)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading migration file %v: %w", s.name, err)
+	}
+	return tables, nil
+}
@@ -0,0 +1,59 @@
+package migrate_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestMigrator_Maintenance(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1-accounts.up.sql": {Data: []byte("create table accounts (id int, name text);\ncreate index accounts_name on accounts (name);")},
+		"2-users.up.sql": {Data: []byte(`create table users (id int, name text);
+-- Index it too
+create index if not exists users_name on "users" (name);
+insert into accounts values (1, 'a'), (2, 'b');
+insert into users values (1, 'a');`)},
+	}
+
+	t.Run("analyzes the tables touched by the applied migrations", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, Dialect: migrate.SQLite, FS: fsys, Maintenance: migrate.MaintenanceAnalyze,
+			MissingDown: migrate.CheckIgnore})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		var tables []string
+		rows, err := db.Query(`select distinct tbl from sqlite_stat1 order by tbl`)
+		is.NotError(t, err)
+		defer func() {
+			_ = rows.Close()
+		}()
+		for rows.Next() {
+			var table string
+			is.NotError(t, rows.Scan(&table))
+			tables = append(tables, table)
+		}
+		is.NotError(t, rows.Err())
+		is.Equal(t, "[accounts users]", fmt.Sprint(tables))
+	})
+
+	t.Run("does no maintenance by default", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, Dialect: migrate.SQLite, FS: fsys, MissingDown: migrate.CheckIgnore})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		var count int
+		err = db.QueryRow(`select count(*) from sqlite_master where name = 'sqlite_stat1'`).Scan(&count)
+		is.NotError(t, err)
+		is.Equal(t, 0, count)
+	})
+}
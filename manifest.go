@@ -0,0 +1,153 @@
+package migrate
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ManifestFileName of the optional manifest in the migrations FS, which lists the up migration files
+// in the order they're applied, one per line, instead of in the order of their names:
+//
+//	# Applied from top to bottom
+//	1-accounts.up.sql
+//	3-invoices.up.sql
+//	2-accounts_email.up.sql
+//
+// So two branches that each add a migration conflict in the manifest when merged, instead of their versions
+// being applied in whatever order their names sort. Blank lines and lines starting with # are ignored.
+// Up migration files that aren't listed are an error, and so are listed files that don't exist.
+// Create appends new migrations to it, if it exists.
+const ManifestFileName = "migrations.list"
+
+// versionOrder of the versions listed in the manifest. If nil, versions are ordered by name.
+type versionOrder map[string]int
+
+// compare versions like strings.Compare, with listed versions in the order of the manifest.
+// Versions that aren't listed, like the empty version and baselines, come before the listed ones, by name.
+func (o versionOrder) compare(a, b string) int {
+	if o == nil {
+		return strings.Compare(a, b)
+	}
+	ra, aListed := o[a]
+	rb, bListed := o[b]
+	switch {
+	case aListed && bListed:
+		return ra - rb
+	case aListed:
+		return 1
+	case bListed:
+		return -1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+// ReadManifest of the migrations in fsys, returning the versions it lists in order, or nil if there is no manifest.
+// It errors on a manifest that doesn't match the up migration files, like a Migrator does. See ManifestFileName.
+func ReadManifest(fsys fs.FS) ([]string, error) {
+	order, err := (&Migrator{fs: fsys}).getVersionOrder()
+	if err != nil || order == nil {
+		return nil, err
+	}
+	versions := make([]string, len(order))
+	for version, i := range order {
+		versions[i] = version
+	}
+	return versions, nil
+}
+
+// getVersionOrder from the manifest, checking it against the up migration files. See ManifestFileName.
+// Returns nil if there is no manifest.
+func (m *Migrator) getVersionOrder() (versionOrder, error) {
+	if m.cache != nil && m.cache.orderKnown {
+		return m.cache.order, nil
+	}
+
+	content, err := fs.ReadFile(m.fs, ManifestFileName)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("error reading %v: %w", ManifestFileName, err)
+		}
+		if m.cache != nil {
+			m.cache.orderKnown = true
+		}
+		return nil, nil
+	}
+
+	order := versionOrder{}
+	listed := map[string]bool{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for i := 1; scanner.Scan(); i++ {
+		name := strings.TrimSpace(scanner.Text())
+		if name == "" || strings.HasPrefix(name, "#") {
+			continue
+		}
+		if !upMatcher.MatchString(name) {
+			return nil, fmt.Errorf("error in %v on line %v: %v isn't an up migration file", ManifestFileName, i, name)
+		}
+		if listed[name] {
+			return nil, fmt.Errorf("error in %v on line %v: %v is listed twice", ManifestFileName, i, name)
+		}
+		listed[name] = true
+		order[upMatcher.ReplaceAllString(name, "$1")] = len(order)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %v: %w", ManifestFileName, err)
+	}
+
+	entries, err := m.readDir()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !upMatcher.MatchString(entry.Name()) {
+			continue
+		}
+		if !listed[entry.Name()] {
+			return nil, fmt.Errorf("error in %v: %v isn't listed", ManifestFileName, entry.Name())
+		}
+		delete(listed, entry.Name())
+	}
+	if len(listed) > 0 {
+		var missing []string
+		for name := range listed {
+			missing = append(missing, name)
+		}
+		sort.Strings(missing)
+		return nil, fmt.Errorf("error in %v: listed files don't exist: %v", ManifestFileName, strings.Join(missing, ", "))
+	}
+
+	if m.cache != nil {
+		m.cache.order = order
+		m.cache.orderKnown = true
+	}
+	return order, nil
+}
+
+// appendToManifest in dir the up migration file name, if there is a manifest. See ManifestFileName.
+func appendToManifest(dir, name string) error {
+	path := filepath.Join(dir, ManifestFileName)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("error reading %v: %w", path, err)
+	}
+
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		content = append(content, '\n')
+	}
+	content = append(content, name+"\n"...)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("error writing %v: %w", path, err)
+	}
+	return nil
+}
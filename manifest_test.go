@@ -0,0 +1,121 @@
+package migrate_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestMigrator_Manifest(t *testing.T) {
+	fsys := func(manifest string) fstest.MapFS {
+		return fstest.MapFS{
+			"1-accounts.up.sql":   {Data: []byte("create table accounts (id integer primary key)")},
+			"1-accounts.down.sql": {Data: []byte("drop table accounts")},
+			"2-email.up.sql":      {Data: []byte("alter table accounts add column email text")},
+			"2-email.down.sql":    {Data: []byte("alter table accounts drop column email")},
+			"3-invoices.up.sql":   {Data: []byte("create table invoices (id integer primary key)")},
+			"3-invoices.down.sql": {Data: []byte("drop table invoices")},
+			"migrations.list":     {Data: []byte(manifest)},
+		}
+	}
+
+	t.Run("applies migrations in the order of the manifest", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, FS: fsys("# Order\n1-accounts.up.sql\n3-invoices.up.sql\n\n2-email.up.sql\n")})
+
+		err := m.MigrateTo(context.Background(), "3-invoices")
+		is.NotError(t, err)
+		is.Equal(t, "3-invoices", getVersion(t, db))
+		_, err = db.Exec(`select email from accounts`)
+		is.True(t, err != nil)
+
+		err = m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "2-email", getVersion(t, db))
+
+		err = m.DownTo(context.Background(), "1-accounts")
+		is.NotError(t, err)
+		is.Equal(t, "1-accounts", getVersion(t, db))
+		_, err = db.Exec(`select id from invoices`)
+		is.True(t, err != nil)
+	})
+
+	t.Run("applies migrations in parallel in the order of the manifest", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		db.SetMaxOpenConns(1)
+		fsys := fsys("1-accounts.up.sql\n3-invoices.up.sql\n2-email.up.sql\n4-orders.up.sql\n")
+		fsys["4-orders.up.sql"] = &fstest.MapFile{Data: []byte("-- ---\n-- depends-on: 3-invoices\n-- ---\ncreate table orders (id integer)")}
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, MissingDown: migrate.CheckIgnore, Parallel: 2})
+
+		err := m.MigrateTo(context.Background(), "3-invoices")
+		is.NotError(t, err)
+
+		err = m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "4-orders", getVersion(t, db))
+	})
+
+	t.Run("rolls back the last batch in the order of the manifest", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, FS: fsys("1-accounts.up.sql\n3-invoices.up.sql\n2-email.up.sql\n"), History: true})
+
+		err := m.MigrateTo(context.Background(), "1-accounts")
+		is.NotError(t, err)
+		err = m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		err = m.RollbackLastBatch(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "1-accounts", getVersion(t, db))
+	})
+
+	t.Run("errors on up migration files that aren't listed", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, FS: fsys("1-accounts.up.sql\n3-invoices.up.sql\n")})
+
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, "error migrating up: error in migrations.list: 2-email.up.sql isn't listed", err.Error())
+	})
+
+	t.Run("errors on listed files that don't exist", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db,
+			FS: fsys("1-accounts.up.sql\n2-email.up.sql\n3-invoices.up.sql\n4-orders.up.sql\n")})
+
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, "error migrating up: error in migrations.list: listed files don't exist: 4-orders.up.sql", err.Error())
+	})
+
+	t.Run("errors on files listed twice", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db,
+			FS: fsys("1-accounts.up.sql\n2-email.up.sql\n1-accounts.up.sql\n3-invoices.up.sql\n")})
+
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, "error migrating up: error in migrations.list on line 3: 1-accounts.up.sql is listed twice", err.Error())
+	})
+}
+
+func TestCreate_Manifest(t *testing.T) {
+	t.Run("appends to the manifest if there is one", func(t *testing.T) {
+		dir := t.TempDir()
+		err := os.WriteFile(filepath.Join(dir, migrate.ManifestFileName), []byte("# Order"), 0644)
+		is.NotError(t, err)
+
+		_, _, err = migrate.Create(dir, "accounts", migrate.CreateOptions{Numbering: migrate.Sequence})
+		is.NotError(t, err)
+
+		content, err := os.ReadFile(filepath.Join(dir, migrate.ManifestFileName))
+		is.NotError(t, err)
+		is.Equal(t, "# Order\n0001-accounts.up.sql\n", string(content))
+	})
+}
@@ -0,0 +1,75 @@
+package migrate
+
+import (
+	"context"
+	"time"
+)
+
+// Metrics receives measurements from the Migrator, if set in Options.
+// It's a small interface so the package doesn't depend on a metrics library.
+// For Prometheus, an adapter could look like this:
+//
+//	type promMetrics struct {
+//		applied, failed prometheus.Counter
+//		duration       prometheus.Histogram
+//		pending        prometheus.Gauge
+//	}
+//
+//	func (p promMetrics) Applied(version string, d time.Duration) {
+//		p.applied.Inc()
+//		p.duration.Observe(d.Seconds())
+//	}
+//
+//	func (p promMetrics) Failed(version string, d time.Duration) {
+//		p.failed.Inc()
+//		p.duration.Observe(d.Seconds())
+//	}
+//
+//	func (p promMetrics) Pending(count int) {
+//		p.pending.Set(float64(count))
+//	}
+type Metrics interface {
+	// Applied is called after a migration to version was applied, with how long it took.
+	Applied(version string, duration time.Duration)
+
+	// Failed is called after a migration to version failed, with how long it took.
+	Failed(version string, duration time.Duration)
+
+	// Pending is called at the start of each run with the number of migrations the run will apply,
+	// and again after each applied migration with the number left.
+	Pending(count int)
+}
+
+// VersionMetrics is Metrics that also receives the versions of each run, like the expvarmetrics package.
+type VersionMetrics interface {
+	Metrics
+
+	// Versions is called at the start of each run with the current version and the version the run migrates to.
+	Versions(current, target string)
+}
+
+// reportVersions of a run applying steps to the Metrics, if it's VersionMetrics.
+func (m *Migrator) reportVersions(ctx context.Context, steps []step) error {
+	vm, ok := m.metrics.(VersionMetrics)
+	if !ok {
+		return nil
+	}
+	current, err := m.getCurrentVersion(ctx)
+	if err != nil {
+		return err
+	}
+	target := current
+	if len(steps) > 0 {
+		target = steps[len(steps)-1].version
+	}
+	vm.Versions(current, target)
+	return nil
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) Applied(string, time.Duration) {}
+
+func (noopMetrics) Failed(string, time.Duration) {}
+
+func (noopMetrics) Pending(int) {}
@@ -0,0 +1,63 @@
+package migrate_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+type recordingMetrics struct {
+	applied []string
+	failed  []string
+	pending []int
+}
+
+func (r *recordingMetrics) Applied(version string, duration time.Duration) {
+	r.applied = append(r.applied, version)
+}
+
+func (r *recordingMetrics) Failed(version string, duration time.Duration) {
+	r.failed = append(r.failed, version)
+}
+
+func (r *recordingMetrics) Pending(count int) {
+	r.pending = append(r.pending, count)
+}
+
+func TestMigrator_Metrics(t *testing.T) {
+	t.Run("records applied migrations and pending counts", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		metrics := &recordingMetrics{}
+
+		m := migrate.New(migrate.Options{DB: db, FS: mustSub(t, testdata, "good"), Metrics: metrics})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		is.Equal(t, "[1 2 3]", fmt.Sprint(metrics.applied))
+		is.Equal(t, 0, len(metrics.failed))
+		is.Equal(t, "[3 2 1 0]", fmt.Sprint(metrics.pending))
+
+		err = m.MigrateTo(context.Background(), "1")
+		is.NotError(t, err)
+		is.Equal(t, "[1 2 3 2 1]", fmt.Sprint(metrics.applied))
+		is.Equal(t, "[3 2 1 0 2 1 0]", fmt.Sprint(metrics.pending))
+	})
+
+	t.Run("records failed migrations", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		metrics := &recordingMetrics{}
+
+		m := migrate.New(migrate.Options{DB: db, FS: mustSub(t, testdata, "bad"), Metrics: metrics})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+
+		is.Equal(t, "[1]", fmt.Sprint(metrics.applied))
+		is.Equal(t, "[2]", fmt.Sprint(metrics.failed))
+		is.Equal(t, "[2 1]", fmt.Sprint(metrics.pending))
+	})
+}
@@ -0,0 +1,17 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+)
+
+// rewrite the SQL of the migration of a step with each Options.Middleware, in order.
+func (m *Migrator) rewrite(ctx context.Context, s step, query string) (string, error) {
+	for i, middleware := range m.middleware {
+		var err error
+		if query, err = middleware(ctx, s.migration, query); err != nil {
+			return "", fmt.Errorf("error in middleware %v for %v: %w", i+1, s.name, err)
+		}
+	}
+	return query, nil
+}
@@ -0,0 +1,88 @@
+package migrate_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestMigrator_Middleware(t *testing.T) {
+	prefix := func(ctx context.Context, m migrate.Migration, sql string) (string, error) {
+		return strings.ReplaceAll(sql, "create table ", "create table app_"), nil
+	}
+
+	t.Run("rewrites the content of each migration with the middleware in order", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		var seen []string
+		m := migrate.New(migrate.Options{DB: db, MissingDown: migrate.CheckIgnore,
+			FS: fstest.MapFS{
+				"1-accounts.up.sql": {Data: []byte("create table accounts (id int)")},
+			},
+			Middleware: []func(ctx context.Context, m migrate.Migration, sql string) (string, error){
+				prefix,
+				func(ctx context.Context, m migrate.Migration, sql string) (string, error) {
+					seen = append(seen, m.Version+": "+sql)
+					return sql + " /* team:payments */", nil
+				},
+			},
+		})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "[1-accounts: create table app_accounts (id int)]", fmt.Sprint(seen))
+
+		_, err = db.Exec(`select id from app_accounts`)
+		is.NotError(t, err)
+		is.Equal(t, "1-accounts", getVersion(t, db))
+	})
+
+	t.Run("rewrites each statement with SplitStatements", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		var seen []string
+		m := migrate.New(migrate.Options{DB: db, MissingDown: migrate.CheckIgnore, SplitStatements: true,
+			FS: fstest.MapFS{
+				"1.up.sql": {Data: []byte("create table accounts (id int);\ncreate table users (id int);")},
+			},
+			Middleware: []func(ctx context.Context, m migrate.Migration, sql string) (string, error){
+				func(ctx context.Context, m migrate.Migration, sql string) (string, error) {
+					seen = append(seen, sql)
+					return prefix(ctx, m, sql)
+				},
+			},
+		})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "[create table accounts (id int) create table users (id int)]", fmt.Sprint(seen))
+
+		_, err = db.Exec(`select id from app_users`)
+		is.NotError(t, err)
+	})
+
+	t.Run("fails the migration on a middleware error", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, MissingDown: migrate.CheckIgnore,
+			FS: fstest.MapFS{
+				"1.up.sql": {Data: []byte("create table accounts (id int)")},
+			},
+			Middleware: []func(ctx context.Context, m migrate.Migration, sql string) (string, error){
+				prefix,
+				func(ctx context.Context, m migrate.Migration, sql string) (string, error) {
+					return "", errors.New("no schema")
+				},
+			},
+		})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, "error migrating up: error in middleware 2 for 1.up.sql: no schema", err.Error())
+		is.Equal(t, "", getVersion(t, db))
+	})
+}
@@ -6,10 +6,21 @@ package migrate
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
+	"log"
+	"os/user"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"maragu.dev/migrate/analyze"
+	"maragu.dev/migrate/internal/sqlscan"
 )
 
 var (
@@ -37,27 +48,271 @@ func To(ctx context.Context, db *sql.DB, fsys fs.FS, version string) error {
 }
 
 // callback that can be run before and after each migration.
+// tx is nil if the Dialect doesn't support transactions. Get the run from ctx with RunInfoFrom.
 type callback = func(ctx context.Context, tx *sql.Tx, version string) error
 
+// executor is what both *sql.DB and *sql.Conn can do, so a run can happen on either.
+type executor interface {
+	queryer
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// queryer is what both an executor and *sql.Tx can do, so migrations can run with or without a transaction.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
 type Migrator struct {
-	after  callback
-	before callback
-	db     *sql.DB
-	fs     fs.FS
-	table  string
+	actor               string
+	after               callback
+	afterAll            func(ctx context.Context, s Summary) error
+	applied             []string // in the current run, see session
+	audit               bool
+	backfills           []Backfill
+	batch               bool
+	before              callback
+	beforeAll           func(ctx context.Context, s Summary) error
+	blockerAge          time.Duration
+	blockerWait         time.Duration
+	cache               *runCache
+	canary              *sql.DB
+	checkPrivileges     bool
+	columns             VersionColumns
+	confirmDangerous    func(ctx context.Context, findings []analyze.Finding) error
+	conn                executor
+	copier              Copier
+	dangerousRules      func() []analyze.Rule
+	db                  *sql.DB
+	dialect             Dialect
+	disableFKsOnDown    bool
+	downBoundary        Boundary
+	dropTablesOnDown    bool
+	emptyFile           CheckLevel
+	expandEnv           bool
+	freshConn           bool
+	fs                  fs.FS
+	history             bool
+	historyBatch        int64 // of the current run, see applyAll
+	implicitCommit      CheckLevel
+	logger              Logger
+	maintenance         Maintenance
+	metrics             Metrics
+	middleware          []func(ctx context.Context, m Migration, sql string) (string, error)
+	missingApplied      CheckLevel
+	missingDown         CheckLevel
+	notifyChannel       string
+	numberCollision     CheckLevel
+	onError             func(ctx context.Context, s Summary)
+	onlineSchemaChanger OnlineSchemaChanger
+	parallel            int
+	policy              Policy
+	progress            func(ctx context.Context, p Progress)
+	requires            []requirement
+	retries             int
+	retryDelay          time.Duration
+	role                string
+	shouldApply         func(ctx context.Context, m Migration) (bool, error)
+	singleConn          bool
+	splitStatements     bool
+	store               VersionStore
+	storeDown           bool
+	sumMismatch         CheckLevel
+	table               string
+	tracer              Tracer
 }
 
 // Options for New. DB and FS are always required.
 type Options struct {
-	After  callback
-	Before callback
-	DB     *sql.DB
-	FS     fs.FS
-	Table  string
+	// Actor recorded in the audit table. Defaults to the name of the current operating system user.
+	Actor string
+	After callback
+	// AfterAll is called after each successful run. Returning an error fails the run,
+	// but doesn't undo the applied migrations.
+	AfterAll func(ctx context.Context, s Summary) error
+	// Audit records every operation, with the actor, when it started and finished, the versions before and after,
+	// and the outcome, in an audit table named like Table with the suffix "_audit".
+	Audit bool
+	// Backfills of data, run in batches with Migrator.RunBackfills after the migrations they belong to.
+	// See Backfill. They need a Dialect that is a BackfillTableCreator, like the built-in ones.
+	Backfills []Backfill
+	// BatchVersionUpdate sends the version update with each migration, saving a round-trip to the database.
+	// The driver must support multiple statements in one exec, like pgx and go-sqlite3 do,
+	// and go-sql-driver/mysql does with multiStatements. The version is quoted as a string literal, see StringQuoter.
+	// It has no effect with SplitStatements or Parallel, or if the Dialect doesn't support transactions.
+	BatchVersionUpdate bool
+	Before             callback
+	// BeforeAll is called before each run. Returning an error aborts the run.
+	BeforeAll func(ctx context.Context, s Summary) error
+	// BlockerAge enables a check for Blockers right before applying migrations, if the Dialect is a BlockerFinder,
+	// like Postgres and MySQL: sessions with transactions open for at least BlockerAge, which could hold locks
+	// on the tables the migrations touch, and queue their statements behind them along with all other queries on them.
+	// The run fails with a report of the blockers, instead of stalling production traffic.
+	BlockerAge time.Duration
+	// BlockerWait is how long to wait for the blockers to go away before failing the run, checking every second.
+	// Defaults to failing right away.
+	BlockerWait time.Duration
+	// Canary database, like a restored snapshot of the primary, to apply pending up migrations to first.
+	// If any of them fail on the canary, the run fails before anything is applied to DB,
+	// which catches failures that depend on the data. The canary uses the same Options as DB,
+	// except that it keeps its version in its own migrations table, and isn't included in Metrics.
+	// Down migrations are not applied to the canary.
+	Canary *sql.DB
+	// CheckPrivileges of the user before applying migrations, like to create tables in a schema and alter existing tables,
+	// found from the create, alter, and drop table statements, and create index statements, of the migration files.
+	// A missing privilege fails the run with what's missing before anything is applied, instead of a permission error
+	// after some migrations are applied. It needs a Dialect that is a PrivilegeChecker, like Postgres.
+	CheckPrivileges bool
+	// ConfirmDangerous is called before applying pending up migrations that the rules of DangerousRules
+	// find risky statements in, like adding a not null column without a default. Returning an error aborts the run
+	// before anything is applied, like to require an environment variable or a human approval for such migrations.
+	// Only the pending migrations are analyzed, so rules don't find references to them in earlier migrations.
+	ConfirmDangerous func(ctx context.Context, findings []analyze.Finding) error
+	// Copier for statements with the copy directive. See Copier.
+	Copier Copier
+	// DangerousRules returns new rules for each run with ConfirmDangerous. Defaults to the built-in rules
+	// of the analyze package with Postgres and Redshift, and to analyze.NotNull and analyze.DroppedColumnReferenced
+	// with other Dialects, because the other built-in rules are about the locks of Postgres.
+	DangerousRules func() []analyze.Rule
+	DB             *sql.DB
+	// Dialect of the database. If set, each run happens on a single connection, holding the lock from Dialect.Lock.
+	// If not set, nothing is quoted or locked, which works for most databases.
+	Dialect Dialect
+	// DisableForeignKeysOnDown disables enforcing foreign keys during each down migration, and checks afterwards
+	// that no rows violate them, failing the migration if any do, like for the table rebuilds of SQLite.
+	// It needs a Dialect that is a ForeignKeyDisabler.
+	DisableForeignKeysOnDown bool
+	// DownBoundary of migrating down to a version with MigrateTo and DownTo. Defaults to KeepTarget.
+	DownBoundary Boundary
+	// DropTablesOnDown drops the migrations table, and the history and backfill tables, after migrating all the way down
+	// with MigrateDown, or MigrateTo the empty version, so the database is left like before the first migration,
+	// like for test teardown. The audit table is kept, for the record of the run.
+	DropTablesOnDown bool
+	// EmptyFile checks that each migration file to apply has more than whitespace.
+	EmptyFile CheckLevel
+	// ExpandEnv replaces ${VAR} in migration files with the value of the environment variable VAR before execution,
+	// like for environment-specific tablespaces and roles. Only the form with braces is replaced,
+	// and it's an error if the variable is not set. Down migrations stored with StoreDown are stored expanded.
+	ExpandEnv bool
+	FS        fs.FS
+	// FreshConnPerMigration applies each migration on a new connection from DB, which is closed after it,
+	// so session state like temporary tables, SET variables, and prepared statements from one migration
+	// can't leak into the next. With a Dialect, the lock is still held by the connection of the run.
+	// Migrations applied with Parallel already run on connections from the pool, which are not closed.
+	FreshConnPerMigration bool
+	// History also records each applied migration, with when it was applied, how long it took, the batch number
+	// of the run that applied it, its description, and the checksum of its up file, in a history table named like Table
+	// with the suffix "_history". See Migrator.Status and Migrator.RollbackLastBatch. History tables created by earlier
+	// versions need the columns added, like with "alter table migrations_history add column batch bigint"
+	// and likewise for description text and checksum text.
+	History bool
+	// ImplicitCommit checks migration files for DDL with other statements, if the Dialect doesn't support
+	// transactional DDL, like MySQL. The database commits the transaction on each DDL statement,
+	// so a failing migration can be partially applied, even though the version is only updated after it.
+	// Without a Dialect, MySQL drivers are detected by name, so the version is still updated after the migration.
+	ImplicitCommit CheckLevel
+	// Logger for warnings. Defaults to the standard logger from the log package.
+	Logger Logger
+	// Maintenance of the tables touched by the up migrations of a run, after they are all applied, if the Dialect
+	// is a Maintainer, like Postgres, MySQL, and SQLite. Failures are logged as warnings. Defaults to no maintenance.
+	Maintenance Maintenance
+	// Metrics for applied and failed migrations, their durations, and pending migrations.
+	Metrics Metrics
+	// Middleware rewrites the SQL of each migration right before it's executed, in order, like to add a comment
+	// with the team and deploy to each statement, or to enforce a schema prefix. It gets the whole content
+	// of the migration file, or each statement with SplitStatements, including any directive comments.
+	// Checksums and the stored content are of the files, not the rewritten SQL. Returning an error fails the migration.
+	Middleware []func(ctx context.Context, m Migration, sql string) (string, error)
+	// MissingApplied checks that the current version, and with History each applied version, has an up migration file.
+	// Old files may be deleted on purpose after squashing them, so migrations proceed either way.
+	// Migrating down to a version without files needs History, to know that it's applied.
+	MissingApplied CheckLevel
+	// MissingDown checks that each up migration to apply has a down migration file.
+	MissingDown CheckLevel
+	// NotifyChannel is notified after each run that applied migrations, with the new version as payload,
+	// so running instances of an application can refresh cached schema and prepared statements without polling,
+	// like with LISTEN on Postgres. It needs a Dialect that is a Notifier. Failures are logged as warnings.
+	NotifyChannel string
+	// NumberCollision checks that no two up migration files have the same number, like "1700000000-accounts"
+	// and "1700000000-users" created in the same second on different branches, whose order is then only by name.
+	NumberCollision CheckLevel
+	// OnError is called after each failed run.
+	OnError func(ctx context.Context, s Summary)
+	// OnlineSchemaChanger for alter table statements in migrations marked with online-schema-change
+	// in their front matter, like with gh-ost for large MySQL tables. See OnlineSchemaChanger.
+	OnlineSchemaChanger OnlineSchemaChanger
+	// Parallel is the maximum number of up migrations applied at the same time, each in its own transaction
+	// on a connection from the pool. Defaults to one at a time. A migration waits for the previous one,
+	// unless its up file starts with a depends directive listing the versions it waits for, if any,
+	// or lists them with depends-on in its front matter:
+	//
+	//	-- migrate:depends 1-accounts 2-users
+	//
+	// The version only moves past a migration when all migrations before it are applied, so if a run fails,
+	// later migrations that were already applied run again on the next run. Make them idempotent,
+	// for example with "if not exists". Callbacks, Metrics, and Progress must be safe for concurrent use.
+	// With a Dialect, the run holds a connection of its own, so it needs Parallel+1 connections. If DB has a lower
+	// limit from SetMaxOpenConns, fewer migrations are applied at the same time, down to one at a time.
+	Parallel int
+	// Policy for the statements in migration files, checked before any migrations are applied. See Policy.
+	Policy Policy
+	// Progress is called before each statement of a migration, if SplitStatements is set.
+	Progress func(ctx context.Context, p Progress)
+	// Requires of all migrations, like "server >= 14" and "extension pgcrypto", checked before each run that applies
+	// migrations, like with the requires key in front matter for a single migration. Server versions are compared
+	// by their numbers, up to the numbers given, so "server = 16" matches 16.2. It needs a Dialect that is a RequirementChecker,
+	// or a CharsetChecker for "charset utf8mb4" and "collation utf8mb4_0900_ai_ci", which the database and all its tables
+	// must have. New panics on invalid requirements.
+	Requires []string
+	// Retries of a failed statement with SplitStatements, if the Dialect is a Retrier that reports the error as retryable.
+	// Each statement runs in a savepoint, which is rolled back to before a retry, so the Dialect must support savepoints.
+	Retries int
+	// RetryDelay before the first retry of a statement, doubled for each retry after that. Defaults to 100ms.
+	RetryDelay time.Duration
+	// Role to run the statements of each migration as, like the role of the application, so the objects they create
+	// are owned by it instead of by the user the Migrator connects as, who must be able to switch to it.
+	// Callbacks and the version and history updates still run as that user. It needs a Dialect that is a RoleSetter,
+	// like Postgres, or else New panics.
+	Role string
+	// ShouldApply is called before each migration, and if it reports false, the migration is skipped:
+	// the version moves past it without running it, its Before and After callbacks, or recording its history.
+	// Use it to gate migrations on feature flags, region, or environment while keeping one set of files.
+	// Skipped migrations still count as applied in the run Summary and Metrics.
+	ShouldApply func(ctx context.Context, m Migration) (bool, error)
+	// SingleConn runs each run on a single connection from DB, so session-level settings,
+	// like a search path or lock timeout set in a migration or callback, apply to the whole run.
+	// It's always on if Dialect is set.
+	SingleConn bool
+	// SplitStatements runs each statement of a migration on its own, instead of the whole file at once,
+	// to report Progress, and for drivers that don't support multiple statements at once.
+	// Statements are split on semicolons outside of quotes, comments, and Postgres dollar-quoted strings,
+	// so don't set it for migrations with other semicolons between statements, such as in SQLite trigger bodies.
+	// Statements are streamed from the file, so large files aren't read into memory all at once.
+	SplitStatements bool
+	// StoreDown also stores the content of the down migration file of each applied up migration in the history table,
+	// base64-encoded in the down_sql column, so it can be reverted with Migrator.RollbackStored even without the file.
+	// It turns on History. History tables created before StoreDown existed need the column added,
+	// like with "alter table migrations_history add column down_sql text".
+	StoreDown bool
+	// SumMismatch checks the migration files against an atlas.sum file in FS, if there is one, like from Atlas
+	// or WriteSumFile, so files changed without updating it are caught before they are applied. See Sum.
+	SumMismatch CheckLevel
+	// Table for the version. If Dialect is set, the table name is quoted, so it may be a reserved word,
+	// and is case-sensitive on databases that fold the case of unquoted identifiers.
+	Table string
+	// Tracer for spans of each run and each migration. See Tracer for how to use OpenTelemetry.
+	Tracer Tracer
+	// VersionColumns of the version table, to adopt an existing table from another tool, like GolangMigrateColumns.
+	// See VersionColumns. BatchVersionUpdate has no effect with them.
+	VersionColumns VersionColumns
+	// VersionStore keeps the version instead of the version table. See VersionStore.
+	VersionStore VersionStore
 }
 
 // New Migrator with Options.
-// If Options.Table is not set, defaults to "migrations". The table name must match ^[\w.]+$ .
+// If Options.Table is not set, defaults to "migrations". The table name must match ^[\w.]+$ ,
+// and the names in Options.VersionColumns must match ^\w+$ .
 // New panics on illegal options.
 func New(opts Options) *Migrator {
 	if opts.DB == nil || opts.FS == nil {
@@ -69,23 +324,129 @@ func New(opts Options) *Migrator {
 	if !tableMatcher.MatchString(opts.Table) {
 		panic("illegal table name " + opts.Table + ", must match " + tableMatcher.String())
 	}
+	if err := opts.VersionColumns.validate(); err != nil {
+		panic(err.Error())
+	}
+	if _, ok := opts.Dialect.(RoleSetter); opts.Role != "" && !ok {
+		panic("role needs a Dialect that is a RoleSetter")
+	}
+	if _, ok := opts.Dialect.(ForeignKeyDisabler); opts.DisableForeignKeysOnDown && !ok {
+		panic("disabling foreign keys on down needs a Dialect that is a ForeignKeyDisabler")
+	}
+	if _, ok := opts.Dialect.(Notifier); opts.NotifyChannel != "" && !ok {
+		panic("notify channel needs a Dialect that is a Notifier")
+	}
+	var requires []requirement
+	for _, r := range opts.Requires {
+		parsed, err := parseRequirements("Options.Requires", r)
+		if err != nil {
+			panic(err.Error())
+		}
+		requires = append(requires, parsed...)
+	}
+	singleConn := opts.SingleConn || opts.Dialect != nil
+	if opts.Dialect == nil {
+		opts.Dialect = defaultDialect
+	}
+	if _, ok := opts.Dialect.(BackfillTableCreator); len(opts.Backfills) > 0 && !ok {
+		panic("backfills need a Dialect that is a BackfillTableCreator")
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.Default()
+	}
+	if opts.Metrics == nil {
+		opts.Metrics = noopMetrics{}
+	}
+	if opts.RetryDelay == 0 {
+		opts.RetryDelay = 100 * time.Millisecond
+	}
+	if opts.Tracer == nil {
+		opts.Tracer = noopTracer{}
+	}
+	if opts.StoreDown {
+		opts.History = true
+	}
+	if opts.Audit && opts.Actor == "" {
+		if u, err := user.Current(); err == nil {
+			opts.Actor = u.Username
+		}
+	}
 	return &Migrator{
-		after:  opts.After,
-		before: opts.Before,
-		db:     opts.DB,
-		fs:     opts.FS,
-		table:  opts.Table,
+		actor:               opts.Actor,
+		after:               opts.After,
+		afterAll:            opts.AfterAll,
+		audit:               opts.Audit,
+		backfills:           opts.Backfills,
+		batch:               opts.BatchVersionUpdate && opts.VersionColumns.Version == "",
+		before:              opts.Before,
+		beforeAll:           opts.BeforeAll,
+		blockerAge:          opts.BlockerAge,
+		blockerWait:         opts.BlockerWait,
+		canary:              opts.Canary,
+		checkPrivileges:     opts.CheckPrivileges,
+		confirmDangerous:    opts.ConfirmDangerous,
+		columns:             opts.VersionColumns,
+		conn:                opts.DB,
+		copier:              opts.Copier,
+		dangerousRules:      opts.DangerousRules,
+		db:                  opts.DB,
+		dialect:             opts.Dialect,
+		disableFKsOnDown:    opts.DisableForeignKeysOnDown,
+		downBoundary:        opts.DownBoundary,
+		dropTablesOnDown:    opts.DropTablesOnDown,
+		emptyFile:           opts.EmptyFile,
+		expandEnv:           opts.ExpandEnv,
+		freshConn:           opts.FreshConnPerMigration,
+		fs:                  opts.FS,
+		history:             opts.History,
+		implicitCommit:      opts.ImplicitCommit,
+		logger:              opts.Logger,
+		maintenance:         opts.Maintenance,
+		metrics:             opts.Metrics,
+		middleware:          opts.Middleware,
+		missingApplied:      opts.MissingApplied,
+		missingDown:         opts.MissingDown,
+		notifyChannel:       opts.NotifyChannel,
+		numberCollision:     opts.NumberCollision,
+		onError:             opts.OnError,
+		onlineSchemaChanger: opts.OnlineSchemaChanger,
+		parallel:            opts.Parallel,
+		policy:              opts.Policy,
+		progress:            opts.Progress,
+		requires:            requires,
+		retries:             opts.Retries,
+		retryDelay:          opts.RetryDelay,
+		role:                opts.Role,
+		shouldApply:         opts.ShouldApply,
+		singleConn:          singleConn,
+		splitStatements:     opts.SplitStatements,
+		store:               opts.VersionStore,
+		storeDown:           opts.StoreDown,
+		sumMismatch:         opts.SumMismatch,
+		table:               opts.Table,
+		tracer:              opts.Tracer,
 	}
 }
 
 // MigrateUp from the current version.
 func (m *Migrator) MigrateUp(ctx context.Context) (err error) {
+	ctx, span := m.tracer.Start(ctx, "migrate up")
+	span.SetAttribute("migrate.table", m.table)
 	defer func() {
 		if err != nil {
 			err = fmt.Errorf("error migrating up: %w", err)
 		}
+		span.End(err)
 	}()
 
+	return m.session(ctx, func(s *Migrator) error {
+		return s.run(ctx, "up", func() error {
+			return s.migrateUp(ctx)
+		})
+	})
+}
+
+func (m *Migrator) migrateUp(ctx context.Context) error {
 	if err := m.createMigrationsTable(ctx); err != nil {
 		return err
 	}
@@ -95,33 +456,33 @@ func (m *Migrator) MigrateUp(ctx context.Context) (err error) {
 		return err
 	}
 
-	names, err := m.getFilenames(upMatcher)
+	steps, err := m.planUp(currentVersion, "")
 	if err != nil {
 		return err
 	}
 
-	for _, name := range names {
-		thisVersion := upMatcher.ReplaceAllString(name, "$1")
-		if thisVersion <= currentVersion {
-			continue
-		}
-
-		if err := m.apply(ctx, name, thisVersion); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return m.applyAll(ctx, steps)
 }
 
 // MigrateDown from the current version.
 func (m *Migrator) MigrateDown(ctx context.Context) (err error) {
+	ctx, span := m.tracer.Start(ctx, "migrate down")
+	span.SetAttribute("migrate.table", m.table)
 	defer func() {
 		if err != nil {
 			err = fmt.Errorf("error migrating down: %w", err)
 		}
+		span.End(err)
 	}()
 
+	return m.session(ctx, func(s *Migrator) error {
+		return s.run(ctx, "down", func() error {
+			return s.migrateDown(ctx)
+		})
+	})
+}
+
+func (m *Migrator) migrateDown(ctx context.Context) error {
 	if err := m.createMigrationsTable(ctx); err != nil {
 		return err
 	}
@@ -131,39 +492,69 @@ func (m *Migrator) MigrateDown(ctx context.Context) (err error) {
 		return err
 	}
 
-	names, err := m.getFilenames(downMatcher)
+	steps, err := m.planDown(currentVersion, "", false)
 	if err != nil {
 		return err
 	}
 
-	for i := len(names) - 1; i >= 0; i-- {
-		thisVersion := downMatcher.ReplaceAllString(names[i], "$1")
-		if thisVersion > currentVersion {
-			continue
-		}
+	if err := m.applyAll(ctx, steps); err != nil {
+		return err
+	}
 
-		nextVersion := ""
-		if i > 0 {
-			nextVersion = downMatcher.ReplaceAllString(names[i-1], "$1")
-		}
+	if m.dropTablesOnDown {
+		return m.dropTables(ctx)
+	}
+	return nil
+}
 
-		if err := m.apply(ctx, names[i], nextVersion); err != nil {
-			return err
-		}
+// dropTables of the Migrator, if the version is empty. See Options.DropTablesOnDown.
+func (m *Migrator) dropTables(ctx context.Context) error {
+	currentVersion, err := m.getCurrentVersion(ctx)
+	if err != nil {
+		return err
+	}
+	// The tables are still needed if not all migrations were reverted
+	if currentVersion != "" {
+		return nil
 	}
 
+	tables := []string{m.backfillTable(), m.historyTable()}
+	if m.store == nil {
+		tables = append(tables, m.table)
+	}
+	for _, table := range tables {
+		if _, err := m.conn.ExecContext(ctx, `drop table if exists `+m.dialect.Quote(table)); err != nil {
+			return fmt.Errorf("error dropping table %v: %w", table, err)
+		}
+	}
+	if m.cache != nil {
+		m.cache.tablesCreated = false
+	}
 	return nil
 }
 
+// MigrateTo the given version, up or down from the current version.
 func (m *Migrator) MigrateTo(ctx context.Context, version string) (err error) {
+	ctx, span := m.tracer.Start(ctx, "migrate to")
+	span.SetAttribute("migrate.table", m.table)
+	span.SetAttribute("migrate.target_version", version)
 	defer func() {
 		if err != nil {
 			err = fmt.Errorf("error migrating to: %w", err)
 		}
+		span.End(err)
 	}()
 
+	return m.session(ctx, func(s *Migrator) error {
+		return s.run(ctx, "to", func() error {
+			return s.migrateTo(ctx, version)
+		})
+	})
+}
+
+func (m *Migrator) migrateTo(ctx context.Context, version string) error {
 	if version == "" {
-		return m.MigrateDown(ctx)
+		return m.migrateDown(ctx)
 	}
 
 	if err := m.createMigrationsTable(ctx); err != nil {
@@ -175,166 +566,1243 @@ func (m *Migrator) MigrateTo(ctx context.Context, version string) (err error) {
 		return err
 	}
 
-	if currentVersion == version {
-		return nil
+	order, err := m.getVersionOrder()
+	if err != nil {
+		return err
 	}
 
-	var matcher *regexp.Regexp
-	if version > currentVersion {
-		matcher = upMatcher
-	} else {
-		matcher = downMatcher
+	switch {
+	case currentVersion == version:
+		return nil
+	case order.compare(version, currentVersion) > 0:
+		return m.migrateUpTo(ctx, version)
+	default:
+		return m.migrateDownTo(ctx, version)
 	}
-	names, err := m.getFilenames(matcher)
+}
+
+// MigrateToTime migrates to the newest version created at or before t, up or down from the current version,
+// like to reproduce a bug against the schema of a release date. The versions must be numbered with Unix timestamps,
+// like with Timestamp numbering in Create. If all versions are created after t, it migrates all the way down.
+func (m *Migrator) MigrateToTime(ctx context.Context, t time.Time) (err error) {
+	ctx, span := m.tracer.Start(ctx, "migrate to time")
+	span.SetAttribute("migrate.table", m.table)
+	span.SetAttribute("migrate.target_time", t.UTC().Format(time.RFC3339))
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error migrating to time: %w", err)
+		}
+		span.End(err)
+	}()
+
+	return m.session(ctx, func(s *Migrator) error {
+		return s.run(ctx, "to time", func() error {
+			version, err := s.versionAt(t)
+			if err != nil {
+				return err
+			}
+			return s.migrateTo(ctx, version)
+		})
+	})
+}
+
+// versionAt returns the newest version created at or before t, from the Unix timestamp it's numbered with,
+// or the empty string if there is none.
+func (m *Migrator) versionAt(t time.Time) (string, error) {
+	names, err := m.getFilenames(upMatcher)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	foundVersion := false
+	var version string
 	for _, name := range names {
-		thisVersion := matcher.ReplaceAllString(name, "$1")
-		if thisVersion == version {
-			foundVersion = true
+		// Unix timestamps in seconds have had ten digits since 2001
+		number := numberMatcher.FindString(name)
+		if len(number) < 10 {
+			return "", fmt.Errorf("version of %v isn't numbered with a Unix timestamp", name)
+		}
+		created, err := strconv.ParseInt(number, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("error parsing timestamp of %v: %w", name, err)
+		}
+		if created > t.Unix() {
+			continue
 		}
+		version = upMatcher.ReplaceAllString(name, "$1")
 	}
-	if !foundVersion {
-		return errors.New("error finding version " + version)
+	return version, nil
+}
+
+// UpTo the given version, including it. It errors if the current version is after the given version.
+func (m *Migrator) UpTo(ctx context.Context, version string) (err error) {
+	ctx, span := m.tracer.Start(ctx, "migrate up to")
+	span.SetAttribute("migrate.table", m.table)
+	span.SetAttribute("migrate.target_version", version)
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error migrating up to: %w", err)
+		}
+		span.End(err)
+	}()
+
+	return m.session(ctx, func(s *Migrator) error {
+		return s.run(ctx, "up to", func() error {
+			return s.migrateUpTo(ctx, version)
+		})
+	})
+}
+
+func (m *Migrator) migrateUpTo(ctx context.Context, version string) error {
+	if err := m.createMigrationsTable(ctx); err != nil {
+		return err
 	}
 
-	switch {
-	case version > currentVersion:
-		for _, name := range names {
-			thisVersion := matcher.ReplaceAllString(name, "$1")
-			if thisVersion <= currentVersion {
-				continue
-			}
-			if thisVersion > version {
-				break
-			}
+	currentVersion, err := m.getCurrentVersion(ctx)
+	if err != nil {
+		return err
+	}
 
-			if err := m.apply(ctx, name, thisVersion); err != nil {
-				return err
-			}
-		}
-	case version < currentVersion:
-		for i := len(names) - 1; i >= 0; i-- {
-			thisVersion := matcher.ReplaceAllString(names[i], "$1")
-			if thisVersion > currentVersion {
-				continue
-			}
+	order, err := m.getVersionOrder()
+	if err != nil {
+		return err
+	}
 
-			if thisVersion <= version {
-				break
-			}
+	if order.compare(version, currentVersion) < 0 {
+		return fmt.Errorf("error migrating up to %v: current version %v is after it", version, currentVersion)
+	}
 
-			nextVersion := matcher.ReplaceAllString(names[i-1], "$1")
+	if err := m.findVersion(upMatcher, version); err != nil {
+		return err
+	}
 
-			if err := m.apply(ctx, names[i], nextVersion); err != nil {
-				return err
-			}
-		}
+	steps, err := m.planUp(currentVersion, version)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return m.applyAll(ctx, steps)
 }
 
-// apply a file identified by name and update to version.
-func (m *Migrator) apply(ctx context.Context, name, version string) error {
-	content, err := fs.ReadFile(m.fs, name)
+// DownTo the given version. By default, the given version stays applied, see Options.DownBoundary.
+// It errors if the current version is before the given version.
+func (m *Migrator) DownTo(ctx context.Context, version string) (err error) {
+	ctx, span := m.tracer.Start(ctx, "migrate down to")
+	span.SetAttribute("migrate.table", m.table)
+	span.SetAttribute("migrate.target_version", version)
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error migrating down to: %w", err)
+		}
+		span.End(err)
+	}()
+
+	return m.session(ctx, func(s *Migrator) error {
+		return s.run(ctx, "down to", func() error {
+			return s.migrateDownTo(ctx, version)
+		})
+	})
+}
+
+func (m *Migrator) migrateDownTo(ctx context.Context, version string) error {
+	if err := m.createMigrationsTable(ctx); err != nil {
+		return err
+	}
+
+	currentVersion, err := m.getCurrentVersion(ctx)
 	if err != nil {
-		return fmt.Errorf("error reading migration file %v: %w", name, err)
+		return err
 	}
 
-	return m.inTransaction(ctx, func(tx *sql.Tx) error {
-		if m.before != nil {
-			if err := m.before(ctx, tx, version); err != nil {
-				return fmt.Errorf("error in 'before' callback when applying version %v from %v: %w", version, name, err)
-			}
-		}
+	order, err := m.getVersionOrder()
+	if err != nil {
+		return err
+	}
 
-		// Normally we wouldn't just string interpolate the version like this,
-		// but because we know the version has been matched against the regexes, we know it's safe.
-		if _, err := tx.ExecContext(ctx, `update `+m.table+` set version = '`+version+`'`); err != nil {
-			return fmt.Errorf("error updating version to %v: %w", version, err)
+	if order.compare(version, currentVersion) > 0 {
+		return fmt.Errorf("error migrating down to %v: current version %v is before it", version, currentVersion)
+	}
+
+	if err := m.findVersion(downMatcher, version); err != nil {
+		// The files of an applied version may be pruned, which is fine if the history knows it
+		ok, historyErr := m.isInHistory(ctx, version)
+		if historyErr != nil {
+			return historyErr
 		}
-		if _, err := tx.ExecContext(ctx, string(content)); err != nil {
-			return fmt.Errorf("error running migration %v from %v: %w", version, name, err)
+		if !ok {
+			return err
 		}
+	}
 
-		if m.after != nil {
-			if err := m.after(ctx, tx, version); err != nil {
-				return fmt.Errorf("error in 'after' callback when applying version %v from %v: %w", version, name, err)
-			}
-		}
-		return nil
-	})
+	steps, err := m.planDown(currentVersion, version, m.downBoundary == RevertTarget)
+	if err != nil {
+		return err
+	}
+
+	return m.applyAll(ctx, steps)
 }
 
-// getFilenames alphabetically where the name matches the given matcher.
-func (m *Migrator) getFilenames(matcher *regexp.Regexp) ([]string, error) {
-	var names []string
-	entries, err := fs.ReadDir(m.fs, ".")
+// findVersion among the file names matching the matcher, or return an error.
+func (m *Migrator) findVersion(matcher *regexp.Regexp, version string) error {
+	names, err := m.getFilenames(matcher)
 	if err != nil {
-		return names, err
+		return err
 	}
 
-	for _, entry := range entries {
-		if !matcher.MatchString(entry.Name()) {
-			continue
+	for _, name := range names {
+		if matcher.ReplaceAllString(name, "$1") == version {
+			return nil
 		}
-		names = append(names, entry.Name())
 	}
-	return names, nil
+	return errors.New("error finding version " + version)
 }
 
-// createMigrationsTable if it does not exist already, and insert the empty version if it's empty.
-func (m *Migrator) createMigrationsTable(ctx context.Context) error {
-	return m.inTransaction(ctx, func(tx *sql.Tx) error {
-		if _, err := tx.ExecContext(ctx, `create table if not exists `+m.table+` (version text not null)`); err != nil {
-			return fmt.Errorf("error creating migrations table %v: %w", m.table, err)
-		}
+// Boundary of migrating down to a version, for MigrateTo and DownTo.
+type Boundary int
 
-		var exists bool
-		if err := tx.QueryRowContext(ctx, `select exists (select * from `+m.table+`)`).Scan(&exists); err != nil {
-			return err
-		}
+const (
+	// KeepTarget leaves the target version applied, and is the default.
+	// Migrating down to version 3 from version 5 reverts 5 and 4, and the version is then 3.
+	KeepTarget Boundary = iota
+	// RevertTarget also reverts the target version.
+	// Migrating down to version 3 from version 5 reverts 5, 4, and 3, and the version is then the one before 3.
+	RevertTarget
+)
 
-		if !exists {
-			if _, err := tx.ExecContext(ctx, `insert into `+m.table+` values ('')`); err != nil {
-				return err
+// Progress of a migration, when statements are split. See Options.SplitStatements.
+type Progress struct {
+	// Elapsed time since the migration started.
+	Elapsed time.Duration
+	// Name of the migration file.
+	Name string
+	// Statement about to run, counting from 1.
+	Statement int
+	// Statements in the migration.
+	Statements int
+	// Version the migration updates to.
+	Version string
+}
+
+// Migration about to be applied, for Options.ShouldApply, or in the migration set, see Migrator.Migrations.
+type Migration struct {
+	// Description from the front matter, or else the name after the leading number, see MigrationStatus.
+	Description string
+	// Down is whether it's a down migration.
+	Down bool
+	// HasDown is whether the migration has a down migration file, so it can be reverted.
+	HasDown bool
+	// Name of the migration file.
+	Name string
+	// Tags from the front matter.
+	Tags []string
+	// Version from the file name.
+	Version string
+
+	open func() (io.ReadCloser, error)
+}
+
+// Open the content of the migration, as it's applied, with included files and expanded environment variables.
+// Close it after reading.
+func (m Migration) Open() (io.ReadCloser, error) {
+	if m.open == nil {
+		return nil, errors.New("error opening migration: no content")
+	}
+	return m.open()
+}
+
+// newMigration for the step, with its front matter.
+func (m *Migrator) newMigration(s step, fm frontMatter) (Migration, error) {
+	hasDown := s.down
+	if !hasDown {
+		downName := s.fileVersion + ".down.sql"
+		if _, err := fs.Stat(m.fs, downName); err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				return Migration{}, fmt.Errorf("error checking migration file %v: %w", downName, err)
 			}
+		} else {
+			hasDown = true
 		}
-		return nil
-	})
+	}
+
+	return Migration{
+		Description: describe(fm, s.fileVersion),
+		Down:        s.down,
+		HasDown:     hasDown,
+		Name:        s.name,
+		Tags:        fm.tags,
+		Version:     s.fileVersion,
+		open: func() (io.ReadCloser, error) {
+			return m.open(s)
+		},
+	}, nil
 }
 
-// getCurrentVersion from the migrations table.
-func (m *Migrator) getCurrentVersion(ctx context.Context) (string, error) {
-	var version string
-	if err := m.db.QueryRowContext(ctx, `select version from `+m.table+``).Scan(&version); err != nil {
-		return "", fmt.Errorf("error getting current migration version: %w", err)
+// step in a run: apply the migration file with name, and set the version.
+// For up migrations, the version from the file name becomes the version. For down migrations, it's the one reverted.
+type step struct {
+	// baseline is whether the file is a baseline of archived migrations, see Archive.
+	baseline bool
+	// content of the migration, instead of the file with name, if stored is set.
+	content     string
+	down        bool
+	fileVersion string
+	name        string
+	// migration of the step for Options.Middleware, set when it's applied.
+	migration Migration
+	// onlineSchemaChange passes alter table statements to the OnlineSchemaChanger, see frontMatter.
+	onlineSchemaChange bool
+	// splitStatements executes the statements one by one, even without Options.SplitStatements, see hasConcurrently.
+	splitStatements bool
+	stored          bool
+	version         string
+}
+
+// open the migration file of a step with its included files, or its content if it's stored.
+// Stored content was expanded when it was stored, so it isn't expanded again.
+func (m *Migrator) open(s step) (io.ReadCloser, error) {
+	if s.stored {
+		return io.NopCloser(strings.NewReader(s.content)), nil
 	}
-	return version, nil
+	f, err := m.openIncluding(s.name, nil)
+	if err != nil {
+		return nil, err
+	}
+	if m.expandEnv {
+		return newEnvReader(s.name, f), nil
+	}
+	return f, nil
 }
 
-func (m *Migrator) inTransaction(ctx context.Context, callback func(tx *sql.Tx) error) (err error) {
-	tx, err := m.db.BeginTx(ctx, nil)
+// planUp from the current version to and including the target version, or to the newest version if target is empty.
+// If there is a baseline, a fresh database starts with it instead of the archived migrations.
+func (m *Migrator) planUp(currentVersion, targetVersion string) ([]step, error) {
+	names, err := m.getFilenames(upMatcher)
 	if err != nil {
-		return fmt.Errorf("error beginning transaction: %w", err)
+		return nil, err
 	}
-	defer func() {
-		if rec := recover(); rec != nil {
-			err = rollback(tx, fmt.Errorf("panic: %v", rec))
+
+	baselineName, baselineVersion, err := m.getBaseline()
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := m.getVersionOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	var steps []step
+	if baselineName != "" && order.compare(currentVersion, baselineVersion) < 0 {
+		if currentVersion != "" {
+			return nil, fmt.Errorf("current version %v is before the baseline %v, so it needs the archived migrations first",
+				currentVersion, baselineName)
 		}
-	}()
-	if err := callback(tx); err != nil {
-		return rollback(tx, err)
+		if targetVersion != "" && order.compare(targetVersion, baselineVersion) < 0 {
+			return nil, fmt.Errorf("version %v is archived in the baseline %v", targetVersion, baselineName)
+		}
+		steps = append(steps, step{baseline: true, fileVersion: baselineVersion, name: baselineName, version: baselineVersion})
+		currentVersion = baselineVersion
 	}
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("error committing transaction: %w", err)
+
+	for _, name := range names {
+		thisVersion := upMatcher.ReplaceAllString(name, "$1")
+		if order.compare(thisVersion, currentVersion) <= 0 {
+			continue
+		}
+		if targetVersion != "" && order.compare(thisVersion, targetVersion) > 0 {
+			break
+		}
+		steps = append(steps, step{fileVersion: thisVersion, name: name, version: thisVersion})
 	}
+	return steps, nil
+}
 
-	return nil
+// planDown from the current version to the target version, leaving the target applied unless revertTarget is set.
+// If target is empty, plan all the way down, or to the baseline if there is one.
+func (m *Migrator) planDown(currentVersion, targetVersion string, revertTarget bool) ([]step, error) {
+	names, err := m.getFilenames(downMatcher)
+	if err != nil {
+		return nil, err
+	}
+
+	_, baselineVersion, err := m.getBaseline()
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := m.getVersionOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	var steps []step
+	for i := len(names) - 1; i >= 0; i-- {
+		thisVersion := downMatcher.ReplaceAllString(names[i], "$1")
+		if order.compare(thisVersion, currentVersion) > 0 {
+			continue
+		}
+		if c := order.compare(thisVersion, targetVersion); c < 0 || c == 0 && !revertTarget {
+			break
+		}
+
+		nextVersion := baselineVersion
+		if i > 0 {
+			nextVersion = downMatcher.ReplaceAllString(names[i-1], "$1")
+		}
+		// The files of the target may be pruned, but it's still the version left applied
+		if !revertTarget && order.compare(nextVersion, targetVersion) < 0 {
+			nextVersion = targetVersion
+		}
+		steps = append(steps, step{down: true, fileVersion: thisVersion, name: names[i], version: nextVersion})
+	}
+	return steps, nil
+}
+
+// applyAll steps in order, stopping at the first error.
+func (m *Migrator) applyAll(ctx context.Context, steps []step) error {
+	steps, err := m.gate(steps)
+	if err != nil {
+		return err
+	}
+	ctx = m.withRunInfo(ctx, steps)
+
+	if err := m.checkApplied(ctx); err != nil {
+		return err
+	}
+	if err := m.checkSum(); err != nil {
+		return err
+	}
+	if err := m.check(steps); err != nil {
+		return err
+	}
+	if err := m.checkPolicy(steps); err != nil {
+		return err
+	}
+	if err := m.checkRequirements(ctx, steps); err != nil {
+		return err
+	}
+	if err := m.verifyPrivileges(ctx, steps); err != nil {
+		return err
+	}
+	if err := m.confirm(ctx, steps); err != nil {
+		return err
+	}
+	if err := m.applyToCanary(ctx, steps); err != nil {
+		return err
+	}
+	if err := m.checkBlockers(ctx, steps); err != nil {
+		return err
+	}
+
+	if m.history && len(steps) > 0 && !steps[0].down {
+		lastBatch, err := m.getLastBatch(ctx)
+		if err != nil {
+			return err
+		}
+		m.historyBatch = lastBatch + 1
+	}
+
+	if err := m.reportVersions(ctx, steps); err != nil {
+		return err
+	}
+	parallel := 1
+	if m.parallel > 1 && len(steps) > 1 && !steps[0].down {
+		parallel = m.getParallel()
+	}
+	if parallel > 1 {
+		if err := m.applyParallel(ctx, steps, parallel); err != nil {
+			return err
+		}
+	} else {
+		m.metrics.Pending(len(steps))
+		for i, s := range steps {
+			if err := m.apply(ctx, s); err != nil {
+				return err
+			}
+			m.applied = append(m.applied, s.name)
+			m.metrics.Pending(len(steps) - i - 1)
+		}
+	}
+
+	if err := m.maintain(ctx, steps); err != nil {
+		return err
+	}
+	return m.notify(ctx, steps)
+}
+
+// session calls fn with a copy of the Migrator to use for a single run, so the run can keep state in it.
+// If a Dialect or SingleConn was given in Options, the run happens on a single connection that holds the dialect lock.
+// Otherwise, the run uses the connection pool.
+func (m *Migrator) session(ctx context.Context, fn func(s *Migrator) error) error {
+	_, err := m.trySession(ctx, false, fn)
+	return err
+}
+
+// trySession is like session, but if try is set and the Dialect is a TryLocker, fn is only called
+// if the lock is free right away. Reports whether fn was called.
+func (m *Migrator) trySession(ctx context.Context, try bool, fn func(s *Migrator) error) (called bool, err error) {
+	s := *m
+	s.cache = &runCache{}
+	if s.dialect == defaultDialect && isMySQLDriver(s.db.Driver()) {
+		s.dialect = defaultMySQLDialect
+	}
+	if !m.singleConn {
+		return true, fn(&s)
+	}
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return false, fmt.Errorf("error getting connection: %w", err)
+	}
+	defer func() {
+		if closeErr := conn.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("error closing connection: %w", closeErr)
+		}
+	}()
+
+	if tl, ok := m.dialect.(TryLocker); try && ok {
+		locked, err := tl.TryLock(ctx, conn, m.table)
+		if err != nil {
+			return false, fmt.Errorf("error locking: %w", err)
+		}
+		if !locked {
+			return false, nil
+		}
+	} else if err := m.dialect.Lock(ctx, conn, m.table); err != nil {
+		return false, fmt.Errorf("error locking: %w", err)
+	}
+	defer func() {
+		// Unlock even if ctx is done, and discard the connection if that fails, so the lock doesn't stay with the pool.
+		if unlockErr := m.dialect.Unlock(context.Background(), conn, m.table); unlockErr != nil {
+			_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+			if err == nil {
+				err = fmt.Errorf("error unlocking: %w", unlockErr)
+			}
+		}
+	}()
+
+	s.conn = conn
+	return true, fn(&s)
+}
+
+// Summary of a run, for the BeforeAll, AfterAll, and OnError callbacks.
+type Summary struct {
+	// Applied migration file names, in the order they were applied.
+	Applied []string
+	// Duration of the run.
+	Duration time.Duration
+	// Err that failed the run, if any.
+	Err error
+	// FromVersion is the version before the run.
+	FromVersion string
+	// Operation of the run, like "up", "down", "to", "to time", "up to", "down to", "down since", "rollback stored",
+	// "rollback last batch", "reconcile", "skip", and "import state".
+	Operation string
+	// RunID of the run, like in the RunInfo of the callbacks. See RunInfoFrom.
+	RunID string
+	// ToVersion is the version after the run. It's empty for BeforeAll.
+	ToVersion string
+}
+
+// run fn as the given operation, calling the BeforeAll, AfterAll, and OnError callbacks,
+// and recording it in the audit table, if those are set.
+// The audit is recorded whether fn succeeds or not.
+func (m *Migrator) run(ctx context.Context, operation string, fn func() error) error {
+	m.cache.operation = operation
+	m.cache.runID = newRunID()
+
+	if !m.audit && m.beforeAll == nil && m.afterAll == nil && m.onError == nil {
+		return fn()
+	}
+
+	startedAt := time.Now()
+
+	if err := m.createMigrationsTable(ctx); err != nil {
+		return err
+	}
+
+	fromVersion, err := m.getCurrentVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	summary := Summary{FromVersion: fromVersion, Operation: operation, RunID: m.cache.runID}
+
+	if m.beforeAll != nil {
+		if err := m.beforeAll(ctx, summary); err != nil {
+			summary.Err = fmt.Errorf("error in 'before all' callback: %w", err)
+		}
+	}
+	if summary.Err == nil {
+		summary.Err = fn()
+	}
+
+	summary.Applied = m.applied
+	summary.Duration = time.Since(startedAt)
+	summary.ToVersion, err = m.getCurrentVersion(ctx)
+	if err != nil {
+		if summary.Err != nil {
+			return summary.Err
+		}
+		return err
+	}
+
+	if m.audit {
+		if err := m.recordAudit(ctx, summary, startedAt); err != nil {
+			if summary.Err != nil {
+				return fmt.Errorf("error recording audit after error (audit error: %v), original error: %w", err, summary.Err)
+			}
+			return err
+		}
+	}
+
+	if summary.Err != nil {
+		if m.onError != nil {
+			m.onError(ctx, summary)
+		}
+		return summary.Err
+	}
+
+	if m.afterAll != nil {
+		if err := m.afterAll(ctx, summary); err != nil {
+			return fmt.Errorf("error in 'after all' callback: %w", err)
+		}
+	}
+	return nil
+}
+
+// apply the file of a step and update to its version, on a fresh connection if set in Options.
+// In a run, the version is remembered if it succeeds, and forgotten if it fails.
+func (m *Migrator) apply(ctx context.Context, s step) error {
+	var err error
+	if m.freshConn {
+		err = m.applyOnFreshConn(ctx, s)
+	} else {
+		err = m.applyWith(ctx, s, nil)
+	}
+	if m.cache != nil {
+		if err != nil {
+			m.cache.versionKnown = false
+		} else {
+			m.cache.setVersion(s.version)
+		}
+	}
+	return err
+}
+
+// applyOnFreshConn applies the file of a step on a new connection, which is discarded after,
+// so the pool doesn't hand out a connection with its session state.
+func (m *Migrator) applyOnFreshConn(ctx context.Context, s step) (err error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting connection: %w", err)
+	}
+	defer func() {
+		_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+		_ = conn.Close()
+	}()
+
+	fresh := *m
+	fresh.conn = conn
+	return fresh.applyWith(ctx, s, nil)
+}
+
+// applyWith applies the file of a step. If update is not nil, it's called after the migration in its transaction,
+// instead of updating to the version of the step.
+func (m *Migrator) applyWith(ctx context.Context, s step, update func(ctx context.Context, q queryer) error) (err error) {
+	name, version := s.name, s.version
+
+	ctx, span := m.tracer.Start(ctx, "migrate apply")
+	span.SetAttribute("migrate.file", name)
+	span.SetAttribute("migrate.version", version)
+	start := time.Now()
+	defer func() {
+		if err != nil {
+			m.metrics.Failed(version, time.Since(start))
+		} else {
+			m.metrics.Applied(version, time.Since(start))
+		}
+		span.End(err)
+	}()
+
+	fm, err := m.readFrontMatter(s)
+	if err != nil {
+		return err
+	}
+	if fm.description != "" {
+		span.SetAttribute("migrate.description", fm.description)
+	}
+	if len(fm.tags) > 0 {
+		span.SetAttribute("migrate.tags", strings.Join(fm.tags, ","))
+	}
+	if fm.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, fm.timeout)
+		defer cancel()
+	}
+	noTransaction := fm.noTransaction || fm.onlineSchemaChange
+	if !noTransaction && supportsTransactions(m.dialect) {
+		concurrently, err := m.hasConcurrently(s)
+		if err != nil {
+			return err
+		}
+		if concurrently {
+			m.logger.Printf("migrate: warning: %v builds or drops an index concurrently, so it runs without a transaction, "+
+				"statement by statement, and a failure leaves it partially applied", name)
+		}
+		noTransaction, s.splitStatements = concurrently, concurrently
+	}
+	inTransaction := m.inTransaction
+	if noTransaction {
+		inTransaction = m.withoutTransaction
+	}
+	s.onlineSchemaChange = fm.onlineSchemaChange
+
+	var deferConstraints string
+	if fm.deferConstraints {
+		if noTransaction || !supportsTransactions(m.dialect) {
+			return fmt.Errorf("error in front matter of %v: defer-constraints needs a transaction", name)
+		}
+		cd, ok := m.dialect.(ConstraintDeferrer)
+		if !ok {
+			return fmt.Errorf("error in front matter of %v: defer-constraints needs a Dialect that is a ConstraintDeferrer", name)
+		}
+		deferConstraints = cd.DeferConstraints()
+	}
+
+	disableForeignKeys := fm.disableForeignKeys || m.disableFKsOnDown && s.down
+	if _, ok := m.dialect.(ForeignKeyDisabler); fm.disableForeignKeys && !ok {
+		return fmt.Errorf("error in front matter of %v: disable-foreign-keys needs a Dialect that is a ForeignKeyDisabler", name)
+	}
+
+	if m.shouldApply != nil || len(m.middleware) > 0 {
+		if s.migration, err = m.newMigration(s, fm); err != nil {
+			return err
+		}
+	}
+
+	skip := false
+	if m.shouldApply != nil {
+		ok, err := m.shouldApply(ctx, s.migration)
+		if err != nil {
+			return fmt.Errorf("error in 'should apply' callback for %v: %w", name, err)
+		}
+		skip = !ok
+		if skip {
+			span.SetAttribute("migrate.skipped", "true")
+		}
+	}
+
+	// Disabled outside the transaction, because SQLite ignores it in one.
+	// They're enabled again even if the migration fails.
+	if disableForeignKeys && !skip {
+		enable, err := m.disableForeignKeys(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if enableErr := enable(); enableErr != nil && err == nil {
+				err = enableErr
+			}
+		}()
+	}
+
+	err = inTransaction(ctx, func(q queryer) error {
+		tx, _ := q.(*sql.Tx)
+
+		// Deferred before the callback, so it can change data in the same way as the migration
+		if deferConstraints != "" && !skip {
+			if _, err := q.ExecContext(ctx, deferConstraints); err != nil {
+				return fmt.Errorf("error deferring constraints of %v: %w", name, err)
+			}
+		}
+
+		if m.before != nil && !skip {
+			if err := m.before(ctx, tx, version); err != nil {
+				return fmt.Errorf("error in 'before' callback when applying version %v from %v: %w", version, name, err)
+			}
+		}
+
+		// If there is no transaction, or DDL commits it implicitly, run the migration before updating the version,
+		// so a failing migration doesn't leave the new version committed.
+		// With a VersionStore, the version is set after the transaction instead.
+		inTx := update == nil && m.store == nil
+		batch := m.batch && inTx && tx != nil && !m.splitStatements && !skip && m.role == ""
+		versionFirst := !batch && inTx && tx != nil && m.dialect.SupportsTransactionalDDL()
+		if versionFirst {
+			if err := m.updateVersion(ctx, q, version); err != nil {
+				return err
+			}
+		}
+		if !skip {
+			rows, err := m.executeAsRole(ctx, q, s, start, batch)
+			if err != nil {
+				return err
+			}
+			if rows >= 0 {
+				span.SetAttribute("db.rows_affected", rows)
+			}
+			if disableForeignKeys {
+				if err := m.checkForeignKeys(ctx, q, s); err != nil {
+					return err
+				}
+			}
+		}
+		switch {
+		case update != nil:
+			if err := update(ctx, q); err != nil {
+				return err
+			}
+		case inTx && !versionFirst && !batch:
+			if err := m.updateVersion(ctx, q, version); err != nil {
+				return err
+			}
+		}
+		if m.history && !skip {
+			if err := m.updateHistory(ctx, q, s, describe(fm, s.fileVersion), time.Since(start)); err != nil {
+				return err
+			}
+		}
+
+		if m.after != nil && !skip {
+			if err := m.after(ctx, tx, version); err != nil {
+				return fmt.Errorf("error in 'after' callback when applying version %v from %v: %w", version, name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if update == nil && m.store != nil {
+		return m.setVersion(ctx, version)
+	}
+	return nil
+}
+
+// execute the migration file of a step, all at once, or statement by statement if they should be split.
+// If batch is set, the version update is sent with the migration.
+// Returns the number of rows affected, or -1 if it isn't known.
+func (m *Migrator) execute(ctx context.Context, q queryer, s step, start time.Time, batch bool) (int64, error) {
+	if m.splitStatements || s.splitStatements || s.onlineSchemaChange {
+		return m.executeStatements(ctx, q, s, start)
+	}
+
+	f, err := m.open(s)
+	if err != nil {
+		return -1, err
+	}
+	content, err := io.ReadAll(f)
+	_ = f.Close()
+	if err != nil {
+		return -1, fmt.Errorf("error reading migration file %v: %w", s.name, err)
+	}
+
+	query, err := m.rewrite(ctx, s, string(content))
+	if err != nil {
+		return -1, err
+	}
+	if batch {
+		// The newline ends any comment on the last line of the migration
+		update, err := m.literalVersionUpdate(s.version)
+		if err != nil {
+			return -1, err
+		}
+		query += "\n;\n" + update
+	}
+
+	result, err := q.ExecContext(ctx, query)
+	if err != nil {
+		return -1, fmt.Errorf("error running migration %v from %v: %w", s.version, s.name, err)
+	}
+	if batch {
+		// The rows affected would be those of the version update, or of all statements, depending on the driver
+		return -1, nil
+	}
+	// Not all drivers and statements report affected rows
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return -1, nil
+	}
+	return rows, nil
+}
+
+// executeStatements of the migration file of a step one by one, streaming them from the file,
+// so only one statement is in memory at a time.
+// If Progress is set, the statements are counted in a first pass over the file.
+func (m *Migrator) executeStatements(ctx context.Context, q queryer, s step, start time.Time) (int64, error) {
+	statements := -1
+	if m.progress != nil {
+		var err error
+		statements, err = m.countStatements(s)
+		if err != nil {
+			return -1, err
+		}
+	}
+
+	f, err := m.open(s)
+	if err != nil {
+		return -1, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var total int64
+	scanner := sqlscan.NewScanner(f)
+	for i := 1; scanner.Scan(); i++ {
+		if m.progress != nil {
+			m.progress(ctx, Progress{
+				Elapsed:    time.Since(start),
+				Name:       s.name,
+				Statement:  i,
+				Statements: statements,
+				Version:    s.version,
+			})
+		}
+
+		rows, err := m.executeStatement(ctx, q, s, scanner.Statement())
+		if err != nil {
+			if statements < 0 {
+				return -1, fmt.Errorf("error running migration %v from %v: error in statement %v: %w", s.version, s.name, i, err)
+			}
+			return -1, fmt.Errorf("error running migration %v from %v: error in statement %v of %v: %w", s.version, s.name, i, statements, err)
+		}
+		if rows >= 0 && total >= 0 {
+			total += rows
+		} else {
+			total = -1
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return -1, fmt.Errorf("error reading migration file %v: %w", s.name, err)
+	}
+	return total, nil
+}
+
+// executeStatement of a step, or copy data if it has the copy directive, or run it in batches with the batch directive,
+// or alter the table online if it's an alter table statement and the step is marked for it.
+// Returns the number of rows affected, or -1 if it isn't known.
+func (m *Migrator) executeStatement(ctx context.Context, q queryer, s step, statement string) (int64, error) {
+	statement, err := m.rewrite(ctx, s, statement)
+	if err != nil {
+		return -1, err
+	}
+
+	if s.onlineSchemaChange {
+		if altered, err := m.alterOnline(ctx, statement); altered || err != nil {
+			return -1, err
+		}
+	}
+
+	if name, ok := copyFile(statement); ok {
+		return m.copy(ctx, statement, name)
+	}
+
+	if opts, ok, err := parseBatchDirective(statement); err != nil || ok {
+		if err != nil {
+			return -1, err
+		}
+		return m.executeInBatches(ctx, q, statement, opts)
+	}
+
+	if r, ok := m.dialect.(Retrier); ok && m.retries > 0 && r.SupportsSavepoints() {
+		if tx, ok := q.(*sql.Tx); ok {
+			return m.execWithRetries(ctx, tx, r, statement)
+		}
+	}
+	return exec(ctx, q, statement)
+}
+
+// exec the statement, returning the number of rows affected, or -1 if it isn't known.
+func exec(ctx context.Context, q queryer, statement string) (int64, error) {
+	result, err := q.ExecContext(ctx, statement)
+	if err != nil {
+		return -1, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return -1, nil
+	}
+	return rows, nil
+}
+
+// countStatements in the migration file of a step, without keeping the file in memory.
+func (m *Migrator) countStatements(s step) (int, error) {
+	f, err := m.open(s)
+	if err != nil {
+		return -1, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var count int
+	scanner := sqlscan.NewScanner(f)
+	for scanner.Scan() {
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return -1, fmt.Errorf("error reading migration file %v: %w", s.name, err)
+	}
+	return count, nil
+}
+
+// updateVersion in the migrations table.
+func (m *Migrator) updateVersion(ctx context.Context, q queryer, version string) error {
+	if m.columns.Version != "" {
+		statements, err := m.updateVersionWithColumns(version)
+		if err != nil {
+			return fmt.Errorf("error updating version to %v: %w", version, err)
+		}
+		for _, statement := range statements {
+			if _, err := q.ExecContext(ctx, statement); err != nil {
+				return fmt.Errorf("error updating version to %v: %w", version, err)
+			}
+		}
+		return nil
+	}
+
+	query, args := updateVersion(m.dialect, m.dialect.Quote(m.table), version)
+	if _, err := q.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("error updating version to %v: %w", version, err)
+	}
+	return nil
+}
+
+// getFilenames alphabetically where the name matches the given matcher, or in the order of the manifest if there is one.
+func (m *Migrator) getFilenames(matcher *regexp.Regexp) ([]string, error) {
+	var names []string
+	entries, err := m.readDir()
+	if err != nil {
+		return names, err
+	}
+
+	for _, entry := range entries {
+		if !matcher.MatchString(entry.Name()) {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+
+	order, err := m.getVersionOrder()
+	if err != nil {
+		return nil, err
+	}
+	if order != nil {
+		sort.SliceStable(names, func(i, j int) bool {
+			return order.compare(matcher.ReplaceAllString(names[i], "$1"), matcher.ReplaceAllString(names[j], "$1")) < 0
+		})
+	}
+	return names, nil
+}
+
+// readDir of the migrations, only once per run.
+func (m *Migrator) readDir() ([]fs.DirEntry, error) {
+	if m.cache != nil && m.cache.entries != nil {
+		return m.cache.entries, nil
+	}
+	entries, err := fs.ReadDir(m.fs, ".")
+	if err != nil {
+		return nil, err
+	}
+	if m.cache != nil {
+		m.cache.entries = entries
+	}
+	return entries, nil
+}
+
+// createMigrationsTable if it does not exist already, and insert the empty version if it's empty.
+// If the version was renumbered, it's updated to the new one, see Renumber.
+// In a run, it only does so once, and remembers the current version.
+func (m *Migrator) createMigrationsTable(ctx context.Context) error {
+	if m.cache != nil && m.cache.tablesCreated {
+		return nil
+	}
+
+	renumbered, err := m.getRenumbered()
+	if err != nil {
+		return err
+	}
+
+	if m.store != nil {
+		if err := m.store.Init(ctx); err != nil {
+			return fmt.Errorf("error initializing version store: %w", err)
+		}
+	}
+
+	var version string
+	err = m.inTransaction(ctx, func(q queryer) error {
+		if m.store == nil {
+			createVersionTable := m.dialect.CreateVersionTable(m.dialect.Quote(m.table))
+			if m.columns.Version != "" {
+				createVersionTable = m.createVersionTableWithColumns()
+			}
+			if _, err := q.ExecContext(ctx, createVersionTable); err != nil {
+				return fmt.Errorf("error creating migrations table %v: %w", m.table, err)
+			}
+		}
+
+		if m.audit {
+			if _, err := q.ExecContext(ctx, createAuditTable(m.dialect, m.dialect.Quote(m.auditTable()))); err != nil {
+				return fmt.Errorf("error creating audit table %v: %w", m.auditTable(), err)
+			}
+		}
+
+		if m.history {
+			if _, err := q.ExecContext(ctx, createHistoryTable(m.dialect, m.dialect.Quote(m.historyTable()))); err != nil {
+				return fmt.Errorf("error creating history table %v: %w", m.historyTable(), err)
+			}
+		}
+
+		if len(m.backfills) > 0 {
+			if _, err := q.ExecContext(ctx, m.dialect.(BackfillTableCreator).CreateBackfillTable(m.dialect.Quote(m.backfillTable()))); err != nil {
+				return fmt.Errorf("error creating backfill table %v: %w", m.backfillTable(), err)
+			}
+		}
+
+		if m.store != nil {
+			if renumbered == nil {
+				return nil
+			}
+			storeVersion, err := m.store.Get(ctx)
+			if err != nil {
+				return fmt.Errorf("error getting current migration version: %w", err)
+			}
+			_, err = m.repairRenumbered(ctx, q, renumbered, storeVersion)
+			return err
+		}
+
+		if m.columns.Version != "" {
+			var err error
+			if version, err = m.selectVersionWithColumns(ctx, q); err != nil {
+				return fmt.Errorf("error getting current migration version: %w", err)
+			}
+			version, err = m.repairRenumbered(ctx, q, renumbered, version)
+			return err
+		}
+
+		// Select the version instead of using exists, because not all databases support exists outside of where clauses,
+		// and to remember the version
+		err := q.QueryRowContext(ctx, `select version from `+m.dialect.Quote(m.table)).Scan(&version)
+		if errors.Is(err, sql.ErrNoRows) {
+			version = ""
+			if _, err := q.ExecContext(ctx, `insert into `+m.dialect.Quote(m.table)+` values ('')`); err != nil {
+				return err
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		version, err = m.repairRenumbered(ctx, q, renumbered, version)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if m.cache != nil {
+		m.cache.tablesCreated = true
+		if m.store == nil {
+			m.cache.setVersion(version)
+		}
+	}
+	return nil
+}
+
+// currentVersion outside of a run, creating the migrations table first if it doesn't exist.
+func (m *Migrator) currentVersion(ctx context.Context) (string, error) {
+	if err := m.createMigrationsTable(ctx); err != nil {
+		return "", err
+	}
+	return m.getCurrentVersion(ctx)
+}
+
+// readVersion without creating the migrations table, reporting false if it doesn't exist yet,
+// in which case the version is empty. Other errors getting the version are returned.
+func (m *Migrator) readVersion(ctx context.Context) (string, bool, error) {
+	version, err := m.getCurrentVersion(ctx)
+	if err == nil {
+		return version, true, nil
+	}
+	if m.store == nil && isUndefinedTable(m.dialect, err) {
+		return "", false, nil
+	}
+	return "", false, err
+}
+
+// getCurrentVersion from the migrations table, or from the cache in a run.
+func (m *Migrator) getCurrentVersion(ctx context.Context) (string, error) {
+	if m.cache != nil && m.cache.versionKnown {
+		return m.cache.version, nil
+	}
+
+	var version string
+	var err error
+	switch {
+	case m.store != nil:
+		version, err = m.store.Get(ctx)
+	case m.columns.Version != "":
+		version, err = m.selectVersionWithColumns(ctx, m.conn)
+	default:
+		err = m.conn.QueryRowContext(ctx, `select version from `+m.dialect.Quote(m.table)).Scan(&version)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error getting current migration version: %w", err)
+	}
+	if m.cache != nil {
+		m.cache.setVersion(version)
+	}
+	return version, nil
+}
+
+// runCache is what a run remembers to save round-trips to the database. See session.
+type runCache struct {
+	entries []fs.DirEntry
+	// operation and runID of the run, see RunInfo
+	operation     string
+	order         versionOrder
+	orderKnown    bool
+	runID         string
+	tablesCreated bool
+	version       string
+	versionKnown  bool
+}
+
+func (c *runCache) setVersion(version string) {
+	c.version = version
+	c.versionKnown = true
+}
+
+// inTransaction calls callback in a transaction, or directly on the connection if the Dialect doesn't support transactions.
+func (m *Migrator) inTransaction(ctx context.Context, callback func(q queryer) error) (err error) {
+	if !supportsTransactions(m.dialect) {
+		return callback(m.conn)
+	}
+
+	tx, err := m.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = rollback(tx, fmt.Errorf("panic: %v", rec))
+		}
+	}()
+	if err := callback(tx); err != nil {
+		return rollback(tx, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// withoutTransaction calls callback with the connection, for migrations with no-transaction in their front matter.
+func (m *Migrator) withoutTransaction(_ context.Context, callback func(q queryer) error) error {
+	return callback(m.conn)
 }
 
 func rollback(tx *sql.Tx, err error) error {
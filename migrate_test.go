@@ -4,12 +4,15 @@ import (
 	"context"
 	"database/sql"
 	"embed"
+	"encoding/base64"
 	"errors"
+	"fmt"
 	"io/fs"
 	"os"
 	"strings"
 	"testing"
 	"testing/fstest"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/jackc/pgx/v4/stdlib"
@@ -25,10 +28,11 @@ func TestMigrator(t *testing.T) {
 	tests := []struct {
 		flavor         string
 		createDatabase func(*testing.T) *sql.DB
+		dialect        migrate.Dialect
 	}{
-		{"postgres", createPostgresDatabase},
-		{"maria", createMariaDatabase},
-		{"sqlite", createSQLiteDatabase},
+		{"postgres", createPostgresDatabase, migrate.Postgres},
+		{"maria", createMariaDatabase, migrate.MySQL},
+		{"sqlite", createSQLiteDatabase, migrate.SQLite},
 	}
 
 	for _, test := range tests {
@@ -190,6 +194,47 @@ func TestMigrator(t *testing.T) {
 				is.Equal(t, "error migrating to: error finding version doesnotexist", err.Error())
 			})
 
+			t.Run("migrates up to and down to versions", func(t *testing.T) {
+				db := test.createDatabase(t)
+
+				m := migrate.New(migrate.Options{DB: db, FS: mustSub(t, testdata, "good")})
+				err := m.UpTo(context.Background(), "2")
+				is.NotError(t, err)
+				is.Equal(t, "2", getVersion(t, db))
+
+				err = m.DownTo(context.Background(), "3")
+				is.True(t, err != nil)
+				is.Equal(t, "error migrating down to: error migrating down to 3: current version 2 is before it", err.Error())
+
+				err = m.UpTo(context.Background(), "3")
+				is.NotError(t, err)
+				is.Equal(t, "3", getVersion(t, db))
+
+				err = m.DownTo(context.Background(), "1")
+				is.NotError(t, err)
+				is.Equal(t, "1", getVersion(t, db))
+
+				err = m.UpTo(context.Background(), "0")
+				is.True(t, err != nil)
+				is.Equal(t, "error migrating up to: error migrating up to 0: current version 1 is after it", err.Error())
+			})
+
+			t.Run("reverts the target version when migrating down with revert target", func(t *testing.T) {
+				db := test.createDatabase(t)
+
+				m := migrate.New(migrate.Options{DB: db, DownBoundary: migrate.RevertTarget, FS: mustSub(t, testdata, "good")})
+				err := m.MigrateUp(context.Background())
+				is.NotError(t, err)
+
+				err = m.MigrateTo(context.Background(), "2")
+				is.NotError(t, err)
+				is.Equal(t, "1", getVersion(t, db))
+
+				err = m.DownTo(context.Background(), "1")
+				is.NotError(t, err)
+				is.Equal(t, "", getVersion(t, db))
+			})
+
 			t.Run("supports custom table name", func(t *testing.T) {
 				db := test.createDatabase(t)
 
@@ -263,6 +308,361 @@ func TestMigrator(t *testing.T) {
 				version := getVersion(t, db)
 				is.Equal(t, "1", version)
 			})
+
+			t.Run("runs migrations up and down with a dialect on a single connection", func(t *testing.T) {
+				db := test.createDatabase(t)
+				db.SetMaxOpenConns(1)
+
+				m := migrate.New(migrate.Options{DB: db, FS: mustSub(t, testdata, "good"), Dialect: test.dialect})
+				err := m.MigrateUp(context.Background())
+				is.NotError(t, err)
+
+				version := getVersion(t, db)
+				is.Equal(t, "3", version)
+
+				err = m.MigrateTo(context.Background(), "1")
+				is.NotError(t, err)
+
+				version = getVersion(t, db)
+				is.Equal(t, "1", version)
+
+				err = m.MigrateDown(context.Background())
+				is.NotError(t, err)
+
+				version = getVersion(t, db)
+				is.Equal(t, "", version)
+			})
+
+			t.Run("runs all migrations on a single connection with single conn", func(t *testing.T) {
+				db := test.createDatabase(t)
+				// Pooled connections are closed after each use, so only a single connection keeps the temporary table
+				db.SetMaxIdleConns(0)
+
+				fsys := fstest.MapFS{
+					"1.up.sql": {Data: []byte("create temporary table session_test (v int)")},
+					"2.up.sql": {Data: []byte("insert into session_test values (1)")},
+				}
+
+				m := migrate.New(migrate.Options{DB: db, FS: fsys, SingleConn: true})
+				err := m.MigrateUp(context.Background())
+				is.NotError(t, err)
+
+				version := getVersion(t, db)
+				is.Equal(t, "2", version)
+			})
+
+			t.Run("batches the version update with each migration", func(t *testing.T) {
+				if test.flavor == "maria" {
+					t.Skip("needs multiStatements in the connection string")
+				}
+				db := test.createDatabase(t)
+
+				fsys := fstest.MapFS{
+					"1.up.sql":   {Data: []byte("create table test (v int);\n-- a comment at the end")},
+					"1.down.sql": {Data: []byte("drop table test;")},
+				}
+
+				m := migrate.New(migrate.Options{BatchVersionUpdate: true, DB: db, FS: fsys})
+				err := m.MigrateUp(context.Background())
+				is.NotError(t, err)
+
+				version := getVersion(t, db)
+				is.Equal(t, "1", version)
+
+				err = m.MigrateDown(context.Background())
+				is.NotError(t, err)
+
+				version = getVersion(t, db)
+				is.Equal(t, "", version)
+			})
+
+			t.Run("quotes a table name that is a reserved word with uppercase letters", func(t *testing.T) {
+				db := test.createDatabase(t)
+
+				m := migrate.New(migrate.Options{DB: db, FS: mustSub(t, testdata, "good"), Dialect: test.dialect, Table: "Order"})
+				err := m.MigrateUp(context.Background())
+				is.NotError(t, err)
+
+				var version string
+				err = db.QueryRow(`select version from ` + test.dialect.Quote("Order")).Scan(&version)
+				is.NotError(t, err)
+				is.Equal(t, "3", version)
+
+				err = m.MigrateDown(context.Background())
+				is.NotError(t, err)
+			})
+
+			t.Run("reports status without history", func(t *testing.T) {
+				db := test.createDatabase(t)
+
+				m := migrate.New(migrate.Options{DB: db, FS: mustSub(t, testdata, "good")})
+				err := m.MigrateTo(context.Background(), "2")
+				is.NotError(t, err)
+
+				statuses, err := m.Status(context.Background())
+				is.NotError(t, err)
+				is.Equal(t, 3, len(statuses))
+				is.Equal(t, "1", statuses[0].Version)
+				is.Equal(t, "1.up.sql", statuses[0].Name)
+				is.True(t, statuses[0].Applied)
+				is.True(t, statuses[0].AppliedAt.IsZero())
+				is.True(t, statuses[1].Applied)
+				is.True(t, !statuses[2].Applied)
+			})
+
+			t.Run("records history and reports it in status", func(t *testing.T) {
+				db := test.createDatabase(t)
+
+				m := migrate.New(migrate.Options{DB: db, Dialect: test.dialect, FS: mustSub(t, testdata, "good"), History: true})
+				err := m.MigrateUp(context.Background())
+				is.NotError(t, err)
+
+				err = m.MigrateTo(context.Background(), "2")
+				is.NotError(t, err)
+
+				statuses, err := m.Status(context.Background())
+				is.NotError(t, err)
+				is.Equal(t, 3, len(statuses))
+				for _, status := range statuses[:2] {
+					is.True(t, status.Applied)
+					is.True(t, time.Since(status.AppliedAt) < time.Minute)
+					is.True(t, status.Duration >= 0)
+				}
+				is.True(t, !statuses[2].Applied)
+				is.True(t, statuses[2].AppliedAt.IsZero())
+
+				var count int
+				err = db.QueryRow(`select count(*) from migrations_history`).Scan(&count)
+				is.NotError(t, err)
+				is.Equal(t, 2, count)
+			})
+
+			t.Run("records descriptions in the history and reports them in status", func(t *testing.T) {
+				db := test.createDatabase(t)
+
+				fsys := fstest.MapFS{
+					"1-add_accounts.up.sql": {Data: []byte("-- ---\n-- description: Add the accounts table\n-- ---\nselect 1")},
+					"2-add_users.up.sql":    {Data: []byte("select 1")},
+					"3.up.sql":              {Data: []byte("select 1")},
+				}
+
+				m := migrate.New(migrate.Options{DB: db, Dialect: test.dialect, FS: fsys, History: true})
+				err := m.MigrateTo(context.Background(), "1-add_accounts")
+				is.NotError(t, err)
+
+				var description string
+				err = db.QueryRow(`select description from migrations_history`).Scan(&description)
+				is.NotError(t, err)
+				is.Equal(t, "Add the accounts table", description)
+
+				statuses, err := m.Status(context.Background())
+				is.NotError(t, err)
+				is.Equal(t, 3, len(statuses))
+				is.Equal(t, "Add the accounts table", statuses[0].Description)
+				is.Equal(t, "add users", statuses[1].Description)
+				is.Equal(t, "", statuses[2].Description)
+			})
+
+			t.Run("stores down migrations in the history table", func(t *testing.T) {
+				db := test.createDatabase(t)
+
+				fsys := fstest.MapFS{
+					"1.up.sql":   {Data: []byte("create table test (v text)")},
+					"1.down.sql": {Data: []byte("-- it's got a \\ backslash\ndrop table test")},
+					"2.up.sql":   {Data: []byte("insert into test values ('a')")},
+				}
+
+				m := migrate.New(migrate.Options{DB: db, FS: fsys, MissingDown: migrate.CheckIgnore, StoreDown: true})
+				err := m.MigrateUp(context.Background())
+				is.NotError(t, err)
+
+				var downSQL sql.NullString
+				err = db.QueryRow(`select down_sql from migrations_history where version = '1'`).Scan(&downSQL)
+				is.NotError(t, err)
+				decoded, err := base64.StdEncoding.DecodeString(downSQL.String)
+				is.NotError(t, err)
+				is.Equal(t, "-- it's got a \\ backslash\ndrop table test", string(decoded))
+
+				err = db.QueryRow(`select down_sql from migrations_history where version = '2'`).Scan(&downSQL)
+				is.NotError(t, err)
+				is.True(t, !downSQL.Valid)
+			})
+
+			t.Run("records operations in the audit table", func(t *testing.T) {
+				db := test.createDatabase(t)
+
+				m := migrate.New(migrate.Options{Actor: `o'brien\`, Audit: true, DB: db, Dialect: test.dialect, FS: mustSub(t, testdata, "bad")})
+				err := m.MigrateUp(context.Background())
+				is.True(t, err != nil)
+
+				err = m.MigrateDown(context.Background())
+				is.NotError(t, err)
+
+				rows, err := db.Query(`select operation, actor, from_version, to_version, outcome from migrations_audit order by started_at`)
+				is.NotError(t, err)
+				defer func() {
+					_ = rows.Close()
+				}()
+
+				var entries [][]string
+				for rows.Next() {
+					var operation, actor, fromVersion, toVersion, outcome string
+					err = rows.Scan(&operation, &actor, &fromVersion, &toVersion, &outcome)
+					is.NotError(t, err)
+					entries = append(entries, []string{operation, actor, fromVersion, toVersion, outcome})
+				}
+				is.NotError(t, rows.Err())
+
+				is.Equal(t, 2, len(entries))
+				is.Equal(t, "up", entries[0][0])
+				is.Equal(t, `o'brien\`, entries[0][1])
+				is.Equal(t, "", entries[0][2])
+				is.Equal(t, "1", entries[0][3])
+				is.True(t, strings.HasPrefix(entries[0][4], "error: error running migration 2 from 2.up.sql"))
+				is.Equal(t, "down", entries[1][0])
+				is.Equal(t, "1", entries[1][2])
+				is.Equal(t, "", entries[1][3])
+				is.Equal(t, "success", entries[1][4])
+			})
+
+			t.Run("splits statements and reports progress", func(t *testing.T) {
+				db := test.createDatabase(t)
+
+				fsys := fstest.MapFS{
+					"1.up.sql": {Data: []byte("create table test (v text);\n-- a comment; with a semicolon\ninsert into test values ('a;b');\ninsert into test values ('c');\n")},
+				}
+
+				var progress []migrate.Progress
+				m := migrate.New(migrate.Options{
+					DB: db,
+					FS: fsys,
+					Progress: func(ctx context.Context, p migrate.Progress) {
+						progress = append(progress, p)
+					},
+					SplitStatements: true,
+				})
+				err := m.MigrateUp(context.Background())
+				is.NotError(t, err)
+
+				var count int
+				err = db.QueryRow(`select count(*) from test where v in ('a;b', 'c')`).Scan(&count)
+				is.NotError(t, err)
+				is.Equal(t, 2, count)
+
+				is.Equal(t, 3, len(progress))
+				for i, p := range progress {
+					is.Equal(t, "1.up.sql", p.Name)
+					is.Equal(t, "1", p.Version)
+					is.Equal(t, i+1, p.Statement)
+					is.Equal(t, 3, p.Statements)
+				}
+			})
+
+			t.Run("reports the failing statement when splitting statements", func(t *testing.T) {
+				db := test.createDatabase(t)
+
+				fsys := fstest.MapFS{
+					"1.up.sql": {Data: []byte("create table test (v text);\nselect from;\ninsert into test values ('a');\n")},
+				}
+
+				m := migrate.New(migrate.Options{DB: db, FS: fsys, SplitStatements: true})
+				err := m.MigrateUp(context.Background())
+				is.True(t, err != nil)
+				is.True(t, strings.Contains(err.Error(), "error running migration 1 from 1.up.sql: error in statement 2: "))
+			})
+
+			t.Run("calls before all and after all callbacks with a summary of the run", func(t *testing.T) {
+				db := test.createDatabase(t)
+
+				var before, after migrate.Summary
+				m := migrate.New(migrate.Options{
+					AfterAll: func(ctx context.Context, s migrate.Summary) error {
+						after = s
+						return nil
+					},
+					BeforeAll: func(ctx context.Context, s migrate.Summary) error {
+						before = s
+						return nil
+					},
+					DB: db,
+					FS: mustSub(t, testdata, "good"),
+					OnError: func(ctx context.Context, s migrate.Summary) {
+						t.Fatal("on error called")
+					},
+				})
+				err := m.MigrateUp(context.Background())
+				is.NotError(t, err)
+
+				is.Equal(t, "up", before.Operation)
+				is.Equal(t, "", before.FromVersion)
+				is.Equal(t, 0, len(before.Applied))
+
+				is.Equal(t, "up", after.Operation)
+				is.Equal(t, "", after.FromVersion)
+				is.Equal(t, "3", after.ToVersion)
+				is.Equal(t, "[1.up.sql 2.up.sql 3.up.sql]", fmt.Sprint(after.Applied))
+				is.True(t, after.Err == nil)
+
+				err = m.MigrateTo(context.Background(), "2")
+				is.NotError(t, err)
+				is.Equal(t, "to", after.Operation)
+				is.Equal(t, "3", after.FromVersion)
+				is.Equal(t, "2", after.ToVersion)
+				is.Equal(t, "[3.down.sql]", fmt.Sprint(after.Applied))
+			})
+
+			t.Run("calls on error callback if the run fails", func(t *testing.T) {
+				db := test.createDatabase(t)
+
+				var summary migrate.Summary
+				m := migrate.New(migrate.Options{
+					AfterAll: func(ctx context.Context, s migrate.Summary) error {
+						t.Fatal("after all called")
+						return nil
+					},
+					DB: db,
+					FS: mustSub(t, testdata, "bad"),
+					OnError: func(ctx context.Context, s migrate.Summary) {
+						summary = s
+					},
+				})
+				err := m.MigrateUp(context.Background())
+				is.True(t, err != nil)
+
+				is.Equal(t, "1", summary.ToVersion)
+				is.Equal(t, "[1.up.sql]", fmt.Sprint(summary.Applied))
+				is.True(t, errors.Is(err, summary.Err))
+			})
+
+			t.Run("aborts the run if before all callback fails", func(t *testing.T) {
+				db := test.createDatabase(t)
+
+				m := migrate.New(migrate.Options{
+					BeforeAll: func(ctx context.Context, s migrate.Summary) error {
+						return errors.New("oh no")
+					},
+					DB: db,
+					FS: mustSub(t, testdata, "good"),
+				})
+				err := m.MigrateUp(context.Background())
+				is.True(t, err != nil)
+				is.Equal(t, "error migrating up: error in 'before all' callback: oh no", err.Error())
+
+				version := getVersion(t, db)
+				is.Equal(t, "", version)
+			})
+
+			t.Run("runs until a bad migration file with a dialect", func(t *testing.T) {
+				db := test.createDatabase(t)
+
+				m := migrate.New(migrate.Options{DB: db, FS: mustSub(t, testdata, "bad"), Dialect: test.dialect})
+				err := m.MigrateUp(context.Background())
+				is.True(t, err != nil)
+				is.True(t, strings.Contains(err.Error(), "error migrating up: error running migration 2 from 2.up.sql"))
+
+				version := getVersion(t, db)
+				is.Equal(t, "1", version)
+			})
 		})
 	}
 }
@@ -278,6 +678,16 @@ func TestNew(t *testing.T) {
 		migrate.New(migrate.Options{DB: &sql.DB{}, FS: fstest.MapFS{}, Table: "+"})
 	})
 
+	t.Run("panics on bad version column name", func(t *testing.T) {
+
+		defer func() {
+			err := recover()
+			is.True(t, err != nil)
+			is.Equal(t, `illegal column name +, must match ^\w+$`, err.(string))
+		}()
+		migrate.New(migrate.Options{DB: &sql.DB{}, FS: fstest.MapFS{}, VersionColumns: migrate.VersionColumns{Version: "+"}})
+	})
+
 	t.Run("support table name containing dot", func(t *testing.T) {
 
 		defer func() {
@@ -402,13 +812,203 @@ func createPostgresDatabase(t *testing.T) *sql.DB {
 		t.Fatal(err)
 	}
 	t.Cleanup(func() {
-		if _, err := db.Exec(`drop table if exists migrations; drop table if exists migrations2; drop table if exists test`); err != nil {
+		if _, err := db.Exec(`drop table if exists migrations; drop table if exists migrations_history; drop table if exists migrations_audit; drop table if exists migrations2; drop table if exists "Order"; drop table if exists test`); err != nil {
 			t.Fatal(err)
 		}
 	})
 	return db
 }
 
+func TestMigrator_ShouldApply(t *testing.T) {
+	t.Run("skips migrations it reports false for, but moves the version past them", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		fsys := fstest.MapFS{
+			"1.up.sql": {Data: []byte("create table a (v int)")},
+			"2.up.sql": {Data: []byte("-- ---\n-- tags: eu-only\n-- ---\ncreate table b (v int)")},
+			"3.up.sql": {Data: []byte("create table c (v int)")},
+		}
+
+		var migrations []migrate.Migration
+		m := migrate.New(migrate.Options{
+			DB:      db,
+			FS:      fsys,
+			History: true,
+			ShouldApply: func(ctx context.Context, m migrate.Migration) (bool, error) {
+				migrations = append(migrations, m)
+				return len(m.Tags) == 0 || m.Tags[0] != "eu-only", nil
+			},
+		})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "3", getVersion(t, db))
+		is.Equal(t, 3, len(migrations))
+		is.Equal(t, "2.up.sql", migrations[1].Name)
+		is.Equal(t, "[eu-only]", fmt.Sprint(migrations[1].Tags))
+
+		var names []string
+		rows, err := db.Query(`select name from sqlite_master where name in ('a', 'b', 'c') order by name`)
+		is.NotError(t, err)
+		for rows.Next() {
+			var name string
+			err = rows.Scan(&name)
+			is.NotError(t, err)
+			names = append(names, name)
+		}
+		is.Equal(t, "[a c]", fmt.Sprint(names))
+
+		var count int
+		err = db.QueryRow(`select count(*) from migrations_history`).Scan(&count)
+		is.NotError(t, err)
+		is.Equal(t, 2, count)
+	})
+
+	t.Run("fails the run on errors", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{
+			DB: db,
+			FS: fstest.MapFS{"1.up.sql": {Data: []byte("select 1")}},
+			ShouldApply: func(ctx context.Context, m migrate.Migration) (bool, error) {
+				return false, errors.New("no flags")
+			},
+		})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, "error migrating up: error in 'should apply' callback for 1.up.sql: no flags", err.Error())
+		is.Equal(t, "", getVersion(t, db))
+	})
+}
+
+func TestMigrator_FreshConnPerMigration(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1.up.sql": {Data: []byte("create temporary table scratch (v int)")},
+		"2.up.sql": {Data: []byte("insert into scratch values (1)")},
+	}
+
+	t.Run("applies each migration on its own connection", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, Dialect: migrate.SQLite, FS: fsys, FreshConnPerMigration: true, MissingDown: migrate.CheckIgnore})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.True(t, strings.Contains(err.Error(), "no such table: scratch"))
+		is.Equal(t, "1", getVersion(t, db))
+	})
+
+	t.Run("shares session state between migrations without it", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, Dialect: migrate.SQLite, FS: fsys, MissingDown: migrate.CheckIgnore})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "2", getVersion(t, db))
+	})
+}
+
+func TestMigrator_DropTablesOnDown(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1.up.sql":   {Data: []byte("create table a (v int)")},
+		"1.down.sql": {Data: []byte("drop table a")},
+		"2.up.sql":   {Data: []byte("create table b (v int)")},
+		"2.down.sql": {Data: []byte("drop table b")},
+	}
+
+	countTables := func(t *testing.T, db *sql.DB) int {
+		t.Helper()
+		var count int
+		err := db.QueryRow(`select count(*) from sqlite_master where type = 'table'`).Scan(&count)
+		is.NotError(t, err)
+		return count
+	}
+
+	t.Run("drops the migrations and history tables after migrating all the way down", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		var summaries []migrate.Summary
+		m := migrate.New(migrate.Options{
+			AfterAll: func(ctx context.Context, s migrate.Summary) error {
+				summaries = append(summaries, s)
+				return nil
+			},
+			DB:               db,
+			Dialect:          migrate.SQLite,
+			DropTablesOnDown: true,
+			FS:               fsys,
+			History:          true,
+		})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, 4, countTables(t, db))
+
+		err = m.MigrateTo(context.Background(), "1")
+		is.NotError(t, err)
+		is.Equal(t, 3, countTables(t, db))
+
+		err = m.MigrateDown(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, 0, countTables(t, db))
+		is.Equal(t, 3, len(summaries))
+		is.Equal(t, "", summaries[2].ToVersion)
+
+		err = m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "2", getVersion(t, db))
+	})
+
+	t.Run("keeps the tables without it", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, Dialect: migrate.SQLite, FS: fsys, History: true})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		err = m.MigrateDown(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, 2, countTables(t, db))
+	})
+}
+
+func TestMigrator_MigrateToTime(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1700000000-accounts.up.sql":   {Data: []byte("create table accounts (id int)")},
+		"1700000000-accounts.down.sql": {Data: []byte("drop table accounts")},
+		"1750000000-orders.up.sql":     {Data: []byte("create table orders (id int)")},
+		"1750000000-orders.down.sql":   {Data: []byte("drop table orders")},
+	}
+
+	t.Run("migrates up and down to the newest version created at or before the time", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, FS: fsys})
+
+		err := m.MigrateToTime(context.Background(), time.Unix(1749999999, 0))
+		is.NotError(t, err)
+		is.Equal(t, "1700000000-accounts", getVersion(t, db))
+
+		err = m.MigrateToTime(context.Background(), time.Unix(1750000000, 0))
+		is.NotError(t, err)
+		is.Equal(t, "1750000000-orders", getVersion(t, db))
+
+		err = m.MigrateToTime(context.Background(), time.Unix(1700000000, 0))
+		is.NotError(t, err)
+		is.Equal(t, "1700000000-accounts", getVersion(t, db))
+
+		err = m.MigrateToTime(context.Background(), time.Unix(1600000000, 0))
+		is.NotError(t, err)
+		is.Equal(t, "", getVersion(t, db))
+	})
+
+	t.Run("errors if the versions aren't numbered with timestamps", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, FS: fstest.MapFS{"0001-accounts.up.sql": {}}})
+
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		err = m.MigrateToTime(context.Background(), time.Now())
+		is.Equal(t, "error migrating to time: version of 0001-accounts.up.sql isn't numbered with a Unix timestamp", err.Error())
+	})
+}
+
 func createSQLiteDatabase(t *testing.T) *sql.DB {
 	t.Helper()
 	db, err := sql.Open("sqlite3", "db.sqlite")
@@ -436,9 +1036,18 @@ func createMariaDatabase(t *testing.T) *sql.DB {
 		if _, err := db.Exec(`drop table if exists migrations`); err != nil {
 			t.Fatal(err)
 		}
+		if _, err := db.Exec(`drop table if exists migrations_history`); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(`drop table if exists migrations_audit`); err != nil {
+			t.Fatal(err)
+		}
 		if _, err := db.Exec(`drop table if exists migrations2`); err != nil {
 			t.Fatal(err)
 		}
+		if _, err := db.Exec("drop table if exists `Order`"); err != nil {
+			t.Fatal(err)
+		}
 		if _, err := db.Exec(`drop table if exists test`); err != nil {
 			t.Fatal(err)
 		}
@@ -0,0 +1,92 @@
+package migratetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"maragu.dev/migrate"
+	"maragu.dev/migrate/schema"
+)
+
+// UpDownUp migrates up one migration at a time, down one at a time, and up again, on a throwaway database,
+// checking that each down migration reverts its up migration: that the tables and columns after it are the same
+// as before the up migration, and after migrating up again the same as the first time.
+// Options.Dialect is required to inspect the schema, and Options.DownBoundary is always KeepTarget.
+// The checks of the migration files in opts that are set to CheckWarn fail the run, like with CheckError.
+func UpDownUp(ctx context.Context, opts migrate.Options) error {
+	if opts.Dialect == nil {
+		return errors.New("error exercising migrations: dialect is needed to inspect the schema")
+	}
+	opts.DownBoundary = migrate.KeepTarget
+	for _, level := range []*migrate.CheckLevel{&opts.EmptyFile, &opts.ImplicitCommit, &opts.MissingDown} {
+		if *level == migrate.CheckWarn {
+			*level = migrate.CheckError
+		}
+	}
+
+	table := opts.Table
+	if table == "" {
+		table = "migrations"
+	}
+	// The table may be qualified with a schema, but Inspect reports table names without it
+	if _, after, ok := strings.Cut(table, "."); ok {
+		table = after
+	}
+	exclude := []string{table, table + "_audit", table + "_backfills", table + "_history"}
+	inspect := func() (schema.Schema, error) {
+		return schema.Inspect(ctx, opts.DB, opts.Dialect, exclude...)
+	}
+
+	m := migrate.New(opts)
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("error exercising migrations: %w", err)
+	}
+
+	// The schema before any migrations, and after each
+	snapshots := make([]schema.Schema, len(statuses)+1)
+	if snapshots[0], err = inspect(); err != nil {
+		return fmt.Errorf("error exercising migrations: %w", err)
+	}
+	for i, s := range statuses {
+		if err := m.UpTo(ctx, s.Version); err != nil {
+			return fmt.Errorf("error exercising migrations: %w", err)
+		}
+		if snapshots[i+1], err = inspect(); err != nil {
+			return fmt.Errorf("error exercising migrations: %w", err)
+		}
+	}
+
+	for i := len(statuses) - 1; i >= 0; i-- {
+		if i == 0 {
+			err = m.MigrateDown(ctx)
+		} else {
+			err = m.DownTo(ctx, statuses[i-1].Version)
+		}
+		if err != nil {
+			return fmt.Errorf("error exercising migrations: %w", err)
+		}
+		reverted, err := inspect()
+		if err != nil {
+			return fmt.Errorf("error exercising migrations: %w", err)
+		}
+		if up, _ := schema.Diff(snapshots[i], reverted); up != "" {
+			return fmt.Errorf("error exercising migrations: %v doesn't revert the schema, it leaves:\n%v", statuses[i].Version, up)
+		}
+	}
+
+	if err := m.MigrateUp(ctx); err != nil {
+		return fmt.Errorf("error exercising migrations: migrating up again: %w", err)
+	}
+	remigrated, err := inspect()
+	if err != nil {
+		return fmt.Errorf("error exercising migrations: %w", err)
+	}
+	if up, _ := schema.Diff(snapshots[len(statuses)], remigrated); up != "" {
+		return fmt.Errorf("error exercising migrations: migrating up again gives another schema, with the changes:\n%v", up)
+	}
+	return nil
+}
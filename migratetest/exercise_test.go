@@ -0,0 +1,81 @@
+package migratetest_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/mattn/go-sqlite3"
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+	"maragu.dev/migrate/migratetest"
+)
+
+func newSQLiteDatabase(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "db.sqlite"))
+	is.NotError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	return db
+}
+
+func TestUpDownUp(t *testing.T) {
+	t.Run("passes if the down migrations revert the up migrations", func(t *testing.T) {
+		err := migratetest.UpDownUp(context.Background(), migrate.Options{
+			DB:      newSQLiteDatabase(t),
+			Dialect: migrate.SQLite,
+			FS: fstest.MapFS{
+				"1.up.sql":   {Data: []byte("create table a (v int)")},
+				"1.down.sql": {Data: []byte("drop table a")},
+				"2.up.sql":   {Data: []byte("alter table a add column w int")},
+				"2.down.sql": {Data: []byte("alter table a drop column w")},
+			},
+			History: true,
+		})
+		is.NotError(t, err)
+	})
+
+	t.Run("errors if a down migration leaves the schema changed", func(t *testing.T) {
+		err := migratetest.UpDownUp(context.Background(), migrate.Options{
+			DB:      newSQLiteDatabase(t),
+			Dialect: migrate.SQLite,
+			FS: fstest.MapFS{
+				"1.up.sql":   {Data: []byte("create table a (v int)")},
+				"1.down.sql": {Data: []byte("drop table a")},
+				"2.up.sql":   {Data: []byte("alter table a add column w int")},
+				"2.down.sql": {Data: []byte("select 1")},
+			},
+		})
+		is.True(t, err != nil)
+		is.Equal(t, "error exercising migrations: 2 doesn't revert the schema, it leaves:\nalter table a add column w int;\n", err.Error())
+	})
+
+	t.Run("errors if a down migration leaves a table", func(t *testing.T) {
+		err := migratetest.UpDownUp(context.Background(), migrate.Options{
+			DB:      newSQLiteDatabase(t),
+			Dialect: migrate.SQLite,
+			FS: fstest.MapFS{
+				"1.up.sql":   {Data: []byte("create table a (v int)")},
+				"1.down.sql": {Data: []byte("select 1")},
+			},
+		})
+		is.True(t, err != nil)
+		is.Equal(t, "error exercising migrations: 1 doesn't revert the schema, it leaves:\ncreate table a (\n  v int\n);\n", err.Error())
+	})
+
+	t.Run("errors on a missing down migration with check warn", func(t *testing.T) {
+		err := migratetest.UpDownUp(context.Background(), migrate.Options{
+			DB:          newSQLiteDatabase(t),
+			Dialect:     migrate.SQLite,
+			FS:          fstest.MapFS{"1.up.sql": {Data: []byte("create table a (v int)")}},
+			MissingDown: migrate.CheckWarn,
+		})
+		is.True(t, err != nil)
+		is.Equal(t, "error exercising migrations: error migrating up to: error checking migration files: 1.up.sql has no down migration file 1.down.sql", err.Error())
+	})
+}
@@ -0,0 +1,81 @@
+package migratetest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strings"
+
+	"maragu.dev/migrate/internal/sqlscan"
+)
+
+// Query to check with CheckQueries.
+type Query struct {
+	// Name of the query, like from a sqlc "-- name: GetAccount :one" comment, for error messages.
+	Name string
+	// SQL of the query.
+	SQL string
+}
+
+var queryNameMatcher = regexp.MustCompile(`(?m)^\s*--\s*name:\s*(\w+)`)
+
+// ParseQueries from SQL separated by semicolons, like the query files of sqlc, naming each query
+// from a "-- name: GetAccount :one" comment before it, if there is one.
+func ParseQueries(sql string) []Query {
+	var queries []Query
+	for _, statement := range sqlscan.Split(sql) {
+		q := Query{SQL: statement}
+		if match := queryNameMatcher.FindStringSubmatch(statement); match != nil {
+			q.Name = match[1]
+		}
+		queries = append(queries, q)
+	}
+	return queries
+}
+
+// ReadQueries from the .sql files in fsys, in the order of their names, like from sqlc's queries directory.
+func ReadQueries(fsys fs.FS) ([]Query, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, fmt.Errorf("error reading queries: %w", err)
+	}
+	var queries []Query
+	for _, name := range names {
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("error reading queries: %w", err)
+		}
+		queries = append(queries, ParseQueries(string(content))...)
+	}
+	return queries, nil
+}
+
+// CheckQueries against the schema of db, like a throwaway database migrated with UpDownUp, so queries that
+// a migration broke, like by renaming a column they use, are caught before they fail in production.
+// Each query is prepared, not executed, so the database checks its tables, columns, and types.
+// Use the placeholders of the database, because sqlc macros like sqlc.arg aren't expanded.
+// The error lists each query that failed.
+func CheckQueries(ctx context.Context, db *sql.DB, queries []Query) error {
+	var failures []string
+	for i, q := range queries {
+		name := q.Name
+		if name == "" {
+			name = fmt.Sprintf("query %v", i+1)
+		}
+		stmt, err := db.PrepareContext(ctx, q.SQL)
+		if err != nil {
+			failures = append(failures, name+": "+err.Error())
+			continue
+		}
+		if err := stmt.Close(); err != nil {
+			return fmt.Errorf("error closing prepared query %v: %w", name, err)
+		}
+	}
+	if len(failures) > 0 {
+		return errors.New("error checking queries:\n" + strings.Join(failures, "\n"))
+	}
+	return nil
+}
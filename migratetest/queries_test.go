@@ -0,0 +1,57 @@
+package migratetest_test
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+	"maragu.dev/migrate/migratetest"
+)
+
+func TestCheckQueries(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1.up.sql": {Data: []byte("create table accounts (id int, name text)")},
+		"2.up.sql": {Data: []byte("alter table accounts rename column name to title")},
+	}
+
+	queries := migratetest.ParseQueries(`-- name: GetAccount :one
+select id, title from accounts where id = ?;
+
+-- name: ListAccountNames :many
+select name from accounts order by name;
+
+-- A query without a name, and a semicolon in a string
+update accounts set title = 'a;b' where id = ?;
+`)
+
+	t.Run("parses queries with their names", func(t *testing.T) {
+		is.Equal(t, 3, len(queries))
+		is.Equal(t, "GetAccount", queries[0].Name)
+		is.Equal(t, "ListAccountNames", queries[1].Name)
+		is.Equal(t, "", queries[2].Name)
+		is.Equal(t, "-- A query without a name, and a semicolon in a string\nupdate accounts set title = 'a;b' where id = ?", queries[2].SQL)
+	})
+
+	t.Run("reports the queries that don't match the migrated schema", func(t *testing.T) {
+		db := newSQLiteDatabase(t)
+		err := migrate.New(migrate.Options{DB: db, FS: fsys, MissingDown: migrate.CheckIgnore}).MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		err = migratetest.CheckQueries(context.Background(), db, queries)
+		is.Equal(t, "error checking queries:\nListAccountNames: no such column: name", err.Error())
+	})
+
+	t.Run("reads queries from sql files", func(t *testing.T) {
+		queries, err := migratetest.ReadQueries(fstest.MapFS{
+			"accounts.sql": {Data: []byte("select 1; select 2;")},
+			"users.sql":    {Data: []byte("-- name: One :one\nselect 3")},
+			"README.md":    {Data: []byte("select 4")},
+		})
+		is.NotError(t, err)
+		is.Equal(t, 3, len(queries))
+		is.Equal(t, "One", queries[2].Name)
+	})
+}
@@ -0,0 +1,49 @@
+//go:build go1.23
+
+package migrate
+
+import (
+	"fmt"
+	"iter"
+)
+
+// Migrations with an up file, in the order they are applied, for tooling that enumerates and inspects the migration set
+// without parsing file names itself. The files and their front matter are read when Migrations is called,
+// and the content of each migration when it's opened, see Migration.Open. It needs Go 1.23 or later.
+//
+//	migrations, err := m.Migrations()
+//	if err != nil {
+//		return err
+//	}
+//	for migration := range migrations {
+//		fmt.Println(migration.Version, migration.HasDown)
+//	}
+func (m *Migrator) Migrations() (iter.Seq[Migration], error) {
+	names, err := m.getFilenames(upMatcher)
+	if err != nil {
+		return nil, fmt.Errorf("error listing migrations: %w", err)
+	}
+
+	var migrations []Migration
+	for _, name := range names {
+		s := step{fileVersion: upMatcher.ReplaceAllString(name, "$1"), name: name}
+		s.version = s.fileVersion
+		fm, err := m.readFrontMatter(s)
+		if err != nil {
+			return nil, fmt.Errorf("error listing migrations: %w", err)
+		}
+		migration, err := m.newMigration(s, fm)
+		if err != nil {
+			return nil, fmt.Errorf("error listing migrations: %w", err)
+		}
+		migrations = append(migrations, migration)
+	}
+
+	return func(yield func(Migration) bool) {
+		for _, migration := range migrations {
+			if !yield(migration) {
+				return
+			}
+		}
+	}, nil
+}
@@ -0,0 +1,73 @@
+//go:build go1.23
+
+package migrate_test
+
+import (
+	"io"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestMigrator_Migrations(t *testing.T) {
+	t.Run("enumerates the up migrations in order, with whether they have a down file and their content", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		is.NotError(t, db.Ping())
+		m := migrate.New(migrate.Options{DB: db, FS: fstest.MapFS{
+			"1-accounts.up.sql":   {Data: []byte("create table accounts (id int);")},
+			"1-accounts.down.sql": {Data: []byte("drop table accounts;")},
+			"2-users.up.sql":      {Data: []byte("-- ---\n-- description: Users of accounts\n-- tags: auth\n-- ---\ncreate table users (id int);")},
+			"3-posts.down.sql":    {Data: []byte("drop table posts;")},
+			"README.md":           {Data: []byte("Not a migration.")},
+		}})
+
+		migrations, err := m.Migrations()
+		is.NotError(t, err)
+
+		var got []migrate.Migration
+		for migration := range migrations {
+			got = append(got, migration)
+		}
+		is.Equal(t, 2, len(got))
+
+		is.Equal(t, "1-accounts", got[0].Version)
+		is.Equal(t, "1-accounts.up.sql", got[0].Name)
+		is.Equal(t, "accounts", got[0].Description)
+		is.True(t, got[0].HasDown)
+		is.True(t, !got[0].Down)
+
+		is.Equal(t, "2-users", got[1].Version)
+		is.Equal(t, "Users of accounts", got[1].Description)
+		is.Equal(t, "auth", got[1].Tags[0])
+		is.True(t, !got[1].HasDown)
+
+		r, err := got[0].Open()
+		is.NotError(t, err)
+		content, err := io.ReadAll(r)
+		is.NotError(t, err)
+		is.NotError(t, r.Close())
+		is.Equal(t, "create table accounts (id int);", string(content))
+	})
+
+	t.Run("stops when the loop breaks", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		is.NotError(t, db.Ping())
+		m := migrate.New(migrate.Options{DB: db, FS: fstest.MapFS{
+			"1-accounts.up.sql": {Data: []byte("create table accounts (id int);")},
+			"2-users.up.sql":    {Data: []byte("create table users (id int);")},
+		}})
+
+		migrations, err := m.Migrations()
+		is.NotError(t, err)
+
+		var versions []string
+		for migration := range migrations {
+			versions = append(versions, migration.Version)
+			break
+		}
+		is.Equal(t, 1, len(versions))
+	})
+}
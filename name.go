@@ -0,0 +1,37 @@
+package migrate
+
+import (
+	"fmt"
+)
+
+// ParseName of a migration file, like "1-accounts.up.sql", with the same patterns the Migrator matches files with,
+// for tools like linters and doc generators. The version is the part before ".up.sql" or ".down.sql", see IsVersion.
+// Versions are applied in the order of their names, like "0002-users" before "0010-posts", so compare them as strings,
+// unless there's a manifest, see ManifestFileName. The Migration has the description from the name, without reading
+// front matter, and can't be opened. HasDown is always false, because the name alone doesn't tell whether
+// there's a down migration file. Use Migrator.Migrations to read the migration files.
+func ParseName(name string) (Migration, error) {
+	down := false
+	match := upMatcher.FindStringSubmatch(name)
+	if match == nil {
+		down = true
+		match = downMatcher.FindStringSubmatch(name)
+	}
+	if match == nil {
+		return Migration{}, fmt.Errorf("error parsing migration file name %v: not like <version>.up.sql or <version>.down.sql", name)
+	}
+
+	version := match[1]
+	return Migration{
+		Description: describe(frontMatter{}, version),
+		Down:        down,
+		Name:        name,
+		Version:     version,
+	}, nil
+}
+
+// IsVersion reports whether s is a valid version, like "1-accounts", with the same pattern the Migrator matches
+// up migration file names with.
+func IsVersion(s string) bool {
+	return upMatcher.MatchString(s + ".up.sql")
+}
@@ -0,0 +1,55 @@
+package migrate_test
+
+import (
+	"testing"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestParseName(t *testing.T) {
+	tests := []struct {
+		name        string
+		version     string
+		description string
+		down        bool
+	}{
+		{"1.up.sql", "1", "", false},
+		{"0002-user_accounts.up.sql", "0002-user_accounts", "user accounts", false},
+		{"1700000000-accounts.down.sql", "1700000000-accounts", "accounts", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m, err := migrate.ParseName(test.name)
+			is.NotError(t, err)
+			is.Equal(t, test.name, m.Name)
+			is.Equal(t, test.version, m.Version)
+			is.Equal(t, test.description, m.Description)
+			is.Equal(t, test.down, m.Down)
+			is.True(t, !m.HasDown)
+		})
+	}
+
+	for _, name := range []string{"1 accounts.up.sql", "1.baseline.sql", "README.md", "archive/1.up.sql", "1.up.sql.bak"} {
+		t.Run("errors on "+name, func(t *testing.T) {
+			_, err := migrate.ParseName(name)
+			is.Equal(t, "error parsing migration file name "+name+": not like <version>.up.sql or <version>.down.sql", err.Error())
+		})
+	}
+}
+
+func TestIsVersion(t *testing.T) {
+	for _, version := range []string{"1", "0002-user_accounts", "1700000000-accounts"} {
+		t.Run(version, func(t *testing.T) {
+			is.True(t, migrate.IsVersion(version))
+		})
+	}
+
+	for _, version := range []string{"", "1 accounts", "1.up", "archive/1"} {
+		t.Run("not "+version, func(t *testing.T) {
+			is.True(t, !migrate.IsVersion(version))
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package migrate
+
+import (
+	"context"
+)
+
+// Notifier is a Dialect that can notify listeners on a channel. See Options.NotifyChannel.
+type Notifier interface {
+	// Notify returns SQL to notify the listeners on the channel with the payload,
+	// or the empty string if the database has no notifications.
+	Notify(channel, payload string) string
+}
+
+func (d postgresDialect) Notify(channel, payload string) string {
+	return `select pg_notify(` + d.QuoteString(channel) + `, ` + d.QuoteString(payload) + `)`
+}
+
+// Notify returns the empty string, because Redshift has no listen and notify.
+func (redshiftDialect) Notify(string, string) string {
+	return ""
+}
+
+// notify the channel in Options.NotifyChannel with the current version, if any of the steps were applied.
+// The migrations are already applied, so failures are logged as warnings instead of failing the run.
+func (m *Migrator) notify(ctx context.Context, steps []step) error {
+	if m.notifyChannel == "" || len(steps) == 0 {
+		return nil
+	}
+
+	version, err := m.getCurrentVersion(ctx)
+	if err != nil {
+		m.logger.Printf("migrate: warning: error notifying channel %v: %v", m.notifyChannel, err)
+		return nil
+	}
+
+	query := m.dialect.(Notifier).Notify(m.notifyChannel, version)
+	if query == "" {
+		return nil
+	}
+	if _, err := m.conn.ExecContext(ctx, query); err != nil {
+		m.logger.Printf("migrate: warning: error notifying channel %v: %v", m.notifyChannel, err)
+	}
+	return nil
+}
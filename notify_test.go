@@ -0,0 +1,104 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+// notifyDialect is SQLite that records notifications in the notifications table.
+type notifyDialect struct {
+	migrate.Dialect
+}
+
+func (notifyDialect) Notify(channel, payload string) string {
+	return `insert into notifications values ('` + channel + ` ` + payload + `')`
+}
+
+func TestMigrator_NotifyChannel(t *testing.T) {
+	newDB := func(t *testing.T) *sql.DB {
+		t.Helper()
+		db := createSQLiteDatabase(t)
+		_, err := db.Exec(`create table notifications (event text)`)
+		is.NotError(t, err)
+		return db
+	}
+
+	getNotifications := func(t *testing.T, db *sql.DB) []string {
+		t.Helper()
+		rows, err := db.Query(`select event from notifications`)
+		is.NotError(t, err)
+		defer func() {
+			_ = rows.Close()
+		}()
+		var events []string
+		for rows.Next() {
+			var event string
+			is.NotError(t, rows.Scan(&event))
+			events = append(events, event)
+		}
+		is.NotError(t, rows.Err())
+		return events
+	}
+
+	fsys := fstest.MapFS{
+		"1.up.sql":   {Data: []byte("select 1")},
+		"1.down.sql": {Data: []byte("select 1")},
+		"2.up.sql":   {Data: []byte("select 1")},
+		"2.down.sql": {Data: []byte("select 1")},
+	}
+
+	t.Run("notifies the channel with the new version after each run that applied migrations", func(t *testing.T) {
+		db := newDB(t)
+		m := migrate.New(migrate.Options{DB: db, Dialect: notifyDialect{Dialect: migrate.SQLite}, FS: fsys, NotifyChannel: "schema"})
+
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		err = m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		err = m.MigrateTo(context.Background(), "1")
+		is.NotError(t, err)
+		is.Equal(t, "[schema 2 schema 1]", fmt.Sprint(getNotifications(t, db)))
+	})
+
+	t.Run("does not notify after a failed run", func(t *testing.T) {
+		db := newDB(t)
+		m := migrate.New(migrate.Options{DB: db, Dialect: notifyDialect{Dialect: migrate.SQLite}, MissingDown: migrate.CheckIgnore,
+			NotifyChannel: "schema",
+			FS: fstest.MapFS{
+				"1.up.sql": {Data: []byte("not sql")},
+			},
+		})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, 0, len(getNotifications(t, db)))
+	})
+
+	t.Run("logs a warning if notifying fails", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		logger := &recordingLogger{}
+		m := migrate.New(migrate.Options{DB: db, Dialect: notifyDialect{Dialect: migrate.SQLite}, FS: fsys, Logger: logger,
+			NotifyChannel: "schema"})
+
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "2", getVersion(t, db))
+		is.Equal(t, 1, len(logger.lines))
+		is.Equal(t, "migrate: warning: error notifying channel schema: no such table: notifications", logger.lines[0])
+	})
+
+	t.Run("panics if the dialect can't notify", func(t *testing.T) {
+		defer func() {
+			err := recover()
+			is.True(t, err != nil)
+			is.Equal(t, "notify channel needs a Dialect that is a Notifier", err.(string))
+		}()
+		migrate.New(migrate.Options{DB: &sql.DB{}, Dialect: migrate.SQLite, FS: fsys, NotifyChannel: "schema"})
+	})
+}
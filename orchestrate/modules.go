@@ -0,0 +1,78 @@
+package orchestrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"maragu.dev/migrate"
+)
+
+// Module with its own migrations, and its own migrations table in the database shared with the other Modules.
+type Module struct {
+	// FS of the migrations of the module.
+	FS fs.FS
+	// Name of the module, like "auth".
+	Name string
+	// Table of the module's migrations. Defaults to the name with a "_migrations" suffix, like "auth_migrations".
+	Table string
+}
+
+// Modules of a modular monolith, which share one database, but each have their own migrations and migrations table,
+// so they can be developed independently:
+//
+//	m := orchestrate.Modules{
+//		DB: db,
+//		Modules: []orchestrate.Module{
+//			{Name: "auth", FS: authMigrations},
+//			{Name: "billing", FS: billingMigrations},
+//		},
+//		Options: migrate.Options{Dialect: migrate.Postgres},
+//	}
+//	results, err := m.MigrateUp(ctx)
+type Modules struct {
+	// DB shared by the modules.
+	DB *sql.DB
+	// Modules in the order they are migrated, like auth before billing, if billing references the tables of auth.
+	Modules []Module
+	// Options for the Migrator of each module. DB, FS, and Table are set for each module.
+	Options migrate.Options
+}
+
+// MigrateUp each module in order, stopping at the first module that fails.
+// The results are those of the modules migrated so far, including the one that failed.
+func (m Modules) MigrateUp(ctx context.Context) ([]Result, error) {
+	seen := map[string]bool{}
+	for _, module := range m.Modules {
+		if module.Name == "" {
+			return nil, errors.New("module without a name")
+		}
+		if seen[module.Name] {
+			return nil, fmt.Errorf("module %v is there twice", module.Name)
+		}
+		seen[module.Name] = true
+	}
+
+	var results []Result
+	for _, module := range m.Modules {
+		opts := m.Options
+		opts.DB = m.DB
+		opts.FS = module.FS
+		opts.Table = module.Table
+		if opts.Table == "" {
+			opts.Table = module.Name + "_migrations"
+		}
+
+		summary, err := migrateUp(ctx, opts)
+		if err != nil && summary.Err == nil {
+			summary.Err = err
+		}
+		results = append(results, Result{Name: module.Name, Summary: summary})
+		if err != nil {
+			return results, fmt.Errorf("error migrating module %v: %w", module.Name, err)
+		}
+	}
+	return results, nil
+}
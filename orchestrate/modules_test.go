@@ -0,0 +1,95 @@
+package orchestrate_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+	"maragu.dev/migrate/orchestrate"
+)
+
+func TestModules_MigrateUp(t *testing.T) {
+	auth := fstest.MapFS{
+		"1.up.sql": {Data: []byte("create table users (id int primary key)")},
+		"2.up.sql": {Data: []byte("create table sessions (id int)")},
+	}
+	billing := fstest.MapFS{
+		"1.up.sql": {Data: []byte("create table invoices (id int, user_id int references users (id))")},
+	}
+
+	newDB := func(t *testing.T) *sql.DB {
+		t.Helper()
+		db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "app.sqlite"))
+		is.NotError(t, err)
+		t.Cleanup(func() {
+			_ = db.Close()
+		})
+		return db
+	}
+
+	t.Run("migrates each module in order, with its own migrations table", func(t *testing.T) {
+		db := newDB(t)
+		m := orchestrate.Modules{
+			DB: db,
+			Modules: []orchestrate.Module{
+				{Name: "auth", FS: auth},
+				{Name: "billing", FS: billing, Table: "billing_versions"},
+			},
+			Options: migrate.Options{MissingDown: migrate.CheckIgnore},
+		}
+
+		results, err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, 2, len(results))
+		is.Equal(t, "auth", results[0].Name)
+		is.Equal(t, "[1.up.sql 2.up.sql]", fmt.Sprint(results[0].Summary.Applied))
+		is.Equal(t, "billing", results[1].Name)
+		is.Equal(t, "[1.up.sql]", fmt.Sprint(results[1].Summary.Applied))
+
+		var version string
+		err = db.QueryRow(`select version from auth_migrations`).Scan(&version)
+		is.NotError(t, err)
+		is.Equal(t, "2", version)
+		err = db.QueryRow(`select version from billing_versions`).Scan(&version)
+		is.NotError(t, err)
+		is.Equal(t, "1", version)
+
+		results, err = m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, 0, len(results[0].Summary.Applied))
+		is.Equal(t, "2", results[0].Summary.FromVersion)
+	})
+
+	t.Run("stops at the first module that fails", func(t *testing.T) {
+		db := newDB(t)
+		m := orchestrate.Modules{
+			DB: db,
+			Modules: []orchestrate.Module{
+				{Name: "auth", FS: fstest.MapFS{"1.up.sql": {Data: []byte("not sql")}}},
+				{Name: "billing", FS: billing},
+			},
+			Options: migrate.Options{MissingDown: migrate.CheckIgnore},
+		}
+
+		results, err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, 1, len(results))
+		is.True(t, results[0].Summary.Err != nil)
+	})
+
+	t.Run("errors on modules with the same name", func(t *testing.T) {
+		m := orchestrate.Modules{
+			DB:      newDB(t),
+			Modules: []orchestrate.Module{{Name: "auth", FS: auth}, {Name: "auth", FS: billing}},
+		}
+
+		_, err := m.MigrateUp(context.Background())
+		is.Equal(t, "module auth is there twice", err.Error())
+	})
+}
@@ -0,0 +1,152 @@
+// Package orchestrate migrates several databases from a single config in a declared order,
+// like the databases of the services in a monorepo, where auth must be migrated before billing.
+// It also migrates the modules of a modular monolith that share one database, each with its own migrations table.
+package orchestrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"sort"
+
+	"maragu.dev/migrate"
+)
+
+// Database to migrate.
+type Database struct {
+	// Dir of the migrations in Orchestrator.FS. Defaults to the name of the database.
+	Dir string
+	// DSN for sql.Open with Orchestrator.Driver.
+	DSN string
+}
+
+// Orchestrator of migrations for several databases:
+//
+//	o := orchestrate.Orchestrator{
+//		Databases: map[string]orchestrate.Database{
+//			"auth":    {DSN: os.Getenv("AUTH_DSN")},
+//			"billing": {DSN: os.Getenv("BILLING_DSN")},
+//		},
+//		Driver:  "pgx",
+//		FS:      migrations,
+//		Options: migrate.Options{Dialect: migrate.Postgres},
+//		Order:   []string{"auth", "billing"},
+//	}
+//	results, err := o.MigrateUp(ctx)
+type Orchestrator struct {
+	// Databases by logical name.
+	Databases map[string]Database
+	// Driver name for sql.Open, like "pgx".
+	Driver string
+	// FS with a directory of migrations for each database.
+	FS fs.FS
+	// Options for the Migrator of each database. DB and FS are set for each database.
+	Options migrate.Options
+	// Order of the databases by name. Databases not in Order are migrated after them, sorted by name.
+	Order []string
+}
+
+// Result of migrating a database.
+type Result struct {
+	// Name of the database.
+	Name string
+	// Summary of the run, with Summary.Err set if it failed.
+	Summary migrate.Summary
+}
+
+// MigrateUp each database in order, stopping at the first database that fails.
+// The results are those of the databases migrated so far, including the one that failed.
+func (o Orchestrator) MigrateUp(ctx context.Context) ([]Result, error) {
+	names, err := o.order()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Result
+	for _, name := range names {
+		summary, err := o.migrateUp(ctx, name)
+		if err != nil && summary.Err == nil {
+			summary.Err = err
+		}
+		results = append(results, Result{Name: name, Summary: summary})
+		if err != nil {
+			return results, fmt.Errorf("error migrating database %v: %w", name, err)
+		}
+	}
+	return results, nil
+}
+
+// migrateUp the named database, returning the summary of the run.
+func (o Orchestrator) migrateUp(ctx context.Context, name string) (migrate.Summary, error) {
+	d := o.Databases[name]
+
+	dir := d.Dir
+	if dir == "" {
+		dir = name
+	}
+	fsys, err := fs.Sub(o.FS, dir)
+	if err != nil {
+		return migrate.Summary{}, err
+	}
+
+	db, err := sql.Open(o.Driver, d.DSN)
+	if err != nil {
+		return migrate.Summary{}, err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	opts := o.Options
+	opts.DB = db
+	opts.FS = fsys
+	return migrateUp(ctx, opts)
+}
+
+// migrateUp with the options, returning the summary of the run.
+func migrateUp(ctx context.Context, opts migrate.Options) (migrate.Summary, error) {
+	var summary migrate.Summary
+	afterAll, onError := opts.AfterAll, opts.OnError
+	opts.AfterAll = func(ctx context.Context, s migrate.Summary) error {
+		summary = s
+		if afterAll != nil {
+			return afterAll(ctx, s)
+		}
+		return nil
+	}
+	opts.OnError = func(ctx context.Context, s migrate.Summary) {
+		summary = s
+		if onError != nil {
+			onError(ctx, s)
+		}
+	}
+
+	err := migrate.New(opts).MigrateUp(ctx)
+	return summary, err
+}
+
+// order of the database names, with those in Order first.
+func (o Orchestrator) order() ([]string, error) {
+	seen := map[string]bool{}
+	var names []string
+	for _, name := range o.Order {
+		if _, ok := o.Databases[name]; !ok {
+			return nil, fmt.Errorf("unknown database %v in order", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("database %v is in order twice", name)
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+
+	var rest []string
+	for name := range o.Databases {
+		if !seen[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	return append(names, rest...), nil
+}
@@ -0,0 +1,86 @@
+package orchestrate_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/mattn/go-sqlite3"
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+	"maragu.dev/migrate/orchestrate"
+)
+
+func TestOrchestrator_MigrateUp(t *testing.T) {
+	fsys := fstest.MapFS{
+		"auth/1.up.sql":          {Data: []byte("create table users (id int)")},
+		"auth/2.up.sql":          {Data: []byte("create table sessions (id int)")},
+		"billing/1.up.sql":       {Data: []byte("create table invoices (id int)")},
+		"analytics/sql/1.up.sql": {Data: []byte("create table events (id int)")},
+	}
+
+	newOrchestrator := func(t *testing.T) orchestrate.Orchestrator {
+		t.Helper()
+		dir := t.TempDir()
+		return orchestrate.Orchestrator{
+			Databases: map[string]orchestrate.Database{
+				"analytics": {Dir: "analytics/sql", DSN: filepath.Join(dir, "analytics.sqlite")},
+				"auth":      {DSN: filepath.Join(dir, "auth.sqlite")},
+				"billing":   {DSN: filepath.Join(dir, "billing.sqlite")},
+			},
+			Driver:  "sqlite3",
+			FS:      fsys,
+			Options: migrate.Options{MissingDown: migrate.CheckIgnore},
+			Order:   []string{"billing", "auth"},
+		}
+	}
+
+	t.Run("migrates each database in order, then the rest by name", func(t *testing.T) {
+		o := newOrchestrator(t)
+
+		results, err := o.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, 3, len(results))
+
+		is.Equal(t, "billing", results[0].Name)
+		is.Equal(t, "[1.up.sql]", fmt.Sprint(results[0].Summary.Applied))
+		is.Equal(t, "auth", results[1].Name)
+		is.Equal(t, "[1.up.sql 2.up.sql]", fmt.Sprint(results[1].Summary.Applied))
+		is.Equal(t, "2", results[1].Summary.ToVersion)
+		is.Equal(t, "analytics", results[2].Name)
+		is.Equal(t, "[1.up.sql]", fmt.Sprint(results[2].Summary.Applied))
+
+		results, err = o.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, 0, len(results[1].Summary.Applied))
+		is.Equal(t, "2", results[1].Summary.FromVersion)
+	})
+
+	t.Run("stops at the first database that fails", func(t *testing.T) {
+		o := newOrchestrator(t)
+		o.FS = fstest.MapFS{
+			"auth/1.up.sql":    {Data: []byte("create table users (id int)")},
+			"billing/1.up.sql": {Data: []byte("not sql")},
+		}
+		delete(o.Databases, "analytics")
+
+		results, err := o.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, 1, len(results))
+		is.Equal(t, "billing", results[0].Name)
+		is.True(t, results[0].Summary.Err != nil)
+		is.Equal(t, `error migrating database billing: error migrating up: error running migration 1 from 1.up.sql: near "not": syntax error`, err.Error())
+	})
+
+	t.Run("errors on an unknown database in order", func(t *testing.T) {
+		o := newOrchestrator(t)
+		o.Order = []string{"auth", "shipping"}
+
+		_, err := o.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, "unknown database shipping in order", err.Error())
+	})
+}
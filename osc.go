@@ -0,0 +1,55 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// OnlineSchemaChanger alters tables without blocking writes, for migrations marked in their front matter:
+//
+//	-- ---
+//	-- online-schema-change: true
+//	-- ---
+//	alter table orders add column note text;
+//
+// Each alter table statement of a marked migration is passed to Alter instead of executed, with the table name
+// as written and the rest of the statement, like "add column note text". Other statements are executed as usual.
+// Marked migrations run outside a transaction, and the version is updated after them.
+// See the hooks package for implementations with gh-ost and pt-online-schema-change.
+type OnlineSchemaChanger interface {
+	Alter(ctx context.Context, table, alter string) error
+}
+
+var alterTableMatcher = regexp.MustCompile("(?is)^alter\\s+table\\s+([\\w.`\"]+)\\s+(.*?)[\\s;]*$")
+
+// parseAlterTable statement into the table and the alteration, if it's an alter table statement.
+// Leading comment lines are skipped.
+func parseAlterTable(statement string) (table, alter string, ok bool) {
+	lines := strings.Split(strings.TrimSpace(statement), "\n")
+	for len(lines) > 0 && (strings.HasPrefix(strings.TrimSpace(lines[0]), "--") || strings.TrimSpace(lines[0]) == "") {
+		lines = lines[1:]
+	}
+	match := alterTableMatcher.FindStringSubmatch(strings.Join(lines, "\n"))
+	if match == nil {
+		return "", "", false
+	}
+	return strings.NewReplacer("`", "", `"`, "").Replace(match[1]), match[2], true
+}
+
+// alterOnline with the OnlineSchemaChanger, if the statement is an alter table statement.
+func (m *Migrator) alterOnline(ctx context.Context, statement string) (bool, error) {
+	table, alter, ok := parseAlterTable(statement)
+	if !ok {
+		return false, nil
+	}
+	if m.onlineSchemaChanger == nil {
+		return true, errors.New("online-schema-change needs an OnlineSchemaChanger in Options")
+	}
+	if err := m.onlineSchemaChanger.Alter(ctx, table, alter); err != nil {
+		return true, fmt.Errorf("error altering %v online: %w", table, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,78 @@
+package migrate_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+type recordingChanger struct {
+	err    error
+	alters []string
+}
+
+func (r *recordingChanger) Alter(ctx context.Context, table, alter string) error {
+	r.alters = append(r.alters, table+": "+alter)
+	return r.err
+}
+
+func TestMigrator_OnlineSchemaChange(t *testing.T) {
+	t.Run("passes alter table statements of marked migrations to the OnlineSchemaChanger", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		fsys := fstest.MapFS{
+			"1.up.sql": {Data: []byte("create table orders (id int)")},
+			"2.up.sql": {Data: []byte("-- ---\n-- online-schema-change: true\n-- ---\n" +
+				"alter table `shop`.orders add column note text;\n-- Backfill\ninsert into orders (id) values (1);\n" +
+				"-- Big table\nALTER TABLE orders\n  add index note (note);")},
+			"3.up.sql": {Data: []byte("alter table orders add column other text")},
+		}
+
+		changer := &recordingChanger{}
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, MissingDown: migrate.CheckIgnore, OnlineSchemaChanger: changer})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "3", getVersion(t, db))
+		is.Equal(t, "[shop.orders: add column note text orders: add index note (note)]", fmt.Sprint(changer.alters))
+
+		var count int
+		err = db.QueryRow(`select count(*) from orders`).Scan(&count)
+		is.NotError(t, err)
+		is.Equal(t, 1, count)
+	})
+
+	t.Run("errors and keeps the version if altering fails", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		fsys := fstest.MapFS{
+			"1.up.sql": {Data: []byte("-- ---\n-- online-schema-change: true\n-- ---\nalter table orders add column note text")},
+		}
+
+		changer := &recordingChanger{err: errors.New("oh no")}
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, MissingDown: migrate.CheckIgnore, OnlineSchemaChanger: changer})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, "error migrating up: error running migration 1 from 1.up.sql: error in statement 1: error altering orders online: oh no", err.Error())
+		is.Equal(t, "", getVersion(t, db))
+	})
+
+	t.Run("errors without an OnlineSchemaChanger", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		fsys := fstest.MapFS{
+			"1.up.sql": {Data: []byte("-- ---\n-- online-schema-change: true\n-- ---\nalter table orders add column note text")},
+		}
+
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, MissingDown: migrate.CheckIgnore})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, "error migrating up: error running migration 1 from 1.up.sql: error in statement 1: "+
+			"online-schema-change needs an OnlineSchemaChanger in Options", err.Error())
+	})
+}
@@ -0,0 +1,212 @@
+package migrate
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const dependsDirective = "-- migrate:depends"
+
+// getParallel number of migrations to apply at the same time, which is Options.Parallel, limited by the maximum number
+// of open connections of the pool, if the session holds one of them. Otherwise workers would wait forever
+// for connections, like with SetMaxOpenConns(1), which is common with SQLite.
+func (m *Migrator) getParallel() int {
+	max := m.db.Stats().MaxOpenConnections
+	if max <= 0 || m.conn == executor(m.db) || m.parallel < max {
+		return m.parallel
+	}
+	parallel := max - 1
+	if parallel < 1 {
+		parallel = 1
+	}
+	m.logger.Printf("migrate: warning: limiting Parallel from %v to %v, "+
+		"because the database pool has at most %v open connections, and the run holds one of them", m.parallel, parallel, max)
+	return parallel
+}
+
+// applyParallel up steps, each as soon as the steps it depends on are applied, at most parallel at a time.
+// Each step runs in its own transaction on a connection from the pool.
+// The version is updated to the newest version with all steps up to and including it applied,
+// in the transaction of the step that completes it.
+func (m *Migrator) applyParallel(ctx context.Context, steps []step, parallel int) error {
+	deps, err := m.getDependencies(steps)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		applied  = make([]bool, len(steps))
+		next     int // first step that isn't applied
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	done := make([]chan struct{}, len(steps))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+	sem := make(chan struct{}, parallel)
+
+	// Workers use connections from the pool, so they don't wait for the session connection or each other
+	worker := *m
+	worker.cache = nil
+	worker.conn = m.db
+
+	m.metrics.Pending(len(steps))
+	for i := range steps {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			for _, d := range deps[i] {
+				select {
+				case <-done[d]:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-sem }()
+
+			locked := false
+			var storeVersion string
+			err := worker.applyWith(ctx, steps[i], func(ctx context.Context, q queryer) error {
+				// Hold the lock until the transaction is done, so versions are updated in order
+				mu.Lock()
+				locked = true
+				applied[i] = true
+				newNext := next
+				for newNext < len(steps) && applied[newNext] {
+					newNext++
+				}
+				if newNext == next {
+					return nil
+				}
+				if worker.store != nil {
+					// Set after the transaction is committed
+					storeVersion = steps[newNext-1].version
+					return nil
+				}
+				return worker.updateVersion(ctx, q, steps[newNext-1].version)
+			})
+			if !locked {
+				mu.Lock()
+			}
+			defer mu.Unlock()
+
+			if err == nil && storeVersion != "" {
+				err = worker.setVersion(ctx, storeVersion)
+			}
+			if err != nil {
+				applied[i] = false
+				if firstErr == nil {
+					firstErr = err
+				}
+				cancel()
+				return
+			}
+			for next < len(steps) && applied[next] {
+				next++
+			}
+			m.applied = append(m.applied, steps[i].name)
+			m.metrics.Pending(len(steps) - len(m.applied))
+			close(done[i])
+		}(i)
+	}
+	wg.Wait()
+	if m.cache != nil {
+		m.cache.versionKnown = false
+	}
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// getDependencies of each step, as indexes of other steps. A step depends on the previous step,
+// unless its file starts with the depends directive or front matter listing the versions it depends on.
+// Versions before the first step are already applied, so they're not included.
+func (m *Migrator) getDependencies(steps []step) ([][]int, error) {
+	order, err := m.getVersionOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := map[string]int{}
+	deps := make([][]int, len(steps))
+	for i, s := range steps {
+		indexes[s.version] = i
+
+		fm, err := m.readFrontMatter(s)
+		if err != nil {
+			return nil, err
+		}
+		versions, ok := fm.dependsOn, fm.hasDependsOn
+		if !ok {
+			versions, ok, err = m.readDependsDirective(s.name)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if !ok {
+			if i > 0 {
+				deps[i] = []int{i - 1}
+			}
+			continue
+		}
+
+		for _, v := range versions {
+			if order.compare(v, steps[0].version) < 0 {
+				continue
+			}
+			j, ok := indexes[v]
+			if !ok || j == i {
+				return nil, fmt.Errorf("error in %v: version %v is not an earlier migration", s.name, v)
+			}
+			deps[i] = append(deps[i], j)
+		}
+	}
+	return deps, nil
+}
+
+// readDependsDirective from the comments at the start of the named file, reporting whether it has one.
+func (m *Migrator) readDependsDirective(name string) ([]string, bool, error) {
+	f, err := m.fs.Open(name)
+	if err != nil {
+		return nil, false, fmt.Errorf("error reading migration file %v: %w", name, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "--") {
+			break
+		}
+		if rest := strings.TrimPrefix(line, dependsDirective); rest != line && (rest == "" || rest[0] == ' ') {
+			return strings.Fields(rest), true, nil
+		}
+	}
+	// A line too long for the scanner isn't a comment with the directive, because it's only on short lines at the start
+	if err := scanner.Err(); err != nil && !errors.Is(err, bufio.ErrTooLong) {
+		return nil, false, fmt.Errorf("error reading migration file %v: %w", name, err)
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,109 @@
+package migrate_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestMigrator_Parallel(t *testing.T) {
+	t.Run("applies migrations after the ones they depend on", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		// SQLite doesn't allow concurrent writes, so the migrations wait for the connection
+		db.SetMaxOpenConns(1)
+
+		fsys := fstest.MapFS{
+			"1.up.sql": {Data: []byte("create table a (v int)")},
+			"2.up.sql": {Data: []byte("-- migrate:depends\ncreate table b (v int)")},
+			"3.up.sql": {Data: []byte("-- Load a\n-- migrate:depends 1\ninsert into a values (1)")},
+			"4.up.sql": {Data: []byte("insert into b select v from a")},
+		}
+
+		var s migrate.Summary
+		m := migrate.New(migrate.Options{
+			AfterAll: func(ctx context.Context, summary migrate.Summary) error {
+				s = summary
+				return nil
+			},
+			DB:       db,
+			FS:       fsys,
+			Parallel: 3,
+		})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		version := getVersion(t, db)
+		is.Equal(t, "4", version)
+		is.Equal(t, 4, len(s.Applied))
+		is.Equal(t, "4.up.sql", s.Applied[3])
+
+		var count int
+		err = db.QueryRow(`select count(*) from b`).Scan(&count)
+		is.NotError(t, err)
+		is.Equal(t, 1, count)
+	})
+
+	t.Run("does not update the version past a failed migration", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		db.SetMaxOpenConns(1)
+
+		fsys := fstest.MapFS{
+			"1.up.sql": {Data: []byte("create table a (v int)")},
+			"2.up.sql": {Data: []byte("-- migrate:depends\nnot sql")},
+			"3.up.sql": {Data: []byte("-- migrate:depends 1\ninsert into a values (1)")},
+		}
+
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, Parallel: 2})
+		err := m.MigrateTo(context.Background(), "1")
+		is.NotError(t, err)
+
+		err = m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.True(t, strings.Contains(err.Error(), "error running migration 2 from 2.up.sql"))
+
+		version := getVersion(t, db)
+		is.Equal(t, "1", version)
+	})
+
+	t.Run("applies fewer migrations at a time if the pool doesn't have a connection for each besides the run's", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		db.SetMaxOpenConns(1)
+
+		fsys := fstest.MapFS{
+			"1.up.sql": {Data: []byte("create table a (v int)")},
+			"2.up.sql": {Data: []byte("-- migrate:depends\ncreate table b (v int)")},
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		l := &recordingLogger{}
+		m := migrate.New(migrate.Options{DB: db, Dialect: migrate.SQLite, FS: fsys, Logger: l, Parallel: 2})
+		err := m.MigrateUp(ctx)
+		is.NotError(t, err)
+		is.Equal(t, "2", getVersion(t, db))
+		is.Equal(t, 1, len(l.lines))
+		is.Equal(t, "migrate: warning: limiting Parallel from 2 to 1, "+
+			"because the database pool has at most 1 open connections, and the run holds one of them", l.lines[0])
+	})
+
+	t.Run("errors on depending on a later migration", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		fsys := fstest.MapFS{
+			"1.up.sql": {Data: []byte("-- migrate:depends 2\ncreate table a (v int)")},
+			"2.up.sql": {Data: []byte("create table b (v int)")},
+		}
+
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, Parallel: 2})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, "error migrating up: error in 1.up.sql: version 2 is not an earlier migration", err.Error())
+	})
+}
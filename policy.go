@@ -0,0 +1,203 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+
+	"maragu.dev/migrate/analyze"
+	"maragu.dev/migrate/internal/sqlscan"
+)
+
+// Policy of rules for the statements in the migration files to apply, with their included files.
+// All statements are checked before any migrations are applied, and the run fails on the first rule that errors.
+//
+//	m := migrate.New(migrate.Options{
+//		DB:     db,
+//		FS:     fsys,
+//		Policy: migrate.Policy{migrate.DenyDropTable(), migrate.DenyUnqualifiedDelete(), migrate.DenyAlter("accounts")},
+//	})
+type Policy []Rule
+
+// Rule for a statement, returning an error if the statement is not allowed.
+type Rule func(s PolicyStatement) error
+
+// PolicyStatement to check with a Rule.
+type PolicyStatement struct {
+	// Down is whether the statement is from a down migration.
+	Down bool
+	// Name of the migration file.
+	Name string
+	// SQL of the statement, with comments.
+	SQL string
+}
+
+// DenyDropTable statements in up migrations. Down migrations are allowed to drop tables,
+// because they often drop the tables their up migration created.
+func DenyDropTable() Rule {
+	return func(s PolicyStatement) error {
+		words := sqlscan.Words(s.SQL)
+		if !s.Down && len(words) >= 2 && words[0] == "drop" && words[1] == "table" {
+			return errors.New("drop table is not allowed")
+		}
+		return nil
+	}
+}
+
+// DenyUnqualifiedDelete statements without a where clause, in both up and down migrations.
+func DenyUnqualifiedDelete() Rule {
+	return func(s PolicyStatement) error {
+		words := sqlscan.Words(s.SQL)
+		if len(words) == 0 || words[0] != "delete" {
+			return nil
+		}
+		for _, w := range words {
+			if w == "where" {
+				return nil
+			}
+		}
+		return errors.New("delete without where is not allowed")
+	}
+}
+
+// DenyAlter table statements on the given tables, in both up and down migrations.
+// Table names are compared without quotes and case, and a name without a schema matches it in any schema.
+func DenyAlter(tables ...string) Rule {
+	return func(s PolicyStatement) error {
+		words := sqlscan.Words(s.SQL)
+		if len(words) < 3 || words[0] != "alter" || words[1] != "table" {
+			return nil
+		}
+		name := ""
+		for _, w := range words[2:] {
+			if w != "if" && w != "exists" && w != "only" {
+				name = w
+				break
+			}
+		}
+		for _, table := range tables {
+			table = strings.ToLower(table)
+			if name == table || !strings.Contains(table, ".") && strings.HasSuffix(name, "."+table) {
+				return fmt.Errorf("alter table %v is not allowed", table)
+			}
+		}
+		return nil
+	}
+}
+
+// checkPolicy for the statements of steps, before any of them are applied.
+func (m *Migrator) checkPolicy(steps []step) error {
+	if len(m.policy) == 0 {
+		return nil
+	}
+
+	for _, s := range steps {
+		f, err := m.open(s)
+		if err != nil {
+			return err
+		}
+
+		scanner := sqlscan.NewScanner(f)
+		for i := 1; scanner.Scan(); i++ {
+			ps := PolicyStatement{Down: s.down, Name: s.name, SQL: scanner.Statement()}
+			for _, rule := range m.policy {
+				if err := rule(ps); err != nil {
+					_ = f.Close()
+					return fmt.Errorf("error checking policy: %v statement %v: %w", s.name, i, err)
+				}
+			}
+		}
+		_ = f.Close()
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("error checking policy: error reading migration file %v: %w", s.name, err)
+		}
+	}
+	return nil
+}
+
+// AnalyzeFiles reads the up migration files in fsys for analyze.Analyze, in the order they are applied,
+// with their included files. Environment variables aren't expanded, see Options.ExpandEnv.
+func AnalyzeFiles(fsys fs.FS) ([]analyze.File, error) {
+	files, err := (&Migrator{fs: fsys}).analyzeFiles()
+	if err != nil {
+		return nil, fmt.Errorf("error reading migrations to analyze: %w", err)
+	}
+	return files, nil
+}
+
+// analyzeFiles of the up migrations, see AnalyzeFiles.
+func (m *Migrator) analyzeFiles() ([]analyze.File, error) {
+	names, err := m.getFilenames(upMatcher)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []analyze.File
+	for _, name := range names {
+		f, err := m.openIncluding(name, nil)
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(f)
+		_ = f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading migration file %v: %w", name, err)
+		}
+		files = append(files, analyze.File{Name: name, SQL: string(content)})
+	}
+	return files, nil
+}
+
+// confirm the up migrations of steps with Options.ConfirmDangerous, if the rules of Options.DangerousRules find risky
+// statements in them. Only the migrations to apply are analyzed, as they are applied.
+func (m *Migrator) confirm(ctx context.Context, steps []step) error {
+	if m.confirmDangerous == nil {
+		return nil
+	}
+
+	var files []analyze.File
+	for _, s := range steps {
+		if s.down || s.baseline {
+			continue
+		}
+		f, err := m.open(s)
+		if err != nil {
+			return err
+		}
+		content, err := io.ReadAll(f)
+		_ = f.Close()
+		if err != nil {
+			return fmt.Errorf("error reading migration file %v: %w", s.name, err)
+		}
+		files = append(files, analyze.File{Name: s.name, SQL: string(content)})
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	findings := analyze.Analyze(files, m.getDangerousRules()...)
+	if len(findings) == 0 {
+		return nil
+	}
+
+	if err := m.confirmDangerous(ctx, findings); err != nil {
+		return fmt.Errorf("error confirming dangerous migrations: %w", err)
+	}
+	return nil
+}
+
+// getDangerousRules from Options.DangerousRules, or else the built-in rules of the analyze package for the Dialect.
+// The rules about indexes and table rewrites are only for Postgres and Redshift, because they're about their locks.
+func (m *Migrator) getDangerousRules() []analyze.Rule {
+	if m.dangerousRules != nil {
+		return m.dangerousRules()
+	}
+	switch m.dialect.(type) {
+	case postgresDialect, redshiftDialect:
+		return analyze.Rules()
+	}
+	return []analyze.Rule{analyze.NotNull(), analyze.DroppedColumnReferenced()}
+}
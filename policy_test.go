@@ -0,0 +1,164 @@
+package migrate_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+	"maragu.dev/migrate/analyze"
+)
+
+func TestMigrator_Policy(t *testing.T) {
+	policy := migrate.Policy{migrate.DenyDropTable(), migrate.DenyUnqualifiedDelete(), migrate.DenyAlter("accounts")}
+
+	t.Run("allows statements that follow the rules", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		fsys := fstest.MapFS{
+			"1.up.sql":   {Data: []byte("create table accounts (v text);\ncreate table users (v text);\nalter table users add w text")},
+			"1.down.sql": {Data: []byte("drop table users;\ndrop table accounts")},
+			"2.up.sql":   {Data: []byte("delete from users where v = 'drop table accounts';\n-- delete from accounts\nselect 1")},
+		}
+
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, Policy: policy})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+	})
+
+	tests := []struct {
+		content string
+		err     string
+	}{
+		{"select 1;\n/* careful */ DROP TABLE users", "error checking policy: 1.up.sql statement 2: drop table is not allowed"},
+		{"delete from users", "error checking policy: 1.up.sql statement 1: delete without where is not allowed"},
+		{`alter table if exists "main"."Accounts" add w text`, "error checking policy: 1.up.sql statement 1: alter table accounts is not allowed"},
+	}
+	for _, test := range tests {
+		t.Run("denies "+test.content, func(t *testing.T) {
+			db := createSQLiteDatabase(t)
+
+			fsys := fstest.MapFS{
+				"0.up.sql": {Data: []byte("create table users (v text)")},
+				"1.up.sql": {Data: []byte(test.content)},
+			}
+
+			m := migrate.New(migrate.Options{DB: db, FS: fsys, Policy: policy})
+			err := m.MigrateUp(context.Background())
+			is.True(t, err != nil)
+			is.Equal(t, "error migrating up: "+test.err, err.Error())
+			is.Equal(t, "", getVersion(t, db))
+		})
+	}
+}
+
+func TestMigrator_ConfirmDangerous(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1-accounts.up.sql": {Data: []byte("create table accounts (id int, name text);")},
+		"2-kind.up.sql":     {Data: []byte("alter table accounts add column kind text not null;")},
+	}
+
+	t.Run("aborts the run if it returns an error for the findings in pending migrations", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		err := migrate.New(migrate.Options{DB: db, FS: fsys, MissingDown: migrate.CheckIgnore}).MigrateTo(context.Background(), "1-accounts")
+		is.NotError(t, err)
+
+		var confirmed []analyze.Finding
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, MissingDown: migrate.CheckIgnore,
+			ConfirmDangerous: func(ctx context.Context, findings []analyze.Finding) error {
+				confirmed = findings
+				return errors.New("set ALLOW_DANGEROUS to apply")
+			},
+		})
+		err = m.MigrateUp(context.Background())
+		is.Equal(t, "error migrating up: error confirming dangerous migrations: set ALLOW_DANGEROUS to apply", err.Error())
+		is.Equal(t, "1-accounts", getVersion(t, db))
+		is.Equal(t, 1, len(confirmed))
+		is.Equal(t, "2-kind.up.sql", confirmed[0].Name)
+		is.Equal(t, "not-null", confirmed[0].Rule)
+	})
+
+	t.Run("isn't called without findings in pending migrations", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		err := migrate.New(migrate.Options{DB: db, FS: fsys, MissingDown: migrate.CheckIgnore}).MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		fsys := fstest.MapFS{
+			"1-accounts.up.sql": fsys["1-accounts.up.sql"],
+			"2-kind.up.sql":     fsys["2-kind.up.sql"],
+			"3-users.up.sql":    {Data: []byte("create table users (id int not null);")},
+		}
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, MissingDown: migrate.CheckIgnore,
+			ConfirmDangerous: func(ctx context.Context, findings []analyze.Finding) error {
+				return errors.New("called")
+			},
+		})
+		err = m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "3-users", getVersion(t, db))
+	})
+
+	t.Run("isn't called for indexes without concurrently on other databases than Postgres", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		fsys := fstest.MapFS{
+			"1-accounts.up.sql": fsys["1-accounts.up.sql"],
+			"2-index.up.sql":    {Data: []byte("create index accounts_name on accounts (name);")},
+		}
+		err := migrate.New(migrate.Options{DB: db, FS: fsys, MissingDown: migrate.CheckIgnore}).MigrateTo(context.Background(), "1-accounts")
+		is.NotError(t, err)
+
+		m := migrate.New(migrate.Options{DB: db, Dialect: migrate.SQLite, FS: fsys, MissingDown: migrate.CheckIgnore,
+			ConfirmDangerous: func(ctx context.Context, findings []analyze.Finding) error {
+				return errors.New("called")
+			},
+		})
+		err = m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "2-index", getVersion(t, db))
+	})
+
+	t.Run("uses the rules of DangerousRules", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		var confirmed []analyze.Finding
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, MissingDown: migrate.CheckIgnore,
+			ConfirmDangerous: func(ctx context.Context, findings []analyze.Finding) error {
+				confirmed = findings
+				return nil
+			},
+			DangerousRules: func() []analyze.Rule {
+				return []analyze.Rule{analyze.NewRule("no-create", func(s analyze.Statement) []string {
+					if strings.HasPrefix(s.SQL, "create") {
+						return []string{"creates something"}
+					}
+					return nil
+				})}
+			},
+		})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, 1, len(confirmed))
+		is.Equal(t, "1-accounts.up.sql: no-create: creates something", confirmed[0].String())
+	})
+}
+
+func TestAnalyzeFiles(t *testing.T) {
+	t.Run("reads the up migration files in the order of the manifest, with their included files", func(t *testing.T) {
+		files, err := migrate.AnalyzeFiles(fstest.MapFS{
+			"1-accounts.up.sql":   {Data: []byte("create table accounts (id int);")},
+			"1-accounts.down.sql": {Data: []byte("drop table accounts;")},
+			"2-users.up.sql":      {Data: []byte("-- migrate:include common/users.sql\n")},
+			"common/users.sql":    {Data: []byte("create table users (id int);")},
+			"migrations.list":     {Data: []byte("2-users.up.sql\n1-accounts.up.sql\n")},
+		})
+		is.NotError(t, err)
+		is.Equal(t, 2, len(files))
+		is.Equal(t, "2-users.up.sql", files[0].Name)
+		is.Equal(t, "create table users (id int);\n", files[0].SQL)
+		is.Equal(t, "1-accounts.up.sql", files[1].Name)
+	})
+}
@@ -0,0 +1,148 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"maragu.dev/migrate/internal/sqlscan"
+)
+
+// PrivilegeChecker is a Dialect that can check the privileges of the connected user before migrations are applied.
+// See Options.CheckPrivileges.
+type PrivilegeChecker interface {
+	// CanCreate returns SQL for a row with whether the user can create objects in the schema,
+	// or in the default schema if it's empty. It's true if the schema doesn't exist yet.
+	CanCreate(schema string) string
+	// CanAlter returns SQL for a row with whether the user can alter and drop the table, and create indexes on it.
+	// It's true if the table doesn't exist yet.
+	CanAlter(table string) string
+}
+
+func (d postgresDialect) CanCreate(schema string) string {
+	name := `current_schema()`
+	if schema != "" {
+		name = d.QuoteString(schema)
+	}
+	return `select coalesce((select has_schema_privilege(oid, 'CREATE') from pg_namespace where nspname = ` + name + `), true)`
+}
+
+// CanAlter on Postgres needs the user to own the table, or be a member of the role that owns it.
+func (d postgresDialect) CanAlter(table string) string {
+	return `select coalesce((select pg_has_role(relowner, 'USAGE') from pg_class where oid = to_regclass(` +
+		d.QuoteString(table) + `)), true)`
+}
+
+var (
+	createObjectMatcher = regexp.MustCompile("(?is)^create\\s+(?:or\\s+replace\\s+)?(?:unlogged\\s+)?" +
+		"(?:table|view|materialized\\s+view|sequence|type|function|procedure)\\s+(?:if\\s+not\\s+exists\\s+)?([\\w.`\"]+)")
+	alterObjectMatcher = regexp.MustCompile("(?is)^(?:" +
+		"alter\\s+table\\s+(?:if\\s+exists\\s+)?(?:only\\s+)?|" +
+		"drop\\s+table\\s+(?:if\\s+exists\\s+)?|" +
+		"create\\s+(?:unique\\s+)?index\\s+(?:concurrently\\s+)?(?:if\\s+not\\s+exists\\s+)?[\\w`\"]*\\s*on\\s+(?:only\\s+)?)([\\w.`\"]+)")
+)
+
+// privilege needed by a migration file.
+type privilege struct {
+	// create in the schema, if set, else alter the table.
+	create bool
+	name   string
+}
+
+func (p privilege) String() string {
+	if p.create {
+		if p.name == "" {
+			return "create in the default schema"
+		}
+		return "create in schema " + p.name
+	}
+	return "alter table " + p.name
+}
+
+// verifyPrivileges needed by the migration files of steps, before any of them are applied. See Options.CheckPrivileges.
+func (m *Migrator) verifyPrivileges(ctx context.Context, steps []step) error {
+	if !m.checkPrivileges || len(steps) == 0 {
+		return nil
+	}
+	pc, ok := m.dialect.(PrivilegeChecker)
+	if !ok {
+		return errors.New("error checking privileges: needs a Dialect that is a PrivilegeChecker")
+	}
+
+	var needed []privilege
+	neededBy := map[privilege]string{}
+	// Tables created by earlier statements of the run are owned by the user
+	created := map[string]bool{}
+	for _, s := range steps {
+		statements, err := m.readStatements(s)
+		if err != nil {
+			return err
+		}
+		for _, statement := range statements {
+			statement = trimCommentLines(statement)
+			var p privilege
+			if match := createObjectMatcher.FindStringSubmatch(statement); match != nil {
+				name := unquoteIdentifier(match[1])
+				created[strings.ToLower(name)] = true
+				p = privilege{create: true}
+				if i := strings.LastIndex(name, "."); i >= 0 {
+					p.name = name[:i]
+				}
+			} else if match := alterObjectMatcher.FindStringSubmatch(statement); match != nil {
+				name := unquoteIdentifier(match[1])
+				if created[strings.ToLower(name)] {
+					continue
+				}
+				p = privilege{name: name}
+			} else {
+				continue
+			}
+			if _, ok := neededBy[p]; !ok {
+				neededBy[p] = s.name
+				needed = append(needed, p)
+			}
+		}
+	}
+
+	var missing []string
+	for _, p := range needed {
+		query := pc.CanAlter(p.name)
+		if p.create {
+			query = pc.CanCreate(p.name)
+		}
+		var ok bool
+		if err := m.conn.QueryRowContext(ctx, query).Scan(&ok); err != nil {
+			return fmt.Errorf("error checking privilege to %v: %w", p, err)
+		}
+		if !ok {
+			missing = append(missing, p.String()+" for "+neededBy[p])
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("error checking privileges: the user can't %v", strings.Join(missing, ", or "))
+	}
+	return nil
+}
+
+// readStatements of the file of a step, with its included files.
+func (m *Migrator) readStatements(s step) ([]string, error) {
+	f, err := m.open(s)
+	if err != nil {
+		return nil, fmt.Errorf("error reading migration file %v: %w", s.name, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var statements []string
+	scanner := sqlscan.NewScanner(f)
+	for scanner.Scan() {
+		statements = append(statements, scanner.Statement())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading migration file %v: %w", s.name, err)
+	}
+	return statements, nil
+}
@@ -0,0 +1,69 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+// privilegedDialect is SQLite with the privileges of the user in the grants table.
+type privilegedDialect struct {
+	migrate.Dialect
+}
+
+func (privilegedDialect) CanCreate(schema string) string {
+	return `select count(*) > 0 from grants where privilege = 'create ` + schema + `'`
+}
+
+func (privilegedDialect) CanAlter(table string) string {
+	return `select count(*) > 0 from grants where privilege = 'alter ` + table + `'`
+}
+
+func TestMigrator_CheckPrivileges(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1-accounts.up.sql": {Data: []byte("create table accounts (id int);\nalter table accounts add column name text;")},
+		"2-users.up.sql":    {Data: []byte("-- Users\ncreate index users_name on users (name);\ncreate view app.active as select 1;")},
+	}
+
+	newDB := func(t *testing.T, grants ...string) *sql.DB {
+		t.Helper()
+		db := createSQLiteDatabase(t)
+		_, err := db.Exec(`create table grants (privilege text); create table users (name text)`)
+		is.NotError(t, err)
+		for _, g := range grants {
+			_, err := db.Exec(`insert into grants values (?)`, g)
+			is.NotError(t, err)
+		}
+		return db
+	}
+
+	t.Run("fails before applying anything with the missing privileges", func(t *testing.T) {
+		db := newDB(t, "create ")
+		m := migrate.New(migrate.Options{DB: db, Dialect: privilegedDialect{Dialect: migrate.SQLite}, FS: fsys,
+			CheckPrivileges: true, MissingDown: migrate.CheckIgnore})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, "error migrating up: error checking privileges: the user can't alter table users for 2-users.up.sql, "+
+			"or create in schema app for 2-users.up.sql", err.Error())
+		is.Equal(t, "", getVersion(t, db))
+	})
+
+	t.Run("applies the migrations if the user has the privileges", func(t *testing.T) {
+		db := newDB(t, "create ", "alter users")
+		m := migrate.New(migrate.Options{DB: db, Dialect: privilegedDialect{Dialect: migrate.SQLite},
+			CheckPrivileges: true, MissingDown: migrate.CheckIgnore,
+			FS: fstest.MapFS{
+				"1-accounts.up.sql": fsys["1-accounts.up.sql"],
+				"2-users.up.sql":    {Data: []byte("create index users_name on users (name);")},
+			},
+		})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "2-users", getVersion(t, db))
+	})
+}
@@ -0,0 +1,89 @@
+package migrate
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// checkApplied checks that the current version, and with Options.History each version recorded in the history,
+// has an up migration file, unless it's archived in the baseline. See Options.MissingApplied.
+func (m *Migrator) checkApplied(ctx context.Context) error {
+	if m.missingApplied == CheckIgnore {
+		return nil
+	}
+
+	missing, err := m.getMissingApplied(ctx)
+	if err != nil {
+		return err
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return m.report(m.missingApplied, "applied versions have no up migration file, like after pruning old files: %v",
+		strings.Join(missing, ", "))
+}
+
+// getMissingApplied versions, sorted, which are the current version, and with Options.History each version
+// recorded in the history, that have no up migration file and aren't archived in the baseline.
+func (m *Migrator) getMissingApplied(ctx context.Context) ([]string, error) {
+	currentVersion, err := m.getCurrentVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := map[string]bool{}
+	if currentVersion != "" {
+		applied[currentVersion] = true
+	}
+	if m.history {
+		history, err := m.getHistory(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for version := range history {
+			applied[version] = true
+		}
+	}
+
+	names, err := m.getFilenames(upMatcher)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		delete(applied, upMatcher.ReplaceAllString(name, "$1"))
+	}
+
+	_, baselineVersion, err := m.getBaseline()
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := m.getVersionOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for version := range applied {
+		if order.compare(version, baselineVersion) <= 0 {
+			continue
+		}
+		missing = append(missing, version)
+	}
+	sort.Strings(missing)
+	return missing, nil
+}
+
+// isInHistory reports whether the version is recorded as applied in the history table, with Options.History.
+func (m *Migrator) isInHistory(ctx context.Context, version string) (bool, error) {
+	if !m.history {
+		return false, nil
+	}
+	history, err := m.getHistory(ctx)
+	if err != nil {
+		return false, err
+	}
+	_, ok := history[version]
+	return ok, nil
+}
@@ -0,0 +1,93 @@
+package migrate_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestMigrator_MissingApplied(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1-accounts.up.sql":   {Data: []byte("create table accounts (id int);")},
+		"1-accounts.down.sql": {Data: []byte("drop table accounts;")},
+		"2-users.up.sql":      {Data: []byte("create table users (id int);")},
+		"2-users.down.sql":    {Data: []byte("drop table users;")},
+	}
+
+	// pruned has the files of 1-accounts and 2-users deleted, like after squashing them
+	pruned := fstest.MapFS{
+		"3-posts.up.sql":   {Data: []byte("create table posts (id int);")},
+		"3-posts.down.sql": {Data: []byte("drop table posts;")},
+	}
+
+	t.Run("warns about applied versions without files with check warn, and migrates", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		err := migrate.New(migrate.Options{DB: db, FS: fsys, History: true}).MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		logger := &recordingLogger{}
+		m := migrate.New(migrate.Options{DB: db, FS: pruned, History: true, Logger: logger, MissingApplied: migrate.CheckWarn})
+		err = m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "3-posts", getVersion(t, db))
+		is.Equal(t, "[migrate: warning: applied versions have no up migration file, like after pruning old files: 1-accounts, 2-users]",
+			fmt.Sprint(logger.lines))
+	})
+
+	t.Run("errors about applied versions without files with check error", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		err := migrate.New(migrate.Options{DB: db, FS: fsys}).MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		m := migrate.New(migrate.Options{DB: db, FS: pruned, MissingApplied: migrate.CheckError})
+		err = m.MigrateUp(context.Background())
+		is.Equal(t, "error migrating up: error checking migration files: applied versions have no up migration file, "+
+			"like after pruning old files: 2-users", err.Error())
+		is.Equal(t, "2-users", getVersion(t, db))
+	})
+
+	t.Run("ignores applied versions without files with check ignore", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		err := migrate.New(migrate.Options{DB: db, FS: fsys}).MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		logger := &recordingLogger{}
+		m := migrate.New(migrate.Options{DB: db, FS: pruned, Logger: logger, MissingApplied: migrate.CheckIgnore})
+		err = m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, 0, len(logger.lines))
+	})
+
+	t.Run("migrates down to an applied version without files with history", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		err := migrate.New(migrate.Options{DB: db, FS: fsys, History: true}).MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		m := migrate.New(migrate.Options{DB: db, FS: pruned, History: true, MissingApplied: migrate.CheckIgnore})
+		err = m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		err = m.MigrateTo(context.Background(), "2-users")
+		is.NotError(t, err)
+		is.Equal(t, "2-users", getVersion(t, db))
+	})
+
+	t.Run("errors migrating down to a version without files without history", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		err := migrate.New(migrate.Options{DB: db, FS: fsys}).MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		m := migrate.New(migrate.Options{DB: db, FS: pruned, MissingApplied: migrate.CheckIgnore})
+		err = m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		err = m.MigrateTo(context.Background(), "2-users")
+		is.Equal(t, "error migrating to: error finding version 2-users", err.Error())
+		is.Equal(t, "3-posts", getVersion(t, db))
+	})
+}
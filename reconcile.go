@@ -0,0 +1,152 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Reconcile the version with the migrations that were applied out of band, like with a script from Migrator.Script
+// that a DBA applied by hand, but only partly, or without the version updates.
+// It needs Options.History, both when writing the script and here, because the script records each migration
+// in the history table, with the checksum of its up file. Starting from the current version, each pending migration
+// with a record in the history table is reconciled, until the first one without. The reconciled migrations
+// get the batch number of this run, so they can be reverted with Migrator.RollbackLastBatch.
+// It errors before changing anything if the up file of a migration to reconcile has changed since it was recorded.
+// Returns the names of the reconciled migration files.
+func (m *Migrator) Reconcile(ctx context.Context) (names []string, err error) {
+	ctx, span := m.tracer.Start(ctx, "migrate reconcile")
+	span.SetAttribute("migrate.table", m.table)
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error reconciling: %w", err)
+		}
+		span.End(err)
+	}()
+
+	err = m.session(ctx, func(s *Migrator) error {
+		return s.run(ctx, "reconcile", func() error {
+			var err error
+			names, err = s.reconcile(ctx)
+			return err
+		})
+	})
+	return names, err
+}
+
+func (m *Migrator) reconcile(ctx context.Context) ([]string, error) {
+	if !m.history {
+		return nil, errors.New("history is needed, see Options.History")
+	}
+
+	if err := m.createMigrationsTable(ctx); err != nil {
+		return nil, err
+	}
+
+	currentVersion, err := m.getCurrentVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	steps, err := m.planUp(currentVersion, "")
+	if err != nil {
+		return nil, err
+	}
+
+	checksums, err := m.getChecksums(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var reconciled []step
+	for _, s := range steps {
+		recorded, ok := checksums[s.fileVersion]
+		if !ok {
+			break
+		}
+		if recorded.Valid {
+			checksum, err := m.checksum(s.name)
+			if err != nil {
+				return nil, err
+			}
+			if checksum != recorded.String {
+				return nil, fmt.Errorf("%v has changed since it was applied: checksum is %v, but the history has %v",
+					s.name, checksum, recorded.String)
+			}
+		}
+		reconciled = append(reconciled, s)
+	}
+	if len(reconciled) == 0 {
+		return nil, nil
+	}
+
+	lastBatch, err := m.getLastBatch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var names, versions []string
+	for _, s := range reconciled {
+		names = append(names, s.name)
+		// The versions have been matched against the regexes, so they're safe to interpolate
+		versions = append(versions, `'`+s.fileVersion+`'`)
+	}
+	version := reconciled[len(reconciled)-1].version
+
+	err = m.inTransaction(ctx, func(q queryer) error {
+		query := `update ` + m.dialect.Quote(m.historyTable()) + ` set batch = ` + strconv.FormatInt(lastBatch+1, 10) +
+			` where batch is null and version in (` + strings.Join(versions, ", ") + `)`
+		if _, err := q.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("error updating history: %w", err)
+		}
+		if m.store != nil {
+			return nil
+		}
+		return m.updateVersion(ctx, q, version)
+	})
+	if err != nil {
+		if m.cache != nil {
+			m.cache.versionKnown = false
+		}
+		return nil, err
+	}
+
+	if m.store != nil {
+		if err := m.setVersion(ctx, version); err != nil {
+			return nil, err
+		}
+	}
+	if m.cache != nil {
+		m.cache.setVersion(version)
+	}
+	m.applied = append(m.applied, names...)
+	return names, nil
+}
+
+// getChecksums of the up files from the history table, by version. Versions recorded without a checksum are null.
+func (m *Migrator) getChecksums(ctx context.Context) (map[string]sql.NullString, error) {
+	rows, err := m.conn.QueryContext(ctx, `select version, checksum from `+m.dialect.Quote(m.historyTable()))
+	if err != nil {
+		return nil, fmt.Errorf("error getting checksums: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	checksums := map[string]sql.NullString{}
+	for rows.Next() {
+		var version string
+		var checksum sql.NullString
+		if err := rows.Scan(&version, &checksum); err != nil {
+			return nil, fmt.Errorf("error scanning checksum: %w", err)
+		}
+		checksums[version] = checksum
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error getting checksums: %w", err)
+	}
+	return checksums, nil
+}
@@ -0,0 +1,105 @@
+package migrate_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestMigrator_Reconcile(t *testing.T) {
+	newFS := func() fstest.MapFS {
+		return fstest.MapFS{
+			"1-accounts.up.sql":   {Data: []byte("create table accounts (id int);")},
+			"1-accounts.down.sql": {Data: []byte("drop table accounts;")},
+			"2-users.up.sql":      {Data: []byte("create table users (id int);")},
+			"2-users.down.sql":    {Data: []byte("drop table users;")},
+			"3-posts.up.sql":      {Data: []byte("create table posts (id int);")},
+			"3-posts.down.sql":    {Data: []byte("drop table posts;")},
+		}
+	}
+
+	t.Run("records the migrations applied by a script without version updates", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, FS: newFS(), History: true})
+
+		var b strings.Builder
+		err := m.Script(context.Background(), &b, "2-users")
+		is.NotError(t, err)
+
+		var script []string
+		for _, line := range strings.Split(b.String(), "\n") {
+			if !strings.HasPrefix(line, "update ") {
+				script = append(script, line)
+			}
+		}
+		_, err = db.Exec(strings.Join(script, "\n"))
+		is.NotError(t, err)
+		is.Equal(t, "", getVersion(t, db))
+
+		names, err := m.Reconcile(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "[1-accounts.up.sql 2-users.up.sql]", fmt.Sprint(names))
+		is.Equal(t, "2-users", getVersion(t, db))
+
+		err = m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "3-posts", getVersion(t, db))
+
+		err = m.RollbackLastBatch(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "2-users", getVersion(t, db))
+
+		err = m.RollbackLastBatch(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "", getVersion(t, db))
+	})
+
+	t.Run("does nothing if nothing was applied out of band", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, FS: newFS(), History: true})
+
+		err := m.MigrateTo(context.Background(), "1-accounts")
+		is.NotError(t, err)
+
+		names, err := m.Reconcile(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, 0, len(names))
+		is.Equal(t, "1-accounts", getVersion(t, db))
+	})
+
+	t.Run("errors if a migration changed since the script was written", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		fsys := newFS()
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, History: true})
+
+		var b strings.Builder
+		err := m.Script(context.Background(), &b, "1-accounts")
+		is.NotError(t, err)
+		_, err = db.Exec(strings.Replace(b.String(), `update migrations set version = '1-accounts';`, "", 1))
+		is.NotError(t, err)
+
+		fsys["1-accounts.up.sql"] = &fstest.MapFile{Data: []byte("create table accounts (id bigint);")}
+
+		_, err = m.Reconcile(context.Background())
+		is.True(t, err != nil)
+		is.True(t, strings.Contains(err.Error(), "1-accounts.up.sql has changed since it was applied"))
+		is.Equal(t, "", getVersion(t, db))
+	})
+
+	t.Run("errors without history", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, FS: newFS()})
+
+		err := m.MigrateTo(context.Background(), "1-accounts")
+		is.NotError(t, err)
+
+		_, err = m.Reconcile(context.Background())
+		is.Equal(t, "error reconciling: history is needed, see Options.History", err.Error())
+	})
+}
@@ -0,0 +1,227 @@
+package migrate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// RenumberedFile is the file in the migrations directory where Renumber keeps the mapping from old to new versions.
+const RenumberedFile = "renumbered.json"
+
+// Renumber the migration files in dir in sequence, zero-padded to four digits or the width of the number of versions,
+// keeping the name after the number, like "1700000000-accounts" to "0001-accounts". It returns the mapping from old
+// to new versions, and also writes it to RenumberedFile in dir, merged with the mapping of earlier renumberings.
+//
+// If there is a manifest, the versions are numbered in its order, and its entries are renamed too, see ManifestFileName.
+//
+// Keep RenumberedFile with the migrations, so that before each run, a Migrator with a database at an old version
+// updates it to the new one, in the migrations table and the history table, and running systems aren't stranded.
+func Renumber(dir string) (mapping map[string]string, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error renumbering migrations: %w", err)
+		}
+	}()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := (&Migrator{fs: os.DirFS(dir)}).getVersionOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []string
+	files := map[string][]string{}
+	for _, e := range entries {
+		version, ok := versionOf(e.Name())
+		if !ok {
+			continue
+		}
+		if len(files[version]) == 0 {
+			versions = append(versions, version)
+		}
+		files[version] = append(files[version], e.Name())
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		return order.compare(versions[i], versions[j]) < 0
+	})
+
+	width := len(fmt.Sprint(len(versions)))
+	if width < 4 {
+		width = 4
+	}
+
+	mapping = map[string]string{}
+	renames := map[string]string{}
+	for i, version := range versions {
+		name := strings.TrimLeft(version, "0123456789")
+		if name == version {
+			return nil, fmt.Errorf("version %v has no number", version)
+		}
+		newVersion := fmt.Sprintf("%0*d", width, i+1) + name
+		if newVersion == version {
+			continue
+		}
+		mapping[version] = newVersion
+		for _, oldName := range files[version] {
+			renames[oldName] = newVersion + strings.TrimPrefix(oldName, version)
+		}
+	}
+
+	for _, newName := range renames {
+		if _, ok := renames[newName]; ok {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, newName)); err == nil {
+			return nil, fmt.Errorf("%v already exists", newName)
+		}
+	}
+
+	// Rename in two steps, so no file overwrites another that is renamed too
+	for oldName := range renames {
+		if err := os.Rename(filepath.Join(dir, oldName), filepath.Join(dir, oldName+".renumber")); err != nil {
+			return nil, err
+		}
+	}
+	for oldName, newName := range renames {
+		if err := os.Rename(filepath.Join(dir, oldName+".renumber"), filepath.Join(dir, newName)); err != nil {
+			return nil, err
+		}
+	}
+
+	if order != nil {
+		if err := renameInManifest(dir, renames); err != nil {
+			return nil, err
+		}
+	}
+
+	earlier, err := readRenumbered(os.DirFS(dir))
+	if err != nil {
+		return nil, err
+	}
+	merged := map[string]string{}
+	for old, mid := range earlier {
+		merged[old] = mid
+		if newVersion, ok := mapping[mid]; ok {
+			merged[old] = newVersion
+		}
+	}
+	for old, newVersion := range mapping {
+		merged[old] = newVersion
+	}
+
+	content, err := json.MarshalIndent(merged, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, RenumberedFile), append(content, '\n'), 0644); err != nil {
+		return nil, err
+	}
+	return mapping, nil
+}
+
+// renameInManifest in dir the up migration files from old to new names, keeping the other lines as they are.
+func renameInManifest(dir string, renames map[string]string) error {
+	path := filepath.Join(dir, ManifestFileName)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.SplitAfter(string(content), "\n")
+	for i, line := range lines {
+		name := strings.TrimSpace(line)
+		if newName, ok := renames[name]; ok {
+			lines[i] = strings.Replace(line, name, newName, 1)
+		}
+	}
+	return os.WriteFile(path, []byte(strings.Join(lines, "")), 0644)
+}
+
+// versionOf a migration or baseline file name, reporting false if it's neither.
+func versionOf(name string) (string, bool) {
+	for _, matcher := range []*regexp.Regexp{upMatcher, downMatcher, baselineMatcher} {
+		if matcher.MatchString(name) {
+			return matcher.ReplaceAllString(name, "$1"), true
+		}
+	}
+	return "", false
+}
+
+// readRenumbered mapping from old to new versions from RenumberedFile in fsys, or nil if there is no such file.
+// The versions are checked, so they're safe to interpolate in SQL.
+func readRenumbered(fsys fs.FS) (map[string]string, error) {
+	content, err := fs.ReadFile(fsys, RenumberedFile)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var mapping map[string]string
+	if err := json.Unmarshal(content, &mapping); err != nil {
+		return nil, fmt.Errorf("error parsing %v: %w", RenumberedFile, err)
+	}
+	for old, newVersion := range mapping {
+		if !upMatcher.MatchString(old+".up.sql") || !upMatcher.MatchString(newVersion+".up.sql") {
+			return nil, fmt.Errorf("error parsing %v: invalid versions %q and %q", RenumberedFile, old, newVersion)
+		}
+	}
+	return mapping, nil
+}
+
+// getRenumbered mapping from RenumberedFile in the migrations, or nil if there is no such file.
+func (m *Migrator) getRenumbered() (map[string]string, error) {
+	entries, err := m.readDir()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.Name() == RenumberedFile {
+			return readRenumbered(m.fs)
+		}
+	}
+	return nil, nil
+}
+
+// repairRenumbered updates the version to its new one from the mapping, if it's an old version,
+// and likewise the versions in the history table. Returns the version, new or not.
+func (m *Migrator) repairRenumbered(ctx context.Context, q queryer, mapping map[string]string, version string) (string, error) {
+	newVersion, ok := mapping[version]
+	if !ok {
+		return version, nil
+	}
+
+	if m.history {
+		table := m.dialect.Quote(m.historyTable())
+		for old, newVersion := range mapping {
+			query := `update ` + table + ` set version = '` + newVersion + `' where version = '` + old + `'`
+			if _, err := q.ExecContext(ctx, query); err != nil {
+				return "", fmt.Errorf("error updating renumbered version %v to %v in history: %w", old, newVersion, err)
+			}
+		}
+	}
+
+	if m.store != nil {
+		if err := m.setVersion(ctx, newVersion); err != nil {
+			return "", err
+		}
+		return newVersion, nil
+	}
+	if err := m.updateVersion(ctx, q, newVersion); err != nil {
+		return "", err
+	}
+	return newVersion, nil
+}
@@ -0,0 +1,128 @@
+package migrate_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestRenumber(t *testing.T) {
+	newDir := func(t *testing.T) string {
+		t.Helper()
+		dir := t.TempDir()
+		files := map[string]string{
+			"1700000000-accounts.up.sql":   "create table accounts (id int);",
+			"1700000000-accounts.down.sql": "drop table accounts;",
+			"1700000100-users.up.sql":      "create table users (id int);",
+			"1700000100-users.down.sql":    "drop table users;",
+		}
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dir
+	}
+
+	t.Run("renumbers the files in sequence and writes the mapping", func(t *testing.T) {
+		dir := newDir(t)
+
+		mapping, err := migrate.Renumber(dir)
+		is.NotError(t, err)
+		is.Equal(t, "map[1700000000-accounts:0001-accounts 1700000100-users:0002-users]", fmt.Sprint(mapping))
+
+		var names []string
+		entries, err := os.ReadDir(dir)
+		is.NotError(t, err)
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		is.Equal(t, "[0001-accounts.down.sql 0001-accounts.up.sql 0002-users.down.sql 0002-users.up.sql renumbered.json]",
+			fmt.Sprint(names))
+
+		content, err := os.ReadFile(filepath.Join(dir, migrate.RenumberedFile))
+		is.NotError(t, err)
+		is.Equal(t, "{\n  \"1700000000-accounts\": \"0001-accounts\",\n  \"1700000100-users\": \"0002-users\"\n}\n", string(content))
+	})
+
+	t.Run("merges the mapping with earlier renumberings", func(t *testing.T) {
+		dir := newDir(t)
+
+		_, err := migrate.Renumber(dir)
+		is.NotError(t, err)
+
+		err = os.Rename(filepath.Join(dir, "0001-accounts.up.sql"), filepath.Join(dir, "0000-accounts.up.sql"))
+		is.NotError(t, err)
+		err = os.Rename(filepath.Join(dir, "0001-accounts.down.sql"), filepath.Join(dir, "0000-accounts.down.sql"))
+		is.NotError(t, err)
+
+		mapping, err := migrate.Renumber(dir)
+		is.NotError(t, err)
+		is.Equal(t, "map[0000-accounts:0001-accounts]", fmt.Sprint(mapping))
+
+		content, err := os.ReadFile(filepath.Join(dir, migrate.RenumberedFile))
+		is.NotError(t, err)
+		is.Equal(t, "{\n  \"0000-accounts\": \"0001-accounts\",\n  \"1700000000-accounts\": \"0001-accounts\",\n"+
+			"  \"1700000100-users\": \"0002-users\"\n}\n", string(content))
+	})
+
+	t.Run("numbers in the order of the manifest and renames its entries", func(t *testing.T) {
+		dir := newDir(t)
+		err := os.WriteFile(filepath.Join(dir, migrate.ManifestFileName),
+			[]byte("# Order\n1700000100-users.up.sql\n1700000000-accounts.up.sql\n"), 0644)
+		is.NotError(t, err)
+
+		mapping, err := migrate.Renumber(dir)
+		is.NotError(t, err)
+		is.Equal(t, "map[1700000000-accounts:0002-accounts 1700000100-users:0001-users]", fmt.Sprint(mapping))
+
+		content, err := os.ReadFile(filepath.Join(dir, migrate.ManifestFileName))
+		is.NotError(t, err)
+		is.Equal(t, "# Order\n0001-users.up.sql\n0002-accounts.up.sql\n", string(content))
+
+		versions, err := migrate.ReadManifest(os.DirFS(dir))
+		is.NotError(t, err)
+		is.Equal(t, "[0001-users 0002-accounts]", fmt.Sprint(versions))
+	})
+}
+
+func TestMigrator_renumbered(t *testing.T) {
+	t.Run("updates an old version and its history to the new ones before a run", func(t *testing.T) {
+		dir := t.TempDir()
+		files := map[string]string{
+			"1700000000-accounts.up.sql": "create table accounts (id int);",
+			"1700000100-users.up.sql":    "create table users (id int);",
+			"1700000200-posts.up.sql":    "create table posts (id int);",
+		}
+		for name, content := range files {
+			err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+			is.NotError(t, err)
+		}
+
+		db := createSQLiteDatabase(t)
+		opts := migrate.Options{DB: db, FS: os.DirFS(dir), History: true, MissingDown: migrate.CheckIgnore}
+		err := migrate.New(opts).MigrateTo(context.Background(), "1700000100-users")
+		is.NotError(t, err)
+
+		_, err = migrate.Renumber(dir)
+		is.NotError(t, err)
+
+		opts.FS = os.DirFS(dir)
+		m := migrate.New(opts)
+		err = m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "0003-posts", getVersion(t, db))
+
+		statuses, err := m.Status(context.Background())
+		is.NotError(t, err)
+		for _, s := range statuses {
+			is.True(t, !s.AppliedAt.IsZero())
+		}
+	})
+}
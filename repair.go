@@ -0,0 +1,202 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// LockBreaker is a Dialect that can break the lock from Dialect.Lock when another session holds it,
+// by ending that session. See RepairOptions.Lock.
+type LockBreaker interface {
+	// BreakLock for the migrations identified by table, reporting whether another session held it.
+	BreakLock(ctx context.Context, conn *sql.Conn, table string) (bool, error)
+}
+
+// BreakLock by terminating the backends holding the advisory lock.
+func (postgresDialect) BreakLock(ctx context.Context, conn *sql.Conn, table string) (bool, error) {
+	var terminated int
+	err := conn.QueryRowContext(ctx, `select count(pg_terminate_backend(pid)) from pg_locks where locktype = 'advisory' `+
+		`and classid = 0 and objid::bigint = $1 and objsubid = 1 and granted and pid <> pg_backend_pid()`, lockKey(table)).Scan(&terminated)
+	return terminated > 0, err
+}
+
+// BreakLock by killing the connection holding the named lock.
+func (mysqlDialect) BreakLock(ctx context.Context, conn *sql.Conn, table string) (bool, error) {
+	var id sql.NullInt64
+	if err := conn.QueryRowContext(ctx, `select is_used_lock(?)`, lockName(table)).Scan(&id); err != nil {
+		return false, err
+	}
+	if !id.Valid {
+		return false, nil
+	}
+	if _, err := conn.ExecContext(ctx, `kill `+strconv.FormatInt(id.Int64, 10)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// BreakLock never breaks anything, because Redshift has no advisory locks.
+func (redshiftDialect) BreakLock(context.Context, *sql.Conn, string) (bool, error) {
+	return false, nil
+}
+
+// RepairOptions for Migrator.Repair, with the repairs to make. See Migrator.Diagnose for what needs repair.
+type RepairOptions struct {
+	// Checksums of the applied migrations in the history table are recomputed from their up files,
+	// like after an intended change to an applied file. Needs Options.History.
+	Checksums bool
+	// Dirty flag of the VersionColumns is set to false, after fixing the database by hand.
+	Dirty bool
+	// Lock held by another session is broken by ending that session, if the Dialect is a LockBreaker.
+	// Only do it if the session is stuck, not while it's migrating.
+	Lock bool
+	// MissingFiles removes the rows of applied versions without an up migration file from the history table,
+	// like after deleting old files on purpose. Needs Options.History.
+	MissingFiles bool
+}
+
+// Repair the state of the migrations with the repairs in opts, like for problems found by Migrator.Diagnose.
+// Returns a description of each repair made, like "updated checksum of 1-accounts".
+func (m *Migrator) Repair(ctx context.Context, opts RepairOptions) (repairs []string, err error) {
+	ctx, span := m.tracer.Start(ctx, "migrate repair")
+	span.SetAttribute("migrate.table", m.table)
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error repairing: %w", err)
+		}
+		span.End(err)
+	}()
+
+	if (opts.Checksums || opts.MissingFiles) && !m.history {
+		return nil, errors.New("history is needed, see Options.History")
+	}
+
+	// The lock is broken first, because the run waits for it
+	if lb, ok := m.dialect.(LockBreaker); ok && opts.Lock {
+		broken, err := m.breakLock(ctx, lb)
+		if err != nil {
+			return nil, err
+		}
+		if broken {
+			repairs = append(repairs, "broke lock held by another session")
+		}
+	}
+
+	err = m.session(ctx, func(s *Migrator) error {
+		// Getting the version fails if it's dirty, so the flag is cleared before the run
+		if opts.Dirty && s.columns.Dirty != "" {
+			res, err := s.conn.ExecContext(ctx, `update `+s.dialect.Quote(s.table)+` set `+s.dialect.Quote(s.columns.Dirty)+
+				` = false where `+s.dialect.Quote(s.columns.Dirty))
+			if err != nil {
+				return fmt.Errorf("error clearing dirty flag: %w", err)
+			}
+			if rows, err := res.RowsAffected(); err == nil && rows > 0 {
+				repairs = append(repairs, "cleared dirty flag")
+			}
+		}
+
+		return s.run(ctx, "repair", func() error {
+			if err := s.createMigrationsTable(ctx); err != nil {
+				return err
+			}
+			if opts.MissingFiles {
+				removed, err := s.removeMissingFromHistory(ctx)
+				if err != nil {
+					return err
+				}
+				for _, version := range removed {
+					repairs = append(repairs, "removed history of "+version)
+				}
+			}
+			if opts.Checksums {
+				updated, err := s.updateChecksums(ctx)
+				if err != nil {
+					return err
+				}
+				for _, version := range updated {
+					repairs = append(repairs, "updated checksum of "+version)
+				}
+			}
+			return nil
+		})
+	})
+	return repairs, err
+}
+
+// breakLock on a connection of its own, reporting whether another session held it.
+func (m *Migrator) breakLock(ctx context.Context, lb LockBreaker) (bool, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return false, fmt.Errorf("error getting connection: %w", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	broken, err := lb.BreakLock(ctx, conn, m.table)
+	if err != nil {
+		return false, fmt.Errorf("error breaking lock: %w", err)
+	}
+	return broken, nil
+}
+
+// removeMissingFromHistory the rows of applied versions without an up migration file, returning their versions.
+func (m *Migrator) removeMissingFromHistory(ctx context.Context) ([]string, error) {
+	missing, err := m.getMissingApplied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for _, version := range missing {
+		res, err := m.conn.ExecContext(ctx, `delete from `+m.dialect.Quote(m.historyTable())+` where version = `+
+			quoteString(m.dialect, version))
+		if err != nil {
+			return nil, fmt.Errorf("error removing history of %v: %w", version, err)
+		}
+		// The current version may only be in the migrations table
+		if rows, err := res.RowsAffected(); err == nil && rows > 0 {
+			removed = append(removed, version)
+		}
+	}
+	return removed, nil
+}
+
+// updateChecksums in the history table of the applied versions with an up migration file whose checksum differs,
+// returning their versions.
+func (m *Migrator) updateChecksums(ctx context.Context) ([]string, error) {
+	checksums, err := m.getChecksums(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := m.getFilenames(upMatcher)
+	if err != nil {
+		return nil, err
+	}
+
+	var updated []string
+	for _, name := range names {
+		version := upMatcher.ReplaceAllString(name, "$1")
+		recorded, ok := checksums[version]
+		if !ok {
+			continue
+		}
+		checksum, err := m.checksum(name)
+		if err != nil {
+			return nil, err
+		}
+		if recorded.Valid && recorded.String == checksum {
+			continue
+		}
+		if _, err := m.conn.ExecContext(ctx, `update `+m.dialect.Quote(m.historyTable())+` set checksum = '`+checksum+
+			`' where version = `+quoteString(m.dialect, version)); err != nil {
+			return nil, fmt.Errorf("error updating checksum of %v: %w", version, err)
+		}
+		updated = append(updated, version)
+	}
+	return updated, nil
+}
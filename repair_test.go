@@ -0,0 +1,105 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestMigrator_Repair(t *testing.T) {
+	fsys := func() fstest.MapFS {
+		return fstest.MapFS{
+			"1-accounts.up.sql":   {Data: []byte("create table accounts (id integer primary key)")},
+			"1-accounts.down.sql": {Data: []byte("drop table accounts")},
+			"2-invoices.up.sql":   {Data: []byte("create table invoices (id integer primary key)")},
+			"2-invoices.down.sql": {Data: []byte("drop table invoices")},
+			"3-orders.up.sql":     {Data: []byte("create table orders (id integer primary key)")},
+			"3-orders.down.sql":   {Data: []byte("drop table orders")},
+		}
+	}
+
+	t.Run("updates checksums and removes history of missing files", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, Dialect: migrate.SQLite, FS: fsys(), History: true})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		changed := fsys()
+		changed["2-invoices.up.sql"] = &fstest.MapFile{Data: []byte("create table invoices (id integer primary key, total int)")}
+		delete(changed, "1-accounts.up.sql")
+		m = migrate.New(migrate.Options{DB: db, Dialect: migrate.SQLite, FS: changed, History: true})
+
+		diagnoses, err := m.Diagnose(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, 2, len(diagnoses))
+
+		repairs, err := m.Repair(context.Background(), migrate.RepairOptions{Checksums: true, MissingFiles: true})
+		is.NotError(t, err)
+		is.Equal(t, "[removed history of 1-accounts updated checksum of 2-invoices]", fmt.Sprint(repairs))
+
+		diagnoses, err = m.Diagnose(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, 0, len(diagnoses))
+
+		repairs, err = m.Repair(context.Background(), migrate.RepairOptions{Checksums: true, MissingFiles: true})
+		is.NotError(t, err)
+		is.Equal(t, 0, len(repairs))
+	})
+
+	t.Run("clears the dirty flag", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		_, err := db.Exec(`create table schema_migrations (version bigint not null primary key, dirty boolean not null);
+			insert into schema_migrations values (1, true)`)
+		is.NotError(t, err)
+
+		fsys := fstest.MapFS{
+			"1_accounts.up.sql": {Data: []byte("create table accounts (id int);")},
+		}
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, Table: "schema_migrations", VersionColumns: migrate.GolangMigrateColumns})
+		repairs, err := m.Repair(context.Background(), migrate.RepairOptions{Dirty: true})
+		is.NotError(t, err)
+		is.Equal(t, "[cleared dirty flag]", fmt.Sprint(repairs))
+
+		err = m.MigrateUp(context.Background())
+		is.NotError(t, err)
+	})
+
+	t.Run("breaks a lock held by another session", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		d := lockBreakerDialect{Dialect: migrate.SQLite, broken: new(bool)}
+		m := migrate.New(migrate.Options{DB: db, Dialect: d, FS: fsys()})
+
+		repairs, err := m.Repair(context.Background(), migrate.RepairOptions{Lock: true})
+		is.NotError(t, err)
+		is.Equal(t, "[broke lock held by another session]", fmt.Sprint(repairs))
+		is.True(t, *d.broken)
+	})
+
+	t.Run("errors without history for history repairs", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		err := db.Ping()
+		is.NotError(t, err)
+
+		m := migrate.New(migrate.Options{DB: db, FS: fsys()})
+		_, err = m.Repair(context.Background(), migrate.RepairOptions{Checksums: true})
+		is.True(t, err != nil)
+		is.Equal(t, "error repairing: history is needed, see Options.History", err.Error())
+	})
+}
+
+// lockBreakerDialect is SQLite with a lock that's always held by another session until broken.
+type lockBreakerDialect struct {
+	migrate.Dialect
+	broken *bool
+}
+
+func (d lockBreakerDialect) BreakLock(context.Context, *sql.Conn, string) (bool, error) {
+	*d.broken = true
+	return true, nil
+}
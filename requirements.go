@@ -0,0 +1,242 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RequirementChecker is a Dialect that can check the requirements of migrations before they are applied,
+// like in the requires key of front matter, and Options.Requires. Postgres, MySQL, and SQLite are RequirementCheckers.
+type RequirementChecker interface {
+	// ServerVersion returns SQL for a row with the version of the database server, like "16.2".
+	ServerVersion() string
+	// HasExtension returns SQL for a row with whether the extension is available, like a Postgres extension
+	// that can be created, a MySQL plugin, or an SQLite module.
+	HasExtension(name string) string
+}
+
+func (postgresDialect) ServerVersion() string {
+	return `select current_setting('server_version')`
+}
+
+func (d postgresDialect) HasExtension(name string) string {
+	return `select exists (select 1 from pg_available_extensions where name = ` + d.QuoteString(name) + `)`
+}
+
+func (mysqlDialect) ServerVersion() string {
+	return `select version()`
+}
+
+func (d mysqlDialect) HasExtension(name string) string {
+	return `select count(*) > 0 from information_schema.plugins where plugin_name = ` + d.QuoteString(name) +
+		` and plugin_status = 'ACTIVE'`
+}
+
+func (sqliteDialect) ServerVersion() string {
+	return `select sqlite_version()`
+}
+
+func (d sqliteDialect) HasExtension(name string) string {
+	return `select count(*) > 0 from pragma_module_list where name = ` + d.QuoteString(name)
+}
+
+// CharsetChecker is a Dialect that can check the character sets and collations of the database and its tables,
+// for the charset and collation requirements, so a migration doesn't create tables with a mismatched one,
+// which causes index length and comparison bugs later. MySQL is a CharsetChecker.
+type CharsetChecker interface {
+	// Charsets returns SQL for rows with what has a character set, like "database app" or "table accounts",
+	// its character set, and its collation. The database has the defaults for new tables.
+	Charsets() string
+}
+
+func (mysqlDialect) Charsets() string {
+	return `select concat('database ', schema_name), default_character_set_name, default_collation_name ` +
+		`from information_schema.schemata where schema_name = database() ` +
+		`union all ` +
+		`select concat('table ', t.table_name), c.character_set_name, t.table_collation from information_schema.tables t ` +
+		`join information_schema.collation_character_set_applicability c on c.collation_name = t.table_collation ` +
+		`where t.table_schema = database() and t.table_type = 'BASE TABLE'`
+}
+
+// requirement of a migration, either an extension, a server version, a charset, or a collation.
+type requirement struct {
+	charset   string
+	collation string
+	extension string
+	op        string
+	version   string
+	// source of the requirement, like the migration file name.
+	source string
+	text   string
+}
+
+var serverRequirementMatcher = regexp.MustCompile(`^server\s*(>=|<=|>|<|=)\s*(\d+(?:\.\d+)*)$`)
+
+// parseRequirements separated by commas, like "server >= 14, extension pgcrypto, charset utf8mb4".
+func parseRequirements(source, value string) ([]requirement, error) {
+	var requirements []requirement
+	for _, text := range strings.Split(value, ",") {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		r := requirement{source: source, text: text}
+		if match := serverRequirementMatcher.FindStringSubmatch(text); match != nil {
+			r.op, r.version = match[1], match[2]
+		} else if fields := strings.Fields(text); len(fields) == 2 && fields[0] == "extension" {
+			r.extension = fields[1]
+		} else if len(fields) == 2 && fields[0] == "charset" {
+			r.charset = fields[1]
+		} else if len(fields) == 2 && fields[0] == "collation" {
+			r.collation = fields[1]
+		} else {
+			return nil, fmt.Errorf("invalid requirement %q, must be like server >= 14, extension pgcrypto, charset utf8mb4, "+
+				"or collation utf8mb4_0900_ai_ci", text)
+		}
+		requirements = append(requirements, r)
+	}
+	return requirements, nil
+}
+
+// checkRequirements of Options.Requires and of the up migrations of steps, before any of them are applied.
+func (m *Migrator) checkRequirements(ctx context.Context, steps []step) error {
+	requirements := append([]requirement(nil), m.requires...)
+	for _, s := range steps {
+		if s.down {
+			continue
+		}
+		fm, err := m.readFrontMatter(s)
+		if err != nil {
+			return err
+		}
+		requirements = append(requirements, fm.requires...)
+	}
+	if len(requirements) == 0 || len(steps) == 0 {
+		return nil
+	}
+
+	var serverVersion string
+	var charsets []charset
+	for _, r := range requirements {
+		if r.charset != "" || r.collation != "" {
+			if charsets == nil {
+				cc, ok := m.dialect.(CharsetChecker)
+				if !ok {
+					return fmt.Errorf("error checking requirements: %v needs a Dialect that is a CharsetChecker", r.source)
+				}
+				var err error
+				if charsets, err = m.getCharsets(ctx, cc); err != nil {
+					return err
+				}
+			}
+			for _, c := range charsets {
+				if r.charset != "" && !strings.EqualFold(c.charset, r.charset) {
+					return fmt.Errorf("error checking requirements: %v requires %v, but %v has %v", r.source, r.text, c.name, c.charset)
+				}
+				if r.collation != "" && !strings.EqualFold(c.collation, r.collation) {
+					return fmt.Errorf("error checking requirements: %v requires %v, but %v has %v", r.source, r.text, c.name, c.collation)
+				}
+			}
+			continue
+		}
+
+		rc, ok := m.dialect.(RequirementChecker)
+		if !ok {
+			return fmt.Errorf("error checking requirements: %v needs a Dialect that is a RequirementChecker", r.source)
+		}
+
+		if r.extension != "" {
+			var has bool
+			if err := m.conn.QueryRowContext(ctx, rc.HasExtension(r.extension)).Scan(&has); err != nil {
+				return fmt.Errorf("error checking extension %v: %w", r.extension, err)
+			}
+			if !has {
+				return fmt.Errorf("error checking requirements: %v requires %v, which isn't available", r.source, r.text)
+			}
+			continue
+		}
+
+		if serverVersion == "" {
+			if err := m.conn.QueryRowContext(ctx, rc.ServerVersion()).Scan(&serverVersion); err != nil {
+				return fmt.Errorf("error getting server version: %w", err)
+			}
+		}
+		if !compareVersions(serverVersion, r.op, r.version) {
+			return fmt.Errorf("error checking requirements: %v requires %v, but it's %v", r.source, r.text, serverVersion)
+		}
+	}
+	return nil
+}
+
+// charset and collation of something with them, like "table accounts".
+type charset struct {
+	name      string
+	charset   string
+	collation string
+}
+
+// getCharsets of the database and its tables, see CharsetChecker. The result isn't nil if there's no error.
+func (m *Migrator) getCharsets(ctx context.Context, cc CharsetChecker) ([]charset, error) {
+	rows, err := m.conn.QueryContext(ctx, cc.Charsets())
+	if err != nil {
+		return nil, fmt.Errorf("error getting charsets: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	charsets := []charset{}
+	for rows.Next() {
+		var name, cs, collation sql.NullString
+		if err := rows.Scan(&name, &cs, &collation); err != nil {
+			return nil, fmt.Errorf("error getting charsets: %w", err)
+		}
+		charsets = append(charsets, charset{name: name.String, charset: cs.String, collation: collation.String})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error getting charsets: %w", err)
+	}
+	return charsets, nil
+}
+
+var versionNumberMatcher = regexp.MustCompile(`^\d+(?:\.\d+)*`)
+
+// compareVersions by their leading dot-separated numbers, like 16.2 in "16.2 (Debian 16.2-1)",
+// reporting whether "have op want" holds. Only the numbers in want are compared, so 16.2 = 16.
+func compareVersions(have, op, want string) bool {
+	a := strings.Split(versionNumberMatcher.FindString(strings.TrimSpace(have)), ".")
+	b := strings.Split(want, ".")
+	cmp := 0
+	for i := 0; i < len(b); i++ {
+		var x int
+		if i < len(a) {
+			x, _ = strconv.Atoi(a[i])
+		}
+		y, _ := strconv.Atoi(b[i])
+		if x != y {
+			if x < y {
+				cmp = -1
+			} else {
+				cmp = 1
+			}
+			break
+		}
+	}
+
+	switch op {
+	case ">=":
+		return cmp >= 0
+	case ">":
+		return cmp > 0
+	case "<=":
+		return cmp <= 0
+	case "<":
+		return cmp < 0
+	default:
+		return cmp == 0
+	}
+}
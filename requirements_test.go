@@ -0,0 +1,145 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestMigrator_Requires(t *testing.T) {
+	t.Run("applies migrations if their requirements are met", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, Dialect: migrate.SQLite, MissingDown: migrate.CheckIgnore,
+			Requires: []string{"server >= 3.8, extension json_each"},
+			FS: fstest.MapFS{
+				"1.up.sql": {Data: []byte("-- ---\n-- requires: server < 99.1, server = 3\n-- ---\ncreate table accounts (id int)")},
+			},
+		})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "1", getVersion(t, db))
+	})
+
+	t.Run("fails before applying any migrations if a migration requires a newer server", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, Dialect: migrate.SQLite, MissingDown: migrate.CheckIgnore,
+			FS: fstest.MapFS{
+				"1.up.sql": {Data: []byte("create table accounts (id int)")},
+				"2.up.sql": {Data: []byte("-- ---\n-- requires: server >= 99\n-- ---\ncreate table users (id int)")},
+			},
+		})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.True(t, strings.HasPrefix(err.Error(), "error migrating up: error checking requirements: 2.up.sql requires server >= 99, but it's 3."))
+		is.Equal(t, "", getVersion(t, db))
+	})
+
+	t.Run("fails if a required extension isn't available", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, Dialect: migrate.SQLite, MissingDown: migrate.CheckIgnore,
+			Requires: []string{"extension pgcrypto"},
+			FS:       fstest.MapFS{"1.up.sql": {Data: []byte("create table accounts (id int)")}},
+		})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, "error migrating up: error checking requirements: Options.Requires requires extension pgcrypto, which isn't available",
+			err.Error())
+	})
+
+	t.Run("applies migrations if the charset and collation are the required ones", func(t *testing.T) {
+		db := newCharsetDB(t)
+		m := migrate.New(migrate.Options{DB: db, Dialect: charsetDialect{Dialect: migrate.SQLite}, MissingDown: migrate.CheckIgnore,
+			Requires: []string{"charset utf8mb4, collation utf8mb4_0900_ai_ci"},
+			FS:       fstest.MapFS{"1.up.sql": {Data: []byte("create table accounts (id int)")}},
+		})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "1", getVersion(t, db))
+	})
+
+	t.Run("fails before applying any migrations if a table has another charset", func(t *testing.T) {
+		db := newCharsetDB(t)
+		_, err := db.Exec(`insert into charsets values ('table users', 'latin1', 'latin1_swedish_ci')`)
+		is.NotError(t, err)
+		m := migrate.New(migrate.Options{DB: db, Dialect: charsetDialect{Dialect: migrate.SQLite}, MissingDown: migrate.CheckIgnore,
+			FS: fstest.MapFS{
+				"1.up.sql": {Data: []byte("create table accounts (id int)")},
+				"2.up.sql": {Data: []byte("-- ---\n-- requires: charset utf8mb4\n-- ---\ncreate index users_name on users (name)")},
+			},
+		})
+		err = m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, "error migrating up: error checking requirements: 2.up.sql requires charset utf8mb4, but table users has latin1",
+			err.Error())
+		is.Equal(t, "", getVersion(t, db))
+	})
+
+	t.Run("fails if the database has another default collation", func(t *testing.T) {
+		db := newCharsetDB(t)
+		m := migrate.New(migrate.Options{DB: db, Dialect: charsetDialect{Dialect: migrate.SQLite}, MissingDown: migrate.CheckIgnore,
+			Requires: []string{"collation utf8mb4_bin"},
+			FS:       fstest.MapFS{"1.up.sql": {Data: []byte("create table accounts (id int)")}},
+		})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, "error migrating up: error checking requirements: Options.Requires requires collation utf8mb4_bin, "+
+			"but database app has utf8mb4_0900_ai_ci", err.Error())
+	})
+
+	t.Run("fails if the dialect can't check charsets", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, Dialect: migrate.SQLite, MissingDown: migrate.CheckIgnore,
+			Requires: []string{"charset utf8mb4"},
+			FS:       fstest.MapFS{"1.up.sql": {Data: []byte("create table accounts (id int)")}},
+		})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, "error migrating up: error checking requirements: Options.Requires needs a Dialect that is a CharsetChecker",
+			err.Error())
+	})
+
+	t.Run("fails on invalid requirements in front matter", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, Dialect: migrate.SQLite, MissingDown: migrate.CheckIgnore,
+			FS: fstest.MapFS{"1.up.sql": {Data: []byte("-- ---\n-- requires: postgres 14\n-- ---\ncreate table accounts (id int)")}},
+		})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, `error migrating up: error in front matter of 1.up.sql: invalid requirement "postgres 14", `+
+			"must be like server >= 14, extension pgcrypto, charset utf8mb4, or collation utf8mb4_0900_ai_ci", err.Error())
+	})
+
+	t.Run("panics on invalid requirements in options", func(t *testing.T) {
+		defer func() {
+			err := recover()
+			is.True(t, err != nil)
+			is.Equal(t, `invalid requirement "server 14", must be like server >= 14, extension pgcrypto, charset utf8mb4, `+
+				"or collation utf8mb4_0900_ai_ci", err.(string))
+		}()
+		migrate.New(migrate.Options{DB: &sql.DB{}, FS: fstest.MapFS{}, Requires: []string{"server 14"}})
+	})
+}
+
+// charsetDialect is SQLite with the charsets from the charsets table.
+type charsetDialect struct {
+	migrate.Dialect
+}
+
+func (charsetDialect) Charsets() string {
+	return `select name, charset, collation from charsets`
+}
+
+func newCharsetDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db := createSQLiteDatabase(t)
+	_, err := db.Exec(`create table charsets (name text, charset text, collation text);
+		insert into charsets values ('database app', 'utf8mb4', 'utf8mb4_0900_ai_ci')`)
+	is.NotError(t, err)
+	return db
+}
@@ -0,0 +1,45 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// savepoint for retrying statements.
+const savepoint = "migrate_statement"
+
+// execWithRetries runs the statement in a savepoint, rolling back to it and retrying if the error is retryable,
+// up to the number of retries from Options.
+func (m *Migrator) execWithRetries(ctx context.Context, tx *sql.Tx, r Retrier, statement string) (int64, error) {
+	delay := m.retryDelay
+	for attempt := 0; ; attempt++ {
+		if _, err := tx.ExecContext(ctx, "savepoint "+savepoint); err != nil {
+			return -1, fmt.Errorf("error creating savepoint: %w", err)
+		}
+
+		rows, err := exec(ctx, tx, statement)
+		if err == nil {
+			if _, err := tx.ExecContext(ctx, "release savepoint "+savepoint); err != nil {
+				return -1, fmt.Errorf("error releasing savepoint: %w", err)
+			}
+			return rows, nil
+		}
+
+		if attempt >= m.retries || !r.IsRetryable(err) {
+			return -1, err
+		}
+
+		if _, rollbackErr := tx.ExecContext(ctx, "rollback to savepoint "+savepoint); rollbackErr != nil {
+			return -1, fmt.Errorf("error rolling back to savepoint after error (savepoint error: %v), original error: %w", rollbackErr, err)
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return -1, ctx.Err()
+		}
+		delay *= 2
+	}
+}
@@ -0,0 +1,135 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+// flakyCalls counts calls of the flaky SQL function, which fails until it's been called flakyFailures times.
+var (
+	flakyCalls    int64
+	flakyFailures int64
+)
+
+func init() {
+	sql.Register("sqlite3_flaky", &sqlite3.SQLiteDriver{
+		ConnectHook: func(c *sqlite3.SQLiteConn) error {
+			return c.RegisterFunc("flaky", func() (int64, error) {
+				if atomic.AddInt64(&flakyCalls, 1) <= atomic.LoadInt64(&flakyFailures) {
+					return 0, errors.New("flaky failure")
+				}
+				return 1, nil
+			}, false)
+		},
+	})
+}
+
+// flakyDialect is SQLite with flaky failures being retryable.
+type flakyDialect struct {
+	migrate.Dialect
+}
+
+func (flakyDialect) IsRetryable(err error) bool {
+	return strings.Contains(err.Error(), "flaky failure")
+}
+
+func (flakyDialect) SupportsSavepoints() bool {
+	return true
+}
+
+func TestMigrator_Retries(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1.up.sql": {Data: []byte("create table a (v int);\ninsert into a values (0);\ninsert into a values (flaky());")},
+	}
+
+	t.Run("retries a statement in a savepoint", func(t *testing.T) {
+		db := createFlakySQLiteDatabase(t, 2)
+
+		m := migrate.New(migrate.Options{
+			DB:              db,
+			Dialect:         flakyDialect{Dialect: migrate.SQLite},
+			FS:              fsys,
+			Retries:         2,
+			RetryDelay:      time.Millisecond,
+			SplitStatements: true,
+		})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, int64(3), atomic.LoadInt64(&flakyCalls))
+
+		var count int
+		err = db.QueryRow(`select count(*) from a`).Scan(&count)
+		is.NotError(t, err)
+		is.Equal(t, 2, count)
+	})
+
+	t.Run("gives up after the retries", func(t *testing.T) {
+		db := createFlakySQLiteDatabase(t, 3)
+
+		m := migrate.New(migrate.Options{
+			DB:              db,
+			Dialect:         flakyDialect{Dialect: migrate.SQLite},
+			FS:              fsys,
+			Retries:         2,
+			RetryDelay:      time.Millisecond,
+			SplitStatements: true,
+		})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.True(t, strings.Contains(err.Error(), "flaky failure"))
+		is.Equal(t, int64(3), atomic.LoadInt64(&flakyCalls))
+		is.Equal(t, "", getVersion(t, db))
+	})
+
+	t.Run("does not retry errors that aren't retryable", func(t *testing.T) {
+		db := createFlakySQLiteDatabase(t, 1)
+
+		m := migrate.New(migrate.Options{DB: db, Dialect: migrate.SQLite, FS: fsys, Retries: 2, SplitStatements: true})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, int64(1), atomic.LoadInt64(&flakyCalls))
+	})
+
+	t.Run("does not retry with a dialect that isn't a Retrier", func(t *testing.T) {
+		db := createFlakySQLiteDatabase(t, 1)
+
+		m := migrate.New(migrate.Options{DB: db, Dialect: testDialect{Dialect: migrate.SQLite}, FS: fsys, Retries: 2,
+			SplitStatements: true})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.True(t, strings.Contains(err.Error(), "flaky failure"))
+		is.Equal(t, int64(1), atomic.LoadInt64(&flakyCalls))
+	})
+}
+
+func createFlakySQLiteDatabase(t *testing.T, failures int64) *sql.DB {
+	t.Helper()
+	atomic.StoreInt64(&flakyCalls, 0)
+	atomic.StoreInt64(&flakyFailures, failures)
+
+	db, err := sql.Open("sqlite3_flaky", "db.sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Log(err)
+		}
+		if err := os.Remove("db.sqlite"); err != nil {
+			t.Fatal(err)
+		}
+	})
+	return db
+}
@@ -0,0 +1,40 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RoleSetter is a Dialect that can run migrations as another role. See Options.Role.
+type RoleSetter interface {
+	// SetRole returns SQL to run the statements after it as the role, and SQL to switch back after them.
+	SetRole(role string) (set, reset string)
+}
+
+func (d postgresDialect) SetRole(role string) (set, reset string) {
+	return `set role ` + d.Quote(role), `reset role`
+}
+
+// SetRole on Redshift sets the session authorization, because it doesn't have set role, so it needs a superuser.
+func (d redshiftDialect) SetRole(role string) (set, reset string) {
+	return `set session authorization ` + d.QuoteString(role), `reset session authorization`
+}
+
+// executeAsRole executes the migration file of a step as the role in Options.Role, if set, and switches back after it.
+// If the migration fails in a transaction, the rollback switches back.
+func (m *Migrator) executeAsRole(ctx context.Context, q queryer, s step, start time.Time, batch bool) (rows int64, err error) {
+	if m.role == "" {
+		return m.execute(ctx, q, s, start, batch)
+	}
+
+	set, reset := m.dialect.(RoleSetter).SetRole(m.role)
+	if _, err := q.ExecContext(ctx, set); err != nil {
+		return -1, fmt.Errorf("error setting role %v: %w", m.role, err)
+	}
+	rows, err = m.execute(ctx, q, s, start, batch)
+	if _, resetErr := q.ExecContext(ctx, reset); resetErr != nil && err == nil {
+		return -1, fmt.Errorf("error resetting role %v: %w", m.role, resetErr)
+	}
+	return rows, err
+}
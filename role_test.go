@@ -0,0 +1,83 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+// roleDialect is SQLite that records setting and resetting the role in the roles table.
+type roleDialect struct {
+	migrate.Dialect
+}
+
+func (roleDialect) SetRole(role string) (set, reset string) {
+	return `insert into roles values ('set ` + role + `')`, `insert into roles values ('reset')`
+}
+
+func TestMigrator_Role(t *testing.T) {
+	newDB := func(t *testing.T) *sql.DB {
+		t.Helper()
+		db := createSQLiteDatabase(t)
+		_, err := db.Exec(`create table roles (event text)`)
+		is.NotError(t, err)
+		return db
+	}
+
+	getRoles := func(t *testing.T, db *sql.DB) []string {
+		t.Helper()
+		rows, err := db.Query(`select event from roles`)
+		is.NotError(t, err)
+		defer func() {
+			_ = rows.Close()
+		}()
+		var events []string
+		for rows.Next() {
+			var event string
+			is.NotError(t, rows.Scan(&event))
+			events = append(events, event)
+		}
+		is.NotError(t, rows.Err())
+		return events
+	}
+
+	t.Run("sets the role before each migration and resets it after", func(t *testing.T) {
+		db := newDB(t)
+		m := migrate.New(migrate.Options{DB: db, Dialect: roleDialect{Dialect: migrate.SQLite}, MissingDown: migrate.CheckIgnore, Role: "app",
+			FS: fstest.MapFS{
+				"1.up.sql": {Data: []byte("insert into roles values ('migrate 1')")},
+				"2.up.sql": {Data: []byte("insert into roles values ('migrate 2')")},
+			},
+		})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "[set app migrate 1 reset set app migrate 2 reset]", fmt.Sprint(getRoles(t, db)))
+	})
+
+	t.Run("rolls back setting the role with a failed migration", func(t *testing.T) {
+		db := newDB(t)
+		m := migrate.New(migrate.Options{DB: db, Dialect: roleDialect{Dialect: migrate.SQLite}, MissingDown: migrate.CheckIgnore, Role: "app",
+			FS: fstest.MapFS{
+				"1.up.sql": {Data: []byte("not sql")},
+			},
+		})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, 0, len(getRoles(t, db)))
+	})
+
+	t.Run("panics if the dialect can't set roles", func(t *testing.T) {
+		defer func() {
+			err := recover()
+			is.True(t, err != nil)
+			is.Equal(t, "role needs a Dialect that is a RoleSetter", err.(string))
+		}()
+		migrate.New(migrate.Options{DB: &sql.DB{}, Dialect: migrate.SQLite, FS: fstest.MapFS{}, Role: "app"})
+	})
+}
@@ -0,0 +1,266 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// RollbackStored down to the given version, with the down migrations stored in the history table
+// instead of the files, so it works even if the files are gone. See Options.StoreDown.
+// The given version stays applied. Migrations applied without history are not reverted.
+// It errors before reverting anything if a migration to revert has no stored down migration.
+func (m *Migrator) RollbackStored(ctx context.Context, version string) (err error) {
+	ctx, span := m.tracer.Start(ctx, "migrate rollback stored")
+	span.SetAttribute("migrate.table", m.table)
+	span.SetAttribute("migrate.target_version", version)
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error rolling back stored: %w", err)
+		}
+		span.End(err)
+	}()
+
+	return m.session(ctx, func(s *Migrator) error {
+		return s.run(ctx, "rollback stored", func() error {
+			return s.rollbackStored(ctx, version)
+		})
+	})
+}
+
+func (m *Migrator) rollbackStored(ctx context.Context, version string) error {
+	if !m.history {
+		return errors.New("history is needed, see Options.StoreDown")
+	}
+
+	if err := m.createMigrationsTable(ctx); err != nil {
+		return err
+	}
+
+	currentVersion, err := m.getCurrentVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	order, err := m.getVersionOrder()
+	if err != nil {
+		return err
+	}
+
+	if order.compare(version, currentVersion) > 0 {
+		return fmt.Errorf("current version %v is before %v", currentVersion, version)
+	}
+
+	steps, err := m.planStoredDown(ctx, currentVersion, version)
+	if err != nil {
+		return err
+	}
+
+	return m.applyAll(ctx, steps)
+}
+
+// planStoredDown from the current version to the target version, leaving the target applied,
+// with the down migrations from the history table.
+func (m *Migrator) planStoredDown(ctx context.Context, currentVersion, targetVersion string) ([]step, error) {
+	stored, err := m.getStoredDowns(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := m.getVersionOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []string
+	for v := range stored {
+		if order.compare(v, targetVersion) > 0 && order.compare(v, currentVersion) <= 0 {
+			versions = append(versions, v)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return order.compare(versions[i], versions[j]) > 0
+	})
+
+	var steps []step
+	for i, v := range versions {
+		content := stored[v]
+		if !content.Valid {
+			return nil, fmt.Errorf("version %v has no stored down migration", v)
+		}
+		nextVersion := targetVersion
+		if i+1 < len(versions) {
+			nextVersion = versions[i+1]
+		}
+		steps = append(steps, step{content: content.String, down: true, fileVersion: v, name: v + ".down.sql", stored: true, version: nextVersion})
+	}
+	return steps, nil
+}
+
+// getStoredDowns from the history table, decoded, by version. Versions without a stored down migration are null.
+func (m *Migrator) getStoredDowns(ctx context.Context) (map[string]sql.NullString, error) {
+	rows, err := m.conn.QueryContext(ctx, `select version, down_sql from `+m.dialect.Quote(m.historyTable()))
+	if err != nil {
+		return nil, fmt.Errorf("error getting stored down migrations: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	downs := map[string]sql.NullString{}
+	for rows.Next() {
+		var version string
+		var encoded sql.NullString
+		if err := rows.Scan(&version, &encoded); err != nil {
+			return nil, fmt.Errorf("error scanning stored down migration: %w", err)
+		}
+		if !encoded.Valid {
+			downs[version] = encoded
+			continue
+		}
+		content, err := base64.StdEncoding.DecodeString(encoded.String)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding stored down migration of version %v: %w", version, err)
+		}
+		downs[version] = sql.NullString{String: string(content), Valid: true}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error getting stored down migrations: %w", err)
+	}
+	return downs, nil
+}
+
+// RollbackLastBatch reverts the migrations applied by the last run that applied any, with the down migration files.
+// Runs are only numbered in batches with Options.History. It does nothing if there are no batches.
+func (m *Migrator) RollbackLastBatch(ctx context.Context) (err error) {
+	ctx, span := m.tracer.Start(ctx, "migrate rollback last batch")
+	span.SetAttribute("migrate.table", m.table)
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error rolling back last batch: %w", err)
+		}
+		span.End(err)
+	}()
+
+	return m.session(ctx, func(s *Migrator) error {
+		return s.run(ctx, "rollback last batch", func() error {
+			return s.rollbackLastBatch(ctx)
+		})
+	})
+}
+
+func (m *Migrator) rollbackLastBatch(ctx context.Context) error {
+	if !m.history {
+		return errors.New("history is needed, see Options.History")
+	}
+
+	if err := m.createMigrationsTable(ctx); err != nil {
+		return err
+	}
+
+	batch, err := m.getLastBatch(ctx)
+	if err != nil {
+		return err
+	}
+	if batch == 0 {
+		return nil
+	}
+
+	oldest, err := m.getOldestVersion(ctx, `batch = `+strconv.FormatInt(batch, 10))
+	if err != nil {
+		return fmt.Errorf("error getting oldest version of batch %v: %w", batch, err)
+	}
+
+	return m.revertFrom(ctx, oldest)
+}
+
+// DownSince reverts the migrations applied at or after the given time, with the down migration files.
+// Migrations applied after the oldest of them are reverted too, so the migrations stay in order.
+// It needs Options.History to know when migrations were applied, and does nothing if none were applied since.
+func (m *Migrator) DownSince(ctx context.Context, t time.Time) (err error) {
+	ctx, span := m.tracer.Start(ctx, "migrate down since")
+	span.SetAttribute("migrate.table", m.table)
+	span.SetAttribute("migrate.since", t.UTC().Format(time.RFC3339))
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error migrating down since %v: %w", t.Format(time.RFC3339), err)
+		}
+		span.End(err)
+	}()
+
+	return m.session(ctx, func(s *Migrator) error {
+		return s.run(ctx, "down since", func() error {
+			return s.downSince(ctx, t)
+		})
+	})
+}
+
+func (m *Migrator) downSince(ctx context.Context, t time.Time) error {
+	if !m.history {
+		return errors.New("history is needed, see Options.History")
+	}
+
+	if err := m.createMigrationsTable(ctx); err != nil {
+		return err
+	}
+
+	// Applied times are stored fixed-width, so they compare as text
+	oldest, err := m.getOldestVersion(ctx, `applied_at >= `+quoteString(m.dialect, t.UTC().Format(historyTimeLayout)))
+	if err != nil {
+		return fmt.Errorf("error getting oldest version applied since %v: %w", t.Format(time.RFC3339), err)
+	}
+	if oldest == "" {
+		return nil
+	}
+
+	return m.revertFrom(ctx, oldest)
+}
+
+// getOldestVersion in the history table where the condition holds, or the empty string if there is none.
+// Versions are compared in Go, because the database compares them by name, not in the order of the manifest.
+func (m *Migrator) getOldestVersion(ctx context.Context, condition string) (string, error) {
+	order, err := m.getVersionOrder()
+	if err != nil {
+		return "", err
+	}
+
+	rows, err := m.conn.QueryContext(ctx, `select version from `+m.dialect.Quote(m.historyTable())+` where `+condition)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var oldest string
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return "", err
+		}
+		if oldest == "" || order.compare(version, oldest) < 0 {
+			oldest = version
+		}
+	}
+	return oldest, rows.Err()
+}
+
+// revertFrom the current version down to and including the given version, with the down migration files.
+func (m *Migrator) revertFrom(ctx context.Context, version string) error {
+	currentVersion, err := m.getCurrentVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	steps, err := m.planDown(currentVersion, version, true)
+	if err != nil {
+		return err
+	}
+
+	return m.applyAll(ctx, steps)
+}
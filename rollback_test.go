@@ -0,0 +1,179 @@
+package migrate_test
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestMigrator_RollbackStored(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1.up.sql":   {Data: []byte("create table a (v int)")},
+		"1.down.sql": {Data: []byte("drop table a")},
+		"2.up.sql":   {Data: []byte("create table b (v int)")},
+		"2.down.sql": {Data: []byte("drop table b")},
+		"3.up.sql":   {Data: []byte("create table c (v int)")},
+		"3.down.sql": {Data: []byte("drop table c")},
+	}
+
+	t.Run("reverts with the stored down migrations without the files", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, StoreDown: true})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		// A newer build might have no migration files at all
+		m = migrate.New(migrate.Options{DB: db, FS: fstest.MapFS{}, StoreDown: true})
+		err = m.RollbackStored(context.Background(), "1")
+		is.NotError(t, err)
+		is.Equal(t, "1", getVersion(t, db))
+
+		var count int
+		err = db.QueryRow(`select count(*) from sqlite_master where name in ('a', 'b', 'c')`).Scan(&count)
+		is.NotError(t, err)
+		is.Equal(t, 1, count)
+
+		err = m.RollbackStored(context.Background(), "")
+		is.NotError(t, err)
+		is.Equal(t, "", getVersion(t, db))
+	})
+
+	t.Run("errors before reverting anything if a down migration is not stored", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, History: true})
+		err := m.MigrateTo(context.Background(), "1")
+		is.NotError(t, err)
+
+		m = migrate.New(migrate.Options{DB: db, FS: fsys, StoreDown: true})
+		err = m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		err = m.RollbackStored(context.Background(), "")
+		is.True(t, err != nil)
+		is.Equal(t, "error rolling back stored: version 1 has no stored down migration", err.Error())
+		is.Equal(t, "3", getVersion(t, db))
+	})
+
+	t.Run("errors without history", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, FS: fsys})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		err = m.RollbackStored(context.Background(), "")
+		is.True(t, err != nil)
+		is.Equal(t, "error rolling back stored: history is needed, see Options.StoreDown", err.Error())
+	})
+}
+
+func TestMigrator_RollbackLastBatch(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1.up.sql":   {Data: []byte("create table a (v int)")},
+		"1.down.sql": {Data: []byte("drop table a")},
+		"2.up.sql":   {Data: []byte("create table b (v int)")},
+		"2.down.sql": {Data: []byte("drop table b")},
+		"3.up.sql":   {Data: []byte("create table c (v int)")},
+		"3.down.sql": {Data: []byte("drop table c")},
+	}
+
+	t.Run("reverts the migrations of the last run, one batch at a time", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, History: true})
+		err := m.MigrateTo(context.Background(), "1")
+		is.NotError(t, err)
+		err = m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		statuses, err := m.Status(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, int64(1), statuses[0].Batch)
+		is.Equal(t, int64(2), statuses[1].Batch)
+		is.Equal(t, int64(2), statuses[2].Batch)
+
+		err = m.RollbackLastBatch(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "1", getVersion(t, db))
+
+		err = m.RollbackLastBatch(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "", getVersion(t, db))
+
+		err = m.RollbackLastBatch(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "", getVersion(t, db))
+	})
+
+	t.Run("errors without history", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, FS: fsys})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		err = m.RollbackLastBatch(context.Background())
+		is.True(t, err != nil)
+		is.Equal(t, "error rolling back last batch: history is needed, see Options.History", err.Error())
+	})
+}
+
+func TestMigrator_DownSince(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1.up.sql":   {Data: []byte("create table a (v int)")},
+		"1.down.sql": {Data: []byte("drop table a")},
+		"2.up.sql":   {Data: []byte("create table b (v int)")},
+		"2.down.sql": {Data: []byte("drop table b")},
+		"3.up.sql":   {Data: []byte("create table c (v int)")},
+		"3.down.sql": {Data: []byte("drop table c")},
+	}
+
+	t.Run("reverts the migrations applied since the given time", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, History: true})
+		err := m.MigrateTo(context.Background(), "1")
+		is.NotError(t, err)
+
+		since := time.Now()
+
+		err = m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		err = m.DownSince(context.Background(), since)
+		is.NotError(t, err)
+		is.Equal(t, "1", getVersion(t, db))
+	})
+
+	t.Run("does nothing if nothing was applied since the given time", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, History: true})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		err = m.DownSince(context.Background(), time.Now().Add(time.Hour))
+		is.NotError(t, err)
+		is.Equal(t, "3", getVersion(t, db))
+	})
+
+	t.Run("errors without history", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, FS: fsys})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		since := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		err = m.DownSince(context.Background(), since)
+		is.True(t, err != nil)
+		is.Equal(t, "error migrating down since 2024-01-02T03:04:05Z: history is needed, see Options.History", err.Error())
+	})
+}
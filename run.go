@@ -0,0 +1,158 @@
+package migrate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrDatabaseUnavailable is returned by RunOnce if the database doesn't accept connections before RunConfig.Wait.
+var ErrDatabaseUnavailable = errors.New("database unavailable")
+
+// RunConfig for RunOnce.
+type RunConfig struct {
+	// Log for JSON log lines, one object per line with time, level, and msg fields. Defaults to os.Stdout.
+	Log io.Writer
+	// Options for the Migrator. DB and FS are required. If Options.Logger is not set, warnings are logged to Log.
+	Options Options
+	// Wait for the database to accept connections. Defaults to one minute.
+	Wait time.Duration
+}
+
+// RunOnce waits for the database, applies pending migrations, and logs what happens as JSON lines,
+// for running migrations in a Kubernetes init container or Job. With Options.Dialect, the run holds
+// the migration lock, so replicas run one at a time. With Options.History, it logs the number of pending migrations
+// and the estimated time to apply them, see Migrator.Estimate. It returns ErrDatabaseUnavailable, wrapped,
+// if the database doesn't accept connections in time.
+func RunOnce(ctx context.Context, cfg RunConfig) error {
+	if cfg.Log == nil {
+		cfg.Log = os.Stdout
+	}
+	if cfg.Wait == 0 {
+		cfg.Wait = time.Minute
+	}
+	l := &jsonLogger{w: cfg.Log}
+
+	opts := cfg.Options
+	if opts.Logger == nil {
+		opts.Logger = l
+	}
+	var summary Summary
+	afterAll, onError := opts.AfterAll, opts.OnError
+	opts.AfterAll = func(ctx context.Context, s Summary) error {
+		summary = s
+		if afterAll != nil {
+			return afterAll(ctx, s)
+		}
+		return nil
+	}
+	opts.OnError = func(ctx context.Context, s Summary) {
+		summary = s
+		if onError != nil {
+			onError(ctx, s)
+		}
+	}
+
+	l.log("info", "waiting for database", nil)
+	if err := waitForDatabase(ctx, cfg, opts); err != nil {
+		l.log("error", "database unavailable", map[string]any{"error": err.Error()})
+		return err
+	}
+
+	m := New(opts)
+	var fields map[string]any
+	if opts.History {
+		// The estimate is only informational, so failing to get it doesn't fail the run
+		if e, err := m.Estimate(ctx, nil); err != nil {
+			l.log("warn", "estimating failed", map[string]any{"error": err.Error()})
+		} else {
+			fields = map[string]any{"pending": len(e.Pending), "estimate_ms": e.Remaining.Milliseconds()}
+		}
+	}
+
+	l.log("info", "migrating up", fields)
+	if err := m.MigrateUp(ctx); err != nil {
+		l.log("error", "migrating up failed", summaryFields(summary, err))
+		return err
+	}
+	l.log("info", "migrated up", summaryFields(summary, nil))
+	return nil
+}
+
+// waitForDatabase by pinging it, with the delay between pings doubling from 100ms up to 5s.
+func waitForDatabase(ctx context.Context, cfg RunConfig, opts Options) error {
+	ctx, cancel := context.WithTimeout(ctx, cfg.Wait)
+	defer cancel()
+
+	delay := waitMinDelay
+	for {
+		err := opts.DB.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return fmt.Errorf("%w after %v: %v", ErrDatabaseUnavailable, cfg.Wait, err)
+		case <-t.C:
+		}
+
+		delay *= 2
+		if delay > waitMaxDelay {
+			delay = waitMaxDelay
+		}
+	}
+}
+
+func summaryFields(s Summary, err error) map[string]any {
+	applied := s.Applied
+	if applied == nil {
+		applied = []string{}
+	}
+	fields := map[string]any{
+		"applied":      applied,
+		"duration_ms":  s.Duration.Milliseconds(),
+		"from_version": s.FromVersion,
+		"to_version":   s.ToVersion,
+	}
+	if err != nil {
+		fields["error"] = err.Error()
+	}
+	return fields
+}
+
+// jsonLogger writes JSON log lines, and is a Logger for warnings.
+type jsonLogger struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+// Printf logs a warning.
+func (l *jsonLogger) Printf(format string, v ...any) {
+	l.log("warn", fmt.Sprintf(format, v...), nil)
+}
+
+func (l *jsonLogger) log(level, msg string, fields map[string]any) {
+	line := map[string]any{}
+	for k, v := range fields {
+		line[k] = v
+	}
+	line["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	line["level"] = level
+	line["msg"] = msg
+
+	b, err := json.Marshal(line)
+	if err != nil {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	_, _ = l.w.Write(append(b, '\n'))
+}
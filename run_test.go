@@ -0,0 +1,113 @@
+package migrate_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+type logLine struct {
+	Applied     []string `json:"applied"`
+	Error       string   `json:"error"`
+	FromVersion string   `json:"from_version"`
+	Level       string   `json:"level"`
+	Msg         string   `json:"msg"`
+	Time        string   `json:"time"`
+	ToVersion   string   `json:"to_version"`
+}
+
+func parseLogLines(t *testing.T, b *bytes.Buffer) []logLine {
+	t.Helper()
+	var lines []logLine
+	for _, text := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		var l logLine
+		err := json.Unmarshal([]byte(text), &l)
+		is.NotError(t, err)
+		lines = append(lines, l)
+	}
+	return lines
+}
+
+func TestRunOnce(t *testing.T) {
+	t.Run("applies pending migrations and logs JSON lines", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		var b bytes.Buffer
+		err := migrate.RunOnce(context.Background(), migrate.RunConfig{
+			Log: &b,
+			Options: migrate.Options{
+				DB:          db,
+				FS:          fstest.MapFS{"1.up.sql": {Data: []byte("create table a (v int)")}},
+				MissingDown: migrate.CheckWarn,
+			},
+		})
+		is.NotError(t, err)
+		is.Equal(t, "1", getVersion(t, db))
+
+		lines := parseLogLines(t, &b)
+		is.Equal(t, 4, len(lines))
+		is.Equal(t, "waiting for database", lines[0].Msg)
+		is.Equal(t, "migrating up", lines[1].Msg)
+		is.Equal(t, "warn", lines[2].Level)
+		is.Equal(t, "migrate: warning: 1.up.sql has no down migration file 1.down.sql", lines[2].Msg)
+		is.Equal(t, "info", lines[3].Level)
+		is.Equal(t, "migrated up", lines[3].Msg)
+		is.Equal(t, "[1.up.sql]", fmt.Sprint(lines[3].Applied))
+		is.Equal(t, "1", lines[3].ToVersion)
+		_, err = time.Parse(time.RFC3339Nano, lines[3].Time)
+		is.NotError(t, err)
+	})
+
+	t.Run("logs and returns the error of a failed migration", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		var b bytes.Buffer
+		err := migrate.RunOnce(context.Background(), migrate.RunConfig{
+			Log: &b,
+			Options: migrate.Options{
+				DB:          db,
+				FS:          fstest.MapFS{"1.up.sql": {Data: []byte("not sql")}},
+				MissingDown: migrate.CheckIgnore,
+			},
+		})
+		is.True(t, err != nil)
+		is.True(t, !errors.Is(err, migrate.ErrDatabaseUnavailable))
+
+		lines := parseLogLines(t, &b)
+		last := lines[len(lines)-1]
+		is.Equal(t, "error", last.Level)
+		is.Equal(t, "migrating up failed", last.Msg)
+		is.Equal(t, err.Error(), last.Error)
+	})
+
+	t.Run("returns ErrDatabaseUnavailable if the database does not accept connections in time", func(t *testing.T) {
+		db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "missing", "db.sqlite"))
+		is.NotError(t, err)
+		t.Cleanup(func() {
+			_ = db.Close()
+		})
+
+		var b bytes.Buffer
+		err = migrate.RunOnce(context.Background(), migrate.RunConfig{
+			Log:     &b,
+			Options: migrate.Options{DB: db, FS: fstest.MapFS{}},
+			Wait:    50 * time.Millisecond,
+		})
+		is.True(t, errors.Is(err, migrate.ErrDatabaseUnavailable))
+
+		lines := parseLogLines(t, &b)
+		is.Equal(t, "database unavailable", lines[len(lines)-1].Msg)
+	})
+}
@@ -0,0 +1,56 @@
+package migrate
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// RunInfo about the run that applies a migration, in the context given to the Before and After callbacks,
+// and to ShouldApply, so they can correlate their logs and audit entries with the run. See RunInfoFrom.
+type RunInfo struct {
+	// Actor of the run, from Options.Actor, which defaults to the operating system user with Options.Audit.
+	Actor string
+	// Down is whether the run migrates down.
+	Down bool
+	// ID of the run, random and unique, like "9f86d081884c7d659a2feaa0c55ad015". It's also in the Summary.
+	ID string
+	// Operation of the run, like in Summary.
+	Operation string
+	// TargetVersion the run migrates to, which is empty when migrating down past the first migration.
+	TargetVersion string
+}
+
+type runInfoKey struct{}
+
+// RunInfoFrom the context of a callback, reporting false if the context isn't from a run.
+func RunInfoFrom(ctx context.Context) (RunInfo, bool) {
+	info, ok := ctx.Value(runInfoKey{}).(RunInfo)
+	return info, ok
+}
+
+// withRunInfo adds the RunInfo of the run applying steps to ctx, if there are any steps.
+func (m *Migrator) withRunInfo(ctx context.Context, steps []step) context.Context {
+	if len(steps) == 0 || m.cache == nil {
+		return ctx
+	}
+	if m.cache.runID == "" {
+		m.cache.runID = newRunID()
+	}
+	return context.WithValue(ctx, runInfoKey{}, RunInfo{
+		Actor:         m.actor,
+		Down:          steps[0].down,
+		ID:            m.cache.runID,
+		Operation:     m.cache.operation,
+		TargetVersion: steps[len(steps)-1].version,
+	})
+}
+
+// newRunID of 16 random bytes, hex-encoded.
+func newRunID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
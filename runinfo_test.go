@@ -0,0 +1,70 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestRunInfoFrom(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1-accounts.up.sql":   {Data: []byte("create table accounts (id int);")},
+		"1-accounts.down.sql": {Data: []byte("drop table accounts;")},
+		"2-users.up.sql":      {Data: []byte("create table users (id int);")},
+		"2-users.down.sql":    {Data: []byte("drop table users;")},
+	}
+
+	t.Run("has the run info in callbacks", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		var infos []migrate.RunInfo
+		var summaries []migrate.Summary
+		m := migrate.New(migrate.Options{
+			Actor: "deployer",
+			Before: func(ctx context.Context, tx *sql.Tx, version string) error {
+				info, ok := migrate.RunInfoFrom(ctx)
+				is.True(t, ok)
+				infos = append(infos, info)
+				return nil
+			},
+			AfterAll: func(ctx context.Context, s migrate.Summary) error {
+				summaries = append(summaries, s)
+				return nil
+			},
+			DB: db,
+			FS: fsys,
+		})
+
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		err = m.MigrateDown(context.Background())
+		is.NotError(t, err)
+
+		is.Equal(t, 4, len(infos))
+		is.Equal(t, 2, len(summaries))
+
+		is.Equal(t, "deployer", infos[0].Actor)
+		is.True(t, !infos[0].Down)
+		is.Equal(t, "up", infos[0].Operation)
+		is.Equal(t, "2-users", infos[0].TargetVersion)
+		is.Equal(t, 32, len(infos[0].ID))
+		is.Equal(t, summaries[0].RunID, infos[0].ID)
+		is.Equal(t, infos[0], infos[1])
+
+		is.True(t, infos[2].Down)
+		is.Equal(t, "down", infos[2].Operation)
+		is.Equal(t, "", infos[2].TargetVersion)
+		is.Equal(t, summaries[1].RunID, infos[2].ID)
+		is.True(t, infos[0].ID != infos[2].ID)
+	})
+
+	t.Run("reports false outside a run", func(t *testing.T) {
+		_, ok := migrate.RunInfoFrom(context.Background())
+		is.True(t, !ok)
+	})
+}
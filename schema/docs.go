@@ -0,0 +1,181 @@
+package schema
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"maragu.dev/migrate"
+)
+
+// DocsFormat of a schema reference. See Docs.
+type DocsFormat int
+
+const (
+	// Markdown with a table of columns and a list of foreign keys for each table.
+	Markdown DocsFormat = iota
+	// Mermaid ER diagram, which renders on GitHub in a mermaid code block.
+	Mermaid
+)
+
+// Docs writes a schema reference of the migrated database to a file after each successful run,
+// so the docs stay in sync with the migrations. Use its AfterAll method as the callback in migrate.Options:
+//
+//	d := schema.Docs{DB: db, Dialect: migrate.Postgres, Format: schema.Mermaid, Path: "docs/schema.mmd"}
+//	m := migrate.New(migrate.Options{AfterAll: d.AfterAll, DB: db, Dialect: migrate.Postgres, FS: fsys})
+type Docs struct {
+	DB      *sql.DB
+	Dialect migrate.Dialect
+	// Exclude tables from the docs. Defaults to the tables of the Migrator with the default table name.
+	Exclude []string
+	Format  DocsFormat
+	// Path of the file to write.
+	Path string
+}
+
+// AfterAll writes the docs. An error fails the run, but doesn't undo the applied migrations.
+func (d Docs) AfterAll(ctx context.Context, _ migrate.Summary) error {
+	exclude := d.Exclude
+	if exclude == nil {
+		exclude = []string{"migrations", "migrations_audit", "migrations_backfills", "migrations_history"}
+	}
+	s, err := Inspect(ctx, d.DB, d.Dialect, exclude...)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(d.Path)
+	if err != nil {
+		return fmt.Errorf("error creating schema docs: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	switch d.Format {
+	case Mermaid:
+		err = WriteMermaid(w, s)
+	default:
+		err = WriteMarkdown(w, s)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error writing schema docs: %w", err)
+	}
+	return nil
+}
+
+// WriteMarkdown reference of the schema to w, with a table of columns and a list of foreign keys for each table.
+func WriteMarkdown(w io.Writer, s Schema) error {
+	var b strings.Builder
+	b.WriteString("# Schema\n")
+	for _, t := range s.Tables {
+		b.WriteString("\n## " + t.Name + "\n\n")
+		b.WriteString("| Column | Definition |\n|---|---|\n")
+		for _, c := range t.Columns {
+			b.WriteString("| " + escapeCell(c.Name) + " | " + escapeCell(c.Definition) + " |\n")
+		}
+		if len(t.ForeignKeys) > 0 {
+			b.WriteString("\nForeign keys:\n\n")
+			for _, fk := range t.ForeignKeys {
+				b.WriteString("- `" + fk.Column + "` references `" + fk.RefTable + "`")
+				if fk.RefColumn != "" {
+					b.WriteString(" (`" + fk.RefColumn + "`)")
+				}
+				b.WriteString("\n")
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteMermaid ER diagram of the schema to w, with the columns of each table and relationships from foreign keys.
+func WriteMermaid(w io.Writer, s Schema) error {
+	var b strings.Builder
+	b.WriteString("erDiagram\n")
+	for _, t := range s.Tables {
+		foreignKeys := map[string]bool{}
+		for _, fk := range t.ForeignKeys {
+			foreignKeys[strings.ToLower(fk.Column)] = true
+		}
+		primaryKeys := primaryKeyColumns(t)
+
+		b.WriteString("    " + mermaidName(t.Name) + " {\n")
+		for _, c := range t.Columns {
+			line := "        " + mermaidType(c.Definition) + " " + mermaidName(c.Name)
+			var keys []string
+			if primaryKeys[strings.ToLower(c.Name)] {
+				keys = append(keys, "PK")
+			}
+			if foreignKeys[strings.ToLower(c.Name)] {
+				keys = append(keys, "FK")
+			}
+			if len(keys) > 0 {
+				line += " " + strings.Join(keys, ", ")
+			}
+			b.WriteString(line + "\n")
+		}
+		b.WriteString("    }\n")
+	}
+	for _, t := range s.Tables {
+		for _, fk := range t.ForeignKeys {
+			b.WriteString("    " + mermaidName(t.Name) + " }o--|| " + mermaidName(fk.RefTable) + ` : "` + fk.Column + `"` + "\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var primaryKeyMatcher = regexp.MustCompile(`(?i)^(?:constraint\s+\S+\s+)?primary\s+key\s*\(([^)]*)\)`)
+
+// primaryKeyColumns of the table, from column definitions and constraints, by lowercase name.
+func primaryKeyColumns(t Table) map[string]bool {
+	columns := map[string]bool{}
+	for _, c := range t.Columns {
+		if strings.Contains(strings.ToLower(c.Definition), "primary key") {
+			columns[strings.ToLower(c.Name)] = true
+		}
+	}
+	for _, constraint := range t.Constraints {
+		if match := primaryKeyMatcher.FindStringSubmatch(constraint); match != nil {
+			for _, c := range strings.Split(match[1], ",") {
+				columns[strings.ToLower(unquote(strings.TrimSpace(c)))] = true
+			}
+		}
+	}
+	return columns
+}
+
+var nonWordMatcher = regexp.MustCompile(`\W`)
+
+// mermaidName with characters that Mermaid doesn't allow in names replaced.
+func mermaidName(name string) string {
+	return nonWordMatcher.ReplaceAllString(name, "_")
+}
+
+// mermaidType from a column definition, which is the first word without any length, or "any" if the column has no type.
+func mermaidType(definition string) string {
+	fields := strings.Fields(definition)
+	if len(fields) == 0 {
+		return "any"
+	}
+	typ, _, _ := strings.Cut(fields[0], "(")
+	typ = mermaidName(typ)
+	switch strings.ToLower(typ) {
+	case "", "check", "default", "not", "null", "primary", "references", "unique":
+		return "any"
+	}
+	return typ
+}
+
+func escapeCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
@@ -0,0 +1,90 @@
+package schema_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+	"maragu.dev/migrate/schema"
+)
+
+func TestParse_foreignKeys(t *testing.T) {
+	t.Run("parses foreign keys from column definitions and constraints", func(t *testing.T) {
+		s, err := schema.Parse(`create table a (id int, b_id int references b (id), c_id int references c,
+			foreign key (x, y) references "d" (dx, dy))`)
+		is.NotError(t, err)
+		is.Equal(t, 4, len(s.Tables[0].ForeignKeys))
+		is.Equal(t, schema.ForeignKey{Column: "b_id", RefColumn: "id", RefTable: "b"}, s.Tables[0].ForeignKeys[0])
+		is.Equal(t, schema.ForeignKey{Column: "c_id", RefTable: "c"}, s.Tables[0].ForeignKeys[1])
+		is.Equal(t, schema.ForeignKey{Column: "x", RefColumn: "dx", RefTable: "d"}, s.Tables[0].ForeignKeys[2])
+		is.Equal(t, schema.ForeignKey{Column: "y", RefColumn: "dy", RefTable: "d"}, s.Tables[0].ForeignKeys[3])
+	})
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	t.Run("writes each table with its columns and foreign keys", func(t *testing.T) {
+		s, err := schema.Parse(desired)
+		is.NotError(t, err)
+
+		var b strings.Builder
+		err = schema.WriteMarkdown(&b, s)
+		is.NotError(t, err)
+		is.Equal(t, "# Schema\n\n"+
+			"## accounts\n\n| Column | Definition |\n|---|---|\n"+
+			"| id | integer primary key |\n| name | text not null default 'a, b; c' |\n\n"+
+			"## users\n\n| Column | Definition |\n|---|---|\n"+
+			"| id | integer |\n| account_id | integer not null references accounts (id) |\n| email | text not null |\n\n"+
+			"Foreign keys:\n\n- `account_id` references `accounts` (`id`)\n", b.String())
+	})
+}
+
+func TestWriteMermaid(t *testing.T) {
+	t.Run("writes an ER diagram with keys and relationships", func(t *testing.T) {
+		s, err := schema.Parse(desired)
+		is.NotError(t, err)
+
+		var b strings.Builder
+		err = schema.WriteMermaid(&b, s)
+		is.NotError(t, err)
+		is.Equal(t, "erDiagram\n"+
+			"    accounts {\n        integer id PK\n        text name\n    }\n"+
+			"    users {\n        integer id PK\n        integer account_id FK\n        text email\n    }\n"+
+			"    users }o--|| accounts : \"account_id\"\n", b.String())
+	})
+}
+
+func TestDocs_AfterAll(t *testing.T) {
+	t.Run("writes the docs of the migrated database after a run", func(t *testing.T) {
+		db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "db.sqlite"))
+		is.NotError(t, err)
+		t.Cleanup(func() {
+			_ = db.Close()
+		})
+
+		path := filepath.Join(t.TempDir(), "schema.md")
+		d := schema.Docs{DB: db, Dialect: migrate.SQLite, Path: path}
+		m := migrate.New(migrate.Options{
+			AfterAll:    d.AfterAll,
+			DB:          db,
+			Dialect:     migrate.SQLite,
+			FS:          fstest.MapFS{"1.up.sql": {Data: []byte(desired)}},
+			History:     true,
+			MissingDown: migrate.CheckIgnore,
+		})
+		err = m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		b, err := os.ReadFile(path)
+		is.NotError(t, err)
+		is.True(t, strings.HasPrefix(string(b), "# Schema\n\n## accounts\n"))
+		is.True(t, strings.Contains(string(b), "## users\n"))
+		is.True(t, !strings.Contains(string(b), "migrations"))
+	})
+}
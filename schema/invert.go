@@ -0,0 +1,105 @@
+package schema
+
+import (
+	"regexp"
+	"strings"
+)
+
+const identifier = "([\\w.\"`]+)"
+
+var (
+	invertCreateTableMatcher = regexp.MustCompile("(?i)^create (?:(?:global |local )?(?:temporary |temp |unlogged ))?table (?:if not exists )?" + identifier)
+	invertCreateIndexMatcher = regexp.MustCompile("(?i)^create (?:unique )?index (concurrently )?(?:if not exists )?" + identifier + " on ")
+	invertCreateMatcher      = regexp.MustCompile("(?i)^create (materialized view|view|sequence|schema|extension|type|domain) (?:if not exists )?" + identifier)
+	invertAlterTableMatcher  = regexp.MustCompile("(?i)^alter table (?:if exists )?(?:only )?" + identifier + " (.*)$")
+	invertAddColumnMatcher   = regexp.MustCompile("(?i)^add (?:column )?(?:if not exists )?" + identifier)
+	invertAddConstraint      = regexp.MustCompile("(?i)^add constraint " + identifier)
+	invertUnnamedConstraint  = regexp.MustCompile("(?i)^add (?:primary key|foreign key|unique|check|exclude)\\b")
+	invertRenameTableMatcher = regexp.MustCompile("(?i)^rename to " + identifier + "$")
+	invertRenameColumn       = regexp.MustCompile("(?i)^rename (?:column )?" + identifier + " to " + identifier + "$")
+)
+
+// Invert the statements of an up migration, returning SQL for the down migration, with the inverse statements
+// in the opposite order, as a starting point to review. It inverts creating tables, indexes, views, sequences,
+// schemas, extensions, types, and domains, and altering tables to add columns or constraints or rename them.
+// Other statements, like updates and drops, can't be inverted from the statement alone, and get a TODO comment
+// with the statement instead. Reports whether all statements were inverted. Indexes are dropped like on Postgres
+// and SQLite, so MySQL needs "on" and the table added.
+func Invert(up string) (down string, ok bool) {
+	ok = true
+	var downs []string
+	for _, statement := range splitStatements(up) {
+		inverse, inverted := invertStatement(strings.Join(strings.Fields(statement), " "))
+		if !inverted {
+			ok = false
+			inverse = "-- TODO: invert\n-- " + strings.ReplaceAll(statement, "\n", "\n-- ")
+		}
+		downs = append(downs, inverse)
+	}
+
+	// Revert in the opposite order
+	for i, j := 0, len(downs)-1; i < j; i, j = i+1, j-1 {
+		downs[i], downs[j] = downs[j], downs[i]
+	}
+
+	var b strings.Builder
+	for i, d := range downs {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(d)
+		if !strings.HasPrefix(d, "-- TODO") {
+			b.WriteString(";")
+		}
+		b.WriteString("\n")
+	}
+	return b.String(), ok
+}
+
+// invertStatement with whitespace collapsed, reporting whether it could.
+func invertStatement(statement string) (string, bool) {
+	if match := invertCreateTableMatcher.FindStringSubmatch(statement); match != nil {
+		return "drop table " + match[1], true
+	}
+	if match := invertCreateIndexMatcher.FindStringSubmatch(statement); match != nil {
+		return "drop index " + strings.ToLower(match[1]) + match[2], true
+	}
+	if match := invertCreateMatcher.FindStringSubmatch(statement); match != nil {
+		return "drop " + strings.ToLower(match[1]) + " " + match[2], true
+	}
+
+	match := invertAlterTableMatcher.FindStringSubmatch(statement)
+	if match == nil {
+		return "", false
+	}
+	table := match[1]
+	var inverses []string
+	for _, action := range splitTopLevel(match[2], ',') {
+		switch {
+		case invertAddConstraint.MatchString(action):
+			inverses = append(inverses, "drop constraint "+invertAddConstraint.FindStringSubmatch(action)[1])
+		case invertUnnamedConstraint.MatchString(action):
+			// The name of the constraint is generated by the database
+			return "", false
+		case invertAddColumnMatcher.MatchString(action):
+			inverses = append(inverses, "drop column "+invertAddColumnMatcher.FindStringSubmatch(action)[1])
+		case invertRenameTableMatcher.MatchString(action):
+			// The table has the new name when migrating down
+			if len(match[2]) != len(action) {
+				return "", false
+			}
+			return "alter table " + invertRenameTableMatcher.FindStringSubmatch(action)[1] + " rename to " + table, true
+		case invertRenameColumn.MatchString(action):
+			columns := invertRenameColumn.FindStringSubmatch(action)
+			inverses = append(inverses, "rename column "+columns[2]+" to "+columns[1])
+		default:
+			return "", false
+		}
+	}
+
+	// Revert the actions in the opposite order too
+	for i, j := 0, len(inverses)-1; i < j; i, j = i+1, j-1 {
+		inverses[i], inverses[j] = inverses[j], inverses[i]
+	}
+	return "alter table " + table + " " + strings.Join(inverses, ", "), true
+}
@@ -0,0 +1,54 @@
+package schema_test
+
+import (
+	"testing"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate/schema"
+)
+
+func TestInvert(t *testing.T) {
+	t.Run("inverts statements in the opposite order", func(t *testing.T) {
+		down, ok := schema.Invert(`-- Accounts
+create table if not exists accounts (
+  id bigint primary key,
+  name text not null
+);
+create unique index concurrently accounts_name_idx on accounts (name);
+alter table accounts add column email text, add constraint accounts_email_check check (email like '%@%');
+alter table accounts rename column name to display_name;
+alter table accounts rename to users;
+create view active_users as select * from users;
+create extension if not exists pgcrypto;`)
+		is.True(t, ok)
+		is.Equal(t, `drop extension pgcrypto;
+
+drop view active_users;
+
+alter table users rename to accounts;
+
+alter table accounts rename column display_name to name;
+
+alter table accounts drop constraint accounts_email_check, drop column email;
+
+drop index concurrently accounts_name_idx;
+
+drop table accounts;
+`, down)
+	})
+
+	t.Run("adds a todo comment for statements it can't invert", func(t *testing.T) {
+		down, ok := schema.Invert("create table accounts (id bigint);\nupdate accounts\n  set id = id + 1;\nalter table accounts add primary key (id);")
+		is.True(t, !ok)
+		is.Equal(t, `-- TODO: invert
+-- alter table accounts add primary key (id)
+
+-- TODO: invert
+-- update accounts
+--   set id = id + 1
+
+drop table accounts;
+`, down)
+	})
+}
@@ -0,0 +1,213 @@
+package schema
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"maragu.dev/migrate"
+)
+
+// Tabler is a model that names its table. See FromModels.
+type Tabler interface {
+	TableName() string
+}
+
+// FromModels builds a schema from Go structs, or pointers to them, with a table for each:
+//
+//	type Account struct {
+//		ID      int64     `sql:"bigint primary key"`
+//		Name    string
+//		Note    *string
+//		Created time.Time `db:"created_at"`
+//	}
+//
+// The table name is the struct name in snake case, like "account", or else from its TableName method.
+// Each exported field is a column, named from its db tag, or else the field name in snake case.
+// Fields of embedded structs are columns too, and fields tagged db:"-" are skipped.
+// The column definition is from the sql tag, or else from the Go type: text, bigint, integer, double precision,
+// boolean, timestamp, or bytea, which is not null unless the field is a pointer or a sql.Null type.
+func FromModels(models ...any) (Schema, error) {
+	var s Schema
+	for _, model := range models {
+		t := reflect.TypeOf(model)
+		for t != nil && t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		if t == nil || t.Kind() != reflect.Struct {
+			return Schema{}, fmt.Errorf("error building schema: model %T is not a struct", model)
+		}
+
+		table := Table{Name: snakeCase(t.Name())}
+		if tabler, ok := model.(Tabler); ok {
+			table.Name = tabler.TableName()
+		}
+		columns, err := modelColumns(t)
+		if err != nil {
+			return Schema{}, fmt.Errorf("error building schema of %v: %w", t.Name(), err)
+		}
+		table.Columns = columns
+		s.Tables = append(s.Tables, table)
+	}
+	return s, nil
+}
+
+// modelColumns of the exported fields of a struct type, including those of embedded structs.
+func modelColumns(t reflect.Type) ([]Column, error) {
+	var columns []Column
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("db"), ",")
+		if name == "-" {
+			continue
+		}
+
+		// The exported fields of embedded structs are promoted, even if the struct type isn't exported
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && name == "" {
+			embedded, err := modelColumns(f.Type)
+			if err != nil {
+				return nil, err
+			}
+			columns = append(columns, embedded...)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = snakeCase(f.Name)
+		}
+		definition := f.Tag.Get("sql")
+		if definition == "" {
+			var ok bool
+			if definition, ok = columnDefinition(f.Type); !ok {
+				return nil, fmt.Errorf("no column type for field %v of type %v, so add a sql tag", f.Name, f.Type)
+			}
+		}
+		columns = append(columns, Column{Definition: definition, Name: name})
+	}
+	return columns, nil
+}
+
+var (
+	bytesType = reflect.TypeOf([]byte(nil))
+	timeType  = reflect.TypeOf(time.Time{})
+	nullTypes = map[reflect.Type]string{
+		reflect.TypeOf(sql.NullBool{}):    "boolean",
+		reflect.TypeOf(sql.NullFloat64{}): "double precision",
+		reflect.TypeOf(sql.NullInt16{}):   "integer",
+		reflect.TypeOf(sql.NullInt32{}):   "integer",
+		reflect.TypeOf(sql.NullInt64{}):   "bigint",
+		reflect.TypeOf(sql.NullString{}):  "text",
+		reflect.TypeOf(sql.NullTime{}):    "timestamp",
+	}
+)
+
+// columnDefinition for a Go type, reporting false if there is none.
+func columnDefinition(t reflect.Type) (string, bool) {
+	if definition, ok := nullTypes[t]; ok {
+		return definition, true
+	}
+	notNull := " not null"
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		notNull = ""
+	}
+
+	var definition string
+	switch {
+	case t == timeType:
+		definition = "timestamp"
+	case t == bytesType:
+		definition = "bytea"
+	default:
+		switch t.Kind() {
+		case reflect.String:
+			definition = "text"
+		case reflect.Bool:
+			definition = "boolean"
+		case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+			definition = "bigint"
+		case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+			definition = "integer"
+		case reflect.Float32, reflect.Float64:
+			definition = "double precision"
+		default:
+			return "", false
+		}
+	}
+	return definition + notNull, true
+}
+
+// snakeCase of a Go name, like "user_id" for "UserID" and "http_status" for "HTTPStatus".
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prevLower := unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// ModelsFile is where GenerateFromModels keeps the schema of the models it last generated migrations for,
+// as create table statements in the migrations directory. Commit it with the migrations.
+const ModelsFile = "models.sql"
+
+// GenerateFromModels creates up and down migration files in dir with the given name, with the diff from the schema
+// in ModelsFile to the schema of the models, and then updates ModelsFile. See FromModels and Diff.
+// It returns the paths of the files, or ErrNoChanges if the models haven't changed.
+func GenerateFromModels(dir, name string, opts migrate.CreateOptions, models ...any) (upPath, downPath string, err error) {
+	want, err := FromModels(models...)
+	if err != nil {
+		return "", "", err
+	}
+
+	var have Schema
+	modelsPath := filepath.Join(dir, ModelsFile)
+	content, err := os.ReadFile(modelsPath)
+	switch {
+	case err == nil:
+		if have, err = Parse(string(content)); err != nil {
+			return "", "", fmt.Errorf("error parsing %v: %w", modelsPath, err)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return "", "", fmt.Errorf("error reading %v: %w", modelsPath, err)
+	}
+
+	up, down := Diff(have, want)
+	if up == "" {
+		return "", "", ErrNoChanges
+	}
+
+	opts.UpTemplate = escapeTemplate(up)
+	opts.DownTemplate = escapeTemplate(down)
+	upPath, downPath, err = migrate.Create(dir, name, opts)
+	if err != nil {
+		return "", "", err
+	}
+
+	var statements []string
+	for _, t := range want.Tables {
+		statements = append(statements, createTable(t))
+	}
+	content = []byte("-- Schema of the models that the migrations were last generated for. Don't edit it by hand.\n\n" +
+		joinStatements(statements))
+	if err := os.WriteFile(modelsPath, content, 0644); err != nil {
+		return "", "", fmt.Errorf("error writing %v: %w", modelsPath, err)
+	}
+	return upPath, downPath, nil
+}
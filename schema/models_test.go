@@ -0,0 +1,95 @@
+package schema_test
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+	"maragu.dev/migrate/schema"
+)
+
+type timestamps struct {
+	CreatedAt time.Time
+	UpdatedAt *time.Time
+}
+
+type account struct {
+	ID   int64 `sql:"bigint primary key"`
+	Name string
+	timestamps
+}
+
+type user struct {
+	AccountID int64 `db:"account"`
+	Email     sql.NullString
+	HTTPCode  int32
+	Secret    string `db:"-"`
+	hidden    string
+}
+
+func (user) TableName() string {
+	return "users"
+}
+
+func TestFromModels(t *testing.T) {
+	t.Run("builds a table for each model", func(t *testing.T) {
+		s, err := schema.FromModels(account{}, &user{})
+		is.NotError(t, err)
+		is.Equal(t, 2, len(s.Tables))
+
+		is.Equal(t, "account", s.Tables[0].Name)
+		is.Equal(t, 4, len(s.Tables[0].Columns))
+		is.Equal(t, schema.Column{Definition: "bigint primary key", Name: "id"}, s.Tables[0].Columns[0])
+		is.Equal(t, schema.Column{Definition: "text not null", Name: "name"}, s.Tables[0].Columns[1])
+		is.Equal(t, schema.Column{Definition: "timestamp not null", Name: "created_at"}, s.Tables[0].Columns[2])
+		is.Equal(t, schema.Column{Definition: "timestamp", Name: "updated_at"}, s.Tables[0].Columns[3])
+
+		is.Equal(t, "users", s.Tables[1].Name)
+		is.Equal(t, 3, len(s.Tables[1].Columns))
+		is.Equal(t, schema.Column{Definition: "bigint not null", Name: "account"}, s.Tables[1].Columns[0])
+		is.Equal(t, schema.Column{Definition: "text", Name: "email"}, s.Tables[1].Columns[1])
+		is.Equal(t, schema.Column{Definition: "integer not null", Name: "http_code"}, s.Tables[1].Columns[2])
+	})
+
+	t.Run("errors on fields without a column type", func(t *testing.T) {
+		type thing struct {
+			Tags []string
+		}
+		_, err := schema.FromModels(thing{})
+		is.Equal(t, "error building schema of thing: no column type for field Tags of type []string, so add a sql tag", err.Error())
+	})
+}
+
+func TestGenerateFromModels(t *testing.T) {
+	t.Run("generates migrations for the diff from the models of the last generation", func(t *testing.T) {
+		dir := t.TempDir()
+		opts := migrate.CreateOptions{Numbering: migrate.Sequence}
+
+		upPath, downPath, err := schema.GenerateFromModels(dir, "accounts", opts, account{})
+		is.NotError(t, err)
+		is.Equal(t, filepath.Join(dir, "0001-accounts.up.sql"), upPath)
+		up, err := os.ReadFile(upPath)
+		is.NotError(t, err)
+		is.Equal(t, "create table account (\n  id bigint primary key,\n  name text not null,\n  created_at timestamp not null,\n"+
+			"  updated_at timestamp\n);\n", string(up))
+		down, err := os.ReadFile(downPath)
+		is.NotError(t, err)
+		is.Equal(t, "drop table account;\n", string(down))
+
+		_, _, err = schema.GenerateFromModels(dir, "nothing", opts, account{})
+		is.True(t, errors.Is(err, schema.ErrNoChanges))
+
+		upPath, _, err = schema.GenerateFromModels(dir, "users", opts, account{}, user{})
+		is.NotError(t, err)
+		is.Equal(t, filepath.Join(dir, "0002-users.up.sql"), upPath)
+		up, err = os.ReadFile(upPath)
+		is.NotError(t, err)
+		is.Equal(t, "create table users (\n  account bigint not null,\n  email text,\n  http_code integer not null\n);\n", string(up))
+	})
+}
@@ -0,0 +1,394 @@
+// Package schema supports a declarative workflow on top of migrate: keep the desired schema in a schema.sql file,
+// and generate versioned up and down migrations by diffing it against the current schema of a database,
+// like a development database migrated with the existing migrations. The migrations are applied with
+// the Migrator as usual, so they can be reviewed and edited like any other migration.
+//
+// The diff is deliberately simple: it creates and drops tables, and adds and drops columns.
+// It doesn't change column types or constraints, and the schema file can only have create table statements,
+// so use regular migrations for indexes, functions, and anything else.
+//
+// GenerateFromModels does the same with Go structs as the desired schema, diffing against the models of its last run.
+//
+// Docs writes a schema reference of the migrated database, in Markdown or as a Mermaid ER diagram, after each run.
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"maragu.dev/migrate"
+	"maragu.dev/migrate/internal/sqlscan"
+)
+
+// Schema of tables.
+type Schema struct {
+	Tables []Table
+}
+
+// Table with columns, and constraints like "primary key (id)" that aren't part of a column definition.
+type Table struct {
+	Columns     []Column
+	Constraints []string
+	// ForeignKeys in the column definitions and constraints, for documentation. See Docs.
+	ForeignKeys []ForeignKey
+	Name        string
+}
+
+// ForeignKey from a column to a column in another table. RefColumn is empty if the reference doesn't name it.
+type ForeignKey struct {
+	Column    string
+	RefColumn string
+	RefTable  string
+}
+
+// Column with its definition after the name, like "text not null".
+type Column struct {
+	Definition string
+	Name       string
+}
+
+var (
+	columnReferencesMatcher = regexp.MustCompile("(?i)\\breferences\\s+([\\w.`\"]+)\\s*(?:\\(\\s*([\\w`\"]+)\\s*\\))?")
+	foreignKeyMatcher       = regexp.MustCompile("(?i)\\bforeign\\s+key\\s*\\(([^)]*)\\)\\s*references\\s+([\\w.`\"]+)\\s*(?:\\(([^)]*)\\))?")
+	createTableMatcher      = regexp.MustCompile(`(?is)^create\s+table\s+(?:if\s+not\s+exists\s+)?([\w."` + "`" + `]+)\s*\((.*)\)$`)
+)
+
+// Parse a schema from create table statements, separated by semicolons.
+// Comments are ignored. Other statements are an error.
+func Parse(sql string) (Schema, error) {
+	var s Schema
+	for _, statement := range splitStatements(sql) {
+		match := createTableMatcher.FindStringSubmatch(statement)
+		if match == nil {
+			return Schema{}, fmt.Errorf("error parsing schema: not a create table statement: %v", firstLine(statement))
+		}
+
+		t := Table{Name: unquote(match[1])}
+		for _, item := range splitTopLevel(match[2], ',') {
+			fields := strings.Fields(item)
+			if len(fields) == 0 {
+				continue
+			}
+			switch strings.ToLower(fields[0]) {
+			case "constraint", "primary", "unique", "foreign", "check", "exclude":
+				t.Constraints = append(t.Constraints, item)
+				t.ForeignKeys = append(t.ForeignKeys, parseForeignKeyConstraint(item)...)
+			default:
+				c := Column{
+					Definition: strings.TrimSpace(strings.TrimPrefix(item, fields[0])),
+					Name:       unquote(fields[0]),
+				}
+				t.Columns = append(t.Columns, c)
+				if match := columnReferencesMatcher.FindStringSubmatch(c.Definition); match != nil {
+					t.ForeignKeys = append(t.ForeignKeys, ForeignKey{Column: c.Name, RefColumn: unquote(match[2]), RefTable: unquote(match[1])})
+				}
+			}
+		}
+		s.Tables = append(s.Tables, t)
+	}
+	return s, nil
+}
+
+// parseForeignKeyConstraint like "foreign key (a, b) references t (c, d)" into a foreign key for each column.
+func parseForeignKeyConstraint(constraint string) []ForeignKey {
+	match := foreignKeyMatcher.FindStringSubmatch(constraint)
+	if match == nil {
+		return nil
+	}
+	columns := strings.Split(match[1], ",")
+	refColumns := strings.Split(match[3], ",")
+	var fks []ForeignKey
+	for i, c := range columns {
+		fk := ForeignKey{Column: unquote(strings.TrimSpace(c)), RefTable: unquote(match[2])}
+		if i < len(refColumns) {
+			fk.RefColumn = unquote(strings.TrimSpace(refColumns[i]))
+		}
+		fks = append(fks, fk)
+	}
+	return fks
+}
+
+// Inspect the current schema of tables and columns in the database, except the excluded tables,
+// like the migrations tables. For SQLite, the create table statements are parsed.
+// For other databases, columns are read from information_schema in the current schema,
+// and their definitions are only the data type and whether they're nullable.
+// Foreign keys are read for SQLite, Postgres, and MySQL.
+func Inspect(ctx context.Context, db *sql.DB, d migrate.Dialect, exclude ...string) (Schema, error) {
+	excluded := map[string]bool{}
+	for _, name := range exclude {
+		excluded[strings.ToLower(name)] = true
+	}
+
+	if d == migrate.SQLite {
+		return inspectSQLite(ctx, db, excluded)
+	}
+
+	currentSchema := "current_schema()"
+	if d == migrate.MySQL {
+		currentSchema = "database()"
+	}
+	rows, err := db.QueryContext(ctx, `select table_name, column_name, data_type, is_nullable from information_schema.columns `+
+		`where table_schema = `+currentSchema+` order by table_name, ordinal_position`)
+	if err != nil {
+		return Schema{}, fmt.Errorf("error inspecting schema: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var s Schema
+	for rows.Next() {
+		var table, column, dataType, nullable string
+		if err := rows.Scan(&table, &column, &dataType, &nullable); err != nil {
+			return Schema{}, fmt.Errorf("error inspecting schema: %w", err)
+		}
+		if excluded[strings.ToLower(table)] {
+			continue
+		}
+		if len(s.Tables) == 0 || s.Tables[len(s.Tables)-1].Name != table {
+			s.Tables = append(s.Tables, Table{Name: table})
+		}
+		definition := dataType
+		if nullable == "NO" {
+			definition += " not null"
+		}
+		t := &s.Tables[len(s.Tables)-1]
+		t.Columns = append(t.Columns, Column{Definition: definition, Name: column})
+	}
+	if err := rows.Err(); err != nil {
+		return Schema{}, fmt.Errorf("error inspecting schema: %w", err)
+	}
+
+	if err := inspectForeignKeys(ctx, db, d, s); err != nil {
+		return Schema{}, err
+	}
+	return s, nil
+}
+
+// inspectForeignKeys of the tables in s from information_schema, for Postgres and MySQL.
+func inspectForeignKeys(ctx context.Context, db *sql.DB, d migrate.Dialect, s Schema) error {
+	var query string
+	switch d {
+	case migrate.Postgres:
+		query = `select kcu.table_name, kcu.column_name, ccu.table_name, ccu.column_name ` +
+			`from information_schema.table_constraints tc ` +
+			`join information_schema.key_column_usage kcu on tc.constraint_name = kcu.constraint_name and tc.table_schema = kcu.table_schema ` +
+			`join information_schema.constraint_column_usage ccu on tc.constraint_name = ccu.constraint_name and tc.table_schema = ccu.table_schema ` +
+			`where tc.constraint_type = 'FOREIGN KEY' and tc.table_schema = current_schema() order by kcu.table_name, kcu.ordinal_position`
+	case migrate.MySQL:
+		query = `select table_name, column_name, referenced_table_name, referenced_column_name from information_schema.key_column_usage ` +
+			`where table_schema = database() and referenced_table_name is not null order by table_name, ordinal_position`
+	default:
+		return nil
+	}
+
+	tables := map[string]*Table{}
+	for i := range s.Tables {
+		tables[s.Tables[i].Name] = &s.Tables[i]
+	}
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("error inspecting foreign keys: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		var table string
+		var fk ForeignKey
+		if err := rows.Scan(&table, &fk.Column, &fk.RefTable, &fk.RefColumn); err != nil {
+			return fmt.Errorf("error inspecting foreign keys: %w", err)
+		}
+		if t, ok := tables[table]; ok {
+			t.ForeignKeys = append(t.ForeignKeys, fk)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error inspecting foreign keys: %w", err)
+	}
+	return nil
+}
+
+func inspectSQLite(ctx context.Context, db *sql.DB, excluded map[string]bool) (Schema, error) {
+	rows, err := db.QueryContext(ctx, `select name, sql from sqlite_master where type = 'table' and name not like 'sqlite_%' order by name`)
+	if err != nil {
+		return Schema{}, fmt.Errorf("error inspecting schema: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var statements []string
+	for rows.Next() {
+		var name, statement string
+		if err := rows.Scan(&name, &statement); err != nil {
+			return Schema{}, fmt.Errorf("error inspecting schema: %w", err)
+		}
+		if !excluded[strings.ToLower(name)] {
+			statements = append(statements, statement)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return Schema{}, fmt.Errorf("error inspecting schema: %w", err)
+	}
+	return Parse(strings.Join(statements, ";\n"))
+}
+
+// Diff the current and desired schemas, returning the SQL of the up and down migrations.
+// Both are empty if there are no differences. Tables and columns are matched by name, ignoring case.
+func Diff(current, desired Schema) (up, down string) {
+	currentTables := tablesByName(current)
+	desiredTables := tablesByName(desired)
+
+	var ups, downs []string
+	for _, t := range desired.Tables {
+		ct, ok := currentTables[strings.ToLower(t.Name)]
+		if !ok {
+			ups = append(ups, createTable(t))
+			downs = append(downs, `drop table `+t.Name)
+			continue
+		}
+
+		currentColumns := columnsByName(ct)
+		desiredColumns := columnsByName(t)
+		for _, c := range t.Columns {
+			if _, ok := currentColumns[strings.ToLower(c.Name)]; !ok {
+				ups = append(ups, `alter table `+t.Name+` add column `+c.Name+` `+c.Definition)
+				downs = append(downs, `alter table `+t.Name+` drop column `+c.Name)
+			}
+		}
+		for _, c := range ct.Columns {
+			if _, ok := desiredColumns[strings.ToLower(c.Name)]; !ok {
+				ups = append(ups, `alter table `+t.Name+` drop column `+c.Name)
+				downs = append(downs, `alter table `+t.Name+` add column `+c.Name+` `+c.Definition)
+			}
+		}
+	}
+	for _, t := range current.Tables {
+		if _, ok := desiredTables[strings.ToLower(t.Name)]; !ok {
+			ups = append(ups, `drop table `+t.Name)
+			downs = append(downs, createTable(t))
+		}
+	}
+
+	// Revert in the opposite order
+	for i, j := 0, len(downs)-1; i < j; i, j = i+1, j-1 {
+		downs[i], downs[j] = downs[j], downs[i]
+	}
+	return joinStatements(ups), joinStatements(downs)
+}
+
+func createTable(t Table) string {
+	var items []string
+	for _, c := range t.Columns {
+		items = append(items, strings.TrimSpace(c.Name+" "+c.Definition))
+	}
+	items = append(items, t.Constraints...)
+	return "create table " + t.Name + " (\n  " + strings.Join(items, ",\n  ") + "\n)"
+}
+
+func joinStatements(statements []string) string {
+	if len(statements) == 0 {
+		return ""
+	}
+	return strings.Join(statements, ";\n\n") + ";\n"
+}
+
+func tablesByName(s Schema) map[string]Table {
+	tables := map[string]Table{}
+	for _, t := range s.Tables {
+		tables[strings.ToLower(t.Name)] = t
+	}
+	return tables
+}
+
+func columnsByName(t Table) map[string]Column {
+	columns := map[string]Column{}
+	for _, c := range t.Columns {
+		columns[strings.ToLower(c.Name)] = c
+	}
+	return columns
+}
+
+// splitStatements on semicolons outside quotes, without comments, skipping empty statements.
+func splitStatements(sql string) []string {
+	var statements []string
+	for _, s := range sqlscan.Split(sql) {
+		statements = append(statements, strings.TrimSpace(sqlscan.StripComments(s)))
+	}
+	return statements
+}
+
+// splitTopLevel s on sep outside quotes and parentheses.
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	depth := 0
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, strings.TrimSpace(s[start:]))
+}
+
+func unquote(name string) string {
+	return strings.NewReplacer(`"`, "", "`", "").Replace(name)
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+// ErrNoChanges is returned by Generate if the schemas don't differ.
+var ErrNoChanges = errors.New("no changes")
+
+// Generate up and down migration files in dir with the given name, with the diff from the current schema
+// of the database to the desired schema. See Inspect and Diff. It returns the paths of the files,
+// or ErrNoChanges if there are no differences.
+func Generate(ctx context.Context, db *sql.DB, d migrate.Dialect, desired, dir, name string, opts migrate.CreateOptions,
+	exclude ...string) (upPath, downPath string, err error) {
+	want, err := Parse(desired)
+	if err != nil {
+		return "", "", err
+	}
+	have, err := Inspect(ctx, db, d, exclude...)
+	if err != nil {
+		return "", "", err
+	}
+
+	up, down := Diff(have, want)
+	if up == "" {
+		return "", "", ErrNoChanges
+	}
+
+	opts.UpTemplate = escapeTemplate(up)
+	opts.DownTemplate = escapeTemplate(down)
+	return migrate.Create(dir, name, opts)
+}
+
+// escapeTemplate so s is the output of the text/template.
+func escapeTemplate(s string) string {
+	return strings.ReplaceAll(s, "{{", `{{"{{"}}`)
+}
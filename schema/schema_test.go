@@ -0,0 +1,128 @@
+package schema_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+	"maragu.dev/migrate/schema"
+)
+
+const desired = `-- Accounts
+create table accounts (
+  id integer primary key,
+  name text not null default 'a, b; c'
+);
+
+/* Users belong to accounts */
+create table users (
+  id integer,
+  account_id integer not null references accounts (id),
+  email text not null,
+  primary key (id)
+);
+`
+
+func TestParse(t *testing.T) {
+	t.Run("parses tables, columns, and constraints", func(t *testing.T) {
+		s, err := schema.Parse(desired)
+		is.NotError(t, err)
+		is.Equal(t, 2, len(s.Tables))
+
+		accounts := s.Tables[0]
+		is.Equal(t, "accounts", accounts.Name)
+		is.Equal(t, 2, len(accounts.Columns))
+		is.Equal(t, "name", accounts.Columns[1].Name)
+		is.Equal(t, "text not null default 'a, b; c'", accounts.Columns[1].Definition)
+
+		users := s.Tables[1]
+		is.Equal(t, 3, len(users.Columns))
+		is.Equal(t, "account_id", users.Columns[1].Name)
+		is.Equal(t, "integer not null references accounts (id)", users.Columns[1].Definition)
+		is.Equal(t, "primary key (id)", strings.Join(users.Constraints, ","))
+	})
+
+	t.Run("errors on other statements", func(t *testing.T) {
+		_, err := schema.Parse("create index users_email on users (email);")
+		is.True(t, err != nil)
+		is.Equal(t, "error parsing schema: not a create table statement: create index users_email on users (email)", err.Error())
+	})
+}
+
+func TestDiff(t *testing.T) {
+	t.Run("creates and drops tables and columns", func(t *testing.T) {
+		current, err := schema.Parse(`create table accounts (id integer primary key, created text); create table old (id integer)`)
+		is.NotError(t, err)
+		want, err := schema.Parse(`create table accounts (id integer primary key, name text not null); create table users (id integer)`)
+		is.NotError(t, err)
+
+		up, down := schema.Diff(current, want)
+		is.Equal(t, "alter table accounts add column name text not null;\n\n"+
+			"alter table accounts drop column created;\n\n"+
+			"create table users (\n  id integer\n);\n\n"+
+			"drop table old;\n", up)
+		is.Equal(t, "create table old (\n  id integer\n);\n\n"+
+			"drop table users;\n\n"+
+			"alter table accounts add column created text;\n\n"+
+			"alter table accounts drop column name;\n", down)
+	})
+
+	t.Run("returns nothing if there are no differences", func(t *testing.T) {
+		s, err := schema.Parse(desired)
+		is.NotError(t, err)
+
+		up, down := schema.Diff(s, s)
+		is.Equal(t, "", up)
+		is.Equal(t, "", down)
+	})
+}
+
+func TestGenerate(t *testing.T) {
+	t.Run("generates migrations that migrate the database to the desired schema and back", func(t *testing.T) {
+		db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "db.sqlite"))
+		is.NotError(t, err)
+		t.Cleanup(func() {
+			_ = db.Close()
+		})
+
+		dir := t.TempDir()
+		ctx := context.Background()
+		m := migrate.New(migrate.Options{DB: db, Dialect: migrate.SQLite, FS: os.DirFS(dir)})
+		err = m.MigrateUp(ctx)
+		is.NotError(t, err)
+
+		upPath, downPath, err := schema.Generate(ctx, db, migrate.SQLite, desired, dir, "init",
+			migrate.CreateOptions{Numbering: migrate.Sequence}, "migrations")
+		is.NotError(t, err)
+		is.Equal(t, filepath.Join(dir, "0001-init.up.sql"), upPath)
+		is.Equal(t, filepath.Join(dir, "0001-init.down.sql"), downPath)
+
+		err = m.MigrateUp(ctx)
+		is.NotError(t, err)
+
+		_, err = db.Exec(`insert into accounts (id) values (1)`)
+		is.NotError(t, err)
+		var name string
+		err = db.QueryRow(`select name from accounts`).Scan(&name)
+		is.NotError(t, err)
+		is.Equal(t, "a, b; c", name)
+
+		_, _, err = schema.Generate(ctx, db, migrate.SQLite, desired, dir, "again",
+			migrate.CreateOptions{Numbering: migrate.Sequence}, "migrations")
+		is.True(t, errors.Is(err, schema.ErrNoChanges))
+
+		err = m.MigrateDown(ctx)
+		is.NotError(t, err)
+		s, err := schema.Inspect(ctx, db, migrate.SQLite, "migrations")
+		is.NotError(t, err)
+		is.Equal(t, 0, len(s.Tables))
+	})
+}
@@ -0,0 +1,145 @@
+package migrate
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Script writes the pending up migrations from the current version to the target version, including it,
+// as a single SQL script to w, for a DBA to review, or to apply manually with a client like psql or mysql.
+// If target is empty, it's the newest version. Each migration has a header comment with its file name,
+// and is wrapped in a transaction with the version update, unless the Dialect doesn't support transactions
+// or the migration has no-transaction in its front matter. Included files and environment variables are expanded.
+// With Options.History, each migration is also recorded in the history table, without a batch,
+// and with the time the script was written as when it was applied, so Migrator.Reconcile can find it later.
+// If the migrations table doesn't exist yet, the script starts by creating it, and the history table with Options.History.
+// Nothing is applied or created, and callbacks and directives like copy are not part of the script.
+func (m *Migrator) Script(ctx context.Context, w io.Writer, target string) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error writing script: %w", err)
+		}
+	}()
+
+	currentVersion, tables, err := m.readVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	order, err := m.getVersionOrder()
+	if err != nil {
+		return err
+	}
+
+	if target != "" {
+		if order.compare(target, currentVersion) < 0 {
+			return fmt.Errorf("current version %v is after %v", currentVersion, target)
+		}
+		if err := m.findVersion(upMatcher, target); err != nil {
+			return err
+		}
+	}
+
+	steps, err := m.planUp(currentVersion, target)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	toVersion := currentVersion
+	if len(steps) > 0 {
+		toVersion = steps[len(steps)-1].version
+	}
+	fmt.Fprintf(bw, "-- Migrations from version %v to %v, with %v pending.\n", versionOrNone(currentVersion), versionOrNone(toVersion), len(steps))
+	if !tables && m.store == nil {
+		m.writeScriptTables(bw)
+	}
+
+	writtenAt := time.Now()
+	for _, s := range steps {
+		if err := m.writeScriptStep(bw, s, writtenAt); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// writeScriptTables that a run would create, for a database without them.
+func (m *Migrator) writeScriptTables(w *bufio.Writer) {
+	w.WriteString("\n-- Migrations tables\n")
+	if m.columns.Version != "" {
+		w.WriteString(m.createVersionTableWithColumns() + ";\n")
+	} else {
+		w.WriteString(m.dialect.CreateVersionTable(m.dialect.Quote(m.table)) + ";\n")
+		w.WriteString(`insert into ` + m.dialect.Quote(m.table) + " values ('');\n")
+	}
+	if m.history {
+		w.WriteString(createHistoryTable(m.dialect, m.dialect.Quote(m.historyTable())) + ";\n")
+	}
+}
+
+// writeScriptStep with its header comment, content, version update, and history, in a transaction if possible.
+func (m *Migrator) writeScriptStep(w *bufio.Writer, s step, writtenAt time.Time) error {
+	fm, err := m.readFrontMatter(s)
+	if err != nil {
+		return err
+	}
+
+	f, err := m.open(s)
+	if err != nil {
+		return err
+	}
+	content, err := io.ReadAll(f)
+	_ = f.Close()
+	if err != nil {
+		return fmt.Errorf("error reading migration file %v: %w", s.name, err)
+	}
+
+	query := strings.TrimSpace(string(content))
+	if !strings.HasSuffix(query, ";") {
+		// The newline ends any comment on the last line of the migration
+		query += "\n;"
+	}
+
+	inTransaction := supportsTransactions(m.dialect) && !fm.noTransaction
+	if inTransaction {
+		concurrently, err := m.hasConcurrently(s)
+		if err != nil {
+			return err
+		}
+		inTransaction = !concurrently
+	}
+
+	fmt.Fprintf(w, "\n-- %v\n", s.name)
+	if inTransaction {
+		w.WriteString("begin;\n\n")
+	}
+	w.WriteString(query + "\n\n")
+	update, err := m.literalVersionUpdate(s.version)
+	if err != nil {
+		return err
+	}
+	w.WriteString(update + ";\n")
+	if m.history {
+		insert, err := m.insertHistory(s, describe(fm, s.fileVersion), writtenAt, 0, "null")
+		if err != nil {
+			return err
+		}
+		w.WriteString(insert + ";\n")
+	}
+	if inTransaction {
+		w.WriteString("commit;\n")
+	}
+	return nil
+}
+
+func versionOrNone(v string) string {
+	if v == "" {
+		return "(none)"
+	}
+	return v
+}
@@ -0,0 +1,87 @@
+package migrate_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestMigrator_Script(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1-accounts.up.sql": {Data: []byte("create table accounts (id int);")},
+		"2-users.up.sql":    {Data: []byte("create table users (id int) -- no semicolon")},
+		"3-index.up.sql":    {Data: []byte("-- ---\n-- no-transaction: true\n-- ---\ncreate index users_id on users (id);")},
+	}
+
+	t.Run("writes the pending migrations with version updates, in transactions", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, Dialect: migrate.SQLite, FS: fsys, MissingDown: migrate.CheckIgnore})
+		err := m.MigrateTo(context.Background(), "1-accounts")
+		is.NotError(t, err)
+
+		var b strings.Builder
+		err = m.Script(context.Background(), &b, "")
+		is.NotError(t, err)
+		is.Equal(t, "-- Migrations from version 1-accounts to 3-index, with 2 pending.\n"+
+			"\n-- 2-users.up.sql\nbegin;\n\ncreate table users (id int) -- no semicolon\n;\n\n"+
+			"update \"migrations\" set version = '2-users';\ncommit;\n"+
+			"\n-- 3-index.up.sql\n-- ---\n-- no-transaction: true\n-- ---\ncreate index users_id on users (id);\n\n"+
+			"update \"migrations\" set version = '3-index';\n",
+			b.String())
+
+		version := getVersion(t, db)
+		is.Equal(t, "1-accounts", version)
+	})
+
+	t.Run("writes up to and including the target", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, MissingDown: migrate.CheckIgnore})
+
+		var b strings.Builder
+		err := m.Script(context.Background(), &b, "2-users")
+		is.NotError(t, err)
+		is.True(t, strings.HasPrefix(b.String(), "-- Migrations from version (none) to 2-users, with 2 pending.\n"))
+		is.True(t, !strings.Contains(b.String(), "3-index"))
+	})
+
+	t.Run("creates the migrations tables in the script instead of the database", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, Dialect: migrate.SQLite, FS: fsys, History: true, MissingDown: migrate.CheckIgnore})
+
+		var b strings.Builder
+		err := m.Script(context.Background(), &b, "1-accounts")
+		is.NotError(t, err)
+		is.True(t, strings.HasPrefix(b.String(), "-- Migrations from version (none) to 1-accounts, with 1 pending.\n"+
+			"\n-- Migrations tables\ncreate table if not exists \"migrations\" (version text not null);\n"+
+			"insert into \"migrations\" values ('');\ncreate table if not exists \"migrations_history\""))
+
+		var count int
+		err = db.QueryRow(`select count(*) from sqlite_master where type = 'table'`).Scan(&count)
+		is.NotError(t, err)
+		is.Equal(t, 0, count)
+
+		_, err = db.Exec(b.String())
+		is.NotError(t, err)
+		is.Equal(t, "1-accounts", getVersion(t, db))
+	})
+
+	t.Run("errors if the target is before the current version", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, MissingDown: migrate.CheckIgnore})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		var b strings.Builder
+		err = m.Script(context.Background(), &b, "1-accounts")
+		is.Equal(t, "error writing script: current version 3-index is after 1-accounts", err.Error())
+	})
+}
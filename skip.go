@@ -0,0 +1,95 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+)
+
+// Skip the up migration with the given version, recording it as applied without running it, like when the equivalent
+// change was already made by hand during an incident. It must be the next migration to apply.
+// The version is updated, and with Options.History, the migration is recorded as applied in a batch of its own,
+// but callbacks and checks don't run.
+func (m *Migrator) Skip(ctx context.Context, version string) (err error) {
+	ctx, span := m.tracer.Start(ctx, "migrate skip")
+	span.SetAttribute("migrate.table", m.table)
+	span.SetAttribute("migrate.target_version", version)
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error skipping %v: %w", version, err)
+		}
+		span.End(err)
+	}()
+
+	return m.session(ctx, func(s *Migrator) error {
+		return s.run(ctx, "skip", func() error {
+			return s.skip(ctx, version)
+		})
+	})
+}
+
+func (m *Migrator) skip(ctx context.Context, version string) error {
+	if err := m.createMigrationsTable(ctx); err != nil {
+		return err
+	}
+
+	currentVersion, err := m.getCurrentVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := m.findVersion(upMatcher, version); err != nil {
+		return err
+	}
+
+	steps, err := m.planUp(currentVersion, version)
+	if err != nil {
+		return err
+	}
+	if len(steps) == 0 {
+		return fmt.Errorf("current version %v is at or after it", currentVersion)
+	}
+	if len(steps) > 1 {
+		return fmt.Errorf("%v must be applied first", steps[0].name)
+	}
+	s := steps[0]
+
+	if m.history {
+		lastBatch, err := m.getLastBatch(ctx)
+		if err != nil {
+			return err
+		}
+		m.historyBatch = lastBatch + 1
+	}
+
+	fm, err := m.readFrontMatter(s)
+	if err != nil {
+		return err
+	}
+
+	err = m.inTransaction(ctx, func(q queryer) error {
+		if m.store == nil {
+			if err := m.updateVersion(ctx, q, s.version); err != nil {
+				return err
+			}
+		}
+		if m.history {
+			return m.updateHistory(ctx, q, s, describe(fm, s.fileVersion), 0)
+		}
+		return nil
+	})
+	if err == nil && m.store != nil {
+		err = m.setVersion(ctx, s.version)
+	}
+	if m.cache != nil {
+		if err != nil {
+			m.cache.versionKnown = false
+		} else {
+			m.cache.setVersion(s.version)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	m.applied = append(m.applied, s.name)
+	return nil
+}
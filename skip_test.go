@@ -0,0 +1,62 @@
+package migrate_test
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestMigrator_Skip(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1-accounts.up.sql": {Data: []byte("create table accounts (id int);")},
+		"2-users.up.sql":    {Data: []byte("create table users (id int);")},
+		"3-posts.up.sql":    {Data: []byte("create table posts (id int);")},
+	}
+
+	t.Run("records the next migration as applied without running it", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		_, err := db.Exec(`create table users (id int)`)
+		is.NotError(t, err)
+
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, History: true, MissingDown: migrate.CheckIgnore})
+		err = m.MigrateTo(context.Background(), "1-accounts")
+		is.NotError(t, err)
+
+		err = m.Skip(context.Background(), "2-users")
+		is.NotError(t, err)
+		is.Equal(t, "2-users", getVersion(t, db))
+
+		statuses, err := m.Status(context.Background())
+		is.NotError(t, err)
+		is.True(t, !statuses[1].AppliedAt.IsZero())
+		is.Equal(t, int64(2), statuses[1].Batch)
+
+		err = m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "3-posts", getVersion(t, db))
+	})
+
+	t.Run("errors if it's not the next migration", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, MissingDown: migrate.CheckIgnore})
+		err := m.Skip(context.Background(), "2-users")
+		is.Equal(t, "error skipping 2-users: 1-accounts.up.sql must be applied first", err.Error())
+		is.Equal(t, "", getVersion(t, db))
+	})
+
+	t.Run("errors if it's already applied", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, MissingDown: migrate.CheckIgnore})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		err = m.Skip(context.Background(), "2-users")
+		is.Equal(t, "error skipping 2-users: current version 3-posts is at or after it", err.Error())
+	})
+}
@@ -0,0 +1,199 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// State of the migrations in a database, for Migrator.ExportState and Migrator.ImportState.
+type State struct {
+	// History of the applied migrations, with Options.History.
+	History []HistoryRecord `json:"history,omitempty"`
+	// Version is the current version.
+	Version string `json:"version"`
+}
+
+// HistoryRecord of an applied migration in the history table. See Options.History.
+type HistoryRecord struct {
+	AppliedAt time.Time `json:"applied_at"`
+	// Batch is the number of the run that applied the migration, or 0 if it's not known.
+	Batch int64 `json:"batch,omitempty"`
+	// Checksum of the up file when it was applied, hex-encoded SHA-256, if known.
+	Checksum    string `json:"checksum,omitempty"`
+	Description string `json:"description,omitempty"`
+	// DownSQL is the stored down migration, base64-encoded. See Options.StoreDown.
+	DownSQL    string `json:"down_sql,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+	Version    string `json:"version"`
+}
+
+// ExportState of the migrations table, and the history table with Options.History, to w as JSON,
+// like to restore it with ImportState along with a logical dump of the database in a new cluster.
+func (m *Migrator) ExportState(ctx context.Context, w io.Writer) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error exporting state: %w", err)
+		}
+	}()
+
+	version, err := m.currentVersion(ctx)
+	if err != nil {
+		return err
+	}
+	state := State{Version: version}
+
+	if m.history {
+		if state.History, err = m.getHistoryRecords(ctx); err != nil {
+			return err
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(state)
+}
+
+// ImportState from JSON written by ExportState, replacing the version, and the history with Options.History.
+// It errors without changing anything if the state has history, but Options.History isn't set.
+func (m *Migrator) ImportState(ctx context.Context, r io.Reader) (err error) {
+	ctx, span := m.tracer.Start(ctx, "migrate import state")
+	span.SetAttribute("migrate.table", m.table)
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error importing state: %w", err)
+		}
+		span.End(err)
+	}()
+
+	var state State
+	if err := json.NewDecoder(r).Decode(&state); err != nil {
+		return fmt.Errorf("error decoding state: %w", err)
+	}
+
+	return m.session(ctx, func(s *Migrator) error {
+		return s.run(ctx, "import state", func() error {
+			return s.importState(ctx, state)
+		})
+	})
+}
+
+func (m *Migrator) importState(ctx context.Context, state State) error {
+	if len(state.History) > 0 && !m.history {
+		return errors.New("history is needed, see Options.History")
+	}
+
+	// The versions are checked, so they're safe to interpolate
+	if state.Version != "" && !upMatcher.MatchString(state.Version+".up.sql") {
+		return fmt.Errorf("invalid version %q", state.Version)
+	}
+	for _, h := range state.History {
+		if !upMatcher.MatchString(h.Version + ".up.sql") {
+			return fmt.Errorf("invalid version %q in history", h.Version)
+		}
+	}
+
+	if err := m.createMigrationsTable(ctx); err != nil {
+		return err
+	}
+
+	err := m.inTransaction(ctx, func(q queryer) error {
+		if m.store == nil {
+			if err := m.updateVersion(ctx, q, state.Version); err != nil {
+				return err
+			}
+		}
+		if !m.history {
+			return nil
+		}
+
+		table := m.dialect.Quote(m.historyTable())
+		if _, err := q.ExecContext(ctx, `delete from `+table); err != nil {
+			return fmt.Errorf("error deleting history: %w", err)
+		}
+		for _, h := range state.History {
+			batch := "null"
+			if h.Batch > 0 {
+				batch = strconv.FormatInt(h.Batch, 10)
+			}
+			query := `insert into ` + table + ` (version, applied_at, duration_ms, batch, description, checksum, down_sql) values ('` +
+				h.Version + `', '` + h.AppliedAt.UTC().Format(historyTimeLayout) + `', ` + strconv.FormatInt(h.DurationMS, 10) + `, ` +
+				batch + `, ` + m.quoteOrNull(h.Description) + `, ` + m.quoteOrNull(h.Checksum) + `, ` + m.quoteOrNull(h.DownSQL) + `)`
+			if _, err := q.ExecContext(ctx, query); err != nil {
+				return fmt.Errorf("error inserting history of version %v: %w", h.Version, err)
+			}
+		}
+		return nil
+	})
+	if err == nil && m.store != nil {
+		err = m.setVersion(ctx, state.Version)
+	}
+	if m.cache != nil {
+		if err != nil {
+			m.cache.versionKnown = false
+		} else {
+			m.cache.setVersion(state.Version)
+		}
+	}
+	return err
+}
+
+// quoteOrNull quotes s as a string literal, or returns null if it's empty.
+func (m *Migrator) quoteOrNull(s string) string {
+	if s == "" {
+		return "null"
+	}
+	return quoteString(m.dialect, s)
+}
+
+// getHistoryRecords from the history table, in the order they were applied,
+// and in the order of the migrations for those applied at the same time.
+func (m *Migrator) getHistoryRecords(ctx context.Context) ([]HistoryRecord, error) {
+	order, err := m.getVersionOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	query := `select version, applied_at, duration_ms, batch, description, checksum, down_sql from ` +
+		m.dialect.Quote(m.historyTable())
+	rows, err := m.conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error getting history: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var records []HistoryRecord
+	for rows.Next() {
+		var h HistoryRecord
+		var appliedAt string
+		var batch sql.NullInt64
+		var description, checksum, downSQL sql.NullString
+		if err := rows.Scan(&h.Version, &appliedAt, &h.DurationMS, &batch, &description, &checksum, &downSQL); err != nil {
+			return nil, fmt.Errorf("error scanning history: %w", err)
+		}
+		if h.AppliedAt, err = time.Parse(historyTimeLayout, appliedAt); err != nil {
+			return nil, fmt.Errorf("error parsing applied_at %v of version %v: %w", appliedAt, h.Version, err)
+		}
+		h.Batch, h.Description, h.Checksum, h.DownSQL = batch.Int64, description.String, checksum.String, downSQL.String
+		records = append(records, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error getting history: %w", err)
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		if !records[i].AppliedAt.Equal(records[j].AppliedAt) {
+			return records[i].AppliedAt.Before(records[j].AppliedAt)
+		}
+		return order.compare(records[i].Version, records[j].Version) < 0
+	})
+	return records, nil
+}
@@ -0,0 +1,93 @@
+package migrate_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestMigrator_ExportState(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1-accounts.up.sql":   {Data: []byte("create table accounts (id int);")},
+		"1-accounts.down.sql": {Data: []byte("drop table accounts;")},
+		"2-users.up.sql":      {Data: []byte("create table users (id int);")},
+		"2-users.down.sql":    {Data: []byte("drop table users;")},
+	}
+
+	t.Run("exports the version and history, and imports them into another database", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, StoreDown: true})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		var b strings.Builder
+		err = m.ExportState(context.Background(), &b)
+		is.NotError(t, err)
+
+		var state migrate.State
+		err = json.Unmarshal([]byte(b.String()), &state)
+		is.NotError(t, err)
+		is.Equal(t, "2-users", state.Version)
+		is.Equal(t, 2, len(state.History))
+		is.Equal(t, "1-accounts", state.History[0].Version)
+		is.Equal(t, int64(1), state.History[0].Batch)
+		is.Equal(t, "accounts", state.History[0].Description)
+		is.Equal(t, 64, len(state.History[0].Checksum))
+		is.True(t, state.History[0].DownSQL != "")
+
+		_, err = db.Exec(`drop table migrations; drop table migrations_history`)
+		is.NotError(t, err)
+
+		err = m.ImportState(context.Background(), strings.NewReader(b.String()))
+		is.NotError(t, err)
+		is.Equal(t, "2-users", getVersion(t, db))
+
+		var imported strings.Builder
+		err = m.ExportState(context.Background(), &imported)
+		is.NotError(t, err)
+		is.Equal(t, b.String(), imported.String())
+
+		err = m.RollbackStored(context.Background(), "1-accounts")
+		is.NotError(t, err)
+		is.Equal(t, "1-accounts", getVersion(t, db))
+	})
+
+	t.Run("exports only the version without history", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, FS: fsys})
+		err := m.MigrateTo(context.Background(), "1-accounts")
+		is.NotError(t, err)
+
+		var b strings.Builder
+		err = m.ExportState(context.Background(), &b)
+		is.NotError(t, err)
+		is.Equal(t, "{\n  \"version\": \"1-accounts\"\n}\n", b.String())
+	})
+
+	t.Run("errors importing history without history", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, FS: fsys})
+		err := m.MigrateTo(context.Background(), "1-accounts")
+		is.NotError(t, err)
+
+		err = m.ImportState(context.Background(), strings.NewReader(`{"version": "2-users", "history": [{"version": "2-users"}]}`))
+		is.Equal(t, "error importing state: history is needed, see Options.History", err.Error())
+		is.Equal(t, "1-accounts", getVersion(t, db))
+	})
+
+	t.Run("errors on invalid versions", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, FS: fsys})
+		err := m.MigrateTo(context.Background(), "1-accounts")
+		is.NotError(t, err)
+
+		err = m.ImportState(context.Background(), strings.NewReader(`{"version": "2'; drop table accounts; --"}`))
+		is.Equal(t, `error importing state: invalid version "2'; drop table accounts; --"`, err.Error())
+	})
+}
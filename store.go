@@ -0,0 +1,140 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// VersionStore keeps the current version somewhere other than in a table in the migrated database,
+// like in a separate control database or a file. Set it in Options.
+//
+// With a VersionStore, the version is set after the transaction of each migration is committed,
+// so if setting it fails, the migration is applied but the version is not updated.
+// History and Audit still use tables in the migrated database.
+type VersionStore interface {
+	// Init the store, like creating a table if it doesn't exist. It's called before each run.
+	Init(ctx context.Context) error
+
+	// Get the current version, or the empty string if no migrations are applied.
+	Get(ctx context.Context) (string, error)
+
+	// Set the current version.
+	Set(ctx context.Context, version string) error
+}
+
+// FileVersionStore keeps the version in the file at Path.
+type FileVersionStore struct {
+	Path string
+}
+
+var _ VersionStore = FileVersionStore{}
+
+// Init does nothing, because a missing file means no migrations are applied.
+func (s FileVersionStore) Init(context.Context) error {
+	return nil
+}
+
+func (s FileVersionStore) Get(context.Context) (string, error) {
+	content, err := os.ReadFile(s.Path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// Set the version by writing to a temporary file and renaming it, so the file always has a whole version.
+// The file keeps its permissions, and a new file can be read by everyone, like with os.WriteFile.
+func (s FileVersionStore) Set(_ context.Context, version string) error {
+	mode := fs.FileMode(0644)
+	if info, err := os.Stat(s.Path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".*")
+	if err != nil {
+		return err
+	}
+	if err := f.Chmod(mode); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if _, err := f.WriteString(version + "\n"); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), s.Path)
+}
+
+// DBVersionStore keeps the version in a table in a database other than the migrated one.
+// Dialect and Table default like in Options.
+type DBVersionStore struct {
+	DB      *sql.DB
+	Dialect Dialect
+	Table   string
+}
+
+var _ VersionStore = DBVersionStore{}
+
+func (s DBVersionStore) Init(ctx context.Context) error {
+	d, table := s.dialectAndTable()
+
+	if _, err := s.DB.ExecContext(ctx, d.CreateVersionTable(table)); err != nil {
+		return err
+	}
+
+	var version string
+	err := s.DB.QueryRowContext(ctx, `select version from `+table).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		_, err = s.DB.ExecContext(ctx, `insert into `+table+` values ('')`)
+	}
+	return err
+}
+
+func (s DBVersionStore) Get(ctx context.Context) (string, error) {
+	_, table := s.dialectAndTable()
+	var version string
+	err := s.DB.QueryRowContext(ctx, `select version from `+table).Scan(&version)
+	return version, err
+}
+
+func (s DBVersionStore) Set(ctx context.Context, version string) error {
+	d, table := s.dialectAndTable()
+	query, args := updateVersion(d, table, version)
+	_, err := s.DB.ExecContext(ctx, query, args...)
+	return err
+}
+
+// dialectAndTable with defaults, and the table quoted.
+func (s DBVersionStore) dialectAndTable() (Dialect, string) {
+	d, table := s.Dialect, s.Table
+	if d == nil {
+		d = defaultDialect
+	}
+	if table == "" {
+		table = "migrations"
+	}
+	return d, d.Quote(table)
+}
+
+// setVersion in the VersionStore.
+func (m *Migrator) setVersion(ctx context.Context, version string) error {
+	if err := m.store.Set(ctx, version); err != nil {
+		return fmt.Errorf("error setting version %v in version store: %w", version, err)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestFileVersionStore(t *testing.T) {
+	t.Run("keeps the version in a file", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		path := filepath.Join(t.TempDir(), "version")
+		store := migrate.FileVersionStore{Path: path}
+
+		m := migrate.New(migrate.Options{DB: db, FS: mustSub(t, testdata, "good"), VersionStore: store})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		content, err := os.ReadFile(path)
+		is.NotError(t, err)
+		is.Equal(t, "3\n", string(content))
+
+		var count int
+		err = db.QueryRow(`select count(*) from sqlite_master where name = 'migrations'`).Scan(&count)
+		is.NotError(t, err)
+		is.Equal(t, 0, count)
+
+		err = m.MigrateTo(context.Background(), "1")
+		is.NotError(t, err)
+
+		version, err := store.Get(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "1", version)
+	})
+
+	t.Run("gets the empty version if the file does not exist", func(t *testing.T) {
+		store := migrate.FileVersionStore{Path: filepath.Join(t.TempDir(), "version")}
+		version, err := store.Get(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "", version)
+	})
+
+	t.Run("keeps the permissions of the file, or makes it readable by everyone", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "version")
+		store := migrate.FileVersionStore{Path: path}
+
+		err := store.Set(context.Background(), "1")
+		is.NotError(t, err)
+
+		info, err := os.Stat(path)
+		is.NotError(t, err)
+		is.Equal(t, os.FileMode(0644), info.Mode().Perm())
+
+		err = os.Chmod(path, 0640)
+		is.NotError(t, err)
+
+		err = store.Set(context.Background(), "2")
+		is.NotError(t, err)
+
+		info, err = os.Stat(path)
+		is.NotError(t, err)
+		is.Equal(t, os.FileMode(0640), info.Mode().Perm())
+	})
+
+	t.Run("does not set the version if the migration fails", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		store := migrate.FileVersionStore{Path: filepath.Join(t.TempDir(), "version")}
+
+		m := migrate.New(migrate.Options{DB: db, FS: mustSub(t, testdata, "bad"), VersionStore: store})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+
+		version, err := store.Get(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "1", version)
+	})
+}
+
+func TestDBVersionStore(t *testing.T) {
+	t.Run("keeps the version in a table in another database", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+
+		controlPath := filepath.Join(t.TempDir(), "control.sqlite")
+		control, err := sql.Open("sqlite3", controlPath)
+		is.NotError(t, err)
+		t.Cleanup(func() {
+			_ = control.Close()
+		})
+
+		store := migrate.DBVersionStore{DB: control, Dialect: migrate.SQLite, Table: "app_migrations"}
+		m := migrate.New(migrate.Options{DB: db, FS: mustSub(t, testdata, "good"), VersionStore: store})
+		err = m.MigrateUp(context.Background())
+		is.NotError(t, err)
+
+		var version string
+		err = control.QueryRow(`select version from app_migrations`).Scan(&version)
+		is.NotError(t, err)
+		is.Equal(t, "3", version)
+
+		err = m.MigrateDown(context.Background())
+		is.NotError(t, err)
+
+		err = control.QueryRow(`select version from app_migrations`).Scan(&version)
+		is.NotError(t, err)
+		is.Equal(t, "", version)
+	})
+}
@@ -0,0 +1,133 @@
+package migrate
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SumFileName of the integrity file in the format of Atlas, see Sum.
+const SumFileName = "atlas.sum"
+
+// Sum returns the content of an atlas.sum integrity file for the .sql files in fsys, in the format of Atlas,
+// so repositories that review migrations with it can keep doing so. Each line has a file name and a hash
+// of it and the files before it, in the order of their names, and the first line has a hash of the rest.
+// A change to any file changes its line and all lines after it, so concurrent changes to the directory conflict
+// in version control. Like Atlas, files with a "-- atlas:sum ignore" line in the comments at their start are left out.
+func Sum(fsys fs.FS) ([]byte, error) {
+	entries, err := sumEntries(fsys)
+	if err != nil {
+		return nil, fmt.Errorf("error summing migration files: %w", err)
+	}
+	return formatSum(entries), nil
+}
+
+// WriteSumFile writes the atlas.sum file of Sum to dir. Run it after changing migration files, and commit it with them.
+func WriteSumFile(dir string) error {
+	content, err := Sum(os.DirFS(dir))
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, SumFileName)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("error writing %v: %w", path, err)
+	}
+	return nil
+}
+
+type sumEntry struct {
+	name, hash string
+}
+
+// sumEntries of the .sql files in fsys, with the hashes of Atlas.
+func sumEntries(fsys fs.FS) ([]sumEntry, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []sumEntry
+	h := sha256.New()
+	for _, name := range names {
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		// The name is always hashed, so ignored files still change the hashes after them when renamed
+		h.Write([]byte(name))
+		if isSumIgnored(content) {
+			continue
+		}
+		h.Write(content)
+		entries = append(entries, sumEntry{name: name, hash: base64.StdEncoding.EncodeToString(h.Sum(nil))})
+	}
+	return entries, nil
+}
+
+// isSumIgnored reports whether the file has the atlas:sum ignore directive in its header,
+// which is the comment lines at its start.
+func isSumIgnored(content []byte) bool {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "-- atlas:sum ignore" {
+			return true
+		}
+		if !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+	return false
+}
+
+// formatSum of the entries, with the hash of them all on the first line.
+func formatSum(entries []sumEntry) []byte {
+	var lines bytes.Buffer
+	h := sha256.New()
+	for _, e := range entries {
+		h.Write([]byte(e.name))
+		h.Write([]byte(e.hash))
+		lines.WriteString(e.name + " h1:" + e.hash + "\n")
+	}
+	return append([]byte("h1:"+base64.StdEncoding.EncodeToString(h.Sum(nil))+"\n"), lines.Bytes()...)
+}
+
+// checkSum checks the migration files against the atlas.sum file in the FS, if there is one. See Options.SumMismatch.
+func (m *Migrator) checkSum() error {
+	if m.sumMismatch == CheckIgnore {
+		return nil
+	}
+
+	recorded, err := fs.ReadFile(m.fs, SumFileName)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("error reading %v: %w", SumFileName, err)
+	}
+
+	entries, err := sumEntries(m.fs)
+	if err != nil {
+		return fmt.Errorf("error summing migration files: %w", err)
+	}
+	if bytes.Equal(recorded, formatSum(entries)) {
+		return nil
+	}
+
+	// Name the first file that differs, because the hashes of all files after it differ too
+	lines := strings.Split(strings.TrimSpace(string(recorded)), "\n")
+	for i, e := range entries {
+		if i+1 >= len(lines) || lines[i+1] != e.name+" h1:"+e.hash {
+			return m.report(m.sumMismatch, "%v doesn't match the migration files from %v, so they changed after it was written",
+				SumFileName, e.name)
+		}
+	}
+	return m.report(m.sumMismatch, "%v doesn't match the migration files, so they changed after it was written", SumFileName)
+}
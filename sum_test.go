@@ -0,0 +1,109 @@
+package migrate_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+func TestSum(t *testing.T) {
+	t.Run("hashes each file with the files before it, and the lines on the first line", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"1-accounts.up.sql": {Data: []byte("create table accounts (id int);")},
+			"2-users.up.sql":    {Data: []byte("create table users (id int);")},
+			"README.md":         {Data: []byte("Not a migration.")},
+		}
+
+		content, err := migrate.Sum(fsys)
+		is.NotError(t, err)
+
+		h := sha256.New()
+		h.Write([]byte("1-accounts.up.sql"))
+		h.Write(fsys["1-accounts.up.sql"].Data)
+		hash1 := base64.StdEncoding.EncodeToString(h.Sum(nil))
+		h.Write([]byte("2-users.up.sql"))
+		h.Write(fsys["2-users.up.sql"].Data)
+		hash2 := base64.StdEncoding.EncodeToString(h.Sum(nil))
+
+		h = sha256.New()
+		h.Write([]byte("1-accounts.up.sql" + hash1 + "2-users.up.sql" + hash2))
+		sum := base64.StdEncoding.EncodeToString(h.Sum(nil))
+
+		is.Equal(t, "h1:"+sum+"\n1-accounts.up.sql h1:"+hash1+"\n2-users.up.sql h1:"+hash2+"\n", string(content))
+	})
+
+	t.Run("leaves out files with the ignore directive", func(t *testing.T) {
+		content, err := migrate.Sum(fstest.MapFS{
+			"1-accounts.up.sql": {Data: []byte("-- atlas:sum ignore\n\ncreate table accounts (id int);")},
+			"2-users.up.sql":    {Data: []byte("create table users (id int);\n-- atlas:sum ignore")},
+		})
+		is.NotError(t, err)
+		lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+		is.Equal(t, 2, len(lines))
+		is.True(t, strings.HasPrefix(lines[1], "2-users.up.sql h1:"))
+	})
+}
+
+func TestMigrator_SumMismatch(t *testing.T) {
+	newFS := func(t *testing.T) fstest.MapFS {
+		t.Helper()
+		dir := t.TempDir()
+		err := os.WriteFile(filepath.Join(dir, "1-accounts.up.sql"), []byte("create table accounts (id int);"), 0644)
+		is.NotError(t, err)
+		err = os.WriteFile(filepath.Join(dir, "2-users.up.sql"), []byte("create table users (id int);"), 0644)
+		is.NotError(t, err)
+		err = migrate.WriteSumFile(dir)
+		is.NotError(t, err)
+
+		fsys := fstest.MapFS{}
+		for _, name := range []string{"1-accounts.up.sql", "2-users.up.sql", migrate.SumFileName} {
+			content, err := os.ReadFile(filepath.Join(dir, name))
+			is.NotError(t, err)
+			fsys[name] = &fstest.MapFile{Data: content}
+		}
+		return fsys
+	}
+
+	t.Run("migrates if the files match the sum file", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		m := migrate.New(migrate.Options{DB: db, FS: newFS(t), MissingDown: migrate.CheckIgnore, SumMismatch: migrate.CheckError})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "2-users", getVersion(t, db))
+	})
+
+	t.Run("warns about the first changed file with check warn", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		fsys := newFS(t)
+		fsys["2-users.up.sql"] = &fstest.MapFile{Data: []byte("create table users (id int, name text);")}
+
+		logger := &recordingLogger{}
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, Logger: logger, SumMismatch: migrate.CheckWarn})
+		err := m.MigrateUp(context.Background())
+		is.NotError(t, err)
+		is.Equal(t, "[migrate: warning: atlas.sum doesn't match the migration files from 2-users.up.sql, "+
+			"so they changed after it was written]", fmt.Sprint(logger.lines))
+	})
+
+	t.Run("errors about a removed file with check error", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		fsys := newFS(t)
+		delete(fsys, "2-users.up.sql")
+
+		m := migrate.New(migrate.Options{DB: db, FS: fsys, MissingDown: migrate.CheckIgnore, SumMismatch: migrate.CheckError})
+		err := m.MigrateUp(context.Background())
+		is.Equal(t, "error migrating up: error checking migration files: atlas.sum doesn't match the migration files, "+
+			"so they changed after it was written", err.Error())
+		is.Equal(t, "", getVersion(t, db))
+	})
+}
@@ -0,0 +1,57 @@
+package migrate
+
+import (
+	"context"
+)
+
+// Tracer starts spans for each migration run and each migration, if set in Options.
+// It mirrors the parts of an OpenTelemetry trace.Tracer that the Migrator needs,
+// so the package doesn't depend on OpenTelemetry. An adapter is a few lines:
+//
+//	type otelTracer struct{ t trace.Tracer }
+//
+//	func (o otelTracer) Start(ctx context.Context, name string) (context.Context, migrate.Span) {
+//		ctx, s := o.t.Start(ctx, name)
+//		return ctx, otelSpan{s}
+//	}
+//
+//	type otelSpan struct{ s trace.Span }
+//
+//	func (o otelSpan) SetAttribute(key string, value any) {
+//		o.s.SetAttributes(attribute.String(key, fmt.Sprint(value)))
+//	}
+//
+//	func (o otelSpan) End(err error) {
+//		if err != nil {
+//			o.s.RecordError(err)
+//			o.s.SetStatus(codes.Error, err.Error())
+//		}
+//		o.s.End()
+//	}
+//
+// Use it with a tracer from your TracerProvider, like otelTracer{provider.Tracer("maragu.dev/migrate")}.
+type Tracer interface {
+	// Start a span with the given name, returning a context containing it.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span started by a Tracer.
+type Span interface {
+	// SetAttribute on the span. The value is a string or an int64.
+	SetAttribute(key string, value any)
+
+	// End the span, with the error from the run or migration, if any.
+	End(err error)
+}
+
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, _ string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(string, any) {}
+
+func (noopSpan) End(error) {}
@@ -0,0 +1,86 @@
+package migrate_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"maragu.dev/is"
+
+	"maragu.dev/migrate"
+)
+
+type recordingTracer struct {
+	lock  sync.Mutex
+	spans []*recordingSpan
+}
+
+func (r *recordingTracer) Start(ctx context.Context, name string) (context.Context, migrate.Span) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	s := &recordingSpan{name: name, attributes: map[string]any{}}
+	r.spans = append(r.spans, s)
+	return ctx, s
+}
+
+type recordingSpan struct {
+	attributes map[string]any
+	ended      bool
+	err        error
+	name       string
+}
+
+func (r *recordingSpan) SetAttribute(key string, value any) {
+	r.attributes[key] = value
+}
+
+func (r *recordingSpan) End(err error) {
+	r.ended = true
+	r.err = err
+}
+
+func TestMigrator_Tracer(t *testing.T) {
+	t.Run("creates a span per run and per migration", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		tracer := &recordingTracer{}
+
+		m := migrate.New(migrate.Options{DB: db, FS: mustSub(t, testdata, "good"), Tracer: tracer})
+		err := m.MigrateTo(context.Background(), "2")
+		is.NotError(t, err)
+
+		is.Equal(t, 3, len(tracer.spans))
+
+		run := tracer.spans[0]
+		is.Equal(t, "migrate to", run.name)
+		is.Equal(t, "migrations", run.attributes["migrate.table"].(string))
+		is.Equal(t, "2", run.attributes["migrate.target_version"].(string))
+		is.True(t, run.ended)
+		is.NotError(t, run.err)
+
+		first := tracer.spans[1]
+		is.Equal(t, "migrate apply", first.name)
+		is.Equal(t, "1.up.sql", first.attributes["migrate.file"].(string))
+		is.Equal(t, "1", first.attributes["migrate.version"].(string))
+		is.True(t, first.ended)
+
+		second := tracer.spans[2]
+		is.Equal(t, "2.up.sql", second.attributes["migrate.file"].(string))
+		is.Equal(t, int64(1), second.attributes["db.rows_affected"].(int64))
+	})
+
+	t.Run("ends spans with the error on failure", func(t *testing.T) {
+		db := createSQLiteDatabase(t)
+		tracer := &recordingTracer{}
+
+		m := migrate.New(migrate.Options{DB: db, FS: mustSub(t, testdata, "bad"), Tracer: tracer})
+		err := m.MigrateUp(context.Background())
+		is.True(t, err != nil)
+
+		is.Equal(t, 3, len(tracer.spans))
+		is.Equal(t, "migrate up", tracer.spans[0].name)
+		is.True(t, errors.Is(tracer.spans[0].err, tracer.spans[2].err))
+		is.NotError(t, tracer.spans[1].err)
+		is.True(t, tracer.spans[2].err != nil)
+	})
+}